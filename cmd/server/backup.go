@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// runBackup implements `vibox backup`: it downloads a backup of the whole
+// installation from POST /api/admin/backup. It returns the process exit code.
+func runBackup(args []string) int {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	server := flags.String("server", envOr("VIBOX_SERVER", "http://localhost:3000"), "ViBox server URL (env VIBOX_SERVER)")
+	token := flags.String("token", envOr("VIBOX_TOKEN", os.Getenv("API_TOKEN")), "admin session token or the API token; API keys are not accepted (env VIBOX_TOKEN or API_TOKEN)")
+	containers := flags.Bool("containers", false, "include the filesystem of every workspace container")
+	output := flags.String("o", "", "file to write the backup to (default vibox-backup.tar.gz, - for stdout)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: vibox backup [flags]")
+		fmt.Fprintln(flags.Output(), "")
+		fmt.Fprintln(flags.Output(), "Downloads a backup of workspaces, users, API keys and share links, e.g.")
+		fmt.Fprintln(flags.Output(), "  vibox backup -server https://vibox.example.com -containers -o vibox.tar.gz")
+		fmt.Fprintln(flags.Output(), "")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+	if *token == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: an API token is required (-token or VIBOX_TOKEN)")
+		return 2
+	}
+	if *output == "" {
+		*output = "vibox-backup.tar.gz"
+	}
+
+	endpoint, err := adminEndpoint(*server, "/api/admin/backup")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 2
+	}
+	if *containers {
+		endpoint += "?containers=true"
+	}
+
+	resp, err := adminRequest(endpoint, *token, "", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to create %s: %v\n", *output, err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+
+	written, err := io.Copy(out, resp.Body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: download failed: %v\n", err)
+		return 1
+	}
+	if *output != "-" {
+		fmt.Fprintf(os.Stderr, "Backup written to %s (%d bytes)\n", *output, written)
+	}
+	return 0
+}
+
+// runRestore implements `vibox restore`: it uploads a backup to
+// POST /api/admin/restore. It returns the process exit code.
+func runRestore(args []string) int {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	server := flags.String("server", envOr("VIBOX_SERVER", "http://localhost:3000"), "ViBox server URL (env VIBOX_SERVER)")
+	token := flags.String("token", envOr("VIBOX_TOKEN", os.Getenv("API_TOKEN")), "admin session token or the API token; API keys are not accepted (env VIBOX_TOKEN or API_TOKEN)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: vibox restore [flags] <backup.tar.gz>")
+		fmt.Fprintln(flags.Output(), "")
+		fmt.Fprintln(flags.Output(), "Restores a backup into a ViBox installation without workspaces, e.g.")
+		fmt.Fprintln(flags.Output(), "  vibox restore -server https://vibox.example.com vibox.tar.gz")
+		fmt.Fprintln(flags.Output(), "")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	if *token == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: an API token is required (-token or VIBOX_TOKEN)")
+		return 2
+	}
+
+	endpoint, err := adminEndpoint(*server, "/api/admin/restore")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 2
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	defer file.Close()
+
+	resp, err := adminRequest(endpoint, *token, "application/gzip", file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Workspaces  int `json:"workspaces"`
+		Users       int `json:"users"`
+		APIKeys     int `json:"api_keys"`
+		Shares      int `json:"shares"`
+		Filesystems int `json:"filesystems"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: invalid response: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Restored %d workspaces (%d with filesystems), %d users, %d API keys and %d share links\n",
+		result.Workspaces, result.Filesystems, result.Users, result.APIKeys, result.Shares)
+	return 0
+}
+
+// adminEndpoint builds the URL of an API endpoint from the server URL
+func adminEndpoint(server, path string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid server URL %q", server)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported server URL scheme %q", u.Scheme)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = ""
+	return u.String(), nil
+}
+
+// adminRequest POSTs body to an API endpoint and returns the response if it
+// succeeded; otherwise the API's error message becomes the error
+func adminRequest(endpoint, token, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	if json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&apiErr) == nil && apiErr.Error != "" {
+		return nil, fmt.Errorf("server returned %s: %s", resp.Status, apiErr.Error)
+	}
+	return nil, fmt.Errorf("server returned %s", resp.Status)
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -17,6 +19,18 @@ import (
 )
 
 func main() {
+	// Client subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "tunnel":
+			os.Exit(runTunnel(os.Args[2:]))
+		case "backup":
+			os.Exit(runBackup(os.Args[2:]))
+		case "restore":
+			os.Exit(runRestore(os.Args[2:]))
+		}
+	}
+
 	// Initialize logger
 	utils.InitLogger()
 	utils.Info("Starting ViBox server...")
@@ -49,25 +63,139 @@ func main() {
 
 	utils.Info("Docker service initialized successfully")
 
-	// Initialize repository with file persistence
-	repo, err := repository.NewWorkspaceRepository(cfg.DataDir)
+	// Initialize workspace repository with the configured storage backend
+	var repo repository.WorkspaceRepository
+	switch cfg.StorageBackend {
+	case "sqlite":
+		dbPath := cfg.SQLitePath
+		if dbPath == "" {
+			dbPath = filepath.Join(cfg.DataDir, "vibox.db")
+		}
+		sqliteRepo, err := repository.NewSQLiteRepository(dbPath)
+		if err != nil {
+			utils.Error("Failed to initialize workspace repository", "error", err.Error())
+			fmt.Fprintf(os.Stderr, "ERROR: Failed to initialize repository: %v\n", err)
+			os.Exit(1)
+		}
+		defer sqliteRepo.Close()
+
+		// One-time migration from the file backend
+		if _, err := sqliteRepo.ImportJSON(cfg.DataDir); err != nil {
+			utils.Error("Failed to import workspaces.json", "error", err.Error())
+			fmt.Fprintf(os.Stderr, "ERROR: Failed to import workspaces.json: %v\n", err)
+			os.Exit(1)
+		}
+		repo = sqliteRepo
+		utils.Info("Workspace repository initialized with SQLite", "path", dbPath)
+	default:
+		fileRepo, err := repository.NewWorkspaceRepositoryWithRecovery(cfg.DataDir, cfg.RecoverCorruptData)
+		if err != nil {
+			utils.Error("Failed to initialize workspace repository", "error", err.Error())
+			fmt.Fprintf(os.Stderr, "ERROR: Failed to initialize repository: %v\n", err)
+			if errors.Is(err, repository.ErrCorruptData) {
+				fmt.Fprintln(os.Stderr, "Repair the file, restore a backup, or set RECOVER_CORRUPT_DATA=true to move it aside and start without workspaces.")
+			}
+			os.Exit(1)
+		}
+		repo = fileRepo
+		utils.Info("Workspace repository initialized with persistence", "dataDir", cfg.DataDir)
+	}
+
+	shareRepo, err := repository.NewShareRepository(cfg.DataDir)
 	if err != nil {
-		utils.Error("Failed to initialize workspace repository", "error", err.Error())
-		fmt.Fprintf(os.Stderr, "ERROR: Failed to initialize repository: %v\n", err)
+		utils.Error("Failed to initialize share repository", "error", err.Error())
+		fmt.Fprintf(os.Stderr, "ERROR: Failed to initialize share repository: %v\n", err)
+		os.Exit(1)
+	}
+
+	userRepo, err := repository.NewUserRepository(cfg.DataDir)
+	if err != nil {
+		utils.Error("Failed to initialize user repository", "error", err.Error())
+		fmt.Fprintf(os.Stderr, "ERROR: Failed to initialize user repository: %v\n", err)
+		os.Exit(1)
+	}
+
+	sessionRepo, err := repository.NewSessionRepository(cfg.DataDir)
+	if err != nil {
+		utils.Error("Failed to initialize session repository", "error", err.Error())
+		fmt.Fprintf(os.Stderr, "ERROR: Failed to initialize session repository: %v\n", err)
+		os.Exit(1)
+	}
+
+	apiKeyRepo, err := repository.NewAPIKeyRepository(cfg.DataDir)
+	if err != nil {
+		utils.Error("Failed to initialize API key repository", "error", err.Error())
+		fmt.Fprintf(os.Stderr, "ERROR: Failed to initialize API key repository: %v\n", err)
+		os.Exit(1)
+	}
+
+	gitCredentialRepo, err := repository.NewGitCredentialRepository(cfg.DataDir)
+	if err != nil {
+		utils.Error("Failed to initialize git credential repository", "error", err.Error())
+		fmt.Fprintf(os.Stderr, "ERROR: Failed to initialize git credential repository: %v\n", err)
 		os.Exit(1)
 	}
-	utils.Info("Workspace repository initialized with persistence", "dataDir", cfg.DataDir)
 
 	// Initialize services
+	authSvc := service.NewAuthService(userRepo, sessionRepo, apiKeyRepo, cfg)
+	if err := authSvc.Bootstrap(); err != nil {
+		utils.Error("Failed to bootstrap admin account", "error", err.Error())
+		fmt.Fprintf(os.Stderr, "ERROR: Failed to bootstrap admin account: %v\n", err)
+		os.Exit(1)
+	}
+	utils.Info("Auth service initialized")
+
+	var oidcSvc *service.OIDCService
+	if cfg.OIDCIssuer != "" {
+		oidcSvc = service.NewOIDCService(authSvc, cfg)
+		utils.Info("OIDC single sign-on enabled", "issuer", cfg.OIDCIssuer)
+	}
+
+	auditSvc, err := service.NewAuditService(cfg)
+	if err != nil {
+		utils.Error("Failed to initialize audit log", "error", err.Error())
+		fmt.Fprintf(os.Stderr, "ERROR: Failed to initialize audit log: %v\n", err)
+		os.Exit(1)
+	}
+	utils.Info("Audit service initialized")
+
+	gitSvc := service.NewGitService(dockerSvc, gitCredentialRepo, userRepo, cfg.GitHelperImage)
+	authSvc.OnUserDeleted(gitSvc.DeleteCredentialsOf)
+	utils.Info("Git service initialized")
+
 	workspaceSvc := service.NewWorkspaceService(dockerSvc, repo, cfg)
+	workspaceSvc.SetGitService(gitSvc)
 	utils.Info("Workspace service initialized")
 
-	terminalSvc := service.NewTerminalService(dockerSvc)
+	terminalSvc := service.NewTerminalService(dockerSvc, cfg)
 	utils.Info("Terminal service initialized")
 
 	proxySvc := service.NewProxyService(dockerSvc)
 	utils.Info("Proxy service initialized")
 
+	execSvc := service.NewExecService(dockerSvc)
+	utils.Info("Exec service initialized")
+
+	shareSvc := service.NewShareService(shareRepo)
+	workspaceSvc.OnWorkspaceDeleted(shareSvc.DeleteSharesOf)
+	utils.Info("Share service initialized")
+
+	tunnelSvc := service.NewTunnelService(dockerSvc)
+	utils.Info("Tunnel service initialized")
+
+	portAccessSvc := service.NewPortAccessService()
+	utils.Info("Port access service initialized")
+
+	fileSvc := service.NewFileService(dockerSvc, cfg)
+	utils.Info("File service initialized")
+
+	backupSvc := service.NewBackupService(dockerSvc, workspaceSvc, repo, userRepo, apiKeyRepo, shareRepo, gitCredentialRepo, cfg.RestoreMaxSize)
+	utils.Info("Backup service initialized")
+
+	eventBus := service.NewEventBus()
+	portScanner := service.NewPortScanner(dockerSvc, workspaceSvc, eventBus, cfg)
+	utils.Info("Port scanner initialized")
+
 	// Restore workspaces from persistent storage
 	ctx := context.Background()
 	utils.Info("Restoring workspaces from persistent storage...")
@@ -78,8 +206,16 @@ func main() {
 		utils.Info("Workspace restoration initiated")
 	}
 
+	// Follow container lifecycle changes made outside ViBox (keeps proxy caches fresh)
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	defer stopWatching()
+	go dockerSvc.WatchEvents(watchCtx)
+
+	// Start discovering listening ports in running workspaces
+	portScanner.Start()
+
 	// Setup router with all services
-	router := api.SetupRouter(cfg, dockerSvc, workspaceSvc, terminalSvc, proxySvc)
+	router := api.SetupRouter(cfg, dockerSvc, workspaceSvc, terminalSvc, proxySvc, execSvc, shareSvc, portScanner, eventBus, tunnelSvc, portAccessSvc, authSvc, oidcSvc, auditSvc, backupSvc, fileSvc, gitSvc)
 
 	// Create HTTP server
 	srv := &http.Server{
@@ -112,6 +248,10 @@ func main() {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// Stop port scanning and end event streams so they don't hold up the shutdown
+	portScanner.Stop()
+	eventBus.Close()
+
 	// Shutdown HTTP server
 	utils.Info("Shutting down HTTP server...")
 	if err := srv.Shutdown(shutdownCtx); err != nil {
@@ -124,5 +264,9 @@ func main() {
 		utils.Error("Workspace service shutdown error", "error", err.Error())
 	}
 
+	if err := auditSvc.Close(); err != nil {
+		utils.Error("Failed to close audit log", "error", err.Error())
+	}
+
 	utils.Info("ViBox server stopped gracefully")
 }
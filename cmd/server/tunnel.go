@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/1PercentSync/vibox/internal/service"
+	"github.com/gorilla/websocket"
+)
+
+// runTunnel implements `vibox tunnel`: it listens on a local address and forwards
+// every accepted connection to a workspace port over /ws/tunnel/:id/:port.
+// It returns the process exit code.
+func runTunnel(args []string) int {
+	flags := flag.NewFlagSet("tunnel", flag.ContinueOnError)
+	server := flags.String("server", envOr("VIBOX_SERVER", "http://localhost:3000"), "ViBox server URL (env VIBOX_SERVER)")
+	token := flags.String("token", envOr("VIBOX_TOKEN", os.Getenv("API_TOKEN")), "API key with the forward scope, or the API token (env VIBOX_TOKEN or API_TOKEN)")
+	listen := flags.String("listen", "", "local address to listen on (default 127.0.0.1:<port>)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: vibox tunnel [flags] <workspace-id> <port>")
+		fmt.Fprintln(flags.Output(), "")
+		fmt.Fprintln(flags.Output(), "Forwards a local TCP port to a port inside a workspace, e.g.")
+		fmt.Fprintln(flags.Output(), "  vibox tunnel -server https://vibox.example.com ws-abc 5432")
+		fmt.Fprintln(flags.Output(), "")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	workspaceID := flags.Arg(0)
+	port, err := strconv.Atoi(flags.Arg(1))
+	if err != nil || port < 1 || port > 65535 {
+		fmt.Fprintf(os.Stderr, "ERROR: invalid port %q\n", flags.Arg(1))
+		return 2
+	}
+	if *token == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: an API token is required (-token or VIBOX_TOKEN)")
+		return 2
+	}
+	if *listen == "" {
+		*listen = net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	}
+
+	tunnelURL, err := tunnelEndpoint(*server, workspaceID, port)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 2
+	}
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to listen on %s: %v\n", *listen, err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	fmt.Fprintf(os.Stderr, "Forwarding %s -> %s port %d\n", listener.Addr(), workspaceID, port)
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+*token)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0
+			}
+			fmt.Fprintf(os.Stderr, "ERROR: accept failed: %v\n", err)
+			return 1
+		}
+		go forwardTunnelConn(ctx, conn, tunnelURL, header)
+	}
+}
+
+// forwardTunnelConn bridges one local connection to a new tunnel WebSocket
+func forwardTunnelConn(ctx context.Context, conn net.Conn, tunnelURL string, header http.Header) {
+	defer conn.Close()
+
+	ws, resp, err := websocket.DefaultDialer.DialContext(ctx, tunnelURL, header)
+	if err != nil {
+		if resp != nil {
+			fmt.Fprintf(os.Stderr, "Tunnel rejected for %s: %s\n", conn.RemoteAddr(), resp.Status)
+		} else {
+			fmt.Fprintf(os.Stderr, "Tunnel failed for %s: %v\n", conn.RemoteAddr(), err)
+		}
+		return
+	}
+	defer ws.Close()
+
+	fmt.Fprintf(os.Stderr, "Connection from %s opened\n", conn.RemoteAddr())
+	if err := service.BridgeTunnel(ws, conn, 0); err != nil {
+		fmt.Fprintf(os.Stderr, "Connection from %s closed: %v\n", conn.RemoteAddr(), err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Connection from %s closed\n", conn.RemoteAddr())
+}
+
+// tunnelEndpoint builds the WebSocket URL of a workspace port tunnel from the server URL
+func tunnelEndpoint(server, workspaceID string, port int) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid server URL %q", server)
+	}
+
+	switch u.Scheme {
+	case "http", "ws":
+		u.Scheme = "ws"
+	case "https", "wss":
+		u.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("unsupported server URL scheme %q", u.Scheme)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/ws/tunnel/" + url.PathEscape(workspaceID) + "/" + strconv.Itoa(port)
+	u.RawQuery = ""
+	return u.String(), nil
+}
+
+// envOr returns the value of an environment variable or fallback when it is unset
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
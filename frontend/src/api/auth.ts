@@ -6,4 +6,12 @@ export const authApi = {
 
   logout: () =>
     client.post('/auth/logout'),
+
+  // Short-lived ticket for URLs that cannot carry the session (WebSocket, links)
+  ticket: (workspaceId: string, kind: 'terminal' | 'forward' | 'tunnel', port?: number) =>
+    client.post<{ ticket: string; expires_at: string }>('/tickets', {
+      kind,
+      workspace_id: workspaceId,
+      port,
+    }),
 }
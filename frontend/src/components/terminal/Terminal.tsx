@@ -13,6 +13,7 @@ import {
   updateTerminalWebSocket,
 } from '@/stores/terminals'
 import { tokenAtom } from '@/stores/auth'
+import { authApi } from '@/api/auth'
 
 interface TerminalProps {
   workspaceId: string
@@ -150,42 +151,58 @@ export function Terminal({ workspaceId }: TerminalProps) {
       return
     }
 
-    // Create new WebSocket connection
-    const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:'
-    const host = window.location.host
-    const wsUrl = `${protocol}//${host}/ws/terminal/${workspaceId}?token=${token}`
+    // Create new WebSocket connection, authenticated with a short-lived ticket
+    // (long-lived tokens are not accepted in query strings)
+    let cancelled = false
 
-    console.log('Creating new WebSocket connection')
-    setStatus('connecting')
+    const connect = (ticket: string) => {
+      const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:'
+      const host = window.location.host
+      const wsUrl = `${protocol}//${host}/ws/terminal/${workspaceId}?vibox_ticket=${encodeURIComponent(ticket)}`
 
-    const ws = new WebSocket(wsUrl)
+      const ws = new WebSocket(wsUrl)
 
-    ws.onopen = () => {
-      console.log('WebSocket connected')
-      setStatus('connected')
-      wsRef.current = ws
+      ws.onopen = () => {
+        console.log('WebSocket connected')
+        setStatus('connected')
+        wsRef.current = ws
 
-      // Update terminal instance with WebSocket
-      setTerminalInstances((prev) =>
-        updateTerminalWebSocket(prev, workspaceId, ws)
-      )
-    }
+        // Update terminal instance with WebSocket
+        setTerminalInstances((prev) =>
+          updateTerminalWebSocket(prev, workspaceId, ws)
+        )
+      }
 
-    ws.onclose = () => {
-      console.log('WebSocket disconnected')
-      setStatus('disconnected')
-    }
+      ws.onclose = () => {
+        console.log('WebSocket disconnected')
+        setStatus('disconnected')
+      }
+
+      ws.onerror = (error) => {
+        console.error('WebSocket error:', error)
+        setStatus('disconnected')
+      }
 
-    ws.onerror = (error) => {
-      console.error('WebSocket error:', error)
-      setStatus('disconnected')
+      wsRef.current = ws
     }
 
-    wsRef.current = ws
+    console.log('Creating new WebSocket connection')
+    setStatus('connecting')
+
+    authApi
+      .ticket(workspaceId, 'terminal')
+      .then(({ data }) => {
+        if (!cancelled) connect(data.ticket)
+      })
+      .catch((error) => {
+        console.error('Failed to get terminal ticket:', error)
+        if (!cancelled) setStatus('disconnected')
+      })
 
     // Don't close WebSocket on unmount - keep it alive
     return () => {
       console.log('WebSocket effect cleanup, keeping connection alive')
+      cancelled = true
     }
   }, [workspaceId, token, terminalInstances, setTerminalInstances])
 
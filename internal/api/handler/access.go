@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/1PercentSync/vibox/internal/api/middleware"
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/service"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// authorizeWorkspace loads a workspace and checks that the current user holds at
+// least the required role on it, writing the error response itself otherwise.
+// Workspaces the user cannot see at all are reported as not found.
+func authorizeWorkspace(c *gin.Context, workspaceService *service.WorkspaceService, workspaceID string, required domain.WorkspaceRole) (*domain.Workspace, domain.WorkspaceRole, bool) {
+	workspace, err := workspaceService.GetWorkspace(workspaceID)
+	if err != nil {
+		utils.Warn("Workspace not found", "id", workspaceID)
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Workspace not found",
+			"code":  "NOT_FOUND",
+		})
+		return nil, "", false
+	}
+
+	user := middleware.CurrentUser(c)
+	role := workspace.RoleOf(user)
+	if role == "" {
+		utils.Warn("Workspace access denied: not a member", "id", workspaceID, "user_id", userID(user))
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Workspace not found",
+			"code":  "NOT_FOUND",
+		})
+		return nil, "", false
+	}
+	if !role.Allows(required) {
+		utils.Warn("Workspace access denied: insufficient role",
+			"id", workspaceID,
+			"user_id", userID(user),
+			"role", role,
+			"required", required,
+		)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "This action requires the " + string(required) + " role on the workspace",
+			"code":  "FORBIDDEN",
+			"details": gin.H{
+				"role":     role,
+				"required": required,
+			},
+		})
+		return nil, "", false
+	}
+
+	return workspace, role, true
+}
+
+// userID returns the ID of a possibly nil user, for logging
+func userID(user *domain.User) string {
+	if user == nil {
+		return ""
+	}
+	return user.ID
+}
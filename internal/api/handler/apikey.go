@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/api/middleware"
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/service"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler handles the current user's API keys
+type APIKeyHandler struct {
+	authService *service.AuthService
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(authService *service.AuthService) *APIKeyHandler {
+	return &APIKeyHandler{
+		authService: authService,
+	}
+}
+
+// apiKeyResponse is the public view of an API key (never includes the secret hash)
+type apiKeyResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// newAPIKeyResponse builds the public view of an API key
+func newAPIKeyResponse(key *domain.APIKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+	}
+}
+
+// Create handles POST /api/keys - Create an API key
+// The secret is only returned in this response.
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	var req service.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Invalid create API key request", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	key, secret, err := h.authService.CreateAPIKey(userID(middleware.CurrentUser(c)), req)
+	if err != nil {
+		h.respondError(c, err, "Failed to create API key")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"key":    newAPIKeyResponse(key),
+		"secret": secret,
+	})
+}
+
+// List handles GET /api/keys - List the current user's API keys
+func (h *APIKeyHandler) List(c *gin.Context) {
+	keys, err := h.authService.ListAPIKeys(userID(middleware.CurrentUser(c)))
+	if err != nil {
+		h.respondError(c, err, "Failed to list API keys")
+		return
+	}
+
+	response := make([]apiKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, newAPIKeyResponse(key))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Delete handles DELETE /api/keys/:id - Revoke one of the current user's API keys
+func (h *APIKeyHandler) Delete(c *gin.Context) {
+	keyID := c.Param("id")
+
+	if err := h.authService.RevokeAPIKey(userID(middleware.CurrentUser(c)), keyID); err != nil {
+		h.respondError(c, err, "Failed to revoke API key")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "API key revoked successfully",
+	})
+}
+
+// respondError maps API key service errors to HTTP responses
+func (h *APIKeyHandler) respondError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+			"code":  "NOT_FOUND",
+		})
+	case errors.Is(err, service.ErrInvalidAPIKeyRequest):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+	default:
+		utils.Error(message, "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": message,
+			"code":  "INTERNAL_ERROR",
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/service"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler serves the audit log
+type AuditHandler struct {
+	auditService *service.AuditService
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(auditService *service.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// List handles GET /api/audit - Query the audit log (admin only)
+//
+// Query parameters (all optional):
+//   - actor: user ID or username
+//   - workspace_id: workspace the action targeted
+//   - action: e.g. "workspace.delete"
+//   - since, until: RFC 3339 timestamps
+//   - limit: maximum entries, newest first (default 100, max 1000)
+func (h *AuditHandler) List(c *gin.Context) {
+	filter := service.AuditFilter{
+		Actor:       c.Query("actor"),
+		WorkspaceID: c.Query("workspace_id"),
+		Action:      c.Query("action"),
+	}
+
+	for _, param := range []struct {
+		name string
+		dest *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request: " + param.name + " must be an RFC 3339 timestamp",
+				"code":  "INVALID_REQUEST",
+			})
+			return
+		}
+		*param.dest = t
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request: limit must be a positive integer",
+				"code":  "INVALID_REQUEST",
+			})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := h.auditService.Query(filter)
+	if err != nil {
+		utils.Error("Failed to query audit log", "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to query audit log",
+			"code":  "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
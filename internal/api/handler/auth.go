@@ -1,86 +1,304 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
+	"github.com/1PercentSync/vibox/internal/api/middleware"
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/service"
 	"github.com/1PercentSync/vibox/pkg/utils"
 	"github.com/gin-gonic/gin"
 )
 
+// oidcStateCookieName binds a single sign-on attempt to the browser that started it
+const oidcStateCookieName = "vibox-oidc-state"
+
+// oidcCallbackPath is where the identity provider sends the browser back to
+const oidcCallbackPath = "/api/auth/oidc/callback"
+
 // AuthHandler handles authentication-related API requests
 type AuthHandler struct {
-	apiToken string
+	authService *service.AuthService
+	oidcService *service.OIDCService // nil when single sign-on is not configured
+	staleDomain string               // Parent domain login cookies used to be scoped to; empty if none
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(apiToken string) *AuthHandler {
+// oidcService may be nil to disable single sign-on.
+// Login cookies are host-only: forward subdomains run user apps and must never
+// receive them. forwardBaseDomain is the parent domain older versions scoped
+// them to; such cookies are deleted on login and logout ("" if forwarding is off).
+func NewAuthHandler(authService *service.AuthService, oidcService *service.OIDCService, forwardBaseDomain string) *AuthHandler {
 	return &AuthHandler{
-		apiToken: apiToken,
+		authService: authService,
+		oidcService: oidcService,
+		staleDomain: forwardBaseDomain,
 	}
 }
 
 // LoginRequest represents a login request
+// Either username and password, or the API token (the original login) must be given.
 type LoginRequest struct {
-	Token string `json:"token" binding:"required"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Token    string `json:"token"`
+}
+
+// userResponse is the public view of a user (the password hash is never returned)
+type userResponse struct {
+	ID        string          `json:"id"`
+	Username  string          `json:"username"`
+	Role      domain.UserRole `json:"role"`
+	GitName   string          `json:"git_name,omitempty"`
+	GitEmail  string          `json:"git_email,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
 }
 
-// Login handles POST /api/auth/login - Authenticate and set cookie
+func newUserResponse(user *domain.User) userResponse {
+	return userResponse{
+		ID:        user.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		GitName:   user.GitName,
+		GitEmail:  user.GitEmail,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+// Login handles POST /api/auth/login - Authenticate and start a session
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
 
 	// Bind and validate request
-	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.Warn("Invalid login request", "error", err.Error())
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Token == "" && (req.Username == "" || req.Password == "")) {
+		utils.Warn("Invalid login request")
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request: token is required",
+			"error": "Invalid request: username and password (or token) are required",
 			"code":  "INVALID_REQUEST",
 		})
 		return
 	}
 
-	// Verify token
-	if req.Token != h.apiToken {
-		utils.Warn("Login failed: invalid token")
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Invalid token",
-			"code":  "UNAUTHORIZED",
+	var (
+		user    *domain.User
+		session *domain.Session
+		token   string
+		err     error
+	)
+	if req.Token != "" {
+		middleware.SetAuditDetails(c, "method=token")
+		user, session, token, err = h.authService.LoginWithToken(req.Token)
+	} else {
+		middleware.SetAuditDetails(c, "method=password username="+req.Username)
+		user, session, token, err = h.authService.Login(req.Username, req.Password)
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			utils.Warn("Login failed: invalid credentials", "username", req.Username)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid username or password",
+				"code":  "UNAUTHORIZED",
+			})
+			return
+		}
+		utils.Error("Login failed", "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to log in",
+			"code":  "INTERNAL_ERROR",
 		})
 		return
 	}
 
-	// Set cookie (24 hours, HttpOnly, SameSite=Lax)
-	c.SetCookie(
-		"vibox-token",            // name
-		req.Token,                // value
-		86400,                    // maxAge: 24 hours
-		"/",                      // path: global
-		"",                       // domain
-		false,                    // secure (should be true in production with HTTPS)
-		true,                     // httpOnly: prevent JavaScript access
-	)
-	c.SetSameSite(http.SameSiteLaxMode) // CSRF protection
+	middleware.SetCurrentUser(c, user)
+	h.setSessionCookie(c, session, token)
 
-	utils.Info("User logged in successfully")
+	utils.Info("User logged in successfully", "user_id", user.ID, "username", user.Username)
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
+		"message":    "Login successful",
+		"user":       newUserResponse(user),
+		"expires_at": session.ExpiresAt,
 	})
 }
 
-// Logout handles POST /api/auth/logout - Clear authentication cookie
+// OIDCLogin handles GET /api/auth/oidc/login - Redirect the browser to the
+// identity provider to log in with single sign-on
+func (h *AuthHandler) OIDCLogin(c *gin.Context) {
+	if h.oidcService == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Single sign-on is not configured",
+			"code":  "NOT_FOUND",
+		})
+		return
+	}
+
+	callbackURL := requestScheme(c) + "://" + c.Request.Host + oidcCallbackPath
+	authURL, state, err := h.oidcService.StartLogin(c.Request.Context(), callbackURL)
+	if err != nil {
+		h.respondOIDCError(c, err)
+		return
+	}
+
+	// The state must come back to the browser that started the login (prevents login CSRF)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookieName, state, 10*60, oidcCallbackPath, "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback handles GET /api/auth/oidc/callback - Complete a single sign-on
+// login, start a session and redirect to the app
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	if h.oidcService == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Single sign-on is not configured",
+			"code":  "NOT_FOUND",
+		})
+		return
+	}
+
+	middleware.SetAuditDetails(c, "method=oidc")
+	state := c.Query("state")
+	cookieState, _ := c.Cookie(oidcStateCookieName)
+	c.SetCookie(oidcStateCookieName, "", -1, oidcCallbackPath, "", c.Request.TLS != nil, true)
+
+	if providerError := c.Query("error"); providerError != "" {
+		utils.Warn("Single sign-on rejected by provider", "error", providerError, "description", c.Query("error_description"))
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Single sign-on failed: " + providerError,
+			"code":    "UNAUTHORIZED",
+			"details": c.Query("error_description"),
+		})
+		return
+	}
+	if state == "" || cookieState != state {
+		h.respondOIDCError(c, service.ErrOIDCLoginInvalid)
+		return
+	}
+
+	user, session, token, err := h.oidcService.FinishLogin(c.Request.Context(), state, c.Query("code"))
+	if err != nil {
+		h.respondOIDCError(c, err)
+		return
+	}
+
+	middleware.SetCurrentUser(c, user)
+	h.setSessionCookie(c, session, token)
+
+	utils.Info("User logged in with single sign-on", "user_id", user.ID, "username", user.Username)
+	c.Redirect(http.StatusFound, "/")
+}
+
+// respondOIDCError maps single sign-on errors to HTTP responses
+func (h *AuthHandler) respondOIDCError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrOIDCLoginInvalid), errors.Is(err, service.ErrOIDCTokenInvalid):
+		utils.Warn("Single sign-on failed", "error", err.Error())
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Single sign-on failed: " + err.Error(),
+			"code":  "UNAUTHORIZED",
+		})
+	case errors.Is(err, service.ErrOIDCAccessDenied):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": err.Error(),
+			"code":  "FORBIDDEN",
+		})
+	case errors.Is(err, service.ErrUsernameTaken):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+			"code":  "CONFLICT",
+		})
+	case errors.Is(err, service.ErrInvalidUser):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+	case errors.Is(err, service.ErrOIDCProvider):
+		utils.Error("Identity provider error", "error", err.Error())
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": "The identity provider could not be reached",
+			"code":  "PROVIDER_ERROR",
+		})
+	default:
+		utils.Error("Single sign-on failed", "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to log in",
+			"code":  "INTERNAL_ERROR",
+		})
+	}
+}
+
+// Logout handles POST /api/auth/logout - End the session and clear cookies
 func (h *AuthHandler) Logout(c *gin.Context) {
-	// Clear cookie by setting maxAge to -1
-	c.SetCookie(
-		"vibox-token",
-		"",
-		-1,    // maxAge: -1 deletes the cookie
-		"/",   // path: must match the original cookie
-		"",
-		false,
-		true,
-	)
+	if token := middleware.SessionToken(c); token != "" {
+		// Identify the user for the audit log before the session is gone
+		if user, err := h.authService.Authenticate(token); err == nil {
+			middleware.SetCurrentUser(c, user)
+		}
+		if err := h.authService.Logout(token); err != nil {
+			utils.Debug("Logout of unknown session", "error", err.Error())
+		}
+	}
+
+	h.clearCookie(c, middleware.SessionCookieName)
+	h.clearCookie(c, middleware.LegacyTokenCookieName)
 
 	utils.Info("User logged out successfully")
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Logout successful",
 	})
 }
+
+// Me handles GET /api/auth/me - Return the authenticated user
+func (h *AuthHandler) Me(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Unauthorized: invalid or missing authentication",
+			"code":  "UNAUTHORIZED",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// setSessionCookie hands a new login session to the browser
+func (h *AuthHandler) setSessionCookie(c *gin.Context, session *domain.Session, token string) {
+	// Session cookie (HttpOnly, SameSite=Lax); the token is opaque and revocable
+	maxAge := int(time.Until(session.ExpiresAt).Seconds())
+	c.SetSameSite(http.SameSiteLaxMode) // CSRF protection
+	c.SetCookie(
+		middleware.SessionCookieName, // name
+		token,                        // value
+		maxAge,                       // maxAge: session lifetime
+		"/",                          // path: global
+		"",                           // domain: host-only, never sent to forward subdomains
+		c.Request.TLS != nil,         // secure: only over HTTPS connections
+		true,                         // httpOnly: prevent JavaScript access
+	)
+	h.clearCookie(c, middleware.LegacyTokenCookieName)
+	h.clearStaleCookie(c, middleware.SessionCookieName)
+}
+
+// clearCookie deletes a cookie set by Login
+func (h *AuthHandler) clearCookie(c *gin.Context, name string) {
+	c.SetCookie(
+		name,
+		"",
+		-1,  // maxAge: -1 deletes the cookie
+		"/", // path: must match the original cookie
+		"",  // domain: must match the original cookie
+		c.Request.TLS != nil,
+		true,
+	)
+	h.clearStaleCookie(c, name)
+}
+
+// clearStaleCookie deletes a login cookie that an older version scoped to the
+// forward base domain, where it would reach the forwarded apps
+func (h *AuthHandler) clearStaleCookie(c *gin.Context, name string) {
+	if h.staleDomain != "" {
+		c.SetCookie(name, "", -1, "/", h.staleDomain, c.Request.TLS != nil, true)
+	}
+}
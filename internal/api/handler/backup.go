@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/service"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// BackupHandler handles backups and restores of the whole installation
+type BackupHandler struct {
+	backupService *service.BackupService
+}
+
+// NewBackupHandler creates a new backup handler
+func NewBackupHandler(backupService *service.BackupService) *BackupHandler {
+	return &BackupHandler{
+		backupService: backupService,
+	}
+}
+
+// Backup handles POST /api/admin/backup - Download a tar.gz backup (admin only)
+//
+// Query parameters (optional):
+//   - containers: "true" to include the filesystem of every workspace container
+func (h *BackupHandler) Backup(c *gin.Context) {
+	var opts service.BackupOptions
+	if value := c.Query("containers"); value != "" {
+		include, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request: containers must be true or false",
+				"code":  "INVALID_REQUEST",
+			})
+			return
+		}
+		opts.IncludeFilesystems = include
+	}
+
+	filename := fmt.Sprintf("vibox-backup-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	if err := h.backupService.Backup(c.Request.Context(), c.Writer, opts); err != nil {
+		utils.Error("Failed to create backup", "error", err.Error())
+		if c.Writer.Written() {
+			// The archive is already on its way; the client sees a truncated download
+			c.Abort()
+			return
+		}
+		c.Writer.Header().Del("Content-Disposition")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create backup",
+			"code":  "INTERNAL_ERROR",
+		})
+		return
+	}
+}
+
+// Restore handles POST /api/admin/restore - Restore a backup into an
+// installation without workspaces (admin only). The body is the tar.gz archive,
+// up to the configured RESTORE_MAX_SIZE.
+func (h *BackupHandler) Restore(c *gin.Context) {
+	if limit := h.backupService.MaxRestoreSize(); limit > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	}
+
+	result, err := h.backupService.Restore(c.Request.Context(), c.Request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": "Backup exceeds the limit of " + strconv.FormatInt(tooLarge.Limit, 10) + " bytes",
+				"code":  "PAYLOAD_TOO_LARGE",
+			})
+		case errors.Is(err, service.ErrInvalidBackup):
+			utils.Warn("Invalid backup archive", "error", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request: " + err.Error(),
+				"code":  "INVALID_REQUEST",
+			})
+		case errors.Is(err, service.ErrRestoreNotEmpty):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Restore requires an installation without workspaces",
+				"code":  "CONFLICT",
+			})
+		default:
+			utils.Error("Failed to restore backup", "error", err.Error(), "restored", result)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to restore backup: " + err.Error(),
+				"code":  "INTERNAL_ERROR",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
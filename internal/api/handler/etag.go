@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/service"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// workspaceETag returns the entity tag of a workspace: its quoted version
+func workspaceETag(workspace *domain.Workspace) string {
+	return `"` + strconv.FormatInt(workspace.Version, 10) + `"`
+}
+
+// setWorkspaceETag sets the ETag response header to the workspace's version
+func setWorkspaceETag(c *gin.Context, workspace *domain.Workspace) {
+	if workspace != nil {
+		c.Header("ETag", workspaceETag(workspace))
+	}
+}
+
+// ifMatch evaluates the If-Match header of a change against the workspace.
+// It returns the context to pass to the service, which then only applies the
+// change if the workspace is still at the matched version. When the header
+// does not match, it writes 412 Precondition Failed and returns false.
+// Without the header (or with "*") changes are unconditional.
+func ifMatch(c *gin.Context, workspace *domain.Workspace) (context.Context, bool) {
+	ctx := c.Request.Context()
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return ctx, true
+	}
+
+	current := workspaceETag(workspace)
+	for _, tag := range strings.Split(header, ",") {
+		// Weak tags never match If-Match (RFC 9110, section 13.1.1)
+		if strings.TrimSpace(tag) == current {
+			return service.WithExpectedVersion(ctx, workspace.Version), true
+		}
+	}
+
+	utils.Warn("Workspace precondition failed", "id", workspace.ID, "if_match", header, "etag", current)
+	setWorkspaceETag(c, workspace)
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"error": "Workspace has been modified since it was read",
+		"code":  "PRECONDITION_FAILED",
+	})
+	return nil, false
+}
+
+// respondVersionError writes the response for a failed conditional or
+// contended change and reports whether err was one
+func respondVersionError(c *gin.Context, workspaceService *service.WorkspaceService, id string, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrPreconditionFailed):
+		// Tell the client which version it would have to match now
+		if workspace, getErr := workspaceService.GetWorkspace(id); getErr == nil {
+			setWorkspaceETag(c, workspace)
+		}
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "Workspace has been modified since it was read",
+			"code":  "PRECONDITION_FAILED",
+		})
+		return true
+	case errors.Is(err, service.ErrWorkspaceConflict):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Workspace is being modified concurrently, please retry",
+			"code":  "CONFLICT",
+		})
+		return true
+	}
+	return false
+}
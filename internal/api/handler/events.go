@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/api/middleware"
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/service"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// eventsHeartbeatInterval keeps idle event streams alive through proxies
+const eventsHeartbeatInterval = 30 * time.Second
+
+// EventHandler streams workspace events to clients
+type EventHandler struct {
+	events           *service.EventBus
+	workspaceService *service.WorkspaceService
+}
+
+// NewEventHandler creates a new event handler
+func NewEventHandler(events *service.EventBus, workspaceService *service.WorkspaceService) *EventHandler {
+	return &EventHandler{
+		events:           events,
+		workspaceService: workspaceService,
+	}
+}
+
+// Stream handles GET /api/events - Stream events as Server-Sent Events
+//
+// Each event is sent with its type as the SSE event name (e.g. "port.opened")
+// and the JSON-encoded event as data. ?workspace_id= restricts the stream to
+// one workspace. Only events of workspaces visible to the user are sent.
+func (h *EventHandler) Stream(c *gin.Context) {
+	workspaceID := c.Query("workspace_id")
+	user := middleware.CurrentUser(c)
+
+	events, unsubscribe := h.events.Subscribe()
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable proxy buffering
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	utils.Debug("Event stream opened", "workspace_id", workspaceID)
+	defer utils.Debug("Event stream closed", "workspace_id", workspaceID)
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case event, ok := <-events:
+			if !ok {
+				// Server is shutting down
+				return
+			}
+			if workspaceID != "" && event.WorkspaceID != workspaceID {
+				continue
+			}
+			if !h.canSee(user, event.WorkspaceID) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				utils.Warn("Failed to encode event", "type", event.Type, "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// canSee reports whether user may receive events of a workspace
+func (h *EventHandler) canSee(user *domain.User, workspaceID string) bool {
+	if user != nil && user.IsAdmin() {
+		return true
+	}
+	workspace, err := h.workspaceService.GetWorkspace(workspaceID)
+	if err != nil {
+		return false
+	}
+	return workspace.RoleOf(user) != ""
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/1PercentSync/vibox/internal/api/middleware"
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/service"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ExecHandler handles non-interactive command execution requests
+type ExecHandler struct {
+	execService      *service.ExecService
+	workspaceService *service.WorkspaceService
+	dockerService    *service.DockerService
+}
+
+// NewExecHandler creates a new exec handler
+func NewExecHandler(
+	execService *service.ExecService,
+	workspaceService *service.WorkspaceService,
+	dockerService *service.DockerService,
+) *ExecHandler {
+	return &ExecHandler{
+		execService:      execService,
+		workspaceService: workspaceService,
+		dockerService:    dockerService,
+	}
+}
+
+// Exec handles POST /api/workspaces/:id/exec - Run a command and return its output
+func (h *ExecHandler) Exec(c *gin.Context) {
+	workspaceID := c.Param("id")
+
+	var req service.ExecRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Invalid exec request", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+	middleware.SetAuditDetails(c, auditCommand(req.Cmd))
+	if err := req.Validate(); err != nil {
+		utils.Warn("Invalid exec request", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	workspace, ok := h.runningWorkspace(c, workspaceID)
+	if !ok {
+		return
+	}
+
+	result, err := h.execService.Run(c.Request.Context(), workspaceID, workspace.ContainerID, req)
+	if err != nil {
+		utils.Error("Exec failed", "workspace_id", workspaceID, "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to execute command: " + err.Error(),
+			"code":  "DOCKER_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Stream handles POST /api/workspaces/:id/exec/stream - Run a command and stream its output
+//
+// The response is newline-delimited JSON (application/x-ndjson). The first line is a
+// "start" chunk carrying the exec_id that can be passed to the cancel endpoint,
+// followed by "stdout"/"stderr" chunks and a final "exit" (or "error") chunk.
+func (h *ExecHandler) Stream(c *gin.Context) {
+	workspaceID := c.Param("id")
+
+	var req service.ExecRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Invalid exec stream request", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+	middleware.SetAuditDetails(c, auditCommand(req.Cmd))
+	if err := req.Validate(); err != nil {
+		utils.Warn("Invalid exec stream request", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	workspace, ok := h.runningWorkspace(c, workspaceID)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // Disable proxy buffering
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	err := h.execService.Stream(c.Request.Context(), workspaceID, workspace.ContainerID, req, func(chunk service.ExecChunk) error {
+		if err := encoder.Encode(chunk); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		// The error chunk has already been written to the stream
+		utils.Error("Exec stream failed", "workspace_id", workspaceID, "error", err.Error())
+	}
+}
+
+// Cancel handles DELETE /api/workspaces/:id/exec/:execId - Cancel a running command
+func (h *ExecHandler) Cancel(c *gin.Context) {
+	workspaceID := c.Param("id")
+	execID := c.Param("execId")
+
+	if _, _, ok := authorizeWorkspace(c, h.workspaceService, workspaceID, domain.WorkspaceRoleEditor); !ok {
+		return
+	}
+
+	if err := h.execService.Cancel(workspaceID, execID); err != nil {
+		if errors.Is(err, service.ErrExecNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Exec not found or already finished",
+				"code":  "NOT_FOUND",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to cancel exec: " + err.Error(),
+			"code":  "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Exec cancelled",
+		"exec_id": execID,
+	})
+}
+
+// runningWorkspace loads the workspace, checks the user may run commands in it
+// (editor role) and verifies its container is running, writing the error
+// response itself when any of that fails
+func (h *ExecHandler) runningWorkspace(c *gin.Context, workspaceID string) (*domain.Workspace, bool) {
+	workspace, _, ok := authorizeWorkspace(c, h.workspaceService, workspaceID, domain.WorkspaceRoleEditor)
+	if !ok {
+		return nil, false
+	}
+
+	status, err := h.dockerService.GetContainerStatus(c.Request.Context(), workspace.ContainerID)
+	if err != nil || status != "running" {
+		utils.Warn("Exec failed: container not running", "workspace_id", workspaceID, "status", status)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Container is not running",
+			"code":  "CONTAINER_NOT_RUNNING",
+			"details": gin.H{
+				"workspace_id": workspaceID,
+				"status":       status,
+			},
+		})
+		return nil, false
+	}
+
+	return workspace, true
+}
+
+// auditCommand renders a command for the audit log, truncated to a sane length
+func auditCommand(cmd []string) string {
+	const maxLength = 500
+	command := strings.Join(cmd, " ")
+	if len(command) > maxLength {
+		command = command[:maxLength] + "..."
+	}
+	return "cmd=" + command
+}
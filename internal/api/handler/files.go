@@ -0,0 +1,376 @@
+package handler
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/1PercentSync/vibox/internal/api/middleware"
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/service"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// multipartMemory is how much of a multipart upload is kept in memory; the rest
+// is spooled to temporary files
+const multipartMemory = 32 << 20
+
+// errNoUploadFiles is returned for multipart uploads without "file" parts
+var errNoUploadFiles = errors.New("no \"file\" parts in the form")
+
+// FileHandler handles the file browser of workspace containers
+type FileHandler struct {
+	fileService      *service.FileService
+	workspaceService *service.WorkspaceService
+	dockerService    *service.DockerService
+}
+
+// NewFileHandler creates a new file handler
+func NewFileHandler(
+	fileService *service.FileService,
+	workspaceService *service.WorkspaceService,
+	dockerService *service.DockerService,
+) *FileHandler {
+	return &FileHandler{
+		fileService:      fileService,
+		workspaceService: workspaceService,
+		dockerService:    dockerService,
+	}
+}
+
+// MakeDirectoryRequest represents a request to create a directory
+type MakeDirectoryRequest struct {
+	Path string `json:"path" binding:"required"`
+}
+
+// RenameFileRequest represents a request to rename or move a file
+type RenameFileRequest struct {
+	From string `json:"from" binding:"required"`
+	To   string `json:"to" binding:"required"`
+}
+
+// List handles GET /api/workspaces/:id/files - List a directory
+//
+// Query parameters:
+//   - path: absolute directory path (default "/")
+func (h *FileHandler) List(c *gin.Context) {
+	workspaceID := c.Param("id")
+	dir := c.DefaultQuery("path", "/")
+
+	workspace, ok := h.workspaceContainer(c, workspaceID, true)
+	if !ok {
+		return
+	}
+
+	listing, err := h.fileService.List(c.Request.Context(), workspace.ContainerID, dir)
+	if err != nil {
+		h.respondError(c, workspaceID, err, "Failed to list directory")
+		return
+	}
+
+	c.JSON(http.StatusOK, listing)
+}
+
+// Download handles GET /api/workspaces/:id/files/download - Download a file or directory
+//
+// Query parameters:
+//   - path: absolute path of the file or directory
+//   - format: "tar" or "zip" to download as an archive (directories default to tar)
+func (h *FileHandler) Download(c *gin.Context) {
+	workspaceID := c.Param("id")
+	filePath := c.Query("path")
+	format := c.Query("format")
+	if format != "" && format != service.ArchiveFormatTar && format != service.ArchiveFormatZip {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: format must be tar or zip",
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+	middleware.SetAuditDetails(c, "path="+filePath)
+
+	workspace, ok := h.workspaceContainer(c, workspaceID, false)
+	if !ok {
+		return
+	}
+
+	info, err := h.fileService.Stat(c.Request.Context(), workspace.ContainerID, filePath)
+	if err != nil {
+		h.respondError(c, workspaceID, err, "Failed to download file")
+		return
+	}
+	if format == "" && info.Type == "directory" {
+		format = service.ArchiveFormatTar
+	}
+
+	filename := info.Name
+	if filename == "/" {
+		filename = "root"
+	}
+	contentType := "application/octet-stream"
+	switch format {
+	case service.ArchiveFormatTar:
+		filename += ".tar"
+		contentType = "application/x-tar"
+	case service.ArchiveFormatZip:
+		filename += ".zip"
+		contentType = "application/zip"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+
+	if format == "" {
+		err = h.fileService.DownloadFile(c.Request.Context(), workspace.ContainerID, filePath, c.Writer)
+	} else {
+		err = h.fileService.DownloadArchive(c.Request.Context(), workspace.ContainerID, filePath, format, c.Writer)
+	}
+	if err != nil {
+		if c.Writer.Written() {
+			// The download is already on its way; the client sees a truncated file
+			utils.Error("Failed to download file", "workspace_id", workspaceID, "path", filePath, "error", err.Error())
+			c.Abort()
+			return
+		}
+		c.Writer.Header().Del("Content-Disposition")
+		h.respondError(c, workspaceID, err, "Failed to download file")
+		return
+	}
+}
+
+// Upload handles POST /api/workspaces/:id/files/upload - Upload files into a directory
+//
+// Query parameters:
+//   - path: absolute path of an existing directory
+//
+// The body is either multipart/form-data with one or more "file" parts, or a
+// tar archive (application/x-tar) that is extracted into the directory.
+func (h *FileHandler) Upload(c *gin.Context) {
+	workspaceID := c.Param("id")
+	dir := c.Query("path")
+	middleware.SetAuditDetails(c, "path="+dir)
+
+	workspace, ok := h.workspaceContainer(c, workspaceID, false)
+	if !ok {
+		return
+	}
+
+	if limit := h.fileService.MaxUploadSize(); limit > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	var err error
+	switch mediaType {
+	case "multipart/form-data":
+		err = h.uploadMultipart(c, workspace.ContainerID, dir)
+	case "application/x-tar":
+		err = h.fileService.UploadArchive(c.Request.Context(), workspace.ContainerID, dir, c.Request.Body)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: body must be multipart/form-data or application/x-tar",
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+	if err != nil {
+		h.respondError(c, workspaceID, err, "Failed to upload files")
+		return
+	}
+
+	utils.Info("Files uploaded successfully", "workspace_id", workspaceID, "path", dir)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Files uploaded successfully",
+		"path":    dir,
+	})
+}
+
+// uploadMultipart uploads the "file" parts of a multipart form
+func (h *FileHandler) uploadMultipart(c *gin.Context, containerID, dir string) error {
+	if err := c.Request.ParseMultipartForm(multipartMemory); err != nil {
+		return err
+	}
+	defer c.Request.MultipartForm.RemoveAll()
+
+	headers := c.Request.MultipartForm.File["file"]
+	if len(headers) == 0 {
+		return errNoUploadFiles
+	}
+
+	files := make([]service.UploadFile, 0, len(headers))
+	for _, header := range headers {
+		file, err := header.Open()
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		files = append(files, service.UploadFile{Name: header.Filename, Size: header.Size, Content: file})
+	}
+	return h.fileService.Upload(c.Request.Context(), containerID, dir, files)
+}
+
+// MakeDirectory handles POST /api/workspaces/:id/files/mkdir - Create a directory
+func (h *FileHandler) MakeDirectory(c *gin.Context) {
+	workspaceID := c.Param("id")
+
+	var req MakeDirectoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Invalid mkdir request", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+	middleware.SetAuditDetails(c, "path="+req.Path)
+
+	workspace, ok := h.workspaceContainer(c, workspaceID, false)
+	if !ok {
+		return
+	}
+
+	if err := h.fileService.MakeDirectory(c.Request.Context(), workspace.ContainerID, req.Path); err != nil {
+		h.respondError(c, workspaceID, err, "Failed to create directory")
+		return
+	}
+
+	info, err := h.fileService.Stat(c.Request.Context(), workspace.ContainerID, req.Path)
+	if err != nil {
+		h.respondError(c, workspaceID, err, "Failed to create directory")
+		return
+	}
+	c.JSON(http.StatusCreated, info)
+}
+
+// Rename handles POST /api/workspaces/:id/files/rename - Rename or move a file or directory
+func (h *FileHandler) Rename(c *gin.Context) {
+	workspaceID := c.Param("id")
+
+	var req RenameFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Invalid rename request", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+	middleware.SetAuditDetails(c, "from="+req.From+" to="+req.To)
+
+	workspace, ok := h.workspaceContainer(c, workspaceID, true)
+	if !ok {
+		return
+	}
+
+	if err := h.fileService.Rename(c.Request.Context(), workspace.ContainerID, req.From, req.To); err != nil {
+		h.respondError(c, workspaceID, err, "Failed to rename file")
+		return
+	}
+
+	info, err := h.fileService.Stat(c.Request.Context(), workspace.ContainerID, req.To)
+	if err != nil {
+		h.respondError(c, workspaceID, err, "Failed to rename file")
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// Delete handles DELETE /api/workspaces/:id/files - Delete a file or directory
+//
+// Query parameters:
+//   - path: absolute path; directories are deleted with everything in them
+func (h *FileHandler) Delete(c *gin.Context) {
+	workspaceID := c.Param("id")
+	filePath := c.Query("path")
+	middleware.SetAuditDetails(c, "path="+filePath)
+
+	workspace, ok := h.workspaceContainer(c, workspaceID, true)
+	if !ok {
+		return
+	}
+
+	if err := h.fileService.Delete(c.Request.Context(), workspace.ContainerID, filePath); err != nil {
+		h.respondError(c, workspaceID, err, "Failed to delete file")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "File deleted successfully",
+	})
+}
+
+// workspaceContainer loads the workspace and checks the user may edit it.
+// Operations that run commands in the container also need it to be running.
+// It writes the error response itself when any of that fails.
+func (h *FileHandler) workspaceContainer(c *gin.Context, workspaceID string, running bool) (*domain.Workspace, bool) {
+	workspace, _, ok := authorizeWorkspace(c, h.workspaceService, workspaceID, domain.WorkspaceRoleEditor)
+	if !ok {
+		return nil, false
+	}
+	if workspace.ContainerID == "" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": service.ErrWorkspaceNotReady.Error(),
+			"code":  "CONFLICT",
+		})
+		return nil, false
+	}
+	if !running {
+		return workspace, true
+	}
+
+	status, err := h.dockerService.GetContainerStatus(c.Request.Context(), workspace.ContainerID)
+	if err != nil || status != "running" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Container is not running",
+			"code":  "CONTAINER_NOT_RUNNING",
+			"details": gin.H{
+				"workspace_id": workspaceID,
+				"status":       status,
+			},
+		})
+		return nil, false
+	}
+	return workspace, true
+}
+
+// respondError maps file browser errors to HTTP responses
+func (h *FileHandler) respondError(c *gin.Context, workspaceID string, err error, message string) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": "Upload exceeds the limit of " + strconv.FormatInt(tooLarge.Limit, 10) + " bytes",
+			"code":  "PAYLOAD_TOO_LARGE",
+		})
+	case errors.Is(err, service.ErrFileNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+			"code":  "NOT_FOUND",
+		})
+	case errors.Is(err, service.ErrFileExists):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+			"code":  "CONFLICT",
+		})
+	case errors.Is(err, service.ErrFileTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": err.Error(),
+			"code":  "PAYLOAD_TOO_LARGE",
+		})
+	case errors.Is(err, service.ErrInvalidPath), errors.Is(err, service.ErrNotDirectory),
+		errors.Is(err, service.ErrIsDirectory), errors.Is(err, service.ErrInvalidArchive),
+		errors.Is(err, http.ErrNotMultipart), errors.Is(err, http.ErrMissingBoundary),
+		errors.Is(err, errNoUploadFiles):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+	default:
+		utils.Error(message, "workspace_id", workspaceID, "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": message + ": " + err.Error(),
+			"code":  "INTERNAL_ERROR",
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/api/middleware"
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/service"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// GitHandler handles the current user's git credentials
+type GitHandler struct {
+	gitService *service.GitService
+}
+
+// NewGitHandler creates a new git handler
+func NewGitHandler(gitService *service.GitService) *GitHandler {
+	return &GitHandler{
+		gitService: gitService,
+	}
+}
+
+// gitCredentialResponse is the public view of a git credential (never includes the secret)
+type gitCredentialResponse struct {
+	ID        string                   `json:"id"`
+	Name      string                   `json:"name"`
+	Type      domain.GitCredentialType `json:"type"`
+	Username  string                   `json:"username,omitempty"`
+	CreatedAt time.Time                `json:"created_at"`
+}
+
+// newGitCredentialResponse builds the public view of a git credential
+func newGitCredentialResponse(credential *domain.GitCredential) gitCredentialResponse {
+	return gitCredentialResponse{
+		ID:        credential.ID,
+		Name:      credential.Name,
+		Type:      credential.Type,
+		Username:  credential.Username,
+		CreatedAt: credential.CreatedAt,
+	}
+}
+
+// Create handles POST /api/git/credentials - Store a git credential
+// The secret can never be read back; workspaces refer to it by ID.
+func (h *GitHandler) Create(c *gin.Context) {
+	var req service.CreateGitCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Invalid create git credential request", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	credential, err := h.gitService.CreateCredential(userID(middleware.CurrentUser(c)), req)
+	if err != nil {
+		h.respondError(c, err, "Failed to create git credential")
+		return
+	}
+
+	middleware.SetAuditDetails(c, credential.ID)
+	c.JSON(http.StatusCreated, newGitCredentialResponse(credential))
+}
+
+// List handles GET /api/git/credentials - List the current user's git credentials
+func (h *GitHandler) List(c *gin.Context) {
+	credentials, err := h.gitService.ListCredentials(userID(middleware.CurrentUser(c)))
+	if err != nil {
+		h.respondError(c, err, "Failed to list git credentials")
+		return
+	}
+
+	response := make([]gitCredentialResponse, 0, len(credentials))
+	for _, credential := range credentials {
+		response = append(response, newGitCredentialResponse(credential))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Delete handles DELETE /api/git/credentials/:id - Delete one of the current
+// user's git credentials. Workspaces still referring to it fail to clone.
+func (h *GitHandler) Delete(c *gin.Context) {
+	credentialID := c.Param("id")
+
+	if err := h.gitService.DeleteCredential(userID(middleware.CurrentUser(c)), credentialID); err != nil {
+		h.respondError(c, err, "Failed to delete git credential")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Git credential deleted successfully",
+	})
+}
+
+// respondError maps git service errors to HTTP responses
+func (h *GitHandler) respondError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrGitCredentialNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+			"code":  "NOT_FOUND",
+		})
+	case errors.Is(err, service.ErrInvalidGitCredential):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+	default:
+		utils.Error(message, "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": message,
+			"code":  "INTERNAL_ERROR",
+		})
+	}
+}
@@ -2,13 +2,18 @@ package handler
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/1PercentSync/vibox/internal/api/middleware"
 	"github.com/1PercentSync/vibox/internal/config"
 	"github.com/1PercentSync/vibox/internal/domain"
 	"github.com/1PercentSync/vibox/internal/repository"
@@ -29,6 +34,17 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
+// testAdmin is the user requests are authenticated as in handler tests
+var testAdmin = &domain.User{ID: "user-admin", Username: "admin", Role: domain.UserRoleAdmin}
+
+// withUser returns middleware that authenticates every request as user
+func withUser(user *domain.User) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		middleware.SetCurrentUser(c, user)
+		c.Next()
+	}
+}
+
 func TestWorkspaceHandler_List_EmptyList(t *testing.T) {
 	// Setup
 	cfg := &config.Config{
@@ -45,10 +61,11 @@ func TestWorkspaceHandler_List_EmptyList(t *testing.T) {
 	defer dockerSvc.Close()
 
 	workspaceSvc := service.NewWorkspaceService(dockerSvc, repo, cfg)
-	handler := NewWorkspaceHandler(workspaceSvc)
+	handler := NewWorkspaceHandler(workspaceSvc, nil)
 
 	// Create test router
 	router := gin.New()
+	router.Use(withUser(testAdmin))
 	router.GET("/api/workspaces", handler.List)
 
 	// Make request
@@ -87,7 +104,7 @@ func TestWorkspaceHandler_Get_NotFound(t *testing.T) {
 	defer dockerSvc.Close()
 
 	workspaceSvc := service.NewWorkspaceService(dockerSvc, repo, cfg)
-	handler := NewWorkspaceHandler(workspaceSvc)
+	handler := NewWorkspaceHandler(workspaceSvc, nil)
 
 	// Create test router
 	router := gin.New()
@@ -129,7 +146,7 @@ func TestWorkspaceHandler_Create_InvalidRequest(t *testing.T) {
 	defer dockerSvc.Close()
 
 	workspaceSvc := service.NewWorkspaceService(dockerSvc, repo, cfg)
-	handler := NewWorkspaceHandler(workspaceSvc)
+	handler := NewWorkspaceHandler(workspaceSvc, nil)
 
 	// Create test router
 	router := gin.New()
@@ -172,7 +189,7 @@ func TestWorkspaceHandler_Create_MissingName(t *testing.T) {
 	defer dockerSvc.Close()
 
 	workspaceSvc := service.NewWorkspaceService(dockerSvc, repo, cfg)
-	handler := NewWorkspaceHandler(workspaceSvc)
+	handler := NewWorkspaceHandler(workspaceSvc, nil)
 
 	// Create test router
 	router := gin.New()
@@ -221,7 +238,7 @@ func TestProxyHandler_Forward_InvalidPort(t *testing.T) {
 
 	workspaceSvc := service.NewWorkspaceService(dockerSvc, repo, cfg)
 	proxySvc := service.NewProxyService(dockerSvc)
-	handler := NewProxyHandler(proxySvc, workspaceSvc, dockerSvc)
+	handler := NewProxyHandler(proxySvc, workspaceSvc, dockerSvc, service.NewPortAccessService())
 
 	// Create test router
 	router := gin.New()
@@ -277,7 +294,7 @@ func TestProxyHandler_Forward_WorkspaceNotFound(t *testing.T) {
 
 	workspaceSvc := service.NewWorkspaceService(dockerSvc, repo, cfg)
 	proxySvc := service.NewProxyService(dockerSvc)
-	handler := NewProxyHandler(proxySvc, workspaceSvc, dockerSvc)
+	handler := NewProxyHandler(proxySvc, workspaceSvc, dockerSvc, service.NewPortAccessService())
 
 	// Create test router
 	router := gin.New()
@@ -319,8 +336,8 @@ func TestTerminalHandler_Connect_WorkspaceNotFound(t *testing.T) {
 	defer dockerSvc.Close()
 
 	workspaceSvc := service.NewWorkspaceService(dockerSvc, repo, cfg)
-	terminalSvc := service.NewTerminalService(dockerSvc)
-	handler := NewTerminalHandler(terminalSvc, workspaceSvc, dockerSvc)
+	terminalSvc := service.NewTerminalService(dockerSvc, cfg)
+	handler := NewTerminalHandler(terminalSvc, workspaceSvc, dockerSvc, nil)
 
 	// Create test router
 	router := gin.New()
@@ -364,10 +381,11 @@ func TestWorkspaceHandler_FullCRUD(t *testing.T) {
 	defer dockerSvc.Close()
 
 	workspaceSvc := service.NewWorkspaceService(dockerSvc, repo, cfg)
-	handler := NewWorkspaceHandler(workspaceSvc)
+	handler := NewWorkspaceHandler(workspaceSvc, nil)
 
 	// Create test router
 	router := gin.New()
+	router.Use(withUser(testAdmin))
 	router.POST("/api/workspaces", handler.Create)
 	router.GET("/api/workspaces", handler.List)
 	router.GET("/api/workspaces/:id", handler.Get)
@@ -451,3 +469,734 @@ func TestWorkspaceHandler_FullCRUD(t *testing.T) {
 		t.Errorf("Expected status 404 after deletion, got %d", w.Code)
 	}
 }
+
+func TestParseForwardHost(t *testing.T) {
+	tests := []struct {
+		name        string
+		host        string
+		baseDomain  string
+		workspaceID string
+		port        int
+		ok          bool
+	}{
+		{"valid host", "8080-ws-abc12345.vibox.example.com", "vibox.example.com", "ws-abc12345", 8080, true},
+		{"host with port", "3000-ws-abc12345.vibox.example.com:443", "vibox.example.com", "ws-abc12345", 3000, true},
+		{"mixed case", "8080-WS-ABC12345.Vibox.Example.com", "vibox.example.com", "ws-abc12345", 8080, true},
+		{"base domain itself", "vibox.example.com", "vibox.example.com", "", 0, false},
+		{"other domain", "8080-ws-abc12345.other.com", "vibox.example.com", "", 0, false},
+		{"nested subdomain", "a.8080-ws-abc12345.vibox.example.com", "vibox.example.com", "", 0, false},
+		{"missing port", "ws-abc12345.vibox.example.com", "vibox.example.com", "", 0, false},
+		{"invalid port", "99999-ws-abc12345.vibox.example.com", "vibox.example.com", "", 0, false},
+		{"forwarding disabled", "8080-ws-abc12345.vibox.example.com", "", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workspaceID, port, ok := ParseForwardHost(tt.host, tt.baseDomain)
+			if ok != tt.ok || workspaceID != tt.workspaceID || port != tt.port {
+				t.Errorf("ParseForwardHost(%q) = (%q, %d, %v), want (%q, %d, %v)",
+					tt.host, workspaceID, port, ok, tt.workspaceID, tt.port, tt.ok)
+			}
+		})
+	}
+}
+
+func TestProxyHandler_ForwardHost_Routing(t *testing.T) {
+	repo, err := repository.NewWorkspaceRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	workspaceSvc := service.NewWorkspaceService(nil, repo, &config.Config{})
+	handler := NewProxyHandler(nil, workspaceSvc, nil, nil)
+
+	authSvc := service.NewAuthService(repository.NewMemoryUserRepository(), repository.NewMemorySessionRepository(), repository.NewMemoryAPIKeyRepository(), &config.Config{APIToken: "test-token"})
+
+	router := gin.New()
+	router.Use(handler.ForwardHost("vibox.example.com", authSvc))
+	router.GET("/health", func(c *gin.Context) {
+		c.String(http.StatusOK, "vibox")
+	})
+
+	// Requests for the base domain reach the ViBox routes
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/health", nil)
+	req.Host = "vibox.example.com"
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "vibox" {
+		t.Errorf("Expected base domain to be served by ViBox, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Unauthenticated API requests to a forwarded host are rejected
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/health", nil)
+	req.Host = "8080-ws-abc12345.vibox.example.com"
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for unauthenticated forward, got %d", w.Code)
+	}
+
+	// Unauthenticated browsers are sent to the parent domain for a forward ticket
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/app?tab=1", nil)
+	req.Host = "8080-ws-abc12345.vibox.example.com"
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	router.ServeHTTP(w, req)
+	expected := "https://vibox.example.com/api/tickets/forward?path=%2Fapp%3Ftab%3D1&port=8080&workspace_id=ws-abc12345"
+	if w.Code != http.StatusFound || w.Header().Get("Location") != expected {
+		t.Errorf("Expected redirect to the ticket endpoint, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestProxyHandler_Forward_PortPolicy(t *testing.T) {
+	repo, err := repository.NewWorkspaceRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	workspaceSvc := service.NewWorkspaceService(nil, repo, &config.Config{})
+
+	// Policies are enforced before the container is looked up, so no Docker is needed
+	if err := repo.Create(&domain.Workspace{ID: "ws-policy", Name: "policy", ContainerID: "container-1"}); err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+	_, err = workspaceSvc.UpdatePortSettings(context.Background(), "ws-policy", 8080, &service.PortSettingsRequest{
+		Visibility:     domain.PortVisibilityPublic,
+		AllowedMethods: []string{"post"},
+		BasicAuth:      &service.BasicAuthRequest{Username: "hook", Password: "s3cret"},
+		CORS:           &domain.PortCORS{AllowedOrigins: []string{"https://app.example.com"}},
+		RateLimit:      &domain.PortRateLimit{RequestsPerMinute: 60, Burst: 3},
+	})
+	if err != nil {
+		t.Fatalf("Failed to update port settings: %v", err)
+	}
+
+	handler := NewProxyHandler(service.NewProxyService(nil), workspaceSvc, nil, service.NewPortAccessService())
+	router := gin.New()
+	router.Any("/forward/:id/:port/*path", handler.Forward)
+
+	send := func(method string, headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/forward/ws-policy/8080/hook", nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		req.RemoteAddr = "192.0.2.1:1234"
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Preflight from an allowed origin is answered by ViBox
+	w := send("OPTIONS", map[string]string{
+		"Origin":                        "https://app.example.com",
+		"Access-Control-Request-Method": "POST",
+	})
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("Expected preflight to be allowed, got %d %v", w.Code, w.Header())
+	}
+
+	// Preflight from another origin is rejected
+	w = send("OPTIONS", map[string]string{
+		"Origin":                        "https://evil.example.com",
+		"Access-Control-Request-Method": "POST",
+	})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for disallowed origin, got %d", w.Code)
+	}
+
+	// Methods outside the allow list are rejected
+	w = send("GET", nil)
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "POST" {
+		t.Errorf("Expected status 405 with Allow: POST, got %d %q", w.Code, w.Header().Get("Allow"))
+	}
+
+	// Missing credentials are challenged (the request counts against the rate limit)
+	w = send("POST", nil)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("Expected basic auth challenge, got %d", w.Code)
+	}
+
+	// The burst of 3 is used up by the remaining requests
+	send("POST", nil)
+	send("POST", nil)
+	w = send("POST", nil)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected status 429 with Retry-After, got %d", w.Code)
+	}
+
+	// Password hashes never appear in API responses
+	workspace, _ := workspaceSvc.GetWorkspace("ws-policy")
+	if auth := workspace.Redacted().PortSettings["8080"].BasicAuth; auth == nil || auth.PasswordHash != "" {
+		t.Errorf("Expected redacted basic auth, got %+v", auth)
+	}
+	if workspace.PortSettings["8080"].BasicAuth.PasswordHash == "" {
+		t.Error("Expected stored password hash to be kept")
+	}
+}
+
+func TestAuthHandler_LoginMeLogout(t *testing.T) {
+	authSvc := service.NewAuthService(repository.NewMemoryUserRepository(), repository.NewMemorySessionRepository(), repository.NewMemoryAPIKeyRepository(), &config.Config{APIToken: "test-token"})
+	if err := authSvc.Bootstrap(); err != nil {
+		t.Fatalf("Bootstrap failed: %v", err)
+	}
+	handler := NewAuthHandler(authSvc, nil, "")
+
+	router := gin.New()
+	router.POST("/api/auth/login", handler.Login)
+	router.POST("/api/auth/logout", handler.Logout)
+	router.GET("/api/auth/me", middleware.AuthMiddleware(authSvc), handler.Me)
+
+	// Wrong password
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/auth/login", bytes.NewBufferString(`{"username":"admin","password":"wrong"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401 for wrong password, got %d", w.Code)
+	}
+
+	// The bootstrap admin logs in with the API token as password
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/auth/login", bytes.NewBufferString(`{"username":"admin","password":"test-token"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for login, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var session *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == middleware.SessionCookieName {
+			session = cookie
+		}
+	}
+	if session == nil || session.Value == "" || session.Value == "test-token" || !session.HttpOnly {
+		t.Fatalf("Expected an opaque HttpOnly session cookie, got %+v", session)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/auth/me", nil)
+	req.AddCookie(session)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for /me, got %d", w.Code)
+	}
+	var me map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &me)
+	if me["username"] != "admin" || me["role"] != "admin" {
+		t.Errorf("Expected admin user, got %v", me)
+	}
+	if _, ok := me["password_hash"]; ok {
+		t.Error("Expected password hash to be omitted")
+	}
+
+	// Logging out ends the session server-side
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/auth/logout", nil)
+	req.AddCookie(session)
+	router.ServeHTTP(w, req)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/auth/me", nil)
+	req.AddCookie(session)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 after logout, got %d", w.Code)
+	}
+}
+
+func TestWorkspaceHandler_RoleBasedAccess(t *testing.T) {
+	repo, err := repository.NewWorkspaceRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	workspaceSvc := service.NewWorkspaceService(nil, repo, &config.Config{})
+	authSvc := service.NewAuthService(repository.NewMemoryUserRepository(), repository.NewMemorySessionRepository(), repository.NewMemoryAPIKeyRepository(), &config.Config{APIToken: "test-token"})
+
+	alice, _ := authSvc.CreateUser(service.CreateUserRequest{Username: "alice", Password: "alice-password"})
+	bob, _ := authSvc.CreateUser(service.CreateUserRequest{Username: "bob", Password: "bob-password"})
+	carol, _ := authSvc.CreateUser(service.CreateUserRequest{Username: "carol", Password: "carol-password"})
+
+	if err := repo.Create(&domain.Workspace{
+		ID:      "ws-owned",
+		Name:    "owned",
+		Owner:   alice.ID,
+		Members: []domain.WorkspaceMember{{UserID: bob.ID, Role: domain.WorkspaceRoleViewer}},
+	}); err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+	if err := repo.Create(&domain.Workspace{ID: "ws-legacy", Name: "legacy"}); err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+
+	handler := NewWorkspaceHandler(workspaceSvc, authSvc)
+	send := func(user *domain.User, method, path, body string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.Use(withUser(user))
+		router.GET("/api/workspaces", handler.List)
+		router.GET("/api/workspaces/:id", handler.Get)
+		router.DELETE("/api/workspaces/:id", handler.Delete)
+		router.PUT("/api/workspaces/:id/ports", handler.UpdatePorts)
+		router.PUT("/api/workspaces/:id/members/:userId", handler.SetMember)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	listIDs := func(user *domain.User) []string {
+		var workspaces []domain.Workspace
+		json.Unmarshal(send(user, "GET", "/api/workspaces", "").Body.Bytes(), &workspaces)
+		ids := make([]string, 0, len(workspaces))
+		for _, workspace := range workspaces {
+			ids = append(ids, workspace.ID)
+		}
+		return ids
+	}
+
+	// List only contains visible workspaces; admins see everything
+	if ids := listIDs(bob); len(ids) != 1 || ids[0] != "ws-owned" {
+		t.Errorf("Expected viewer to see only ws-owned, got %v", ids)
+	}
+	if ids := listIDs(carol); len(ids) != 0 {
+		t.Errorf("Expected outsider to see nothing, got %v", ids)
+	}
+	if ids := listIDs(testAdmin); len(ids) != 2 {
+		t.Errorf("Expected admin to see all workspaces, got %v", ids)
+	}
+
+	// Outsiders cannot tell the workspace exists
+	if w := send(carol, "GET", "/api/workspaces/ws-owned", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for outsider, got %d", w.Code)
+	}
+	if w := send(bob, "GET", "/api/workspaces/ws-owned", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for viewer, got %d", w.Code)
+	}
+
+	// Viewers cannot modify or delete
+	if w := send(bob, "PUT", "/api/workspaces/ws-owned/ports", `{"ports":{"8080":"web"}}`); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for viewer updating ports, got %d", w.Code)
+	}
+	if w := send(bob, "DELETE", "/api/workspaces/ws-owned", ""); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for viewer deleting, got %d", w.Code)
+	}
+	if w := send(bob, "PUT", "/api/workspaces/ws-owned/members/"+bob.ID, `{"role":"owner"}`); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for viewer promoting itself, got %d", w.Code)
+	}
+
+	// The owner promotes the viewer to editor, who may then update ports
+	if w := send(alice, "PUT", "/api/workspaces/ws-owned/members/"+bob.ID, `{"role":"editor"}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for owner setting a member, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := send(bob, "PUT", "/api/workspaces/ws-owned/ports", `{"ports":{"8080":"web"}}`); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for editor updating ports, got %d", w.Code)
+	}
+	if w := send(alice, "PUT", "/api/workspaces/ws-owned/members/user-unknown", `{"role":"viewer"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown user, got %d", w.Code)
+	}
+	if w := send(alice, "PUT", "/api/workspaces/ws-owned/members/"+carol.ID, `{"role":"superuser"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown role, got %d", w.Code)
+	}
+}
+
+func TestWorkspaceHandler_ETagIfMatch(t *testing.T) {
+	repo, err := repository.NewWorkspaceRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	workspaceSvc := service.NewWorkspaceService(nil, repo, &config.Config{})
+	if err := repo.Create(&domain.Workspace{ID: "ws-etag", Name: "etag"}); err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+
+	handler := NewWorkspaceHandler(workspaceSvc, nil)
+	send := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.Use(withUser(testAdmin))
+		router.GET("/api/workspaces/:id", handler.Get)
+		router.PUT("/api/workspaces/:id/ports", handler.UpdatePorts)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("GET", "/api/workspaces/ws-etag", "", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag != `"1"` {
+		t.Fatalf("Expected 200 with ETag \"1\", got %d with %q", w.Code, etag)
+	}
+	if w := send("GET", "/api/workspaces/ws-etag", "", map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304 for unchanged workspace, got %d", w.Code)
+	}
+
+	// A write with the current ETag succeeds and returns the next one
+	w = send("PUT", "/api/workspaces/ws-etag/ports", `{"ports":{"8080":"web"}}`, map[string]string{"If-Match": etag})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for matching If-Match, got %d: %s", w.Code, w.Body.String())
+	}
+	if next := w.Header().Get("ETag"); next != `"2"` {
+		t.Errorf("Expected ETag \"2\" after update, got %q", next)
+	}
+
+	// Replaying the stale ETag is rejected and leaves the workspace unchanged
+	w = send("PUT", "/api/workspaces/ws-etag/ports", `{"ports":{"9090":"api"}}`, map[string]string{"If-Match": etag})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("Expected status 412 for stale If-Match, got %d", w.Code)
+	}
+	if current := w.Header().Get("ETag"); current != `"2"` {
+		t.Errorf("Expected 412 to report the current ETag \"2\", got %q", current)
+	}
+	if workspace, _ := repo.Get("ws-etag"); workspace.Ports["9090"] != "" {
+		t.Error("Expected stale write not to be applied")
+	}
+
+	// Unconditional writes still work
+	if w := send("PUT", "/api/workspaces/ws-etag/ports", `{"ports":{"9090":"api"}}`, nil); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 without If-Match, got %d", w.Code)
+	}
+}
+
+func TestWorkspaceHandler_ExportImport(t *testing.T) {
+	repo, err := repository.NewWorkspaceRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	workspaceSvc := service.NewWorkspaceService(nil, repo, &config.Config{})
+	if err := repo.Create(&domain.Workspace{
+		ID:     "ws-export",
+		Name:   "export",
+		Config: domain.WorkspaceConfig{Image: "alpine:latest"},
+		Ports:  map[string]string{"8080": "web"},
+	}); err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+
+	handler := NewWorkspaceHandler(workspaceSvc, nil)
+	router := gin.New()
+	router.Use(withUser(testAdmin))
+	router.GET("/api/workspaces/:id/export", handler.Export)
+	router.POST("/api/workspaces/import", handler.Import)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("GET", "/api/workspaces/ws-export/export", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Content-Type"), "yaml") {
+		t.Fatalf("Expected YAML export, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	definition, err := service.ParseWorkspaceDefinition(w.Body.Bytes())
+	if err != nil {
+		t.Fatalf("Expected export to be importable: %v\n%s", err, w.Body.String())
+	}
+	if definition.Image != "alpine:latest" || definition.Ports["8080"] != "web" {
+		t.Errorf("Unexpected definition %+v", definition)
+	}
+
+	w = send("GET", "/api/workspaces/ws-export/export?format=json", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"kind":"Workspace"`) {
+		t.Errorf("Expected JSON export, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := send("GET", "/api/workspaces/ws-export/export?format=xml", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown format, got %d", w.Code)
+	}
+
+	// Invalid definitions are rejected before anything is created
+	if w := send("POST", "/api/workspaces/import", "version: 1\nkind: Workspace\nname: x\nvolumes: []\n"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unsupported field, got %d", w.Code)
+	}
+	if list, _ := repo.List(); len(list) != 1 {
+		t.Errorf("Expected no workspace to be created, got %d", len(list))
+	}
+}
+
+func TestWorkspaceHandler_Update(t *testing.T) {
+	repo, err := repository.NewWorkspaceRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	workspaceSvc := service.NewWorkspaceService(nil, repo, &config.Config{})
+	if err := repo.Create(&domain.Workspace{ID: "ws-patch", Name: "patch", Config: domain.WorkspaceConfig{Image: "alpine:latest"}}); err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+
+	handler := NewWorkspaceHandler(workspaceSvc, nil)
+	router := gin.New()
+	router.Use(withUser(testAdmin))
+	router.PATCH("/api/workspaces/:id", handler.Update)
+
+	send := func(body string, headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", "/api/workspaces/ws-patch", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(`{"name": "renamed", "image": "ubuntu:24.04"}`, map[string]string{"If-Match": `"1"`})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var workspace domain.Workspace
+	if err := json.Unmarshal(w.Body.Bytes(), &workspace); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if workspace.Name != "renamed" || len(workspace.PendingChanges) != 1 || workspace.PendingChanges[0] != domain.PendingImage {
+		t.Errorf("Expected renamed workspace with pending image change, got %+v", workspace)
+	}
+
+	if w := send(`{"env": {"": "x"}}`, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid env, got %d", w.Code)
+	}
+	if w := send(`{"name": "stale"}`, map[string]string{"If-Match": `"1"`}); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status 412 for stale If-Match, got %d", w.Code)
+	}
+}
+
+func TestExecHandler_InvalidRequest(t *testing.T) {
+	handler := NewExecHandler(nil, nil, nil)
+	router := gin.New()
+	router.Use(withUser(testAdmin))
+	router.POST("/api/workspaces/:id/exec", handler.Exec)
+	router.POST("/api/workspaces/:id/exec/stream", handler.Stream)
+
+	bodies := []string{
+		`{"cmd": ["env"], "env": {"BAD=KEY": "x"}}`,
+		`{"cmd": ["sleep", "1"], "timeout": -1}`,
+		`{"cmd": ["sleep", "1"], "timeout": 7200}`,
+	}
+	for _, path := range []string{"/api/workspaces/ws-1/exec", "/api/workspaces/ws-1/exec/stream"} {
+		for _, body := range bodies {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_REQUEST") {
+				t.Errorf("%s %s: expected 400 INVALID_REQUEST, got %d: %s", path, body, w.Code, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestProxyHandler_Forward_PasswordShareOnBasicAuthPort(t *testing.T) {
+	repo, err := repository.NewWorkspaceRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	workspaceSvc := service.NewWorkspaceService(nil, repo, &config.Config{})
+	if err := repo.Create(&domain.Workspace{ID: "ws-share", Name: "share", ContainerID: "container-1"}); err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+	_, err = workspaceSvc.UpdatePortSettings(context.Background(), "ws-share", 8080, &service.PortSettingsRequest{
+		BasicAuth: &service.BasicAuthRequest{Username: "dev", Password: "port-pass"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to update port settings: %v", err)
+	}
+
+	shareSvc := service.NewShareService(repository.NewMemoryShareRepository())
+	_, passwordToken, _ := shareSvc.CreateShare("ws-share", 8080, service.CreateShareRequest{Password: "share-pass"})
+	_, openToken, _ := shareSvc.CreateShare("ws-share", 8080, service.CreateShareRequest{})
+
+	// Both checks run as in Forward; the proxying itself needs Docker
+	handler := NewProxyHandler(service.NewProxyService(nil), workspaceSvc, nil, service.NewPortAccessService())
+	router := gin.New()
+	router.Any("/forward/:id/:port/*path", middleware.ForwardAuthMiddleware(nil, shareSvc, workspaceSvc), func(c *gin.Context) {
+		workspace, _ := workspaceSvc.GetWorkspace("ws-share")
+		if _, ok := handler.enforcePortPolicy(c, "ws-share", 8080, workspace.PortSettingsFor(8080)); ok {
+			c.Status(http.StatusOK)
+		}
+	})
+
+	send := func(token, username, password string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/forward/ws-share/8080/?vibox_share="+token, nil)
+		if username != "" || password != "" {
+			req.SetBasicAuth(username, password)
+		}
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := send(passwordToken, "", "share-pass"); code != http.StatusOK {
+		t.Errorf("Expected the share password to pass the port's basic auth, got %d", code)
+	}
+	if code := send(passwordToken, "dev", "port-pass"); code != http.StatusUnauthorized {
+		t.Errorf("Expected the port credentials to be no share password, got %d", code)
+	}
+	if code := send(openToken, "dev", "port-pass"); code != http.StatusOK {
+		t.Errorf("Expected an open share to need the port credentials, got %d", code)
+	}
+	if code := send(openToken, "", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected an open share without port credentials to be challenged, got %d", code)
+	}
+}
+
+func TestUserHandler_ChangePassword(t *testing.T) {
+	authSvc := service.NewAuthService(repository.NewMemoryUserRepository(), repository.NewMemorySessionRepository(), repository.NewMemoryAPIKeyRepository(), &config.Config{APIToken: "test-token"})
+	alice, _ := authSvc.CreateUser(service.CreateUserRequest{Username: "alice", Password: "alice-password"})
+	admin, _ := authSvc.CreateUser(service.CreateUserRequest{Username: "root", Password: "root-password", Role: domain.UserRoleAdmin})
+
+	handler := NewUserHandler(authSvc)
+	send := func(user *domain.User, id, body string) int {
+		router := gin.New()
+		router.Use(withUser(user))
+		router.PUT("/api/users/:id/password", handler.ChangePassword)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/users/"+id+"/password", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Changing your own password needs the current one
+	if code := send(alice, alice.ID, `{"password":"new-password"}`); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without current password, got %d", code)
+	}
+	if code := send(alice, alice.ID, `{"password":"new-password","current_password":"wrong-password"}`); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for wrong current password, got %d", code)
+	}
+	if code := send(alice, alice.ID, `{"password":"new-password","current_password":"alice-password"}`); code != http.StatusOK {
+		t.Errorf("Expected status 200 with current password, got %d", code)
+	}
+	if code := send(admin, admin.ID, `{"password":"new-password"}`); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for admin changing own password without current one, got %d", code)
+	}
+
+	// Admins change someone else's password without it
+	if code := send(admin, alice.ID, `{"password":"reset-password"}`); code != http.StatusOK {
+		t.Errorf("Expected status 200 for admin reset, got %d", code)
+	}
+	if _, _, _, err := authSvc.Login("alice", "reset-password"); err != nil {
+		t.Errorf("Expected login with reset password, got %v", err)
+	}
+}
+
+func TestBackupHandler_RestoreTooLarge(t *testing.T) {
+	workspaces, err := repository.NewWorkspaceRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	backupSvc := service.NewBackupService(nil, nil, workspaces, repository.NewMemoryUserRepository(), repository.NewMemoryAPIKeyRepository(), repository.NewMemoryShareRepository(), repository.NewMemoryGitCredentialRepository(), 64)
+	handler := NewBackupHandler(backupSvc)
+
+	router := gin.New()
+	router.POST("/api/admin/restore", handler.Restore)
+
+	// A valid gzip stream, so the limit and not the header is what fails
+	var body bytes.Buffer
+	gz, _ := gzip.NewWriterLevel(&body, gzip.NoCompression)
+	gz.Write([]byte(strings.Repeat("x", 1024)))
+	gz.Close()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/admin/restore", &body)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTicketHandler_Forward(t *testing.T) {
+	repo, err := repository.NewWorkspaceRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	workspaceSvc := service.NewWorkspaceService(nil, repo, &config.Config{})
+	authSvc := service.NewAuthService(repository.NewMemoryUserRepository(), repository.NewMemorySessionRepository(), repository.NewMemoryAPIKeyRepository(), &config.Config{APIToken: "test-token"})
+	if err := repo.Create(&domain.Workspace{ID: "ws-abc12345", Name: "forward"}); err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+
+	admin, _ := authSvc.CreateUser(service.CreateUserRequest{Username: "root", Password: "root-password", Role: domain.UserRoleAdmin})
+
+	handler := NewTicketHandler(authSvc, workspaceSvc, "vibox.example.com")
+	router := gin.New()
+	router.Use(withUser(admin))
+	router.GET("/api/tickets/forward", handler.Forward)
+
+	send := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/tickets/forward?"+query, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("workspace_id=ws-abc12345&port=8080&path=%2Fapp%3Ftab%3D1")
+	if w.Code != http.StatusFound {
+		t.Fatalf("Expected status 302, got %d: %s", w.Code, w.Body.String())
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || location.Host != "8080-ws-abc12345.vibox.example.com" || location.Path != "/app" || location.Query().Get("tab") != "1" {
+		t.Fatalf("Unexpected redirect %q", w.Header().Get("Location"))
+	}
+	target := domain.TicketTarget{Kind: domain.TicketKindForward, WorkspaceID: "ws-abc12345", Port: 8080}
+	if _, err := authSvc.AuthenticateTicket(location.Query().Get(middleware.TicketQueryParam), target); err != nil {
+		t.Errorf("Expected a forward ticket for the port, got %v", err)
+	}
+
+	// The target host is always the port's subdomain
+	for _, path := range []string{"https%3A%2F%2Fevil.example.com%2F", "%2F%2Fevil.example.com%2F", "app"} {
+		if w := send("workspace_id=ws-abc12345&port=8080&path=" + path); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for path %q, got %d", path, w.Code)
+		}
+	}
+	if w := send("workspace_id=ws-missing&port=8080"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown workspace, got %d", w.Code)
+	}
+}
+
+func TestProxyHandler_Forward_RateLimitIgnoresForwardedFor(t *testing.T) {
+	repo, err := repository.NewWorkspaceRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	workspaceSvc := service.NewWorkspaceService(nil, repo, &config.Config{})
+	if err := repo.Create(&domain.Workspace{ID: "ws-limit", Name: "limit", ContainerID: "container-1"}); err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+	_, err = workspaceSvc.UpdatePortSettings(context.Background(), "ws-limit", 8080, &service.PortSettingsRequest{
+		Visibility: domain.PortVisibilityPublic,
+		BasicAuth:  &service.BasicAuthRequest{Username: "hook", Password: "s3cret"},
+		RateLimit:  &domain.PortRateLimit{RequestsPerMinute: 60, Burst: 2},
+	})
+	if err != nil {
+		t.Fatalf("Failed to update port settings: %v", err)
+	}
+
+	// As configured by SetupRouter without TRUSTED_PROXIES
+	handler := NewProxyHandler(service.NewProxyService(nil), workspaceSvc, nil, service.NewPortAccessService())
+	router := gin.New()
+	if err := router.SetTrustedProxies(nil); err != nil {
+		t.Fatalf("SetTrustedProxies failed: %v", err)
+	}
+	router.Any("/forward/:id/:port/*path", handler.Forward)
+
+	var code int
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/forward/ws-limit/8080/hook", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i))
+		router.ServeHTTP(w, req)
+		code = w.Code
+	}
+	if code != http.StatusTooManyRequests {
+		t.Errorf("Expected a rotating X-Forwarded-For to share one rate limit, got %d", code)
+	}
+}
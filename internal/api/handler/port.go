@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/service"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// PortHandler handles listening port discovery requests
+type PortHandler struct {
+	portScanner      *service.PortScanner
+	workspaceService *service.WorkspaceService
+	dockerService    *service.DockerService
+}
+
+// NewPortHandler creates a new port handler
+func NewPortHandler(
+	portScanner *service.PortScanner,
+	workspaceService *service.WorkspaceService,
+	dockerService *service.DockerService,
+) *PortHandler {
+	return &PortHandler{
+		portScanner:      portScanner,
+		workspaceService: workspaceService,
+		dockerService:    dockerService,
+	}
+}
+
+// discoveredPort is a listening port together with its user-defined label
+type discoveredPort struct {
+	service.ListeningPort
+	Label string `json:"label,omitempty"`
+	URL   string `json:"url"` // Path-based forward URL
+}
+
+// List handles GET /api/workspaces/:id/ports - List listening ports in the workspace
+//
+// Returns the result of the last background scan, or scans now if there is none
+// yet or ?refresh=true is given.
+func (h *PortHandler) List(c *gin.Context) {
+	workspaceID := c.Param("id")
+
+	workspace, _, ok := authorizeWorkspace(c, h.workspaceService, workspaceID, domain.WorkspaceRoleViewer)
+	if !ok {
+		return
+	}
+
+	result, ok := h.portScanner.LastScan(workspaceID)
+	if !ok || c.Query("refresh") == "true" {
+		status, err := h.dockerService.GetContainerStatus(c.Request.Context(), workspace.ContainerID)
+		if err != nil || status != "running" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Container is not running",
+				"code":  "CONTAINER_NOT_RUNNING",
+				"details": gin.H{
+					"workspace_id": workspaceID,
+					"status":       status,
+				},
+			})
+			return
+		}
+
+		result, err = h.portScanner.ScanWorkspace(c.Request.Context(), workspace)
+		if err != nil {
+			utils.Error("Failed to scan ports", "workspace_id", workspaceID, "error", err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to scan ports: " + err.Error(),
+				"code":  "DOCKER_ERROR",
+			})
+			return
+		}
+	}
+
+	ports := make([]discoveredPort, 0, len(result.Ports))
+	for _, port := range result.Ports {
+		ports = append(ports, discoveredPort{
+			ListeningPort: port,
+			Label:         workspace.PortLabel(port.Port).Name,
+			URL:           "/forward/" + workspaceID + "/" + strconv.Itoa(port.Port) + "/",
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"workspace_id": workspaceID,
+		"ports":        ports,
+		"scanned_at":   result.ScannedAt.Format(time.RFC3339),
+	})
+}
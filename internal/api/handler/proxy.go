@@ -1,9 +1,15 @@
 package handler
 
 import (
+	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
+	"github.com/1PercentSync/vibox/internal/api/middleware"
+	"github.com/1PercentSync/vibox/internal/domain"
 	"github.com/1PercentSync/vibox/internal/service"
 	"github.com/1PercentSync/vibox/pkg/utils"
 	"github.com/gin-gonic/gin"
@@ -14,6 +20,7 @@ type ProxyHandler struct {
 	proxyService     *service.ProxyService
 	workspaceService *service.WorkspaceService
 	dockerService    *service.DockerService
+	portAccess       *service.PortAccessService
 }
 
 // NewProxyHandler creates a new proxy handler
@@ -21,11 +28,13 @@ func NewProxyHandler(
 	proxyService *service.ProxyService,
 	workspaceService *service.WorkspaceService,
 	dockerService *service.DockerService,
+	portAccess *service.PortAccessService,
 ) *ProxyHandler {
 	return &ProxyHandler{
 		proxyService:     proxyService,
 		workspaceService: workspaceService,
 		dockerService:    dockerService,
+		portAccess:       portAccess,
 	}
 }
 
@@ -62,6 +71,118 @@ func (h *ProxyHandler) Forward(c *gin.Context) {
 		return
 	}
 
+	// Extract the path after /forward/:id/:port
+	// The *path parameter includes the leading slash
+	targetPath := c.Param("path")
+	if targetPath == "" {
+		targetPath = "/"
+	}
+
+	h.forward(c, workspaceID, port, targetPath, "/forward/"+workspaceID+"/"+portStr)
+}
+
+// ForwardHost returns middleware that serves requests addressed to
+// <port>-<workspace-id>.<baseDomain> by proxying them to the container port at
+// the root path. Requests for any other host continue down the chain untouched.
+//
+// Apps that emit absolute URLs (Vite, Next.js, Jupyter) work unmodified this way,
+// since they are not mounted under the /forward/:id/:port prefix.
+// Authentication uses the same credential sources as AuthMiddleware. Login
+// cookies are never sent to the subdomains: browsers are redirected to
+// GET /api/tickets/forward on baseDomain for a forward ticket instead, which is
+// exchanged for a cookie of the subdomain.
+func (h *ProxyHandler) ForwardHost(baseDomain string, auth middleware.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		workspaceID, port, ok := ParseForwardHost(c.Request.Host, baseDomain)
+		if !ok {
+			c.Next()
+			return
+		}
+		// Never fall through to the ViBox routes for a forwarded host
+		defer c.Abort()
+
+		middleware.SetTicketTarget(c, domain.TicketTarget{Kind: domain.TicketKindForward, WorkspaceID: workspaceID, Port: port})
+		public := h.workspaceService.PortVisibility(workspaceID, port) == domain.PortVisibilityPublic
+		if !public && !middleware.IsAuthenticated(c, auth) {
+			utils.Warn("Unauthorized subdomain forward request", "host", c.Request.Host)
+			if strings.Contains(c.GetHeader("Accept"), "text/html") {
+				// Send browsers to ViBox on the parent domain for a ticket to this port
+				query := url.Values{
+					"workspace_id": {workspaceID},
+					"port":         {strconv.Itoa(port)},
+					"path":         {c.Request.URL.RequestURI()},
+				}
+				c.Redirect(http.StatusFound, requestScheme(c)+"://"+baseDomain+forwardTicketPath+"?"+query.Encode())
+				return
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Unauthorized: invalid or missing authentication",
+				"code":  "UNAUTHORIZED",
+			})
+			return
+		}
+		if !middleware.AllowsScope(c, domain.ScopeForward) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "API key lacks the required scope",
+				"code":    "FORBIDDEN",
+				"details": "required scope: " + domain.ScopeForward,
+			})
+			return
+		}
+
+		targetPath := c.Request.URL.Path
+		if targetPath == "" {
+			targetPath = "/"
+		}
+		h.forward(c, workspaceID, port, targetPath, "")
+	}
+}
+
+// ParseForwardHost extracts the workspace ID and port from a host of the form
+// <port>-<workspace-id>.<baseDomain> (an optional :port suffix is ignored)
+func ParseForwardHost(host, baseDomain string) (workspaceID string, port int, ok bool) {
+	if baseDomain == "" {
+		return "", 0, false
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	label, found := strings.CutSuffix(host, "."+strings.ToLower(baseDomain))
+	if !found || label == "" || strings.Contains(label, ".") {
+		return "", 0, false
+	}
+
+	portStr, workspaceID, found := strings.Cut(label, "-")
+	if !found || workspaceID == "" {
+		return "", 0, false
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, false
+	}
+
+	return workspaceID, port, true
+}
+
+// requestScheme returns the scheme the client used, honouring X-Forwarded-Proto from a reverse proxy
+func requestScheme(c *gin.Context) string {
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		return strings.ToLower(strings.TrimSpace(strings.Split(proto, ",")[0]))
+	}
+	if c.Request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// forward verifies the workspace container is running and proxies the request to
+// the given port, rewriting the request path to targetPath.
+// prefix is the public mount point of the app ("" when served at the root of a subdomain);
+// response rewriting is applied under it when the port label opts in with ";rewrite".
+func (h *ProxyHandler) forward(c *gin.Context, workspaceID string, port int, targetPath, prefix string) {
 	// 1. Verify workspace exists
 	workspace, err := h.workspaceService.GetWorkspace(workspaceID)
 	if err != nil {
@@ -73,8 +194,27 @@ func (h *ProxyHandler) Forward(c *gin.Context) {
 		return
 	}
 
-	// 2. Check container status
-	status, err := h.dockerService.GetContainerStatus(c.Request.Context(), workspace.ContainerID)
+	// 2. Enforce the port's visibility and access policy
+	settings := workspace.PortSettingsFor(port)
+	if !canReachPort(c, workspace, settings.EffectiveVisibility()) {
+		utils.Warn("Proxy request denied: not a member of the workspace",
+			"workspace_id", workspaceID,
+			"port", port,
+			"user_id", userID(middleware.CurrentUser(c)),
+		)
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Workspace not found",
+			"code":  "NOT_FOUND",
+		})
+		return
+	}
+	corsHeaders, ok := h.enforcePortPolicy(c, workspaceID, port, settings)
+	if !ok {
+		return
+	}
+
+	// 3. Check container status (served from the proxy's container cache when warm)
+	status, err := h.proxyService.ContainerStatus(c.Request.Context(), workspace.ContainerID)
 	if err != nil {
 		utils.Error("Failed to get container status", "workspace_id", workspaceID, "error", err.Error())
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -100,14 +240,7 @@ func (h *ProxyHandler) Forward(c *gin.Context) {
 		return
 	}
 
-	// 3. Proxy the request
-	// Extract the path after /forward/:id/:port
-	// The *path parameter includes the leading slash
-	targetPath := c.Param("path")
-	if targetPath == "" {
-		targetPath = "/"
-	}
-
+	// 4. Proxy the request
 	utils.Debug("Proxying request to container",
 		"workspace_id", workspaceID,
 		"container_id", workspace.ContainerID,
@@ -121,7 +254,13 @@ func (h *ProxyHandler) Forward(c *gin.Context) {
 	c.Request.URL.Path = targetPath
 	c.Request.URL.RawPath = targetPath
 
-	err = h.proxyService.ProxyRequest(c.Writer, c.Request, workspace.ContainerID, port)
+	opts := service.ProxyOptions{
+		Prefix:      prefix,
+		Rewrite:     prefix != "" && workspace.PortLabel(port).Rewrite,
+		CORSHeaders: corsHeaders,
+	}
+
+	err = h.proxyService.ProxyRequestWithOptions(c.Writer, c.Request, workspace.ContainerID, port, opts)
 	if err != nil {
 		utils.Error("Proxy request failed",
 			"workspace_id", workspaceID,
@@ -137,3 +276,107 @@ func (h *ProxyHandler) Forward(c *gin.Context) {
 		"port", port,
 	)
 }
+
+// canReachPort reports whether the request may reach a port with the given
+// visibility: public ports and share links are open, team ports are open to any
+// logged-in user, and private ports require a role on the workspace
+func canReachPort(c *gin.Context, workspace *domain.Workspace, visibility domain.PortVisibility) bool {
+	if visibility == domain.PortVisibilityPublic {
+		return true
+	}
+	if _, shared := c.Get("share"); shared {
+		// ForwardAuthMiddleware validated a share link for this port
+		return true
+	}
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		return false
+	}
+	if visibility == domain.PortVisibilityTeam {
+		return true
+	}
+	return workspace.RoleOf(user).Allows(domain.WorkspaceRoleViewer)
+}
+
+// sharePasswordChecked reports whether the request was authorized by a
+// password-protected share link. Its password is sent with basic auth too, so
+// it stands in for the port's own basic-auth credentials.
+func sharePasswordChecked(c *gin.Context) bool {
+	value, ok := c.Get("share")
+	if !ok {
+		return false
+	}
+	share, ok := value.(*domain.PortShare)
+	return ok && share.HasPassword()
+}
+
+// enforcePortPolicy applies the CORS, method, rate-limit and basic-auth rules of
+// a port, writing the response itself when the request must not be proxied.
+// A password-protected share link replaces the port's basic auth.
+// It returns the CORS headers to set on the proxied response (nil when the port
+// has no CORS policy and the app handles CORS itself).
+func (h *ProxyHandler) enforcePortPolicy(c *gin.Context, workspaceID string, port int, settings domain.PortSettings) (http.Header, bool) {
+	origin := c.GetHeader("Origin")
+
+	var corsHeaders http.Header
+	if settings.CORS != nil {
+		// Answer preflight requests on behalf of the app
+		if c.Request.Method == http.MethodOptions && origin != "" && c.GetHeader("Access-Control-Request-Method") != "" {
+			headers := service.CORSPreflightHeaders(settings.CORS, origin,
+				c.GetHeader("Access-Control-Request-Method"), c.GetHeader("Access-Control-Request-Headers"))
+			if headers == nil {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error": "CORS request not allowed",
+					"code":  "FORBIDDEN",
+				})
+				return nil, false
+			}
+			for key, values := range headers {
+				c.Writer.Header()[key] = values
+			}
+			c.Status(http.StatusNoContent)
+			return nil, false
+		}
+
+		corsHeaders = service.CORSHeaders(settings.CORS, origin)
+		if corsHeaders == nil {
+			// Strip whatever the app would have sent
+			corsHeaders = http.Header{}
+		}
+	}
+
+	if !settings.AllowsMethod(c.Request.Method) {
+		c.Header("Allow", strings.Join(settings.AllowedMethods, ", "))
+		c.JSON(http.StatusMethodNotAllowed, gin.H{
+			"error": "Method not allowed on this port",
+			"code":  "METHOD_NOT_ALLOWED",
+		})
+		return nil, false
+	}
+
+	if allowed, wait := h.portAccess.Allow(workspaceID, port, c.ClientIP(), settings.RateLimit); !allowed {
+		utils.Warn("Forward request rate limited", "workspace_id", workspaceID, "port", port, "client_ip", c.ClientIP())
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": "Rate limit exceeded",
+			"code":  "RATE_LIMITED",
+		})
+		return nil, false
+	}
+
+	if settings.BasicAuth != nil && !sharePasswordChecked(c) {
+		username, password, ok := c.Request.BasicAuth()
+		if !ok || !h.portAccess.CheckBasicAuth(settings.BasicAuth, username, password) {
+			c.Header("WWW-Authenticate", `Basic realm="ViBox forwarded port", charset="UTF-8"`)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid or missing credentials",
+				"code":  "UNAUTHORIZED",
+			})
+			return nil, false
+		}
+		// The credentials belong to ViBox, not to the app
+		c.Request.Header.Del("Authorization")
+	}
+
+	return corsHeaders, true
+}
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/api/middleware"
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/service"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ShareHandler handles share link management for forwarded ports
+type ShareHandler struct {
+	shareService     *service.ShareService
+	workspaceService *service.WorkspaceService
+}
+
+// NewShareHandler creates a new share handler
+func NewShareHandler(shareService *service.ShareService, workspaceService *service.WorkspaceService) *ShareHandler {
+	return &ShareHandler{
+		shareService:     shareService,
+		workspaceService: workspaceService,
+	}
+}
+
+// shareResponse is the public view of a share (hashes are never returned)
+type shareResponse struct {
+	ID          string    `json:"id"`
+	WorkspaceID string    `json:"workspace_id"`
+	Port        int       `json:"port"`
+	HasPassword bool      `json:"has_password"`
+	ReadOnly    bool      `json:"read_only"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Token       string    `json:"token,omitempty"` // Only returned on creation
+	URL         string    `json:"url,omitempty"`   // Only returned on creation
+}
+
+func newShareResponse(share *domain.PortShare) shareResponse {
+	return shareResponse{
+		ID:          share.ID,
+		WorkspaceID: share.WorkspaceID,
+		Port:        share.Port,
+		HasPassword: share.HasPassword(),
+		ReadOnly:    share.ReadOnly,
+		CreatedAt:   share.CreatedAt,
+		ExpiresAt:   share.ExpiresAt,
+	}
+}
+
+// Create handles POST /api/workspaces/:id/ports/:port/shares - Create a share link
+//
+// The returned token is shown only once. Hand out the returned url; opening it
+// stores the token in a cookie scoped to /forward/:id/:port/.
+func (h *ShareHandler) Create(c *gin.Context) {
+	workspaceID := c.Param("id")
+	port, ok := h.parsePort(c)
+	if !ok {
+		return
+	}
+
+	// The body is optional; an empty one creates a share with the defaults
+	var req service.CreateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.Warn("Invalid create share request", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	if _, _, ok := authorizeWorkspace(c, h.workspaceService, workspaceID, domain.WorkspaceRoleOwner); !ok {
+		return
+	}
+
+	share, token, err := h.shareService.CreateShare(workspaceID, port, req)
+	if err != nil {
+		utils.Warn("Failed to create share", "workspace_id", workspaceID, "port", port, "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to create share: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	resp := newShareResponse(share)
+	resp.Token = token
+	resp.URL = "/forward/" + workspaceID + "/" + strconv.Itoa(port) + "/?" +
+		url.Values{middleware.ShareQueryParam: {token}}.Encode()
+
+	utils.Info("Share created", "share_id", share.ID, "workspace_id", workspaceID, "port", port)
+	c.JSON(http.StatusCreated, resp)
+}
+
+// List handles GET /api/workspaces/:id/ports/:port/shares - List share links of a port
+func (h *ShareHandler) List(c *gin.Context) {
+	workspaceID := c.Param("id")
+	port, ok := h.parsePort(c)
+	if !ok {
+		return
+	}
+
+	if _, _, ok := authorizeWorkspace(c, h.workspaceService, workspaceID, domain.WorkspaceRoleOwner); !ok {
+		return
+	}
+
+	shares, err := h.shareService.ListShares(workspaceID, port)
+	if err != nil {
+		utils.Error("Failed to list shares", "workspace_id", workspaceID, "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list shares: " + err.Error(),
+			"code":  "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	resp := make([]shareResponse, 0, len(shares))
+	for _, share := range shares {
+		resp = append(resp, newShareResponse(share))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Delete handles DELETE /api/workspaces/:id/ports/:port/shares/:shareId - Revoke a share link
+func (h *ShareHandler) Delete(c *gin.Context) {
+	workspaceID := c.Param("id")
+	shareID := c.Param("shareId")
+	port, ok := h.parsePort(c)
+	if !ok {
+		return
+	}
+
+	if _, _, ok := authorizeWorkspace(c, h.workspaceService, workspaceID, domain.WorkspaceRoleOwner); !ok {
+		return
+	}
+
+	if err := h.shareService.RevokeShare(workspaceID, port, shareID); err != nil {
+		if errors.Is(err, service.ErrShareNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Share not found",
+				"code":  "NOT_FOUND",
+			})
+			return
+		}
+		utils.Error("Failed to revoke share", "share_id", shareID, "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke share: " + err.Error(),
+			"code":  "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Share revoked",
+		"share_id": shareID,
+	})
+}
+
+// parsePort parses the :port parameter, writing the error response itself when invalid
+func (h *ShareHandler) parsePort(c *gin.Context) (int, bool) {
+	portStr := c.Param("port")
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid port number",
+			"code":  "INVALID_REQUEST",
+			"details": gin.H{
+				"port": portStr,
+			},
+		})
+		return 0, false
+	}
+	return port, true
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/service"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// SnapshotHandler handles workspace snapshots
+type SnapshotHandler struct {
+	workspaceService *service.WorkspaceService
+}
+
+// NewSnapshotHandler creates a new snapshot handler
+func NewSnapshotHandler(workspaceService *service.WorkspaceService) *SnapshotHandler {
+	return &SnapshotHandler{
+		workspaceService: workspaceService,
+	}
+}
+
+// CreateSnapshotRequest represents a request to snapshot a workspace
+type CreateSnapshotRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// Create handles POST /api/workspaces/:id/snapshots - Snapshot the workspace container
+func (h *SnapshotHandler) Create(c *gin.Context) {
+	id := c.Param("id")
+
+	var req CreateSnapshotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Invalid create snapshot request", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	if _, _, ok := authorizeWorkspace(c, h.workspaceService, id, domain.WorkspaceRoleEditor); !ok {
+		return
+	}
+
+	snapshot, err := h.workspaceService.CreateSnapshot(c.Request.Context(), id, req.Name)
+	if err != nil {
+		h.respondError(c, id, err, "Failed to create snapshot")
+		return
+	}
+
+	c.JSON(http.StatusCreated, snapshot)
+}
+
+// List handles GET /api/workspaces/:id/snapshots - List the snapshots of a workspace
+func (h *SnapshotHandler) List(c *gin.Context) {
+	id := c.Param("id")
+
+	if _, _, ok := authorizeWorkspace(c, h.workspaceService, id, domain.WorkspaceRoleViewer); !ok {
+		return
+	}
+
+	snapshots, err := h.workspaceService.ListSnapshots(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, id, err, "Failed to list snapshots")
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshots)
+}
+
+// Delete handles DELETE /api/workspaces/:id/snapshots/:name - Delete a snapshot
+func (h *SnapshotHandler) Delete(c *gin.Context) {
+	id := c.Param("id")
+
+	if _, _, ok := authorizeWorkspace(c, h.workspaceService, id, domain.WorkspaceRoleEditor); !ok {
+		return
+	}
+
+	if err := h.workspaceService.DeleteSnapshot(c.Request.Context(), id, c.Param("name")); err != nil {
+		h.respondError(c, id, err, "Failed to delete snapshot")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Snapshot deleted successfully",
+	})
+}
+
+// Restore handles POST /api/workspaces/:id/snapshots/:name/restore - Recreate
+// the workspace container from a snapshot
+func (h *SnapshotHandler) Restore(c *gin.Context) {
+	id := c.Param("id")
+
+	workspace, _, ok := authorizeWorkspace(c, h.workspaceService, id, domain.WorkspaceRoleEditor)
+	if !ok {
+		return
+	}
+	ctx, ok := ifMatch(c, workspace)
+	if !ok {
+		return
+	}
+
+	if err := h.workspaceService.RestoreSnapshot(ctx, id, c.Param("name")); err != nil {
+		h.respondError(c, id, err, "Failed to restore snapshot")
+		return
+	}
+
+	workspace, _ = h.workspaceService.GetWorkspace(id)
+
+	utils.Info("Snapshot restore initiated successfully", "id", id, "snapshot", c.Param("name"))
+	setWorkspaceETag(c, workspace)
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Snapshot restore started",
+		"workspace": workspace.Redacted(),
+	})
+}
+
+// respondError maps snapshot errors to HTTP responses
+func (h *SnapshotHandler) respondError(c *gin.Context, id string, err error, message string) {
+	if respondVersionError(c, h.workspaceService, id, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrInvalidSnapshotName):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: snapshot names may contain letters, digits, '_', '.' and '-' (up to 128 characters, not starting with '.' or '-')",
+			"code":  "INVALID_REQUEST",
+		})
+	case errors.Is(err, service.ErrSnapshotNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Snapshot not found",
+			"code":  "NOT_FOUND",
+		})
+	case errors.Is(err, service.ErrSnapshotExists), errors.Is(err, service.ErrSnapshotInUse), errors.Is(err, service.ErrWorkspaceNotReady):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+			"code":  "CONFLICT",
+		})
+	default:
+		utils.Error(message, "id", id, "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": message + ": " + err.Error(),
+			"code":  "INTERNAL_ERROR",
+		})
+	}
+}
@@ -1,8 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
+	"github.com/1PercentSync/vibox/internal/api/middleware"
+	"github.com/1PercentSync/vibox/internal/domain"
 	"github.com/1PercentSync/vibox/internal/service"
 	"github.com/1PercentSync/vibox/pkg/utils"
 	"github.com/gin-gonic/gin"
@@ -14,18 +18,22 @@ type TerminalHandler struct {
 	terminalService  *service.TerminalService
 	workspaceService *service.WorkspaceService
 	dockerService    *service.DockerService
+	auditService     *service.AuditService
 }
 
 // NewTerminalHandler creates a new terminal handler
+// auditService may be nil to not record terminal sessions.
 func NewTerminalHandler(
 	terminalService *service.TerminalService,
 	workspaceService *service.WorkspaceService,
 	dockerService *service.DockerService,
+	auditService *service.AuditService,
 ) *TerminalHandler {
 	return &TerminalHandler{
 		terminalService:  terminalService,
 		workspaceService: workspaceService,
 		dockerService:    dockerService,
+		auditService:     auditService,
 	}
 }
 
@@ -33,34 +41,24 @@ var upgrader = websocket.Upgrader{
 	ReadBufferSize:  8192,
 	WriteBufferSize: 8192,
 	CheckOrigin: func(r *http.Request) bool {
-		// Currently allowing all origins for simplicity.
-		// Since we use API token authentication (not cookies),
-		// the CSRF risk from CheckOrigin is minimal.
-		//
-		// If token is compromised, attackers can make direct API calls anyway.
-		// CheckOrigin only prevents malicious websites from using
-		// tokens stored in user's browser.
-		//
-		// TODO: For production with sensitive data, consider adding
-		// origin whitelist via ALLOWED_ORIGINS env var.
+		// Any origin may connect with a ticket or a header credential, which a
+		// page cannot obtain by itself. Upgrades authenticated only by the
+		// login cookie are refused from other origins by AuthMiddleware.
 		return true
 	},
 }
 
 // Connect handles GET /ws/terminal/:id - Connect to workspace terminal
+// Viewers of the workspace get a read-only terminal.
 func (h *TerminalHandler) Connect(c *gin.Context) {
 	workspaceID := c.Param("id")
 
-	// 1. Verify workspace exists
-	workspace, err := h.workspaceService.GetWorkspace(workspaceID)
-	if err != nil {
-		utils.Warn("Terminal connection failed: workspace not found", "id", workspaceID)
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Workspace not found",
-			"code":  "NOT_FOUND",
-		})
+	// 1. Verify workspace exists and the user may see it
+	workspace, role, ok := authorizeWorkspace(c, h.workspaceService, workspaceID, domain.WorkspaceRoleViewer)
+	if !ok {
 		return
 	}
+	readOnly := !role.Allows(domain.WorkspaceRoleEditor)
 
 	// 2. Check container status
 	status, err := h.dockerService.GetContainerStatus(c.Request.Context(), workspace.ContainerID)
@@ -89,7 +87,17 @@ func (h *TerminalHandler) Connect(c *gin.Context) {
 		return
 	}
 
-	// 3. Upgrade to WebSocket
+	// 3. Reject early if the session caps are already reached
+	if err := h.terminalService.CheckSessionLimit(workspaceID); err != nil {
+		utils.Warn("Terminal connection rejected: session limit reached", "workspace_id", workspaceID, "error", err.Error())
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": "Too many terminal sessions: " + err.Error(),
+			"code":  "TOO_MANY_SESSIONS",
+		})
+		return
+	}
+
+	// 4. Upgrade to WebSocket
 	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		utils.Error("Failed to upgrade to WebSocket", "workspace_id", workspaceID, "error", err.Error())
@@ -97,12 +105,36 @@ func (h *TerminalHandler) Connect(c *gin.Context) {
 		return
 	}
 
-	utils.Info("WebSocket connection established", "workspace_id", workspaceID, "container_id", workspace.ContainerID)
+	utils.Info("WebSocket connection established", "workspace_id", workspaceID, "container_id", workspace.ContainerID, "read_only", readOnly)
+	if readOnly {
+		middleware.SetAuditDetails(c, "read_only=true")
+	}
+	h.auditService.Record(middleware.NewAuditEntry(c, domain.AuditActionTerminalConnect, domain.AuditOutcomeSuccess))
+
+	// 5. Create terminal session (blocks until the session ends)
+	err = h.terminalService.CreateSession(c.Request.Context(), ws, workspaceID, workspace.ContainerID, service.TerminalOptions{ReadOnly: readOnly})
+
+	outcome := domain.AuditOutcomeSuccess
+	if err != nil {
+		outcome = domain.AuditOutcomeFailure
+		middleware.SetAuditDetails(c, "error="+err.Error())
+	}
+	h.auditService.Record(middleware.NewAuditEntry(c, domain.AuditActionTerminalDisconnect, outcome))
 
-	// 4. Create terminal session
-	err = h.terminalService.CreateSession(c.Request.Context(), ws, workspace.ContainerID)
 	if err != nil {
 		utils.Error("Terminal session error", "workspace_id", workspaceID, "error", err.Error())
-		// Session will be cleaned up by TerminalService
+
+		// The session was never established, so the connection is still ours to close
+		// (close reasons are limited to 123 bytes, so send a short fixed text)
+		closeCode, reason := websocket.CloseInternalServerErr, "failed to start terminal session"
+		if errors.Is(err, service.ErrSessionLimitReached) {
+			closeCode, reason = websocket.CloseTryAgainLater, "terminal session limit reached"
+		}
+		_ = ws.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(closeCode, reason),
+			time.Now().Add(time.Second),
+		)
+		ws.Close()
 	}
 }
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/1PercentSync/vibox/internal/api/middleware"
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/service"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ticketScopes maps ticket kinds to the API key scope needed to mint them
+var ticketScopes = map[domain.TicketKind]string{
+	domain.TicketKindTerminal: domain.ScopeTerminal,
+	domain.TicketKindForward:  domain.ScopeForward,
+	domain.TicketKindTunnel:   domain.ScopeForward,
+}
+
+// forwardTicketPath is where forward subdomains send browsers without credentials
+const forwardTicketPath = "/api/tickets/forward"
+
+// TicketHandler mints signed tickets for URLs that cannot carry other credentials
+type TicketHandler struct {
+	authService       *service.AuthService
+	workspaceService  *service.WorkspaceService
+	forwardBaseDomain string // Base domain of the forward subdomains ("" = disabled)
+}
+
+// NewTicketHandler creates a new ticket handler
+func NewTicketHandler(authService *service.AuthService, workspaceService *service.WorkspaceService, forwardBaseDomain string) *TicketHandler {
+	return &TicketHandler{
+		authService:       authService,
+		workspaceService:  workspaceService,
+		forwardBaseDomain: forwardBaseDomain,
+	}
+}
+
+// CreateTicketRequest represents a request to mint a ticket
+type CreateTicketRequest struct {
+	Kind        domain.TicketKind `json:"kind" binding:"required"`
+	WorkspaceID string            `json:"workspace_id" binding:"required"`
+	Port        int               `json:"port,omitempty"` // Required for forward and tunnel tickets
+}
+
+// Create handles POST /api/tickets - Mint a short-lived ticket
+//
+// The ticket is passed as ?vibox_ticket= to /ws/terminal/:id, /ws/tunnel/:id/:port
+// or /forward/:id/:port/ (and the forward subdomains), and is only valid there.
+// Access to the workspace is still checked when the ticket is used. A used
+// forward ticket is exchanged for a cookie bound to the same port, so the
+// forwarded page can load its assets.
+func (h *TicketHandler) Create(c *gin.Context) {
+	var req CreateTicketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Invalid create ticket request", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	if scope, ok := ticketScopes[req.Kind]; ok && !middleware.AllowsScope(c, scope) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "API key lacks the required scope",
+			"code":    "FORBIDDEN",
+			"details": "required scope: " + scope,
+		})
+		return
+	}
+
+	if _, _, ok := authorizeWorkspace(c, h.workspaceService, req.WorkspaceID, domain.WorkspaceRoleViewer); !ok {
+		return
+	}
+
+	target := domain.TicketTarget{Kind: req.Kind, WorkspaceID: req.WorkspaceID, Port: req.Port}
+	ticket, expiresAt, err := h.authService.IssueTicket(userID(middleware.CurrentUser(c)), target)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTicketRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+				"code":  "INVALID_REQUEST",
+			})
+			return
+		}
+		utils.Error("Failed to issue ticket", "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to issue ticket",
+			"code":  "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"ticket":     ticket,
+		"expires_at": expiresAt,
+	})
+}
+
+// Forward handles GET /api/tickets/forward - Send the browser to a forward
+// subdomain with a forward ticket
+//
+// Login cookies stay on the ViBox host, so a forward subdomain without a
+// forward cookie redirects here; the ticket is exchanged for a cookie there.
+//
+// Query parameters:
+//   - workspace_id, port: the forwarded port
+//   - path: path and query to open on the subdomain (default "/")
+func (h *TicketHandler) Forward(c *gin.Context) {
+	workspaceID := c.Query("workspace_id")
+	port, err := strconv.Atoi(c.Query("port"))
+	target, parseErr := url.Parse(c.DefaultQuery("path", "/"))
+	if workspaceID == "" || err != nil || port < 1 || port > 65535 ||
+		parseErr != nil || target.Scheme != "" || target.Host != "" || !strings.HasPrefix(target.Path, "/") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: workspace_id, port and an absolute path are required",
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	if !middleware.AllowsScope(c, domain.ScopeForward) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "API key lacks the required scope",
+			"code":    "FORBIDDEN",
+			"details": "required scope: " + domain.ScopeForward,
+		})
+		return
+	}
+	if _, _, ok := authorizeWorkspace(c, h.workspaceService, workspaceID, domain.WorkspaceRoleViewer); !ok {
+		return
+	}
+
+	ticket, _, err := h.authService.IssueTicket(userID(middleware.CurrentUser(c)),
+		domain.TicketTarget{Kind: domain.TicketKindForward, WorkspaceID: workspaceID, Port: port})
+	if err != nil {
+		utils.Error("Failed to issue forward ticket", "workspace_id", workspaceID, "port", port, "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to issue ticket",
+			"code":  "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	query := target.Query()
+	query.Set(middleware.TicketQueryParam, ticket)
+	target.RawQuery = query.Encode()
+	target.Scheme = requestScheme(c)
+	target.Host = strconv.Itoa(port) + "-" + workspaceID + "." + h.forwardBaseDomain
+	c.Redirect(http.StatusFound, target.String())
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/service"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// TunnelHandler handles TCP tunnel WebSocket connections
+type TunnelHandler struct {
+	tunnelService    *service.TunnelService
+	workspaceService *service.WorkspaceService
+	dockerService    *service.DockerService
+}
+
+// NewTunnelHandler creates a new tunnel handler
+func NewTunnelHandler(
+	tunnelService *service.TunnelService,
+	workspaceService *service.WorkspaceService,
+	dockerService *service.DockerService,
+) *TunnelHandler {
+	return &TunnelHandler{
+		tunnelService:    tunnelService,
+		workspaceService: workspaceService,
+		dockerService:    dockerService,
+	}
+}
+
+// Connect handles GET /ws/tunnel/:id/:port - Tunnel a raw TCP connection over WebSocket
+//
+// Binary messages carry the TCP byte stream in both directions; text messages
+// are ignored. The TCP connection is opened before the upgrade, so an unreachable
+// port is reported as a plain HTTP error (502).
+//
+// Example (using the built-in client):
+//
+//	vibox tunnel -server https://vibox.example.com -token $API_TOKEN ws-abc 5432
+//	psql -h 127.0.0.1 -p 5432
+func (h *TunnelHandler) Connect(c *gin.Context) {
+	workspaceID := c.Param("id")
+	portStr := c.Param("port")
+
+	// 1. Parse port number
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		utils.Warn("Invalid tunnel port", "workspace_id", workspaceID, "port", portStr)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid port number",
+			"code":  "INVALID_REQUEST",
+			"details": gin.H{
+				"port": portStr,
+			},
+		})
+		return
+	}
+
+	// 2. Verify workspace exists and the user may use it
+	workspace, _, ok := authorizeWorkspace(c, h.workspaceService, workspaceID, domain.WorkspaceRoleEditor)
+	if !ok {
+		return
+	}
+
+	// 3. Check container status
+	status, err := h.dockerService.GetContainerStatus(c.Request.Context(), workspace.ContainerID)
+	if err != nil || status != "running" {
+		utils.Warn("Tunnel connection failed: container not running", "workspace_id", workspaceID, "status", status)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Container is not running",
+			"code":  "CONTAINER_NOT_RUNNING",
+			"details": gin.H{
+				"workspace_id": workspaceID,
+				"status":       status,
+			},
+		})
+		return
+	}
+
+	// 4. Connect to the container port
+	conn, err := h.tunnelService.Dial(c.Request.Context(), workspace.ContainerID, port)
+	if err != nil {
+		utils.Warn("Tunnel connection failed", "workspace_id", workspaceID, "port", port, "error", err.Error())
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": "Failed to connect to container port: " + err.Error(),
+			"code":  "PROXY_ERROR",
+		})
+		return
+	}
+
+	// 5. Upgrade to WebSocket
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		utils.Error("Failed to upgrade tunnel to WebSocket", "workspace_id", workspaceID, "error", err.Error())
+		conn.Close()
+		// Response already sent by upgrader
+		return
+	}
+	defer ws.Close()
+
+	utils.Info("Tunnel opened", "workspace_id", workspaceID, "port", port, "remote", c.ClientIP())
+	if err := h.tunnelService.Serve(ws, conn); err != nil {
+		utils.Warn("Tunnel closed with error", "workspace_id", workspaceID, "port", port, "error", err.Error())
+		return
+	}
+	utils.Info("Tunnel closed", "workspace_id", workspaceID, "port", port)
+}
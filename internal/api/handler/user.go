@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/1PercentSync/vibox/internal/api/middleware"
+	"github.com/1PercentSync/vibox/internal/service"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// UserHandler handles user account management
+type UserHandler struct {
+	authService *service.AuthService
+}
+
+// NewUserHandler creates a new user handler
+func NewUserHandler(authService *service.AuthService) *UserHandler {
+	return &UserHandler{
+		authService: authService,
+	}
+}
+
+// ChangePasswordRequest represents a request to set a user's password
+type ChangePasswordRequest struct {
+	Password        string `json:"password" binding:"required"`
+	CurrentPassword string `json:"current_password"` // required when changing your own password
+}
+
+// SetGitIdentityRequest represents a request to set a user's git author identity
+type SetGitIdentityRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// Create handles POST /api/users - Create a user (admin only)
+func (h *UserHandler) Create(c *gin.Context) {
+	var req service.CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Invalid create user request", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	user, err := h.authService.CreateUser(req)
+	if err != nil {
+		h.respondError(c, err, "Failed to create user")
+		return
+	}
+
+	c.JSON(http.StatusCreated, newUserResponse(user))
+}
+
+// List handles GET /api/users - List all users (admin only)
+func (h *UserHandler) List(c *gin.Context) {
+	users, err := h.authService.ListUsers()
+	if err != nil {
+		utils.Error("Failed to list users", "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list users",
+			"code":  "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	response := make([]userResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, newUserResponse(user))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Delete handles DELETE /api/users/:id - Delete a user (admin only)
+func (h *UserHandler) Delete(c *gin.Context) {
+	userID := c.Param("id")
+
+	if err := h.authService.DeleteUser(userID); err != nil {
+		h.respondError(c, err, "Failed to delete user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User deleted successfully",
+	})
+}
+
+// ChangePassword handles PUT /api/users/:id/password - Set a user's password
+// Users may change their own password after confirming the current one;
+// admins may change anyone else's without it. The user's other sessions are ended.
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	userID := c.Param("id")
+
+	current := middleware.CurrentUser(c)
+	if current == nil || (current.ID != userID && !current.IsAdmin()) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "You can only change your own password",
+			"code":  "FORBIDDEN",
+		})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Invalid change password request", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	keepToken := ""
+	if current.ID == userID {
+		// A stolen session alone must not be enough to take over the account
+		if err := h.authService.VerifyPassword(userID, req.CurrentPassword); err != nil {
+			h.respondError(c, err, "Failed to change password")
+			return
+		}
+		keepToken = middleware.SessionToken(c)
+	}
+	if err := h.authService.ChangePassword(userID, req.Password, keepToken); err != nil {
+		h.respondError(c, err, "Failed to change password")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password changed successfully",
+	})
+}
+
+// SetGitIdentity handles PUT /api/users/:id/git-identity - Set the name and
+// email configured for git in the user's workspaces. Users may set their own;
+// admins may set anyone's. It applies to containers created afterwards.
+func (h *UserHandler) SetGitIdentity(c *gin.Context) {
+	userID := c.Param("id")
+
+	current := middleware.CurrentUser(c)
+	if current == nil || (current.ID != userID && !current.IsAdmin()) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "You can only change your own git identity",
+			"code":  "FORBIDDEN",
+		})
+		return
+	}
+
+	var req SetGitIdentityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Invalid git identity request", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	user, err := h.authService.SetGitIdentity(userID, req.Name, req.Email)
+	if err != nil {
+		h.respondError(c, err, "Failed to set git identity")
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// respondError maps user service errors to HTTP responses
+func (h *UserHandler) respondError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+			"code":  "NOT_FOUND",
+		})
+	case errors.Is(err, service.ErrInvalidUser):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+	case errors.Is(err, service.ErrInvalidCredentials):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Current password is incorrect",
+			"code":  "FORBIDDEN",
+		})
+	case errors.Is(err, service.ErrUsernameTaken), errors.Is(err, service.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+			"code":  "CONFLICT",
+		})
+	default:
+		utils.Error(message, "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": message,
+			"code":  "INTERNAL_ERROR",
+		})
+	}
+}
@@ -1,9 +1,14 @@
 package handler
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/1PercentSync/vibox/internal/api/middleware"
+	"github.com/1PercentSync/vibox/internal/domain"
 	"github.com/1PercentSync/vibox/internal/service"
 	"github.com/1PercentSync/vibox/pkg/utils"
 	"github.com/gin-gonic/gin"
@@ -11,16 +16,23 @@ import (
 
 // WorkspaceHandler handles workspace-related API requests
 type WorkspaceHandler struct {
-	service *service.WorkspaceService
+	service     *service.WorkspaceService
+	authService *service.AuthService
 }
 
 // NewWorkspaceHandler creates a new workspace handler
-func NewWorkspaceHandler(service *service.WorkspaceService) *WorkspaceHandler {
+func NewWorkspaceHandler(service *service.WorkspaceService, authService *service.AuthService) *WorkspaceHandler {
 	return &WorkspaceHandler{
-		service: service,
+		service:     service,
+		authService: authService,
 	}
 }
 
+// SetMemberRequest represents a request to grant a user a role on a workspace
+type SetMemberRequest struct {
+	Role domain.WorkspaceRole `json:"role" binding:"required"`
+}
+
 // Create handles POST /api/workspaces - Create a new workspace
 func (h *WorkspaceHandler) Create(c *gin.Context) {
 	var req service.CreateWorkspaceRequest
@@ -35,9 +47,22 @@ func (h *WorkspaceHandler) Create(c *gin.Context) {
 		return
 	}
 
+	// The creator owns the workspace
+	if user := middleware.CurrentUser(c); user != nil {
+		req.Owner = user.ID
+	}
+
 	// Create workspace
 	workspace, err := h.service.CreateWorkspace(c.Request.Context(), req)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidWorkspaceConfig) {
+			utils.Warn("Invalid create workspace request", "error", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request: " + err.Error(),
+				"code":  "INVALID_REQUEST",
+			})
+			return
+		}
 		utils.Error("Failed to create workspace", "error", err.Error(), "name", req.Name)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to create workspace: " + err.Error(),
@@ -47,10 +72,153 @@ func (h *WorkspaceHandler) Create(c *gin.Context) {
 	}
 
 	utils.Info("Workspace created successfully", "id", workspace.ID, "name", workspace.Name)
+	middleware.SetAuditWorkspace(c, workspace.ID)
+	setWorkspaceETag(c, workspace)
+	c.JSON(http.StatusCreated, workspace)
+}
+
+// Clone handles POST /api/workspaces/:id/clone - Create a new workspace from
+// an existing one (editor role on the source; the caller owns the clone)
+func (h *WorkspaceHandler) Clone(c *gin.Context) {
+	id := c.Param("id")
+
+	// The body is optional; an empty one clones the configuration only
+	var req service.CloneWorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.Warn("Invalid clone workspace request", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	if _, _, ok := authorizeWorkspace(c, h.service, id, domain.WorkspaceRoleEditor); !ok {
+		return
+	}
+
+	if user := middleware.CurrentUser(c); user != nil {
+		req.Owner = user.ID
+	}
+
+	workspace, err := h.service.CloneWorkspace(c.Request.Context(), id, req)
+	if err != nil {
+		utils.Error("Failed to clone workspace", "id", id, "error", err.Error())
+		if errors.Is(err, service.ErrWorkspaceNotReady) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Workspace has no container to copy the filesystem from",
+				"code":  "CONFLICT",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to clone workspace: " + err.Error(),
+			"code":  "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	utils.Info("Workspace cloned successfully", "source_id", id, "id", workspace.ID)
+	middleware.SetAuditWorkspace(c, workspace.ID)
+	setWorkspaceETag(c, workspace)
+	c.JSON(http.StatusCreated, workspace.Redacted())
+}
+
+// maxDefinitionSize bounds the size of an imported workspace definition
+const maxDefinitionSize = 1 << 20
+
+// Export handles GET /api/workspaces/:id/export - Get the portable definition of a workspace
+//
+// Query parameters (optional):
+//   - format: "yaml" (default) or "json"
+func (h *WorkspaceHandler) Export(c *gin.Context) {
+	id := c.Param("id")
+
+	format := c.DefaultQuery("format", "yaml")
+	if format != "yaml" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: format must be yaml or json",
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	if _, _, ok := authorizeWorkspace(c, h.service, id, domain.WorkspaceRoleViewer); !ok {
+		return
+	}
+
+	definition, err := h.service.ExportDefinition(id)
+	if err != nil {
+		utils.Error("Failed to export workspace", "id", id, "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to export workspace: " + err.Error(),
+			"code":  "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, definition)
+		return
+	}
+	c.YAML(http.StatusOK, definition)
+}
+
+// Import handles POST /api/workspaces/import - Create a workspace from a
+// definition (YAML or JSON, as returned by export)
+func (h *WorkspaceHandler) Import(c *gin.Context) {
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxDefinitionSize))
+	if err != nil {
+		utils.Warn("Failed to read workspace definition", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	definition, err := service.ParseWorkspaceDefinition(data)
+	if err != nil {
+		utils.Warn("Invalid workspace definition", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	// The importer owns the workspace
+	var owner string
+	if user := middleware.CurrentUser(c); user != nil {
+		owner = user.ID
+	}
+
+	workspace, err := h.service.ImportDefinition(c.Request.Context(), definition, owner)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidWorkspaceConfig) {
+			utils.Warn("Invalid workspace definition", "error", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request: " + err.Error(),
+				"code":  "INVALID_REQUEST",
+			})
+			return
+		}
+		utils.Error("Failed to import workspace", "error", err.Error(), "name", definition.Name)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to import workspace: " + err.Error(),
+			"code":  "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	utils.Info("Workspace imported successfully", "id", workspace.ID, "name", workspace.Name)
+	middleware.SetAuditWorkspace(c, workspace.ID)
+	setWorkspaceETag(c, workspace)
 	c.JSON(http.StatusCreated, workspace)
 }
 
-// List handles GET /api/workspaces - List all workspaces
+// List handles GET /api/workspaces - List the workspaces visible to the user
+// (admins see all workspaces)
 func (h *WorkspaceHandler) List(c *gin.Context) {
 	workspaces, err := h.service.ListWorkspaces()
 	if err != nil {
@@ -62,35 +230,59 @@ func (h *WorkspaceHandler) List(c *gin.Context) {
 		return
 	}
 
-	utils.Debug("Listed workspaces", "count", len(workspaces))
-	c.JSON(http.StatusOK, workspaces)
+	user := middleware.CurrentUser(c)
+	redacted := make([]*domain.Workspace, 0, len(workspaces))
+	for _, workspace := range workspaces {
+		if workspace.RoleOf(user) == "" {
+			continue
+		}
+		redacted = append(redacted, workspace.Redacted())
+	}
+
+	utils.Debug("Listed workspaces", "count", len(redacted))
+	c.JSON(http.StatusOK, redacted)
 }
 
 // Get handles GET /api/workspaces/:id - Get workspace by ID
+// The ETag header carries the workspace version; send it back in If-Match to
+// make a change conditional, or in If-None-Match to skip unchanged responses.
 func (h *WorkspaceHandler) Get(c *gin.Context) {
 	id := c.Param("id")
 
-	workspace, err := h.service.GetWorkspace(id)
-	if err != nil {
-		utils.Warn("Workspace not found", "id", id)
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Workspace not found",
-			"code":  "NOT_FOUND",
-		})
+	workspace, _, ok := authorizeWorkspace(c, h.service, id, domain.WorkspaceRoleViewer)
+	if !ok {
+		return
+	}
+
+	setWorkspaceETag(c, workspace)
+	if c.GetHeader("If-None-Match") == workspaceETag(workspace) {
+		c.Status(http.StatusNotModified)
 		return
 	}
 
 	utils.Debug("Retrieved workspace", "id", id, "name", workspace.Name)
-	c.JSON(http.StatusOK, workspace)
+	c.JSON(http.StatusOK, workspace.Redacted())
 }
 
-// Delete handles DELETE /api/workspaces/:id - Delete workspace
+// Delete handles DELETE /api/workspaces/:id - Delete workspace (owner only)
 func (h *WorkspaceHandler) Delete(c *gin.Context) {
 	id := c.Param("id")
 
-	err := h.service.DeleteWorkspace(c.Request.Context(), id)
+	workspace, _, ok := authorizeWorkspace(c, h.service, id, domain.WorkspaceRoleOwner)
+	if !ok {
+		return
+	}
+	ctx, ok := ifMatch(c, workspace)
+	if !ok {
+		return
+	}
+
+	err := h.service.DeleteWorkspace(ctx, id)
 	if err != nil {
 		utils.Error("Failed to delete workspace", "id", id, "error", err.Error())
+		if respondVersionError(c, h.service, id, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to delete workspace: " + err.Error(),
 			"code":  "DOCKER_ERROR",
@@ -105,6 +297,109 @@ func (h *WorkspaceHandler) Delete(c *gin.Context) {
 	})
 }
 
+// Update handles PATCH /api/workspaces/:id - Change workspace settings
+//
+// Only the fields present are changed. The name, ports and resource limits
+// apply immediately; image, scripts, env and labels are listed in
+// pending_changes until POST /api/workspaces/:id/apply recreates the container.
+//
+// Example: {"memory_limit": 1073741824, "env": {"NODE_ENV": "development"}}
+func (h *WorkspaceHandler) Update(c *gin.Context) {
+	id := c.Param("id")
+
+	var req service.UpdateWorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Invalid update workspace request", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	// Repositories may be cloned with the owner's git credentials, so only
+	// the owner can choose where they point
+	role := domain.WorkspaceRoleEditor
+	if req.Repositories != nil {
+		role = domain.WorkspaceRoleOwner
+	}
+	workspace, _, ok := authorizeWorkspace(c, h.service, id, role)
+	if !ok {
+		return
+	}
+	ctx, ok := ifMatch(c, workspace)
+	if !ok {
+		return
+	}
+
+	workspace, err := h.service.UpdateWorkspace(ctx, id, req)
+	if err != nil {
+		if respondVersionError(c, h.service, id, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidWorkspaceConfig) {
+			utils.Warn("Invalid update workspace request", "id", id, "error", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request: " + err.Error(),
+				"code":  "INVALID_REQUEST",
+			})
+			return
+		}
+		utils.Error("Failed to update workspace", "id", id, "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update workspace: " + err.Error(),
+			"code":  "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	utils.Info("Workspace updated successfully", "id", id, "pending_changes", workspace.PendingChanges)
+	setWorkspaceETag(c, workspace)
+	c.JSON(http.StatusOK, workspace.Redacted())
+}
+
+// Apply handles POST /api/workspaces/:id/apply - Recreate the container with
+// the pending configuration changes. Like a reset, this discards changes made
+// inside the container.
+func (h *WorkspaceHandler) Apply(c *gin.Context) {
+	id := c.Param("id")
+
+	workspace, _, ok := authorizeWorkspace(c, h.service, id, domain.WorkspaceRoleEditor)
+	if !ok {
+		return
+	}
+	ctx, ok := ifMatch(c, workspace)
+	if !ok {
+		return
+	}
+
+	applied, err := h.service.ApplyChanges(ctx, id)
+	if err != nil {
+		utils.Error("Failed to apply workspace changes", "id", id, "error", err.Error())
+		if respondVersionError(c, h.service, id, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to apply changes: " + err.Error(),
+			"code":  "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	message := "No pending changes"
+	if applied {
+		message = "Recreating workspace with the pending changes"
+	}
+
+	workspace, _ = h.service.GetWorkspace(id)
+	setWorkspaceETag(c, workspace)
+	c.JSON(http.StatusOK, gin.H{
+		"message":   message,
+		"applied":   applied,
+		"workspace": workspace.Redacted(),
+	})
+}
+
 // UpdatePorts handles PUT /api/workspaces/:id/ports - Update workspace port mappings
 func (h *WorkspaceHandler) UpdatePorts(c *gin.Context) {
 	id := c.Param("id")
@@ -122,9 +417,21 @@ func (h *WorkspaceHandler) UpdatePorts(c *gin.Context) {
 		return
 	}
 
-	err := h.service.UpdatePorts(c.Request.Context(), id, req.Ports)
+	workspace, _, ok := authorizeWorkspace(c, h.service, id, domain.WorkspaceRoleEditor)
+	if !ok {
+		return
+	}
+	ctx, ok := ifMatch(c, workspace)
+	if !ok {
+		return
+	}
+
+	err := h.service.UpdatePorts(ctx, id, req.Ports)
 	if err != nil {
 		utils.Error("Failed to update workspace ports", "id", id, "error", err.Error())
+		if respondVersionError(c, h.service, id, err) {
+			return
+		}
 		// Check if workspace not found
 		if strings.Contains(err.Error(), "not found") {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -141,19 +448,115 @@ func (h *WorkspaceHandler) UpdatePorts(c *gin.Context) {
 	}
 
 	// Get updated workspace to return
-	workspace, _ := h.service.GetWorkspace(id)
+	workspace, _ = h.service.GetWorkspace(id)
 
 	utils.Info("Workspace ports updated successfully", "id", id)
-	c.JSON(http.StatusOK, workspace)
+	setWorkspaceETag(c, workspace)
+	c.JSON(http.StatusOK, workspace.Redacted())
+}
+
+// UpdatePortSettings handles PUT /api/workspaces/:id/ports/:port/settings - Set a port's access policy (owner only)
+//
+// Example: expose a webhook receiver publicly, POST only, at most 60 requests per minute per client:
+//
+//	{"visibility": "public", "allowed_methods": ["POST"], "rate_limit": {"requests_per_minute": 60}}
+func (h *WorkspaceHandler) UpdatePortSettings(c *gin.Context) {
+	h.updatePortSettings(c, true)
+}
+
+// DeletePortSettings handles DELETE /api/workspaces/:id/ports/:port/settings - Reset a port to private
+func (h *WorkspaceHandler) DeletePortSettings(c *gin.Context) {
+	h.updatePortSettings(c, false)
+}
+
+// updatePortSettings sets (or with set=false removes) the settings of a port
+func (h *WorkspaceHandler) updatePortSettings(c *gin.Context, set bool) {
+	id := c.Param("id")
+	portStr := c.Param("port")
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid port number",
+			"code":  "INVALID_REQUEST",
+			"details": gin.H{
+				"port": portStr,
+			},
+		})
+		return
+	}
+
+	workspace, _, ok := authorizeWorkspace(c, h.service, id, domain.WorkspaceRoleOwner)
+	if !ok {
+		return
+	}
+	ctx, ok := ifMatch(c, workspace)
+	if !ok {
+		return
+	}
+
+	var req *service.PortSettingsRequest
+	if set {
+		req = &service.PortSettingsRequest{}
+		if err := c.ShouldBindJSON(req); err != nil {
+			utils.Warn("Invalid port settings request", "error", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request: " + err.Error(),
+				"code":  "INVALID_REQUEST",
+			})
+			return
+		}
+	}
+
+	workspace, err = h.service.UpdatePortSettings(ctx, id, port, req)
+	if err != nil {
+		utils.Warn("Failed to update port settings", "id", id, "port", port, "error", err.Error())
+		if respondVersionError(c, h.service, id, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidPortSettings):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+				"code":  "INVALID_REQUEST",
+			})
+		case strings.Contains(err.Error(), "not found"):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Workspace not found",
+				"code":  "NOT_FOUND",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to update port settings: " + err.Error(),
+				"code":  "INTERNAL_ERROR",
+			})
+		}
+		return
+	}
+
+	setWorkspaceETag(c, workspace)
+	c.JSON(http.StatusOK, workspace.Redacted())
 }
 
 // ResetWorkspace handles POST /api/workspaces/:id/reset - Reset workspace to initial state
 func (h *WorkspaceHandler) ResetWorkspace(c *gin.Context) {
 	id := c.Param("id")
 
-	err := h.service.ResetWorkspace(c.Request.Context(), id)
+	workspace, _, ok := authorizeWorkspace(c, h.service, id, domain.WorkspaceRoleEditor)
+	if !ok {
+		return
+	}
+	ctx, ok := ifMatch(c, workspace)
+	if !ok {
+		return
+	}
+
+	err := h.service.ResetWorkspace(ctx, id)
 	if err != nil {
 		utils.Error("Failed to reset workspace", "id", id, "error", err.Error())
+		if respondVersionError(c, h.service, id, err) {
+			return
+		}
 		// Check if workspace not found
 		if strings.Contains(err.Error(), "not found") {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -170,11 +573,105 @@ func (h *WorkspaceHandler) ResetWorkspace(c *gin.Context) {
 	}
 
 	// Get workspace to return
-	workspace, _ := h.service.GetWorkspace(id)
+	workspace, _ = h.service.GetWorkspace(id)
 
 	utils.Info("Workspace reset initiated successfully", "id", id)
+	setWorkspaceETag(c, workspace)
 	c.JSON(http.StatusOK, gin.H{
 		"message":   "Workspace reset successfully",
-		"workspace": workspace,
+		"workspace": workspace.Redacted(),
 	})
 }
+
+// SetMember handles PUT /api/workspaces/:id/members/:userId - Grant a user a role (owner only)
+func (h *WorkspaceHandler) SetMember(c *gin.Context) {
+	id := c.Param("id")
+	memberID := c.Param("userId")
+
+	var req SetMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Warn("Invalid set member request", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	workspace, _, ok := authorizeWorkspace(c, h.service, id, domain.WorkspaceRoleOwner)
+	if !ok {
+		return
+	}
+	ctx, ok := ifMatch(c, workspace)
+	if !ok {
+		return
+	}
+
+	if _, err := h.authService.GetUser(memberID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "User not found",
+			"code":  "NOT_FOUND",
+		})
+		return
+	}
+
+	workspace, err := h.service.SetMember(ctx, id, memberID, req.Role)
+	if err != nil {
+		h.respondMemberError(c, id, err)
+		return
+	}
+
+	utils.Info("Workspace member set", "id", id, "user_id", memberID, "role", req.Role)
+	setWorkspaceETag(c, workspace)
+	c.JSON(http.StatusOK, workspace.Redacted())
+}
+
+// RemoveMember handles DELETE /api/workspaces/:id/members/:userId - Revoke a user's access (owner only)
+func (h *WorkspaceHandler) RemoveMember(c *gin.Context) {
+	id := c.Param("id")
+	memberID := c.Param("userId")
+
+	workspace, _, ok := authorizeWorkspace(c, h.service, id, domain.WorkspaceRoleOwner)
+	if !ok {
+		return
+	}
+	ctx, ok := ifMatch(c, workspace)
+	if !ok {
+		return
+	}
+
+	workspace, err := h.service.RemoveMember(ctx, id, memberID)
+	if err != nil {
+		h.respondMemberError(c, id, err)
+		return
+	}
+
+	utils.Info("Workspace member removed", "id", id, "user_id", memberID)
+	setWorkspaceETag(c, workspace)
+	c.JSON(http.StatusOK, workspace.Redacted())
+}
+
+// respondMemberError maps member update errors to HTTP responses
+func (h *WorkspaceHandler) respondMemberError(c *gin.Context, id string, err error) {
+	utils.Warn("Failed to update workspace members", "id", id, "error", err.Error())
+	if respondVersionError(c, h.service, id, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrInvalidMember):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+	case strings.Contains(err.Error(), "not found"):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Workspace not found",
+			"code":  "NOT_FOUND",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update members: " + err.Error(),
+			"code":  "INTERNAL_ERROR",
+		})
+	}
+}
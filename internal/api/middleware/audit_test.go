@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// auditLog collects recorded entries
+type auditLog []domain.AuditEntry
+
+func (l *auditLog) Record(entry domain.AuditEntry) {
+	*l = append(*l, entry)
+}
+
+func TestAudit(t *testing.T) {
+	utils.InitLogger()
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		status  int
+		outcome domain.AuditOutcome
+	}{
+		{"success", http.StatusOK, domain.AuditOutcomeSuccess},
+		{"denied", http.StatusForbidden, domain.AuditOutcomeDenied},
+		{"failure", http.StatusInternalServerError, domain.AuditOutcomeFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log auditLog
+			router := gin.New()
+			router.Use(AuthMiddleware(staticToken("test-token")))
+			router.PUT("/api/workspaces/:id/ports/:port/settings", Audit(&log, domain.AuditActionPortSettingsUpdate), func(c *gin.Context) {
+				SetAuditDetails(c, "visibility=public")
+				c.Status(tt.status)
+			})
+
+			req, _ := http.NewRequest("PUT", "/api/workspaces/ws-1/ports/3000/settings", nil)
+			req.Header.Set("Authorization", "Bearer test-token")
+			req.RemoteAddr = "192.0.2.7:5555"
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if len(log) != 1 {
+				t.Fatalf("Expected 1 audit entry, got %d", len(log))
+			}
+			entry := log[0]
+			if entry.Outcome != tt.outcome || entry.Status != tt.status {
+				t.Errorf("Expected outcome %s with status %d, got %s/%d", tt.outcome, tt.status, entry.Outcome, entry.Status)
+			}
+			if entry.ActorID != "user-test" || entry.Actor != "test" || entry.SourceIP != "192.0.2.7" {
+				t.Errorf("Unexpected actor or source: %+v", entry)
+			}
+			if entry.WorkspaceID != "ws-1" || entry.Target != "port=3000" || entry.Details != "visibility=public" {
+				t.Errorf("Unexpected target: %+v", entry)
+			}
+		})
+	}
+}
+
+func TestAudit_CreatedWorkspace(t *testing.T) {
+	utils.InitLogger()
+	gin.SetMode(gin.TestMode)
+
+	var log auditLog
+	router := gin.New()
+	router.POST("/api/workspaces", Audit(&log, domain.AuditActionWorkspaceCreate), func(c *gin.Context) {
+		SetAuditWorkspace(c, "ws-new")
+		c.Status(http.StatusCreated)
+	})
+	router.POST("/api/workspaces/:id/clone", Audit(&log, domain.AuditActionWorkspaceClone), func(c *gin.Context) {
+		SetAuditWorkspace(c, "ws-copy")
+		c.Status(http.StatusCreated)
+	})
+
+	for _, path := range []string{"/api/workspaces", "/api/workspaces/ws-src/clone"} {
+		req, _ := http.NewRequest("POST", path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if len(log) != 2 {
+		t.Fatalf("Expected 2 audit entries, got %d", len(log))
+	}
+	if log[0].WorkspaceID != "ws-new" || log[0].Target != "" {
+		t.Errorf("Expected the created workspace, got %+v", log[0])
+	}
+	if log[1].WorkspaceID != "ws-copy" || log[1].Target != "id=ws-src" {
+		t.Errorf("Expected the clone with its source in the target, got %+v", log[1])
+	}
+}
+
+func TestAudit_SourceIPFromTrustedProxiesOnly(t *testing.T) {
+	utils.InitLogger()
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		trusted  []string
+		expected string
+	}{
+		{"no trusted proxies", nil, "192.0.2.7"},
+		{"request from a trusted proxy", []string{"192.0.2.0/24"}, "203.0.113.9"},
+		{"request from another address", []string{"10.0.0.0/8"}, "192.0.2.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log auditLog
+			router := gin.New()
+			if err := router.SetTrustedProxies(tt.trusted); err != nil {
+				t.Fatalf("SetTrustedProxies failed: %v", err)
+			}
+			router.POST("/api/auth/login", Audit(&log, domain.AuditActionLogin), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req, _ := http.NewRequest("POST", "/api/auth/login", nil)
+			req.RemoteAddr = "192.0.2.7:5555"
+			req.Header.Set("X-Forwarded-For", "203.0.113.9")
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if len(log) != 1 || log[0].SourceIP != tt.expected {
+				t.Errorf("Expected source IP %s, got %+v", tt.expected, log)
+			}
+		})
+	}
+}
@@ -2,55 +2,378 @@ package middleware
 
 import (
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware validates the API token from Cookie or query parameter
+const (
+	// SessionCookieName holds the opaque login session token
+	SessionCookieName = domain.SessionCookieName
+	// LegacyTokenCookieName held the raw API token before user accounts existed
+	LegacyTokenCookieName = domain.LegacyTokenCookieName
+	// TokenHeader carries a credential for non-browser clients
+	TokenHeader = "X-ViBox-Token"
+	// TicketQueryParam carries a signed ticket (see POST /api/tickets)
+	TicketQueryParam = "vibox_ticket"
+	// ForwardCookieName keeps a forwarded page authenticated after it was opened
+	// with a forward ticket (scoped to the forwarded port's path or host)
+	ForwardCookieName = domain.ForwardCookieName
+	// userContextKey is the gin context key of the authenticated user
+	userContextKey = "user"
+	// apiKeyContextKey is the gin context key of the API key the request was authenticated with
+	apiKeyContextKey = "api_key"
+	// ticketTargetContextKey overrides the ticket target derived from the route
+	ticketTargetContextKey = "ticket_target"
+)
+
+// Authenticator resolves a credential (a session token, an API key, a ticket or
+// the API token) to a user
+type Authenticator interface {
+	Authenticate(token string) (*domain.User, error)
+	AuthenticateAPIKey(key string) (*domain.User, *domain.APIKey, error)
+	AuthenticateTicket(ticket string, target domain.TicketTarget) (*domain.User, error)
+	// IssueForwardCookie mints the ticket a used forward ticket is exchanged for
+	IssueForwardCookie(userID string, target domain.TicketTarget) (string, time.Time, error)
+	// AllowQueryToken reports whether long-lived credentials are accepted in ?token=
+	AllowQueryToken() bool
+}
+
+// requestToken is a credential found in a request
+type requestToken struct {
+	value      string
+	fromBearer bool
+	ticket     bool
+	fromCookie bool // A ticket from the forward cookie
+	login      bool // A login cookie, attached by the browser to same-site requests
+}
+
+// AuthMiddleware authenticates the request and stores the user in the context
+// (see CurrentUser)
 //
 // Supported authentication methods (in priority order):
-// 1. Cookie: vibox-token (recommended for browser access)
-// 2. Query parameter: ?token=<token> (for WebSocket connections only)
+// 1. Header: X-ViBox-Token: <API key, session or API token>
+// 2. Header: Authorization: Bearer <API key, session or API token>
+// 3. Cookie: vibox-session (set by POST /api/auth/login)
+// 4. Cookie: vibox-token (API token; cookies from before user accounts)
+// 5. Query parameter: ?vibox_ticket=<signed ticket> (terminal, tunnel and forward routes only)
+// 6. Cookie: vibox-forward (set when a forward ticket is used; that forwarded port only)
+// 7. Query parameter: ?token=<session or API token> (only if ALLOW_QUERY_TOKEN is enabled)
+//
+// Requests authenticated with an API key are limited to the key's scopes (see RequireScope).
+// The login cookies (3, 4) do not authenticate state-changing requests or
+// WebSocket upgrades sent from another origin (see crossOriginWrite).
 //
 // For browser requests without authentication:
 // - HTML requests (Accept: text/html) → Redirect to /login
 // - API requests (Accept: application/json) → Return 401 JSON
 //
-// Note: Cookie authentication is the primary method. Query parameter is only
-// supported for WebSocket connections where cookies are difficult to manage.
-func AuthMiddleware(requiredToken string) gin.HandlerFunc {
+// Note: Cookies are the primary method for browsers and headers for scripts.
+// Where neither can be set (WebSocket connections, links), a short-lived ticket
+// bound to the workspace and route kind is used instead of a long-lived token.
+func AuthMiddleware(auth Authenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 1. Try to get token from Cookie (preferred)
-		token, err := c.Cookie("vibox-token")
-		if err == nil && token == requiredToken {
+		if IsAuthenticated(c, auth) {
 			c.Next()
 			return
 		}
 
-		// 2. Try to get token from query parameter (for WebSocket only)
-		token = c.Query("token")
-		if token != "" && token == requiredToken {
+		rejectUnauthorized(c)
+	}
+}
+
+// RequireAdmin rejects requests whose authenticated user is not an admin
+// Must run after AuthMiddleware.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user := CurrentUser(c); user != nil && user.IsAdmin() {
 			c.Next()
 			return
 		}
 
-		// Authentication failed - handle based on request type
-		accept := c.GetHeader("Accept")
-		isHTMLRequest := strings.Contains(accept, "text/html")
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Admin privileges required",
+			"code":  "FORBIDDEN",
+		})
+		c.Abort()
+	}
+}
 
-		if isHTMLRequest {
-			// Browser request → Redirect to login page
-			c.Redirect(http.StatusFound, "/login")
+// RequireScope rejects requests authenticated with an API key that lacks the scope.
+// Requests authenticated by a login session or the API token are not restricted.
+// Must run after AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !AllowsScope(c, scope) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "API key lacks the required scope",
+				"code":    "FORBIDDEN",
+				"details": "required scope: " + scope,
+			})
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
-		// API request → Return JSON error
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized: invalid or missing authentication",
-			"code":  "UNAUTHORIZED",
-		})
+// DenyAPIKeys rejects requests authenticated with an API key, for account
+// management that must not be reachable with a delegated credential.
+// Must run after AuthMiddleware.
+func DenyAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if CurrentAPIKey(c) != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "This endpoint cannot be used with an API key",
+				"code":  "FORBIDDEN",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// rejectUnauthorized aborts the request based on its type
+func rejectUnauthorized(c *gin.Context) {
+	accept := c.GetHeader("Accept")
+	isHTMLRequest := strings.Contains(accept, "text/html")
+
+	if isHTMLRequest {
+		// Browser request → Redirect to login page
+		c.Redirect(http.StatusFound, "/login")
 		c.Abort()
+		return
+	}
+
+	// API request → Return JSON error
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error": "Unauthorized: invalid or missing authentication",
+		"code":  "UNAUTHORIZED",
+	})
+	c.Abort()
+}
+
+// IsAuthenticated reports whether the request carries a valid credential,
+// using the same sources and priority as AuthMiddleware. On success the user
+// is stored in the context.
+func IsAuthenticated(c *gin.Context, auth Authenticator) bool {
+	// The ticket is consumed here and never passed on (e.g. to forwarded apps)
+	ticket := c.Query(TicketQueryParam)
+	if ticket != "" {
+		query := c.Request.URL.Query()
+		query.Del(TicketQueryParam)
+		c.Request.URL.RawQuery = query.Encode()
+	}
+
+	for _, token := range requestTokens(c, ticket, auth.AllowQueryToken()) {
+		if token.ticket {
+			target, ok := ticketTarget(c)
+			if !ok {
+				continue
+			}
+			if user, err := auth.AuthenticateTicket(token.value, target); err == nil {
+				if target.Kind == domain.TicketKindForward && !token.fromCookie {
+					setForwardCookie(c, auth, user, target)
+				}
+				SetCurrentUser(c, user)
+				return true
+			}
+			continue
+		}
+
+		if token.login && crossOriginWrite(c) {
+			utils.Warn("Ignoring login cookie on cross-origin request",
+				"method", c.Request.Method, "path", c.Request.URL.Path, "origin", c.GetHeader("Origin"))
+			continue
+		}
+
+		var user *domain.User
+		var err error
+		if domain.IsAPIKey(token.value) {
+			var key *domain.APIKey
+			user, key, err = auth.AuthenticateAPIKey(token.value)
+			if err == nil {
+				c.Set(apiKeyContextKey, key)
+			}
+		} else {
+			user, err = auth.Authenticate(token.value)
+		}
+		if err != nil {
+			continue
+		}
+
+		if token.fromBearer {
+			// The header was meant for ViBox; never pass it on to forwarded apps
+			c.Request.Header.Del("Authorization")
+		}
+		SetCurrentUser(c, user)
+		return true
+	}
+	return false
+}
+
+// CurrentUser returns the user authenticated by AuthMiddleware, or nil
+func CurrentUser(c *gin.Context) *domain.User {
+	if value, ok := c.Get(userContextKey); ok {
+		if user, ok := value.(*domain.User); ok {
+			return user
+		}
+	}
+	return nil
+}
+
+// CurrentAPIKey returns the API key the request was authenticated with, or nil
+// when it was authenticated otherwise
+func CurrentAPIKey(c *gin.Context) *domain.APIKey {
+	if value, ok := c.Get(apiKeyContextKey); ok {
+		if key, ok := value.(*domain.APIKey); ok {
+			return key
+		}
+	}
+	return nil
+}
+
+// AllowsScope reports whether the request's credential grants the scope.
+// Only API keys are restricted; other credentials carry every scope.
+func AllowsScope(c *gin.Context, scope string) bool {
+	key := CurrentAPIKey(c)
+	return key == nil || key.HasScope(scope)
+}
+
+// SetCurrentUser stores the authenticated user in the context
+func SetCurrentUser(c *gin.Context, user *domain.User) {
+	c.Set(userContextKey, user)
+}
+
+// SessionToken returns the login session token the request was sent with, if any
+func SessionToken(c *gin.Context) string {
+	token, _ := c.Cookie(SessionCookieName)
+	return token
+}
+
+// requestTokens returns the credentials carried by the request, in priority order
+func requestTokens(c *gin.Context, ticket string, allowQueryToken bool) []requestToken {
+	var tokens []requestToken
+
+	// 1. X-ViBox-Token header
+	if token := c.GetHeader(TokenHeader); token != "" {
+		tokens = append(tokens, requestToken{value: token})
+	}
+
+	// 2. Authorization: Bearer header
+	if header := c.GetHeader("Authorization"); header != "" {
+		if token, ok := strings.CutPrefix(header, "Bearer "); ok && token != "" {
+			tokens = append(tokens, requestToken{value: strings.TrimSpace(token), fromBearer: true})
+		}
+	}
+
+	// 3. Session cookie
+	if token, err := c.Cookie(SessionCookieName); err == nil && token != "" {
+		tokens = append(tokens, requestToken{value: token, login: true})
+	}
+
+	// 4. Legacy API token cookie
+	if token, err := c.Cookie(LegacyTokenCookieName); err == nil && token != "" {
+		tokens = append(tokens, requestToken{value: token, login: true})
+	}
+
+	// 5. Signed ticket
+	if ticket != "" {
+		tokens = append(tokens, requestToken{value: ticket, ticket: true})
+	}
+
+	// 6. Forward cookie (a ticket bound to one forwarded port)
+	if token, err := c.Cookie(ForwardCookieName); err == nil && token != "" {
+		tokens = append(tokens, requestToken{value: token, ticket: true, fromCookie: true})
+	}
+
+	// 7. Long-lived credential in the query string (legacy clients, opt-in)
+	if token := c.Query("token"); token != "" && allowQueryToken {
+		tokens = append(tokens, requestToken{value: token})
+	}
+
+	return tokens
+}
+
+// crossOriginWrite reports whether the request changes state (an unsafe method
+// or a WebSocket upgrade) and was sent by a page of another origin. Forward
+// subdomains serve user apps and are same-site with ViBox, so SameSite=Lax
+// cookies alone do not stop them from calling the API.
+// Requests without Sec-Fetch-Site and Origin headers (non-browser clients) pass.
+func crossOriginWrite(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+			return false
+		}
+	}
+
+	if site := c.GetHeader("Sec-Fetch-Site"); site != "" {
+		return site != "same-origin" && site != "none"
+	}
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err != nil || !strings.EqualFold(u.Host, c.Request.Host)
+}
+
+// setForwardCookie exchanges a used forward ticket for a cookie, so the assets
+// and requests of the forwarded page are authenticated too. The cookie is
+// scoped to the port's /forward path, or to the forward subdomain's host.
+func setForwardCookie(c *gin.Context, auth Authenticator, user *domain.User, target domain.TicketTarget) {
+	value, expiresAt, err := auth.IssueForwardCookie(user.ID, target)
+	if err != nil {
+		utils.Warn("Failed to issue forward cookie", "workspace_id", target.WorkspaceID, "port", target.Port, "error", err.Error())
+		return
+	}
+
+	cookiePath := "/"
+	if strings.HasPrefix(c.FullPath(), "/forward/") {
+		cookiePath = "/forward/" + target.WorkspaceID + "/" + strconv.Itoa(target.Port) + "/"
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ForwardCookieName, value, int(time.Until(expiresAt).Seconds()), cookiePath, "", c.Request.TLS != nil, true)
+}
+
+// SetTicketTarget sets what a ticket on this request must be bound to, for
+// requests whose route does not identify it (subdomain forwards)
+func SetTicketTarget(c *gin.Context, target domain.TicketTarget) {
+	c.Set(ticketTargetContextKey, target)
+}
+
+// ticketTarget returns the target a ticket must be bound to for this request
+func ticketTarget(c *gin.Context) (domain.TicketTarget, bool) {
+	if value, ok := c.Get(ticketTargetContextKey); ok {
+		if target, ok := value.(domain.TicketTarget); ok {
+			return target, true
+		}
+	}
+
+	target := domain.TicketTarget{WorkspaceID: c.Param("id")}
+	switch route := c.FullPath(); {
+	case strings.HasPrefix(route, "/ws/terminal/"):
+		target.Kind = domain.TicketKindTerminal
+	case strings.HasPrefix(route, "/ws/tunnel/"):
+		target.Kind = domain.TicketKindTunnel
+	case strings.HasPrefix(route, "/forward/"):
+		target.Kind = domain.TicketKindForward
+	default:
+		return domain.TicketTarget{}, false
+	}
+	if target.Kind != domain.TicketKindTerminal {
+		port, err := strconv.Atoi(c.Param("port"))
+		if err != nil {
+			return domain.TicketTarget{}, false
+		}
+		target.Port = port
 	}
+	return target, target.WorkspaceID != ""
 }
@@ -1,13 +1,61 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
 	"github.com/gin-gonic/gin"
 )
 
+// staticToken is an Authenticator that accepts a single token
+type staticToken string
+
+func (t staticToken) Authenticate(token string) (*domain.User, error) {
+	if token == "" || token != string(t) {
+		return nil, errors.New("invalid token")
+	}
+	return &domain.User{ID: "user-test", Username: "test", Role: domain.UserRoleUser}, nil
+}
+
+// AuthenticateTicket accepts "<token>:<kind>:<workspace>" for that kind and workspace
+func (t staticToken) AuthenticateTicket(ticket string, target domain.TicketTarget) (*domain.User, error) {
+	return t.Authenticate(strings.TrimSuffix(ticket, ":"+string(target.Kind)+":"+target.WorkspaceID))
+}
+
+// IssueForwardCookie returns a ticket AuthenticateTicket accepts for the target
+func (t staticToken) IssueForwardCookie(userID string, target domain.TicketTarget) (string, time.Time, error) {
+	return string(t) + ":" + string(target.Kind) + ":" + target.WorkspaceID, time.Now().Add(time.Hour), nil
+}
+
+// AllowQueryToken enables ?token= (the behaviour the original tests cover)
+func (t staticToken) AllowQueryToken() bool {
+	return true
+}
+
+// noQueryToken is a staticToken with ?token= disabled (the default)
+type noQueryToken struct {
+	staticToken
+}
+
+func (noQueryToken) AllowQueryToken() bool {
+	return false
+}
+
+// AuthenticateAPIKey accepts the token as an API key limited to workspaces:read
+func (t staticToken) AuthenticateAPIKey(key string) (*domain.User, *domain.APIKey, error) {
+	user, err := t.Authenticate(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, &domain.APIKey{ID: "key-test", UserID: user.ID, Scopes: []string{domain.ScopeWorkspacesRead}}, nil
+}
+
 func TestAuthMiddleware(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -89,7 +137,7 @@ func TestAuthMiddleware(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup
 			router := gin.New()
-			router.Use(AuthMiddleware(testToken))
+			router.Use(AuthMiddleware(staticToken(testToken)))
 			router.GET("/test", func(c *gin.Context) {
 				c.JSON(http.StatusOK, gin.H{"message": "success"})
 			})
@@ -119,3 +167,200 @@ func TestAuthMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	apiKey := domain.APIKeyPrefix + "test-key"
+
+	tests := []struct {
+		name           string
+		token          string
+		scope          string
+		expectedStatus int
+	}{
+		{"API key with scope", apiKey, domain.ScopeWorkspacesRead, http.StatusOK},
+		{"API key without scope", apiKey, domain.ScopeExec, http.StatusForbidden},
+		{"other credentials are unrestricted", "test-secret-token", domain.ScopeExec, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(AuthMiddleware(staticToken(tt.token)))
+			router.GET("/test", RequireScope(tt.scope), func(c *gin.Context) {
+				if got := c.GetHeader("Authorization"); got != "" {
+					t.Errorf("Authorization header should be stripped after use, got %q", got)
+				}
+				c.JSON(http.StatusOK, gin.H{"message": "success"})
+			})
+
+			req, _ := http.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_QueryCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		auth           Authenticator
+		path           string
+		expectedStatus int
+	}{
+		{"query token disabled by default", noQueryToken{"secret"}, "/ws/terminal/ws-1?token=secret", http.StatusUnauthorized},
+		{"query token when enabled", staticToken("secret"), "/ws/terminal/ws-1?token=secret", http.StatusOK},
+		{"ticket for this workspace", noQueryToken{"secret"}, "/ws/terminal/ws-1?vibox_ticket=secret:terminal:ws-1", http.StatusOK},
+		{"ticket for another workspace", noQueryToken{"secret"}, "/ws/terminal/ws-2?vibox_ticket=secret:terminal:ws-1", http.StatusUnauthorized},
+		{"ticket for another kind", noQueryToken{"secret"}, "/ws/terminal/ws-1?vibox_ticket=secret:forward:ws-1", http.StatusUnauthorized},
+		{"ticket outside ticket routes", noQueryToken{"secret"}, "/api/workspaces/ws-1?vibox_ticket=secret:terminal:ws-1", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			handler := func(c *gin.Context) {
+				if c.Request.URL.Query().Has(TicketQueryParam) {
+					t.Error("Ticket should be stripped from the request")
+				}
+				c.Status(http.StatusOK)
+			}
+			router.GET("/ws/terminal/:id", AuthMiddleware(tt.auth), handler)
+			router.GET("/api/workspaces/:id", AuthMiddleware(tt.auth), handler)
+
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_ForwardTicketCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	auth := noQueryToken{"secret"}
+	router := gin.New()
+	router.GET("/forward/:id/:port/*path", AuthMiddleware(auth), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/ws/terminal/:id", AuthMiddleware(auth), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	// The page is opened with a ticket, which is exchanged for a cookie
+	req, _ := http.NewRequest("GET", "/forward/ws-1/3000/?vibox_ticket=secret:forward:ws-1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == ForwardCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("Expected a forward cookie to be set")
+	}
+	if cookie.Path != "/forward/ws-1/3000/" || !cookie.HttpOnly {
+		t.Errorf("Expected an HttpOnly cookie scoped to the port, got %+v", cookie)
+	}
+
+	// The page's assets are authenticated by the cookie
+	req, _ = http.NewRequest("GET", "/forward/ws-1/3000/assets/app.js", nil)
+	req.AddCookie(&http.Cookie{Name: ForwardCookieName, Value: cookie.Value})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the cookie to authenticate assets, got %d", w.Code)
+	}
+
+	// The cookie is only valid for that forwarded port
+	req, _ = http.NewRequest("GET", "/forward/ws-2/3000/", nil)
+	req.AddCookie(&http.Cookie{Name: ForwardCookieName, Value: cookie.Value})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the cookie to be rejected for another workspace, got %d", w.Code)
+	}
+
+	// Other ticket kinds are not turned into cookies
+	req, _ = http.NewRequest("GET", "/ws/terminal/ws-1?vibox_ticket=secret:terminal:ws-1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || len(w.Result().Cookies()) != 0 {
+		t.Errorf("Expected no cookie for a terminal ticket, got %d, %v", w.Code, w.Result().Cookies())
+	}
+}
+
+func TestAuthMiddleware_CrossOriginLoginCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	utils.InitLogger()
+
+	router := gin.New()
+	router.Use(AuthMiddleware(staticToken("secret")))
+	router.Any("/api/workspaces/:id/exec", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/ws/terminal/:id", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		headers  map[string]string
+		expected int
+	}{
+		{"same-origin post", "POST", "/api/workspaces/ws-1/exec", map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "http://vibox.example.com"}, http.StatusOK},
+		{"post from a forward subdomain", "POST", "/api/workspaces/ws-1/exec", map[string]string{"Sec-Fetch-Site": "same-site", "Origin": "http://8080-ws-1.vibox.example.com"}, http.StatusUnauthorized},
+		{"post with foreign origin only", "POST", "/api/workspaces/ws-1/exec", map[string]string{"Origin": "http://8080-ws-1.vibox.example.com"}, http.StatusUnauthorized},
+		{"post with matching origin only", "POST", "/api/workspaces/ws-1/exec", map[string]string{"Origin": "http://vibox.example.com"}, http.StatusOK},
+		{"post without browser headers", "POST", "/api/workspaces/ws-1/exec", nil, http.StatusOK},
+		{"cross-site read", "GET", "/api/workspaces/ws-1/exec", map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusOK},
+		{"cross-site websocket", "GET", "/ws/terminal/ws-1", map[string]string{"Upgrade": "websocket", "Sec-Fetch-Site": "same-site"}, http.StatusUnauthorized},
+		{"same-origin websocket", "GET", "/ws/terminal/ws-1", map[string]string{"Upgrade": "websocket", "Origin": "http://vibox.example.com"}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			req.Host = "vibox.example.com"
+			req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: "secret"})
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+
+	// Headers are still accepted from other origins; they cannot be forged by a page
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/workspaces/ws-1/exec", nil)
+	req.Host = "vibox.example.com"
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	req.Header.Set(TokenHeader, "secret")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected header credential from another origin to be accepted, got %d", w.Code)
+	}
+}
@@ -1,12 +1,20 @@
 package middleware
 
 import (
+	"strings"
+
 	"github.com/gin-gonic/gin"
 )
 
 // CORSMiddleware handles Cross-Origin Resource Sharing
+// Forwarded ports are skipped: their CORS is handled by the app or by the port's policy.
 func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, "/forward/") {
+			c.Next()
+			return
+		}
+
 		// Allow all origins in development
 		// In production, this should be configurable to specific domains
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ShareQueryParam carries a share token on the first request of a share link
+	ShareQueryParam = "vibox_share"
+	// ShareCookieName remembers the share token for subsequent requests (scoped to the port's path)
+	ShareCookieName = domain.ShareCookieName
+	// ShareHeader carries a share token for non-browser clients
+	ShareHeader = "X-ViBox-Share"
+)
+
+// ShareValidator validates share tokens for forwarded ports
+type ShareValidator interface {
+	ValidateShare(token, workspaceID string, port int) (*domain.PortShare, error)
+	CheckSharePassword(share *domain.PortShare, password string) bool
+}
+
+// PortVisibilityLookup reports the visibility of a workspace port
+type PortVisibilityLookup interface {
+	PortVisibility(workspaceID string, port int) domain.PortVisibility
+}
+
+// ForwardAuthMiddleware authenticates /forward/:id/:port requests with either a
+// share link for that exact port or a normal login (see AuthMiddleware).
+// Ports whose visibility is public skip authentication entirely.
+//
+// Share tokens are accepted from (in priority order):
+// 1. Query parameter: ?vibox_share=<token> (the link handed out; moved into a cookie)
+// 2. Header: X-ViBox-Share
+// 3. Cookie: vibox-share (path-scoped to /forward/:id/:port/)
+//
+// Password-protected shares expect the password via HTTP Basic auth (any username);
+// on ports with their own basic auth, the share password is accepted in its place.
+// Read-only shares only allow GET, HEAD and OPTIONS.
+func ForwardAuthMiddleware(auth Authenticator, shares ShareValidator, ports PortVisibilityLookup) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		workspaceID := c.Param("id")
+		port, _ := strconv.Atoi(c.Param("port"))
+
+		if ports.PortVisibility(workspaceID, port) == domain.PortVisibilityPublic {
+			c.Next()
+			return
+		}
+
+		token, fromQuery := shareToken(c)
+		if token == "" {
+			if IsAuthenticated(c, auth) {
+				c.Next()
+				return
+			}
+			rejectUnauthorized(c)
+			return
+		}
+
+		basePath := "/forward/" + workspaceID + "/" + c.Param("port") + "/"
+
+		share, err := shares.ValidateShare(token, workspaceID, port)
+		if err != nil {
+			// A stale share cookie must not lock out a logged-in user
+			if IsAuthenticated(c, auth) {
+				stripShareCredentials(c, false)
+				c.Next()
+				return
+			}
+			utils.Warn("Rejected share link", "workspace_id", workspaceID, "port", port, "error", err.Error())
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid or expired share link",
+				"code":  "UNAUTHORIZED",
+			})
+			c.Abort()
+			return
+		}
+
+		if share.HasPassword() {
+			_, password, ok := c.Request.BasicAuth()
+			if !ok || !shares.CheckSharePassword(share, password) {
+				c.Header("WWW-Authenticate", `Basic realm="ViBox shared port", charset="UTF-8"`)
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "Password required",
+					"code":  "UNAUTHORIZED",
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		if share.ReadOnly {
+			switch c.Request.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				c.Header("Allow", "GET, HEAD, OPTIONS")
+				c.JSON(http.StatusMethodNotAllowed, gin.H{
+					"error": "This share link is read-only",
+					"code":  "FORBIDDEN",
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		if fromQuery {
+			// Keep the token out of the app's URLs; assets and navigation use the cookie from here on
+			c.SetSameSite(http.SameSiteLaxMode)
+			c.SetCookie(ShareCookieName, token, int(time.Until(share.ExpiresAt).Seconds()), basePath, "", c.Request.TLS != nil, true)
+		}
+		stripShareCredentials(c, share.HasPassword())
+
+		utils.Debug("Request authorized by share link", "share_id", share.ID, "workspace_id", workspaceID, "port", port)
+		c.Set("share", share)
+		c.Next()
+	}
+}
+
+// shareToken extracts a share token from the request
+func shareToken(c *gin.Context) (token string, fromQuery bool) {
+	if token := c.Query(ShareQueryParam); token != "" {
+		return token, true
+	}
+	if token := c.GetHeader(ShareHeader); token != "" {
+		return token, false
+	}
+	if token, err := c.Cookie(ShareCookieName); err == nil && token != "" {
+		return token, false
+	}
+	return "", false
+}
+
+// stripShareCredentials removes share credentials so they are not forwarded to the container
+func stripShareCredentials(c *gin.Context, basicAuth bool) {
+	query := c.Request.URL.Query()
+	if query.Has(ShareQueryParam) {
+		query.Del(ShareQueryParam)
+		c.Request.URL.RawQuery = query.Encode()
+	}
+	c.Request.Header.Del(ShareHeader)
+	if basicAuth {
+		c.Request.Header.Del("Authorization")
+	}
+}
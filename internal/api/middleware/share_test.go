@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/repository"
+	"github.com/1PercentSync/vibox/internal/service"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// publicPorts is a PortVisibilityLookup with the given "<workspace>/<port>" ports public
+type publicPorts map[string]bool
+
+func (p publicPorts) PortVisibility(workspaceID string, port int) domain.PortVisibility {
+	if p[workspaceID+"/"+strconv.Itoa(port)] {
+		return domain.PortVisibilityPublic
+	}
+	return domain.PortVisibilityPrivate
+}
+
+func TestForwardAuthMiddleware(t *testing.T) {
+	utils.InitLogger()
+	gin.SetMode(gin.TestMode)
+
+	apiToken := "test-secret-token"
+	shares := service.NewShareService(repository.NewMemoryShareRepository())
+
+	_, openToken, _ := shares.CreateShare("ws-1", 8080, service.CreateShareRequest{})
+	_, readOnlyToken, _ := shares.CreateShare("ws-1", 8080, service.CreateShareRequest{ReadOnly: true})
+	_, passwordToken, _ := shares.CreateShare("ws-1", 8080, service.CreateShareRequest{Password: "hunter2"})
+
+	var forwarded *http.Request
+	router := gin.New()
+	router.Any("/forward/:id/:port/*path", ForwardAuthMiddleware(staticToken(apiToken), shares, publicPorts{"ws-1/9000": true}), func(c *gin.Context) {
+		forwarded = c.Request
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		shareHeader    string
+		cookie         *http.Cookie
+		basicPassword  string
+		expectedStatus int
+	}{
+		{"no credentials", http.MethodGet, "/forward/ws-1/8080/", "", nil, "", http.StatusUnauthorized},
+		{"api token still works", http.MethodPost, "/forward/ws-1/8080/?token=" + apiToken, "", nil, "", http.StatusOK},
+		{"share in query", http.MethodGet, "/forward/ws-1/8080/?vibox_share=" + openToken, "", nil, "", http.StatusOK},
+		{"share in header", http.MethodPost, "/forward/ws-1/8080/api", openToken, nil, "", http.StatusOK},
+		{"share in cookie", http.MethodGet, "/forward/ws-1/8080/app.js", "", &http.Cookie{Name: ShareCookieName, Value: openToken}, "", http.StatusOK},
+		{"share for another port", http.MethodGet, "/forward/ws-1/3000/", openToken, nil, "", http.StatusUnauthorized},
+		{"share for another workspace", http.MethodGet, "/forward/ws-2/8080/", openToken, nil, "", http.StatusUnauthorized},
+		{"unknown share", http.MethodGet, "/forward/ws-1/8080/", "vbs_bogus", nil, "", http.StatusUnauthorized},
+		{"read-only allows GET", http.MethodGet, "/forward/ws-1/8080/", readOnlyToken, nil, "", http.StatusOK},
+		{"read-only rejects POST", http.MethodPost, "/forward/ws-1/8080/", readOnlyToken, nil, "", http.StatusMethodNotAllowed},
+		{"password missing", http.MethodGet, "/forward/ws-1/8080/", passwordToken, nil, "", http.StatusUnauthorized},
+		{"password wrong", http.MethodGet, "/forward/ws-1/8080/", passwordToken, nil, "nope", http.StatusUnauthorized},
+		{"password correct", http.MethodGet, "/forward/ws-1/8080/", passwordToken, nil, "hunter2", http.StatusOK},
+		{"public port", http.MethodPost, "/forward/ws-1/9000/", "", nil, "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forwarded = nil
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.shareHeader != "" {
+				req.Header.Set(ShareHeader, tt.shareHeader)
+			}
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			if tt.basicPassword != "" {
+				req.SetBasicAuth("guest", tt.basicPassword)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK && tt.shareHeader != "" {
+				if forwarded.Header.Get(ShareHeader) != "" {
+					t.Error("Expected share header to be stripped before forwarding")
+				}
+				if tt.basicPassword != "" && forwarded.Header.Get("Authorization") != "" {
+					t.Error("Expected share password to be stripped before forwarding")
+				}
+			}
+		})
+	}
+}
+
+func TestForwardAuthMiddleware_QueryTokenMovesToCookie(t *testing.T) {
+	utils.InitLogger()
+	gin.SetMode(gin.TestMode)
+
+	shares := service.NewShareService(repository.NewMemoryShareRepository())
+	_, token, _ := shares.CreateShare("ws-1", 8080, service.CreateShareRequest{})
+
+	var rawQuery string
+	router := gin.New()
+	router.Any("/forward/:id/:port/*path", ForwardAuthMiddleware(staticToken("api-token"), shares, publicPorts{}), func(c *gin.Context) {
+		rawQuery = c.Request.URL.RawQuery
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/forward/ws-1/8080/?page=2&vibox_share="+token, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if rawQuery != "page=2" {
+		t.Errorf("Expected share token to be removed from the query, got %q", rawQuery)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != ShareCookieName || cookies[0].Value != token {
+		t.Fatalf("Expected share cookie to be set, got %v", cookies)
+	}
+	if cookies[0].Path != "/forward/ws-1/8080/" || !cookies[0].HttpOnly {
+		t.Errorf("Expected HttpOnly cookie scoped to the port, got path %q", cookies[0].Path)
+	}
+}
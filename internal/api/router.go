@@ -8,8 +8,10 @@ import (
 	"github.com/1PercentSync/vibox/internal/api/handler"
 	"github.com/1PercentSync/vibox/internal/api/middleware"
 	"github.com/1PercentSync/vibox/internal/config"
+	"github.com/1PercentSync/vibox/internal/domain"
 	"github.com/1PercentSync/vibox/internal/service"
 	"github.com/1PercentSync/vibox/internal/static"
+	"github.com/1PercentSync/vibox/pkg/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -20,6 +22,18 @@ func SetupRouter(
 	workspaceSvc *service.WorkspaceService,
 	terminalSvc *service.TerminalService,
 	proxySvc *service.ProxyService,
+	execSvc *service.ExecService,
+	shareSvc *service.ShareService,
+	portScanner *service.PortScanner,
+	eventBus *service.EventBus,
+	tunnelSvc *service.TunnelService,
+	portAccessSvc *service.PortAccessService,
+	authSvc *service.AuthService,
+	oidcSvc *service.OIDCService,
+	auditSvc *service.AuditService,
+	backupSvc *service.BackupService,
+	fileSvc *service.FileService,
+	gitSvc *service.GitService,
 ) *gin.Engine {
 	// Set Gin mode
 	gin.SetMode(gin.ReleaseMode)
@@ -27,16 +41,51 @@ func SetupRouter(
 	// Create router
 	router := gin.New()
 
+	// Client IPs (port rate limits, the audit log) come from X-Forwarded-For only
+	// for requests from a configured reverse proxy; by default nobody is trusted
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		utils.Error("Invalid trusted proxies, trusting none", "error", err.Error())
+	}
+
 	// Apply global middleware
 	router.Use(middleware.RecoveryMiddleware())
 	router.Use(middleware.LoggerMiddleware())
-	router.Use(middleware.CORSMiddleware())
 
 	// Create handlers
-	authHandler := handler.NewAuthHandler(cfg.APIToken)
-	workspaceHandler := handler.NewWorkspaceHandler(workspaceSvc)
-	terminalHandler := handler.NewTerminalHandler(terminalSvc, workspaceSvc, dockerSvc)
-	proxyHandler := handler.NewProxyHandler(proxySvc, workspaceSvc, dockerSvc)
+	authHandler := handler.NewAuthHandler(authSvc, oidcSvc, cfg.ForwardBaseDomain)
+	userHandler := handler.NewUserHandler(authSvc)
+	apiKeyHandler := handler.NewAPIKeyHandler(authSvc)
+	workspaceHandler := handler.NewWorkspaceHandler(workspaceSvc, authSvc)
+	terminalHandler := handler.NewTerminalHandler(terminalSvc, workspaceSvc, dockerSvc, auditSvc)
+	proxyHandler := handler.NewProxyHandler(proxySvc, workspaceSvc, dockerSvc, portAccessSvc)
+	execHandler := handler.NewExecHandler(execSvc, workspaceSvc, dockerSvc)
+	shareHandler := handler.NewShareHandler(shareSvc, workspaceSvc)
+	portHandler := handler.NewPortHandler(portScanner, workspaceSvc, dockerSvc)
+	eventHandler := handler.NewEventHandler(eventBus, workspaceSvc)
+	tunnelHandler := handler.NewTunnelHandler(tunnelSvc, workspaceSvc, dockerSvc)
+	auditHandler := handler.NewAuditHandler(auditSvc)
+	ticketHandler := handler.NewTicketHandler(authSvc, workspaceSvc, cfg.ForwardBaseDomain)
+	backupHandler := handler.NewBackupHandler(backupSvc)
+	gitHandler := handler.NewGitHandler(gitSvc)
+	snapshotHandler := handler.NewSnapshotHandler(workspaceSvc)
+	fileHandler := handler.NewFileHandler(fileSvc, workspaceSvc, dockerSvc)
+
+	// audit records a route's action in the audit log
+	audit := func(action string) gin.HandlerFunc {
+		return middleware.Audit(auditSvc, action)
+	}
+
+	// Subdomain port forwarding: <port>-<workspace-id>.<base-domain>
+	// Registered before all routes so forwarded hosts never reach the ViBox API or UI
+	if cfg.ForwardBaseDomain != "" {
+		// Trailing-slash redirects happen before middleware runs and would otherwise
+		// rewrite forwarded app paths that happen to resemble ViBox routes
+		router.RedirectTrailingSlash = false
+		router.Use(proxyHandler.ForwardHost(cfg.ForwardBaseDomain, authSvc))
+	}
+
+	// CORS for the ViBox API (after ForwardHost: forwarded apps follow their port's policy)
+	router.Use(middleware.CORSMiddleware())
 
 	// Health check endpoint (no auth required)
 	router.GET("/health", func(c *gin.Context) {
@@ -49,36 +98,128 @@ func SetupRouter(
 	// Authentication endpoints (no auth required for login)
 	auth := router.Group("/api/auth")
 	{
-		auth.POST("/login", authHandler.Login)
-		auth.POST("/logout", authHandler.Logout) // Logout can work without auth
+		auth.POST("/login", audit(domain.AuditActionLogin), authHandler.Login)
+		auth.POST("/logout", audit(domain.AuditActionLogout), authHandler.Logout) // Logout can work without auth
+
+		// Single sign-on (OpenID Connect)
+		auth.GET("/oidc/login", authHandler.OIDCLogin)
+		auth.GET("/oidc/callback", audit(domain.AuditActionLogin), authHandler.OIDCCallback)
 	}
 
 	// API routes (with auth)
 	api := router.Group("/api")
-	api.Use(middleware.AuthMiddleware(cfg.APIToken))
+	api.Use(middleware.AuthMiddleware(authSvc))
 	{
+		// Current user
+		api.GET("/auth/me", authHandler.Me)
+
+		// Short-lived tickets for terminal, tunnel and forward URLs
+		api.POST("/tickets", ticketHandler.Create)
+		if cfg.ForwardBaseDomain != "" {
+			api.GET("/tickets/forward", ticketHandler.Forward)
+		}
+
+		// User management (changing one's own password is allowed for everyone)
+		// Account management needs a login; API keys cannot manage accounts or mint keys
+		accounts := api.Group("", middleware.DenyAPIKeys())
+		accounts.GET("/users", middleware.RequireAdmin(), userHandler.List)
+		accounts.POST("/users", audit(domain.AuditActionUserCreate), middleware.RequireAdmin(), userHandler.Create)
+		accounts.DELETE("/users/:id", audit(domain.AuditActionUserDelete), middleware.RequireAdmin(), userHandler.Delete)
+		accounts.PUT("/users/:id/password", audit(domain.AuditActionUserPassword), userHandler.ChangePassword)
+		accounts.PUT("/users/:id/git-identity", audit(domain.AuditActionUserGitIdentity), userHandler.SetGitIdentity)
+
+		// API keys of the current user
+		accounts.POST("/keys", audit(domain.AuditActionAPIKeyCreate), apiKeyHandler.Create)
+		accounts.GET("/keys", apiKeyHandler.List)
+		accounts.DELETE("/keys/:id", audit(domain.AuditActionAPIKeyRevoke), apiKeyHandler.Delete)
+
+		// Git credentials of the current user
+		accounts.POST("/git/credentials", audit(domain.AuditActionGitCredentialCreate), gitHandler.Create)
+		accounts.GET("/git/credentials", gitHandler.List)
+		accounts.DELETE("/git/credentials/:id", audit(domain.AuditActionGitCredentialDelete), gitHandler.Delete)
+
+		// Audit log
+		accounts.GET("/audit", middleware.RequireAdmin(), auditHandler.List)
+
+		// Backup and restore of the whole installation
+		accounts.POST("/admin/backup", audit(domain.AuditActionBackup), middleware.RequireAdmin(), backupHandler.Backup)
+		accounts.POST("/admin/restore", audit(domain.AuditActionRestore), middleware.RequireAdmin(), backupHandler.Restore)
+
+		read := middleware.RequireScope(domain.ScopeWorkspacesRead)
+		write := middleware.RequireScope(domain.ScopeWorkspacesWrite)
+		exec := middleware.RequireScope(domain.ScopeExec)
+
 		// Workspace management
-		api.POST("/workspaces", workspaceHandler.Create)
-		api.GET("/workspaces", workspaceHandler.List)
-		api.GET("/workspaces/:id", workspaceHandler.Get)
-		api.DELETE("/workspaces/:id", workspaceHandler.Delete)
+		api.POST("/workspaces", audit(domain.AuditActionWorkspaceCreate), write, workspaceHandler.Create)
+		api.GET("/workspaces", read, workspaceHandler.List)
+		api.GET("/workspaces/:id", read, workspaceHandler.Get)
+		api.PATCH("/workspaces/:id", audit(domain.AuditActionWorkspaceUpdate), write, workspaceHandler.Update)
+		api.DELETE("/workspaces/:id", audit(domain.AuditActionWorkspaceDelete), write, workspaceHandler.Delete)
+		api.POST("/workspaces/:id/clone", audit(domain.AuditActionWorkspaceClone), write, workspaceHandler.Clone)
+		api.GET("/workspaces/:id/export", read, workspaceHandler.Export)
+		api.POST("/workspaces/import", audit(domain.AuditActionWorkspaceImport), write, workspaceHandler.Import)
 
 		// Workspace operations
-		api.PUT("/workspaces/:id/ports", workspaceHandler.UpdatePorts)
-		api.POST("/workspaces/:id/reset", workspaceHandler.ResetWorkspace)
+		api.GET("/workspaces/:id/ports", read, portHandler.List)
+		api.PUT("/workspaces/:id/ports", audit(domain.AuditActionPortsUpdate), write, workspaceHandler.UpdatePorts)
+		api.PUT("/workspaces/:id/ports/:port/settings", audit(domain.AuditActionPortSettingsUpdate), write, workspaceHandler.UpdatePortSettings)
+		api.DELETE("/workspaces/:id/ports/:port/settings", audit(domain.AuditActionPortSettingsDelete), write, workspaceHandler.DeletePortSettings)
+		api.POST("/workspaces/:id/reset", audit(domain.AuditActionWorkspaceReset), write, workspaceHandler.ResetWorkspace)
+		api.POST("/workspaces/:id/apply", audit(domain.AuditActionWorkspaceApply), write, workspaceHandler.Apply)
+
+		// Workspace snapshots
+		api.POST("/workspaces/:id/snapshots", audit(domain.AuditActionSnapshotCreate), write, snapshotHandler.Create)
+		api.GET("/workspaces/:id/snapshots", read, snapshotHandler.List)
+		api.DELETE("/workspaces/:id/snapshots/:name", audit(domain.AuditActionSnapshotDelete), write, snapshotHandler.Delete)
+		api.POST("/workspaces/:id/snapshots/:name/restore", audit(domain.AuditActionSnapshotRestore), write, snapshotHandler.Restore)
+
+		// Workspace file browser
+		api.GET("/workspaces/:id/files", read, fileHandler.List)
+		api.GET("/workspaces/:id/files/download", audit(domain.AuditActionFileDownload), read, fileHandler.Download)
+		api.POST("/workspaces/:id/files/upload", audit(domain.AuditActionFileUpload), write, fileHandler.Upload)
+		api.POST("/workspaces/:id/files/mkdir", audit(domain.AuditActionFileMkdir), write, fileHandler.MakeDirectory)
+		api.POST("/workspaces/:id/files/rename", audit(domain.AuditActionFileRename), write, fileHandler.Rename)
+		api.DELETE("/workspaces/:id/files", audit(domain.AuditActionFileDelete), write, fileHandler.Delete)
+
+		// Workspace members
+		api.PUT("/workspaces/:id/members/:userId", audit(domain.AuditActionMemberSet), write, workspaceHandler.SetMember)
+		api.DELETE("/workspaces/:id/members/:userId", audit(domain.AuditActionMemberRemove), write, workspaceHandler.RemoveMember)
+
+		// Non-interactive command execution
+		api.POST("/workspaces/:id/exec", audit(domain.AuditActionExec), exec, execHandler.Exec)
+		api.POST("/workspaces/:id/exec/stream", audit(domain.AuditActionExec), exec, execHandler.Stream)
+		api.DELETE("/workspaces/:id/exec/:execId", audit(domain.AuditActionExecCancel), exec, execHandler.Cancel)
+
+		// Workspace events (Server-Sent Events)
+		api.GET("/events", read, eventHandler.Stream)
+
+		// Share links for forwarded ports
+		api.POST("/workspaces/:id/ports/:port/shares", audit(domain.AuditActionShareCreate), write, shareHandler.Create)
+		api.GET("/workspaces/:id/ports/:port/shares", read, shareHandler.List)
+		api.DELETE("/workspaces/:id/ports/:port/shares/:shareId", audit(domain.AuditActionShareDelete), write, shareHandler.Delete)
 	}
 
 	// WebSocket terminal (with auth)
-	// Note: WebSocket connections must use ?token= query parameter for auth
+	// Note: WebSocket connections may use a ?vibox_ticket= from POST /api/tickets for auth
 	router.GET("/ws/terminal/:id",
-		middleware.AuthMiddleware(cfg.APIToken),
+		middleware.AuthMiddleware(authSvc),
+		middleware.RequireScope(domain.ScopeTerminal),
 		terminalHandler.Connect,
 	)
 
-	// Port forwarding (with auth)
+	// TCP tunnel over WebSocket (with auth), used by `vibox tunnel`
+	router.GET("/ws/tunnel/:id/:port",
+		middleware.AuthMiddleware(authSvc),
+		audit(domain.AuditActionTunnel),
+		middleware.RequireScope(domain.ScopeForward),
+		tunnelHandler.Connect,
+	)
+
+	// Port forwarding (with auth or a share link for that port)
 	// Matches: /forward/{workspace-id}/{port}/any/path
 	router.Any("/forward/:id/:port/*path",
-		middleware.AuthMiddleware(cfg.APIToken),
+		middleware.ForwardAuthMiddleware(authSvc, shareSvc, workspaceSvc),
+		middleware.RequireScope(domain.ScopeForward),
 		proxyHandler.Forward,
 	)
 
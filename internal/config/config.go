@@ -2,8 +2,10 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 )
 
 // Config holds all configuration for the application
@@ -15,6 +17,64 @@ type Config struct {
 	MemoryLimit  int64
 	CPULimit     int64
 	DataDir      string // Directory for persistent data storage
+
+	// Reverse proxies (IPs or CIDRs) whose X-Forwarded-For is trusted for client IPs (empty = none)
+	TrustedProxies []string
+
+	// Workspace storage: "file" (workspaces.json, the default) or "sqlite"
+	StorageBackend string
+	// SQLite database file (empty = vibox.db under DataDir)
+	SQLitePath string
+	// Start with no workspaces when workspaces.json is corrupt, moving the file
+	// aside, instead of refusing to start
+	RecoverCorruptData bool
+
+	// Lifetime of a login session in seconds (0 = default of 7 days)
+	SessionTTL int64
+
+	// Lifetime of signed WebSocket/forward tickets in seconds (0 = default of 60, at most 600)
+	TicketTTL int64
+	// Accept long-lived credentials (API token, sessions, API keys) in ?token=
+	// Off by default: query strings end up in browser history and proxy logs.
+	AllowQueryToken bool
+
+	// OpenID Connect single sign-on (disabled unless OIDCIssuer is set)
+	OIDCIssuer        string   // Issuer URL; endpoints are read from its discovery document
+	OIDCClientID      string   // Client ID registered with the provider
+	OIDCClientSecret  string   // Client secret (empty for public clients, which rely on PKCE)
+	OIDCScopes        []string // Requested scopes ("openid" is always included)
+	OIDCRedirectURL   string   // Callback URL registered with the provider (empty = derived from the request)
+	OIDCUsernameClaim string   // Claim used as the ViBox username
+	OIDCGroupsClaim   string   // Claim listing the user's groups
+	OIDCAdminGroups   []string // Members of these groups get the admin role (empty = roles are managed in ViBox)
+	OIDCAllowedGroups []string // Only members of these groups may log in (empty = anyone the provider authenticates)
+
+	// Terminal session limits
+	TerminalIdleTimeout             int64 // Seconds without input before a terminal is closed (0 = disabled)
+	MaxTerminalSessions             int   // Maximum concurrent terminal sessions overall (0 = unlimited)
+	MaxTerminalSessionsPerWorkspace int   // Maximum concurrent terminal sessions per workspace (0 = unlimited)
+
+	// Subdomain-based port forwarding: <port>-<workspace-id>.<ForwardBaseDomain>
+	// Login cookies stay on the ViBox host; subdomains are opened with forward tickets.
+	ForwardBaseDomain string
+
+	// Seconds between background scans for listening ports in running workspaces (0 = disabled)
+	PortScanInterval int
+
+	// Audit log rotation (audit.log under DataDir)
+	AuditMaxSize  int64 // Bytes after which the audit log is rotated (0 = default of 10 MiB)
+	AuditMaxFiles int   // Rotated audit log files kept (0 = default of 5)
+
+	// File browser size limits in bytes (0 = unlimited)
+	FilesMaxUploadSize   int64 // Request body of an upload
+	FilesMaxDownloadSize int64 // File or archive streamed by a download
+
+	// Largest backup archive accepted by a restore in bytes (0 = unlimited)
+	RestoreMaxSize int64
+
+	// Image of the short-lived containers that clone repositories with git
+	// credentials, so secrets never enter workspace containers (empty = alpine/git)
+	GitHelperImage string
 }
 
 // Load reads configuration from environment variables
@@ -27,6 +87,46 @@ func Load() *Config {
 		MemoryLimit:  getEnvInt64("MEMORY_LIMIT", 512*1024*1024), // 512MB default
 		CPULimit:     getEnvInt64("CPU_LIMIT", 1000000000),       // 1 CPU default
 		DataDir:      getEnv("DATA_DIR", "./data"),               // Default to ./data in development
+
+		TrustedProxies: getEnvList("TRUSTED_PROXIES", ""),
+
+		StorageBackend: strings.ToLower(getEnv("STORAGE_BACKEND", "file")),
+		SQLitePath:     getEnv("SQLITE_PATH", ""),
+
+		RecoverCorruptData: getEnvBool("RECOVER_CORRUPT_DATA", false),
+
+		SessionTTL: getEnvInt64("SESSION_TTL", 7*24*60*60), // 7 days default
+
+		TicketTTL:       getEnvInt64("TICKET_TTL", 60),
+		AllowQueryToken: getEnvBool("ALLOW_QUERY_TOKEN", false),
+
+		OIDCIssuer:        strings.TrimSuffix(getEnv("OIDC_ISSUER", ""), "/"),
+		OIDCClientID:      getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:  getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCScopes:        getEnvList("OIDC_SCOPES", "openid profile email"),
+		OIDCRedirectURL:   getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCUsernameClaim: getEnv("OIDC_USERNAME_CLAIM", "preferred_username"),
+		OIDCGroupsClaim:   getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCAdminGroups:   getEnvList("OIDC_ADMIN_GROUPS", ""),
+		OIDCAllowedGroups: getEnvList("OIDC_ALLOWED_GROUPS", ""),
+
+		TerminalIdleTimeout:             getEnvInt64("TERMINAL_IDLE_TIMEOUT", 0),
+		MaxTerminalSessions:             getEnvInt("MAX_TERMINAL_SESSIONS", 50),
+		MaxTerminalSessionsPerWorkspace: getEnvInt("MAX_TERMINAL_SESSIONS_PER_WORKSPACE", 10),
+
+		ForwardBaseDomain: strings.ToLower(strings.Trim(getEnv("FORWARD_BASE_DOMAIN", ""), ".")),
+
+		PortScanInterval: getEnvInt("PORT_SCAN_INTERVAL", 10),
+
+		AuditMaxSize:  getEnvInt64("AUDIT_MAX_SIZE", 10*1024*1024), // 10 MiB default
+		AuditMaxFiles: getEnvInt("AUDIT_MAX_FILES", 5),
+
+		FilesMaxUploadSize:   getEnvInt64("FILES_MAX_UPLOAD_SIZE", 100*1024*1024),    // 100 MiB default
+		FilesMaxDownloadSize: getEnvInt64("FILES_MAX_DOWNLOAD_SIZE", 1024*1024*1024), // 1 GiB default
+
+		RestoreMaxSize: getEnvInt64("RESTORE_MAX_SIZE", 10*1024*1024*1024), // 10 GiB default
+
+		GitHelperImage: getEnv("GIT_HELPER_IMAGE", "alpine/git:latest"),
 	}
 
 	return cfg
@@ -46,6 +146,51 @@ func (c *Config) Validate() error {
 	if c.DefaultImage == "" {
 		return fmt.Errorf("DEFAULT_IMAGE cannot be empty")
 	}
+	switch c.StorageBackend {
+	case "", "file", "sqlite":
+	default:
+		return fmt.Errorf("STORAGE_BACKEND must be \"file\" or \"sqlite\"")
+	}
+	if c.SessionTTL < 0 {
+		return fmt.Errorf("SESSION_TTL cannot be negative")
+	}
+	if c.OIDCIssuer != "" {
+		if !strings.HasPrefix(c.OIDCIssuer, "https://") && !strings.HasPrefix(c.OIDCIssuer, "http://") {
+			return fmt.Errorf("OIDC_ISSUER must be an http(s) URL")
+		}
+		if c.OIDCClientID == "" {
+			return fmt.Errorf("OIDC_CLIENT_ID is required when OIDC_ISSUER is set")
+		}
+	}
+	if c.TicketTTL < 0 {
+		return fmt.Errorf("TICKET_TTL cannot be negative")
+	}
+	if c.TerminalIdleTimeout < 0 {
+		return fmt.Errorf("TERMINAL_IDLE_TIMEOUT cannot be negative")
+	}
+	if c.MaxTerminalSessions < 0 || c.MaxTerminalSessionsPerWorkspace < 0 {
+		return fmt.Errorf("terminal session limits cannot be negative")
+	}
+	if c.PortScanInterval < 0 {
+		return fmt.Errorf("PORT_SCAN_INTERVAL cannot be negative")
+	}
+	if c.AuditMaxSize < 0 || c.AuditMaxFiles < 0 {
+		return fmt.Errorf("AUDIT_MAX_SIZE and AUDIT_MAX_FILES cannot be negative")
+	}
+	if c.FilesMaxUploadSize < 0 || c.FilesMaxDownloadSize < 0 {
+		return fmt.Errorf("FILES_MAX_UPLOAD_SIZE and FILES_MAX_DOWNLOAD_SIZE cannot be negative")
+	}
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return fmt.Errorf("TRUSTED_PROXIES entry %q is not an IP address or CIDR", proxy)
+		}
+	}
+	if c.RestoreMaxSize < 0 {
+		return fmt.Errorf("RESTORE_MAX_SIZE cannot be negative")
+	}
+	if strings.ContainsAny(c.ForwardBaseDomain, ":/ ") {
+		return fmt.Errorf("FORWARD_BASE_DOMAIN must be a bare domain name without scheme, port or path")
+	}
 	return nil
 }
 
@@ -58,6 +203,27 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+// getEnvBool gets a boolean environment variable with a fallback default value
+func getEnvBool(key string, defaultValue bool) bool {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvList gets a list environment variable (separated by commas or spaces)
+// with a fallback default value
+func getEnvList(key, defaultValue string) []string {
+	return strings.FieldsFunc(getEnv(key, defaultValue), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
 // getEnvInt64 gets an integer environment variable with a fallback default value
 func getEnvInt64(key string, defaultValue int64) int64 {
 	valueStr := os.Getenv(key)
@@ -70,3 +236,16 @@ func getEnvInt64(key string, defaultValue int64) int64 {
 	}
 	return value
 }
+
+// getEnvInt gets an integer environment variable with a fallback default value
+func getEnvInt(key string, defaultValue int) int {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
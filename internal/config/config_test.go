@@ -78,6 +78,28 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "invalid trusted proxy",
+			config: &Config{
+				Port:           "3000",
+				APIToken:       "test-token",
+				DockerHost:     "unix:///var/run/docker.sock",
+				DefaultImage:   "ubuntu:22.04",
+				TrustedProxies: []string{"10.0.0.0/8", "proxy.local"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown storage backend",
+			config: &Config{
+				Port:           "3000",
+				APIToken:       "test-token",
+				DockerHost:     "unix:///var/run/docker.sock",
+				DefaultImage:   "ubuntu:22.04",
+				StorageBackend: "postgres",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
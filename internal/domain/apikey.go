@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix identifies API key secrets
+const APIKeyPrefix = "vbk_"
+
+// API key scopes
+const (
+	ScopeWorkspacesRead  = "workspaces:read"  // List and inspect workspaces, ports and events
+	ScopeWorkspacesWrite = "workspaces:write" // Create, modify and delete workspaces (implies workspaces:read)
+	ScopeTerminal        = "terminal"         // Open terminals
+	ScopeForward         = "forward"          // Reach forwarded ports and tunnels
+	ScopeExec            = "exec"             // Run commands
+)
+
+// APIKeyScopes lists all valid scopes
+var APIKeyScopes = []string{ScopeWorkspacesRead, ScopeWorkspacesWrite, ScopeTerminal, ScopeForward, ScopeExec}
+
+// APIKey is a personal credential for scripts and CI. It acts as its user,
+// limited to its scopes.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`      // First characters of the secret, to recognise the key
+	SecretHash string     `json:"secret_hash"` // SHA-256 of the secret; the secret itself is never stored
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // nil = never expires
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// IsAPIKey reports whether a credential looks like an API key secret
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// IsValidScope reports whether scope is a known API key scope
+func IsValidScope(scope string) bool {
+	for _, known := range APIKeyScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExpired reports whether the key has expired at the given time
+func (k *APIKey) IsExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// HasScope reports whether the key grants scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, granted := range k.Scopes {
+		if granted == scope || (granted == ScopeWorkspacesWrite && scope == ScopeWorkspacesRead) {
+			return true
+		}
+	}
+	return false
+}
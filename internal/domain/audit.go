@@ -0,0 +1,73 @@
+package domain
+
+import "time"
+
+// AuditOutcome is the result of an audited action
+type AuditOutcome string
+
+const (
+	// AuditOutcomeSuccess means the action was carried out
+	AuditOutcomeSuccess AuditOutcome = "success"
+	// AuditOutcomeDenied means authentication or authorization rejected the action
+	AuditOutcomeDenied AuditOutcome = "denied"
+	// AuditOutcomeFailure means the action was attempted but failed
+	AuditOutcomeFailure AuditOutcome = "failure"
+)
+
+// Audited actions
+const (
+	AuditActionLogin               = "auth.login"
+	AuditActionLogout              = "auth.logout"
+	AuditActionUserCreate          = "user.create"
+	AuditActionUserDelete          = "user.delete"
+	AuditActionUserPassword        = "user.password"
+	AuditActionUserGitIdentity     = "user.git_identity"
+	AuditActionAPIKeyCreate        = "apikey.create"
+	AuditActionAPIKeyRevoke        = "apikey.revoke"
+	AuditActionGitCredentialCreate = "gitcredential.create"
+	AuditActionGitCredentialDelete = "gitcredential.delete"
+	AuditActionWorkspaceCreate     = "workspace.create"
+	AuditActionWorkspaceDelete     = "workspace.delete"
+	AuditActionWorkspaceClone      = "workspace.clone"
+	AuditActionWorkspaceImport     = "workspace.import"
+	AuditActionWorkspaceReset      = "workspace.reset"
+	AuditActionWorkspaceUpdate     = "workspace.update"
+	AuditActionWorkspaceApply      = "workspace.apply"
+	AuditActionPortsUpdate         = "workspace.ports.update"
+	AuditActionPortSettingsUpdate  = "workspace.port_settings.update"
+	AuditActionPortSettingsDelete  = "workspace.port_settings.delete"
+	AuditActionSnapshotCreate      = "workspace.snapshot.create"
+	AuditActionSnapshotDelete      = "workspace.snapshot.delete"
+	AuditActionSnapshotRestore     = "workspace.snapshot.restore"
+	AuditActionFileDownload        = "workspace.files.download"
+	AuditActionFileUpload          = "workspace.files.upload"
+	AuditActionFileMkdir           = "workspace.files.mkdir"
+	AuditActionFileRename          = "workspace.files.rename"
+	AuditActionFileDelete          = "workspace.files.delete"
+	AuditActionMemberSet           = "workspace.member.set"
+	AuditActionMemberRemove        = "workspace.member.remove"
+	AuditActionShareCreate         = "share.create"
+	AuditActionShareDelete         = "share.delete"
+	AuditActionExec                = "exec.run"
+	AuditActionExecCancel          = "exec.cancel"
+	AuditActionTerminalConnect     = "terminal.connect"
+	AuditActionTerminalDisconnect  = "terminal.disconnect"
+	AuditActionTunnel              = "tunnel.connect"
+	AuditActionBackup              = "admin.backup"
+	AuditActionRestore             = "admin.restore"
+)
+
+// AuditEntry is one record of the append-only audit log
+type AuditEntry struct {
+	Time        time.Time    `json:"time"`
+	ActorID     string       `json:"actor_id,omitempty"`
+	Actor       string       `json:"actor,omitempty"`      // Username at the time of the action
+	APIKeyID    string       `json:"api_key_id,omitempty"` // Set when the actor used an API key
+	Action      string       `json:"action"`
+	WorkspaceID string       `json:"workspace_id,omitempty"`
+	Target      string       `json:"target,omitempty"` // Other objects acted on, e.g. "port=3000"
+	SourceIP    string       `json:"source_ip"`
+	Outcome     AuditOutcome `json:"outcome"`
+	Status      int          `json:"status,omitempty"` // HTTP status of the request
+	Details     string       `json:"details,omitempty"`
+}
@@ -0,0 +1,23 @@
+package domain
+
+// Cookies that carry ViBox credentials. The API middleware reads them; the
+// port proxy strips them so they never reach workspace containers.
+const (
+	// SessionCookieName holds the opaque login session token
+	SessionCookieName = "vibox-session"
+	// LegacyTokenCookieName held the raw API token before user accounts existed
+	LegacyTokenCookieName = "vibox-token"
+	// ShareCookieName remembers a share token for the shared port
+	ShareCookieName = "vibox-share"
+	// ForwardCookieName keeps a forwarded page authenticated after a forward ticket
+	ForwardCookieName = "vibox-forward"
+)
+
+// CredentialCookieNames lists every cookie that carries a ViBox credential.
+// Add new credential cookies here so the proxy strips them too.
+var CredentialCookieNames = []string{
+	SessionCookieName,
+	LegacyTokenCookieName,
+	ShareCookieName,
+	ForwardCookieName,
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// GitRepository is a git repository cloned into a workspace container when it
+// is created or reset, unless its target path already exists
+type GitRepository struct {
+	URL        string `json:"url"`
+	Ref        string `json:"ref,omitempty"`        // Branch or tag (default: the remote's default branch)
+	Path       string `json:"path"`                 // Absolute target directory in the container
+	Credential string `json:"credential,omitempty"` // ID of a git credential of the workspace owner
+}
+
+// GitCredentialType is the kind of secret a git credential holds
+type GitCredentialType string
+
+const (
+	// GitCredentialToken is an access token sent over HTTPS
+	GitCredentialToken GitCredentialType = "token"
+	// GitCredentialSSHKey is a private SSH key, typically a deploy key
+	GitCredentialSSHKey GitCredentialType = "ssh_key"
+)
+
+// GitCredential is a secret a user stores to clone private repositories into
+// their workspaces. It is only handed to git for the duration of a clone.
+type GitCredential struct {
+	ID         string            `json:"id"`
+	UserID     string            `json:"user_id"`
+	Name       string            `json:"name"`
+	Type       GitCredentialType `json:"type"`
+	Username   string            `json:"username,omitempty"`    // HTTPS username sent with a token
+	Secret     string            `json:"secret"`                // Token or private key; never returned by the API
+	KnownHosts string            `json:"known_hosts,omitempty"` // known_hosts lines pinning the SSH host keys
+	CreatedAt  time.Time         `json:"created_at"`
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// PortShare is a public link that grants access to a single forwarded port of a
+// workspace without the API token
+type PortShare struct {
+	ID           string    `json:"id"`
+	WorkspaceID  string    `json:"workspace_id"`
+	Port         int       `json:"port"`
+	TokenHash    string    `json:"token_hash"`              // SHA-256 of the share token; the token itself is never stored
+	PasswordHash string    `json:"password_hash,omitempty"` // bcrypt hash; empty when no password is required
+	ReadOnly     bool      `json:"read_only"`               // Only GET/HEAD/OPTIONS requests are allowed
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// HasPassword reports whether the share is password protected
+func (s *PortShare) HasPassword() bool {
+	return s.PasswordHash != ""
+}
+
+// IsExpired reports whether the share has expired at the given time
+func (s *PortShare) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
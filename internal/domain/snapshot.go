@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SnapshotImageRepository is the image repository holding workspace snapshots:
+// snapshot <name> of workspace <id> is the image vibox-snapshot/<id>:<name>
+const SnapshotImageRepository = "vibox-snapshot"
+
+// snapshotNamePattern matches valid snapshot names; they become image tags
+var snapshotNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// Snapshot is a checkpoint of a workspace container's filesystem, stored as a
+// Docker image
+type Snapshot struct {
+	Name      string    `json:"name"`
+	Image     string    `json:"image"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size,omitempty"` // Bytes, as reported by Docker
+}
+
+// IsValidSnapshotName reports whether name can be used for a snapshot
+func IsValidSnapshotName(name string) bool {
+	return snapshotNamePattern.MatchString(name)
+}
+
+// SnapshotImage returns the image reference of a workspace snapshot
+func SnapshotImage(workspaceID, name string) string {
+	return SnapshotImageRepository + "/" + strings.ToLower(workspaceID) + ":" + name
+}
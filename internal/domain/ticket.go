@@ -0,0 +1,33 @@
+package domain
+
+// TicketPrefix identifies signed tickets
+const TicketPrefix = "vbt_"
+
+// TicketKind is the kind of route a ticket may be used for
+type TicketKind string
+
+const (
+	// TicketKindTerminal opens the terminal WebSocket of a workspace
+	TicketKindTerminal TicketKind = "terminal"
+	// TicketKindForward reaches a forwarded port of a workspace
+	TicketKindForward TicketKind = "forward"
+	// TicketKindTunnel opens a TCP tunnel to a port of a workspace
+	TicketKindTunnel TicketKind = "tunnel"
+)
+
+// IsValid reports whether the kind is known
+func (k TicketKind) IsValid() bool {
+	switch k {
+	case TicketKindTerminal, TicketKindForward, TicketKindTunnel:
+		return true
+	}
+	return false
+}
+
+// TicketTarget is what a ticket is bound to: one kind of route on one
+// workspace (and one port for forwards and tunnels)
+type TicketTarget struct {
+	Kind        TicketKind `json:"kind"`
+	WorkspaceID string     `json:"workspace_id"`
+	Port        int        `json:"port,omitempty"`
+}
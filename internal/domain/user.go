@@ -0,0 +1,45 @@
+package domain
+
+import "time"
+
+// UserRole determines what a user may do across ViBox
+type UserRole string
+
+const (
+	// UserRoleAdmin can manage users and every workspace
+	UserRoleAdmin UserRole = "admin"
+	// UserRoleUser is a regular account
+	UserRoleUser UserRole = "user"
+)
+
+// User is an account that can log in to ViBox
+type User struct {
+	ID           string    `json:"id"`
+	Username     string    `json:"username"`      // Unique, stored lowercase
+	PasswordHash string    `json:"password_hash"` // bcrypt hash; empty for single sign-on accounts
+	Role         UserRole  `json:"role"`
+	OIDCSubject  string    `json:"oidc_subject,omitempty"` // "<issuer>#<sub>" of a linked single sign-on identity
+	GitName      string    `json:"git_name,omitempty"`     // git user.name set up in the user's workspaces
+	GitEmail     string    `json:"git_email,omitempty"`    // git user.email set up in the user's workspaces
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// IsAdmin reports whether the user has the admin role
+func (u *User) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
+}
+
+// Session is a server-side login session. The client holds an opaque session
+// token; only its hash is stored.
+type Session struct {
+	TokenHash string    `json:"token_hash"` // SHA-256 of the session token
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// IsExpired reports whether the session has expired at the given time
+func (s *Session) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
@@ -1,6 +1,12 @@
 package domain
 
-import "time"
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // WorkspaceStatus represents the current status of a workspace
 type WorkspaceStatus string
@@ -23,17 +29,281 @@ type Workspace struct {
 	Config      WorkspaceConfig   `json:"config"`
 	Ports       map[string]string `json:"ports,omitempty"` // Port label mappings (port number -> service name)
 	Error       string            `json:"error,omitempty"` // Runtime field, not persisted
+
+	// PortSettings holds the access policy of forwarded ports (port number -> settings).
+	// Ports without settings are private.
+	PortSettings map[string]PortSettings `json:"port_settings,omitempty"`
+
+	// Owner is the ID of the user who created the workspace. Workspaces created
+	// before user accounts existed have no owner and are only visible to admins.
+	Owner   string            `json:"owner,omitempty"`
+	Members []WorkspaceMember `json:"members,omitempty"` // Other users with access
+
+	// Snapshot is the name of the snapshot the workspace was last restored
+	// from. Its container is then created from the snapshot image instead of
+	// Config.Image, without running the initialization scripts again. Reset
+	// clears it.
+	Snapshot string `json:"snapshot,omitempty"`
+
+	// PendingChanges lists the configuration changes (Pending* constants) made
+	// since the container was created that it does not reflect yet. Applying
+	// them recreates the container.
+	PendingChanges []string `json:"pending_changes,omitempty"`
+
+	// Version is incremented by the repository on every update. An update
+	// carrying an older version is rejected, so concurrent writers cannot
+	// silently overwrite each other; the API exposes it as the ETag.
+	Version int64 `json:"version"`
+}
+
+// WorkspaceRole is the access level of a user on a workspace
+type WorkspaceRole string
+
+const (
+	// WorkspaceRoleOwner can do everything, including deleting the workspace,
+	// managing members, port policies and share links
+	WorkspaceRoleOwner WorkspaceRole = "owner"
+	// WorkspaceRoleEditor can use the workspace: terminals, exec, tunnels, ports and reset
+	WorkspaceRoleEditor WorkspaceRole = "editor"
+	// WorkspaceRoleViewer can see the workspace, its forwarded ports and read-only terminals
+	WorkspaceRoleViewer WorkspaceRole = "viewer"
+)
+
+// WorkspaceMember grants a user a role on a workspace
+type WorkspaceMember struct {
+	UserID string        `json:"user_id"`
+	Role   WorkspaceRole `json:"role"`
+}
+
+// workspaceRoleRank orders roles from least to most privileged
+var workspaceRoleRank = map[WorkspaceRole]int{
+	WorkspaceRoleViewer: 1,
+	WorkspaceRoleEditor: 2,
+	WorkspaceRoleOwner:  3,
+}
+
+// IsValid reports whether the role is one of the known roles
+func (r WorkspaceRole) IsValid() bool {
+	return workspaceRoleRank[r] > 0
+}
+
+// Allows reports whether the role grants at least the required role.
+// The empty role (no access) allows nothing.
+func (r WorkspaceRole) Allows(required WorkspaceRole) bool {
+	return workspaceRoleRank[r] > 0 && workspaceRoleRank[r] >= workspaceRoleRank[required]
+}
+
+// RoleOf returns the role of a user on the workspace, or "" when the user has
+// no access. Admins are treated as owners of every workspace.
+func (w *Workspace) RoleOf(user *User) WorkspaceRole {
+	if user == nil {
+		return ""
+	}
+	if user.IsAdmin() || (w.Owner != "" && w.Owner == user.ID) {
+		return WorkspaceRoleOwner
+	}
+	for _, member := range w.Members {
+		if member.UserID == user.ID {
+			return member.Role
+		}
+	}
+	return ""
 }
 
 // WorkspaceConfig holds configuration for a workspace
 type WorkspaceConfig struct {
-	Image   string   `json:"image"`
-	Scripts []Script `json:"scripts,omitempty"`
+	Image   string            `json:"image"`
+	Scripts []Script          `json:"scripts,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`    // Environment variables of the container
+	Labels  map[string]string `json:"labels,omitempty"` // Docker labels of the container ("vibox." keys are reserved)
+
+	// Repositories are cloned before the scripts run
+	Repositories []GitRepository `json:"repositories,omitempty"`
+
+	// Resource limits; 0 uses the server defaults (MEMORY_LIMIT, CPU_LIMIT)
+	MemoryLimit int64 `json:"memory_limit,omitempty"` // Bytes
+	CPULimit    int64 `json:"cpu_limit,omitempty"`    // Nano CPUs (1000000000 = 1 CPU)
 }
 
+// Configuration changes that only take effect when the container is
+// recreated, as listed in Workspace.PendingChanges
+const (
+	PendingImage        = "image"
+	PendingScripts      = "scripts"
+	PendingEnv          = "env"
+	PendingLabels       = "labels"
+	PendingRepositories = "repositories"
+	PendingLimits       = "limits" // Limits are applied live; listed only if that failed
+)
+
 // Script represents an initialization script to be executed in the workspace
 type Script struct {
 	Name    string `json:"name"`
 	Content string `json:"content"`
 	Order   int    `json:"order"`
 }
+
+// Port label options, appended to a label as "name;option"
+const (
+	// PortOptionRewrite rewrites redirects, cookie paths and absolute links in
+	// HTML/CSS responses so apps work under the /forward/:id/:port/ prefix
+	PortOptionRewrite = "rewrite"
+)
+
+// PortLabel is the parsed form of a port label such as "web;rewrite"
+type PortLabel struct {
+	Name    string
+	Rewrite bool
+}
+
+// ParsePortLabel splits a port label into its display name and options.
+// Unknown options are ignored.
+func ParsePortLabel(label string) PortLabel {
+	parts := strings.Split(label, ";")
+	parsed := PortLabel{Name: strings.TrimSpace(parts[0])}
+	for _, opt := range parts[1:] {
+		switch strings.ToLower(strings.TrimSpace(opt)) {
+		case PortOptionRewrite:
+			parsed.Rewrite = true
+		}
+	}
+	return parsed
+}
+
+// PortLabel returns the parsed label for a port (zero value if the port has no label)
+func (w *Workspace) PortLabel(port int) PortLabel {
+	return ParsePortLabel(w.Ports[strconv.Itoa(port)])
+}
+
+// PortVisibility controls who may reach a forwarded port
+type PortVisibility string
+
+const (
+	PortVisibilityPrivate PortVisibility = "private" // Members of the workspace (or a share link)
+	PortVisibilityTeam    PortVisibility = "team"    // Any authenticated ViBox user
+	PortVisibilityPublic  PortVisibility = "public"  // No ViBox authentication
+)
+
+// PortSettings is the access policy of a forwarded port
+type PortSettings struct {
+	Visibility     PortVisibility `json:"visibility,omitempty"`      // Default: private
+	AllowedMethods []string       `json:"allowed_methods,omitempty"` // Empty = all methods
+	BasicAuth      *PortBasicAuth `json:"basic_auth,omitempty"`
+	CORS           *PortCORS      `json:"cors,omitempty"` // nil = leave CORS to the app
+	RateLimit      *PortRateLimit `json:"rate_limit,omitempty"`
+}
+
+// PortBasicAuth requires HTTP Basic credentials in addition to the visibility rules
+type PortBasicAuth struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash,omitempty"` // bcrypt hash
+}
+
+// PortCORS is the CORS policy answered by ViBox on behalf of the app
+type PortCORS struct {
+	AllowedOrigins   []string `json:"allowed_origins"` // "*" allows any origin
+	AllowedMethods   []string `json:"allowed_methods,omitempty"`
+	AllowedHeaders   []string `json:"allowed_headers,omitempty"`
+	AllowCredentials bool     `json:"allow_credentials,omitempty"`
+	MaxAge           int      `json:"max_age,omitempty"` // Seconds preflight results may be cached
+}
+
+// PortRateLimit limits requests per client IP
+type PortRateLimit struct {
+	RequestsPerMinute int `json:"requests_per_minute"`
+	Burst             int `json:"burst,omitempty"` // Default: RequestsPerMinute
+}
+
+// PortSettingsFor returns the settings of a port (zero value, i.e. private, if none)
+func (w *Workspace) PortSettingsFor(port int) PortSettings {
+	return w.PortSettings[strconv.Itoa(port)]
+}
+
+// Redacted returns a copy of the workspace safe for API responses (password hashes removed)
+func (w *Workspace) Redacted() *Workspace {
+	redacted := *w
+	if len(w.PortSettings) > 0 {
+		redacted.PortSettings = make(map[string]PortSettings, len(w.PortSettings))
+		for port, settings := range w.PortSettings {
+			if settings.BasicAuth != nil {
+				settings.BasicAuth = &PortBasicAuth{Username: settings.BasicAuth.Username}
+			}
+			redacted.PortSettings[port] = settings
+		}
+	}
+	return &redacted
+}
+
+// Clone returns a deep copy of the workspace, sharing no maps, slices or pointers
+func (w *Workspace) Clone() *Workspace {
+	if w == nil {
+		return nil
+	}
+	clone := *w
+	clone.Config.Scripts = slices.Clone(w.Config.Scripts)
+	clone.Config.Env = maps.Clone(w.Config.Env)
+	clone.Config.Labels = maps.Clone(w.Config.Labels)
+	clone.Config.Repositories = slices.Clone(w.Config.Repositories)
+	clone.PendingChanges = slices.Clone(w.PendingChanges)
+	clone.Members = slices.Clone(w.Members)
+	clone.Ports = maps.Clone(w.Ports)
+	if w.PortSettings != nil {
+		clone.PortSettings = make(map[string]PortSettings, len(w.PortSettings))
+		for port, settings := range w.PortSettings {
+			clone.PortSettings[port] = settings.clone()
+		}
+	}
+	return &clone
+}
+
+// clone returns a deep copy of the port settings
+func (s PortSettings) clone() PortSettings {
+	s.AllowedMethods = slices.Clone(s.AllowedMethods)
+	if s.BasicAuth != nil {
+		basicAuth := *s.BasicAuth
+		s.BasicAuth = &basicAuth
+	}
+	if s.CORS != nil {
+		cors := *s.CORS
+		cors.AllowedOrigins = slices.Clone(cors.AllowedOrigins)
+		cors.AllowedMethods = slices.Clone(cors.AllowedMethods)
+		cors.AllowedHeaders = slices.Clone(cors.AllowedHeaders)
+		s.CORS = &cors
+	}
+	if s.RateLimit != nil {
+		rateLimit := *s.RateLimit
+		s.RateLimit = &rateLimit
+	}
+	return s
+}
+
+// EffectiveVisibility returns the visibility, defaulting to private
+func (s PortSettings) EffectiveVisibility() PortVisibility {
+	if s.Visibility == "" {
+		return PortVisibilityPrivate
+	}
+	return s.Visibility
+}
+
+// AllowsMethod reports whether requests with the given HTTP method are allowed
+func (s PortSettings) AllowsMethod(method string) bool {
+	if len(s.AllowedMethods) == 0 {
+		return true
+	}
+	for _, allowed := range s.AllowedMethods {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsOrigin reports whether the CORS policy allows the origin
+func (c *PortCORS) AllowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
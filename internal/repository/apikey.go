@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+// APIKeyRepository defines the interface for API key storage operations
+type APIKeyRepository interface {
+	Create(key *domain.APIKey) error
+	Get(id string) (*domain.APIKey, error)
+	GetBySecretHash(secretHash string) (*domain.APIKey, error)
+	List() ([]*domain.APIKey, error)
+	Update(key *domain.APIKey) error
+	Delete(id string) error
+}
+
+// apiKeyData represents the API key data structure saved to disk
+type apiKeyData struct {
+	Keys map[string]*domain.APIKey `json:"keys"`
+}
+
+// FileAPIKeyRepository implements APIKeyRepository with file-based persistence
+// Records are copied on the way in and out so callers never share state with the store.
+type FileAPIKeyRepository struct {
+	mu       sync.RWMutex
+	store    map[string]*domain.APIKey
+	dataFile string
+}
+
+// NewAPIKeyRepository creates a new file-based API key repository
+// dataDir: directory where the api_keys.json file will be stored
+func NewAPIKeyRepository(dataDir string) (*FileAPIKeyRepository, error) {
+	utils.Info("Initializing file-based API key repository", "dataDir", dataDir)
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		utils.Error("Failed to create data directory", "error", err, "dataDir", dataDir)
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	repo := &FileAPIKeyRepository{
+		store:    make(map[string]*domain.APIKey),
+		dataFile: filepath.Join(dataDir, "api_keys.json"),
+	}
+
+	var data apiKeyData
+	if err := readJSONFile(repo.dataFile, &data); err != nil {
+		if !os.IsNotExist(err) {
+			utils.Error("Failed to load API key data", "error", err, "file", repo.dataFile)
+			return nil, fmt.Errorf("failed to load API keys: %w", err)
+		}
+		utils.Info("No existing API key data found, starting with empty repository")
+	} else if data.Keys != nil {
+		repo.store = data.Keys
+		utils.Info("Loaded API keys from disk", "count", len(repo.store))
+	}
+
+	return repo, nil
+}
+
+// NewMemoryAPIKeyRepository creates an API key repository without persistence
+func NewMemoryAPIKeyRepository() *FileAPIKeyRepository {
+	return &FileAPIKeyRepository{
+		store: make(map[string]*domain.APIKey),
+	}
+}
+
+// save writes all API keys to disk
+func (r *FileAPIKeyRepository) save() error {
+	if r.dataFile == "" {
+		// Persistence disabled
+		return nil
+	}
+
+	if err := writeJSONFile(r.dataFile, apiKeyData{Keys: r.store}); err != nil {
+		utils.Error("Failed to save API key data", "error", err, "file", r.dataFile)
+		return err
+	}
+
+	utils.Debug("API key data saved to disk", "file", r.dataFile, "count", len(r.store))
+	return nil
+}
+
+// copyAPIKey returns a deep copy of a key
+func copyAPIKey(key *domain.APIKey) *domain.APIKey {
+	copied := *key
+	copied.Scopes = append([]string(nil), key.Scopes...)
+	if key.ExpiresAt != nil {
+		expiresAt := *key.ExpiresAt
+		copied.ExpiresAt = &expiresAt
+	}
+	if key.LastUsedAt != nil {
+		lastUsedAt := *key.LastUsedAt
+		copied.LastUsedAt = &lastUsedAt
+	}
+	return &copied
+}
+
+// Create adds a new API key to the repository and persists to disk
+func (r *FileAPIKeyRepository) Create(key *domain.APIKey) error {
+	if key == nil {
+		return fmt.Errorf("API key cannot be nil")
+	}
+	if key.ID == "" {
+		return fmt.Errorf("API key ID cannot be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.store[key.ID]; exists {
+		return fmt.Errorf("API key with ID %s already exists", key.ID)
+	}
+
+	r.store[key.ID] = copyAPIKey(key)
+
+	if err := r.save(); err != nil {
+		delete(r.store, key.ID)
+		return fmt.Errorf("failed to persist API key: %w", err)
+	}
+
+	utils.Info("API key created in repository", "id", key.ID, "userID", key.UserID)
+	return nil
+}
+
+// Get retrieves an API key by ID
+func (r *FileAPIKeyRepository) Get(id string) (*domain.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, exists := r.store[id]
+	if !exists {
+		return nil, fmt.Errorf("API key with ID %s not found", id)
+	}
+
+	return copyAPIKey(key), nil
+}
+
+// GetBySecretHash retrieves an API key by the hash of its secret
+func (r *FileAPIKeyRepository) GetBySecretHash(secretHash string) (*domain.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.store {
+		if key.SecretHash == secretHash {
+			return copyAPIKey(key), nil
+		}
+	}
+
+	return nil, fmt.Errorf("API key not found")
+}
+
+// List returns all API keys in the repository
+func (r *FileAPIKeyRepository) List() ([]*domain.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]*domain.APIKey, 0, len(r.store))
+	for _, key := range r.store {
+		keys = append(keys, copyAPIKey(key))
+	}
+
+	return keys, nil
+}
+
+// Update updates an existing API key and persists to disk
+func (r *FileAPIKeyRepository) Update(key *domain.APIKey) error {
+	if key == nil {
+		return fmt.Errorf("API key cannot be nil")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, exists := r.store[key.ID]
+	if !exists {
+		return fmt.Errorf("API key with ID %s not found", key.ID)
+	}
+
+	r.store[key.ID] = copyAPIKey(key)
+
+	if err := r.save(); err != nil {
+		r.store[key.ID] = old
+		return fmt.Errorf("failed to persist API key update: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes an API key from the repository and persists to disk
+func (r *FileAPIKeyRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, exists := r.store[id]
+	if !exists {
+		return fmt.Errorf("API key with ID %s not found", id)
+	}
+
+	delete(r.store, id)
+
+	if err := r.save(); err != nil {
+		r.store[id] = key
+		return fmt.Errorf("failed to persist API key deletion: %w", err)
+	}
+
+	utils.Info("API key deleted from repository", "id", id)
+	return nil
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+// GitCredentialRepository defines the interface for git credential storage operations
+type GitCredentialRepository interface {
+	Create(credential *domain.GitCredential) error
+	Get(id string) (*domain.GitCredential, error)
+	List() ([]*domain.GitCredential, error)
+	Delete(id string) error
+}
+
+// gitCredentialData represents the git credential data structure saved to disk
+type gitCredentialData struct {
+	Credentials map[string]*domain.GitCredential `json:"credentials"`
+}
+
+// FileGitCredentialRepository implements GitCredentialRepository with file-based persistence
+// Secrets are stored as given (git needs them in clear), in a file only the owner can read.
+type FileGitCredentialRepository struct {
+	mu       sync.RWMutex
+	store    map[string]*domain.GitCredential
+	dataFile string
+}
+
+// NewGitCredentialRepository creates a new file-based git credential repository
+// dataDir: directory where the git_credentials.json file will be stored
+func NewGitCredentialRepository(dataDir string) (*FileGitCredentialRepository, error) {
+	utils.Info("Initializing file-based git credential repository", "dataDir", dataDir)
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		utils.Error("Failed to create data directory", "error", err, "dataDir", dataDir)
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	repo := &FileGitCredentialRepository{
+		store:    make(map[string]*domain.GitCredential),
+		dataFile: filepath.Join(dataDir, "git_credentials.json"),
+	}
+
+	var data gitCredentialData
+	if err := readJSONFile(repo.dataFile, &data); err != nil {
+		if !os.IsNotExist(err) {
+			utils.Error("Failed to load git credential data", "error", err, "file", repo.dataFile)
+			return nil, fmt.Errorf("failed to load git credentials: %w", err)
+		}
+		utils.Info("No existing git credential data found, starting with empty repository")
+	} else if data.Credentials != nil {
+		repo.store = data.Credentials
+		utils.Info("Loaded git credentials from disk", "count", len(repo.store))
+	}
+
+	return repo, nil
+}
+
+// NewMemoryGitCredentialRepository creates a git credential repository without persistence
+func NewMemoryGitCredentialRepository() *FileGitCredentialRepository {
+	return &FileGitCredentialRepository{
+		store: make(map[string]*domain.GitCredential),
+	}
+}
+
+// save writes all git credentials to disk
+func (r *FileGitCredentialRepository) save() error {
+	if r.dataFile == "" {
+		// Persistence disabled
+		return nil
+	}
+
+	if err := writeJSONFile(r.dataFile, gitCredentialData{Credentials: r.store}); err != nil {
+		utils.Error("Failed to save git credential data", "error", err, "file", r.dataFile)
+		return err
+	}
+
+	utils.Debug("Git credential data saved to disk", "file", r.dataFile, "count", len(r.store))
+	return nil
+}
+
+// Create adds a new git credential to the repository and persists to disk
+func (r *FileGitCredentialRepository) Create(credential *domain.GitCredential) error {
+	if credential == nil {
+		return fmt.Errorf("git credential cannot be nil")
+	}
+	if credential.ID == "" {
+		return fmt.Errorf("git credential ID cannot be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.store[credential.ID]; exists {
+		return fmt.Errorf("git credential with ID %s already exists", credential.ID)
+	}
+
+	copied := *credential
+	r.store[credential.ID] = &copied
+
+	if err := r.save(); err != nil {
+		delete(r.store, credential.ID)
+		return fmt.Errorf("failed to persist git credential: %w", err)
+	}
+
+	utils.Info("Git credential created in repository", "id", credential.ID, "userID", credential.UserID)
+	return nil
+}
+
+// Get retrieves a git credential by ID
+func (r *FileGitCredentialRepository) Get(id string) (*domain.GitCredential, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	credential, exists := r.store[id]
+	if !exists {
+		return nil, fmt.Errorf("git credential with ID %s not found", id)
+	}
+
+	copied := *credential
+	return &copied, nil
+}
+
+// List returns all git credentials in the repository
+func (r *FileGitCredentialRepository) List() ([]*domain.GitCredential, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	credentials := make([]*domain.GitCredential, 0, len(r.store))
+	for _, credential := range r.store {
+		copied := *credential
+		credentials = append(credentials, &copied)
+	}
+
+	return credentials, nil
+}
+
+// Delete removes a git credential from the repository and persists to disk
+func (r *FileGitCredentialRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	credential, exists := r.store[id]
+	if !exists {
+		return fmt.Errorf("git credential with ID %s not found", id)
+	}
+
+	delete(r.store, id)
+
+	if err := r.save(); err != nil {
+		r.store[id] = credential
+		return fmt.Errorf("failed to persist git credential deletion: %w", err)
+	}
+
+	utils.Info("Git credential deleted from repository", "id", id)
+	return nil
+}
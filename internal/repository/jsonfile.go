@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// writeJSONFile atomically writes v as indented JSON to path
+// (write to a temporary file first, then rename over the original)
+func writeJSONFile(path string, v any) error {
+	jsonData, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, jsonData, 0600); err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile, path); err != nil {
+		_ = os.Remove(tmpFile)
+		return fmt.Errorf("failed to rename temporary file: %w", err)
+	}
+
+	return nil
+}
+
+// readJSONFile reads JSON from path into v
+// The returned error satisfies os.IsNotExist when the file does not exist.
+func readJSONFile(path string, v any) error {
+	jsonData, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(jsonData, v); err != nil {
+		return fmt.Errorf("failed to unmarshal data: %w", err)
+	}
+
+	return nil
+}
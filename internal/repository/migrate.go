@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+// ErrCorruptData is matched (with errors.Is) by load errors of a workspaces.json
+// that cannot be read as any known format
+var ErrCorruptData = errors.New("workspace data file is corrupt")
+
+// ErrUnsupportedDataVersion is returned when workspaces.json was written by a
+// newer build than this one
+var ErrUnsupportedDataVersion = errors.New("workspace data file has an unsupported format version")
+
+// dataMigrations upgrade the raw workspaces.json document one format version at
+// a time: dataMigrations[i] turns version i into version i+1. Append new steps,
+// never edit or reorder existing ones.
+var dataMigrations = []func(doc map[string]any) error{
+	migrateDataV0,
+}
+
+// currentDataVersion is the format version written by this build
+var currentDataVersion = len(dataMigrations)
+
+// migrateDataV0 upgrades unversioned files. Workspaces start at version 1
+// (see domain.Workspace.Version); older ones were stored without one.
+func migrateDataV0(doc map[string]any) error {
+	workspaces, _ := doc["workspaces"].(map[string]any)
+	for id, value := range workspaces {
+		ws, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("workspace %s is not an object", id)
+		}
+		if version, _ := ws["version"].(json.Number); version == "" || version == "0" {
+			ws["version"] = json.Number("1")
+		}
+	}
+	return nil
+}
+
+// decodePersistentData parses a workspaces.json document, migrating it to the
+// current format. It also returns the format version the document had.
+func decodePersistentData(raw []byte) (*PersistentData, int, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var doc map[string]any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrCorruptData, err)
+	}
+	if doc == nil {
+		return nil, 0, fmt.Errorf("%w: document is null", ErrCorruptData)
+	}
+
+	version := 0
+	if value, ok := doc["version"]; ok {
+		number, ok := value.(json.Number)
+		if !ok {
+			return nil, 0, fmt.Errorf("%w: version is not a number", ErrCorruptData)
+		}
+		n, err := number.Int64()
+		if err != nil || n < 0 {
+			return nil, 0, fmt.Errorf("%w: invalid version %s", ErrCorruptData, number)
+		}
+		version = int(n)
+	}
+	if version > currentDataVersion {
+		return nil, version, fmt.Errorf("%w: version %d, this build supports up to %d", ErrUnsupportedDataVersion, version, currentDataVersion)
+	}
+
+	for v := version; v < currentDataVersion; v++ {
+		if err := dataMigrations[v](doc); err != nil {
+			return nil, version, fmt.Errorf("%w: failed to migrate from version %d: %v", ErrCorruptData, v, err)
+		}
+	}
+	doc["version"] = currentDataVersion
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return nil, version, fmt.Errorf("failed to marshal migrated data: %w", err)
+	}
+	var data PersistentData
+	if err := json.Unmarshal(migrated, &data); err != nil {
+		return nil, version, fmt.Errorf("%w: %v", ErrCorruptData, err)
+	}
+	return &data, version, nil
+}
+
+// backupDataFile copies a data file next to itself before it is rewritten in a
+// new format, e.g. workspaces.json.v0-20250101T120000.bak, and returns the copy's path
+func backupDataFile(path string, version int) (string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read data file for backup: %w", err)
+	}
+	backup := fmt.Sprintf("%s.v%d-%s.bak", path, version, time.Now().UTC().Format("20060102T150405"))
+	if err := os.WriteFile(backup, raw, 0600); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+	return backup, nil
+}
+
+// quarantineDataFile moves a corrupt data file aside so a fresh one can be
+// written, e.g. workspaces.json.corrupt-20250101T120000, and returns its new path
+func quarantineDataFile(path string) (string, error) {
+	quarantined := path + ".corrupt-" + time.Now().UTC().Format("20060102T150405")
+	if err := os.Rename(path, quarantined); err != nil {
+		return "", fmt.Errorf("failed to move corrupt data file aside: %w", err)
+	}
+	utils.Warn("Moved corrupt data file aside", "file", path, "movedTo", quarantined)
+	return quarantined, nil
+}
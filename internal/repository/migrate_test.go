@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// globDataFiles returns the files in dir whose name starts with prefix
+func globDataFiles(t *testing.T, dir, prefix string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, prefix+"*"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	return matches
+}
+
+func TestNewWorkspaceRepository_MigratesUnversionedData(t *testing.T) {
+	dir := t.TempDir()
+	dataFile := filepath.Join(dir, "workspaces.json")
+	legacy := `{"workspaces":{"ws-old":{"id":"ws-old","name":"old","created_at":"2025-01-01T00:00:00Z","config":{"image":"ubuntu:22.04"}}}}`
+	if err := os.WriteFile(dataFile, []byte(legacy), 0600); err != nil {
+		t.Fatalf("Failed to write data file: %v", err)
+	}
+
+	repo, err := NewWorkspaceRepository(dir)
+	if err != nil {
+		t.Fatalf("NewWorkspaceRepository failed: %v", err)
+	}
+	ws, err := repo.Get("ws-old")
+	if err != nil {
+		t.Fatalf("Expected legacy workspace to be loaded: %v", err)
+	}
+	if ws.Version != 1 {
+		t.Errorf("Expected migrated workspace at version 1, got %d", ws.Version)
+	}
+
+	// The file is rewritten in the current format...
+	var data PersistentData
+	if err := readJSONFile(dataFile, &data); err != nil {
+		t.Fatalf("Failed to read migrated file: %v", err)
+	}
+	if data.Version != currentDataVersion {
+		t.Errorf("Expected format version %d, got %d", currentDataVersion, data.Version)
+	}
+
+	// ...after backing up the original
+	backups := globDataFiles(t, dir, "workspaces.json.v0-")
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], ".bak") {
+		t.Fatalf("Expected one v0 backup, got %v", backups)
+	}
+	if raw, _ := os.ReadFile(backups[0]); string(raw) != legacy {
+		t.Errorf("Expected backup to hold the original file, got %s", raw)
+	}
+
+	// Loading current data migrates nothing
+	if _, err := NewWorkspaceRepository(dir); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if backups := globDataFiles(t, dir, "workspaces.json.v"); len(backups) != 1 {
+		t.Errorf("Expected no further backups, got %v", backups)
+	}
+}
+
+func TestNewWorkspaceRepository_CorruptData(t *testing.T) {
+	dir := t.TempDir()
+	dataFile := filepath.Join(dir, "workspaces.json")
+	if err := os.WriteFile(dataFile, []byte(`{"workspaces": {"ws-1": `), 0600); err != nil {
+		t.Fatalf("Failed to write data file: %v", err)
+	}
+
+	// Refuses to start rather than discarding the workspaces
+	if _, err := NewWorkspaceRepository(dir); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("Expected ErrCorruptData, got %v", err)
+	}
+	if raw, _ := os.ReadFile(dataFile); string(raw) != `{"workspaces": {"ws-1": ` {
+		t.Fatal("Expected corrupt file to be left untouched")
+	}
+
+	// Recovery moves the file aside and starts empty
+	repo, err := NewWorkspaceRepositoryWithRecovery(dir, true)
+	if err != nil {
+		t.Fatalf("Expected recovery to succeed, got %v", err)
+	}
+	if list, _ := repo.List(); len(list) != 0 {
+		t.Errorf("Expected empty repository after recovery, got %d workspaces", len(list))
+	}
+	if quarantined := globDataFiles(t, dir, "workspaces.json.corrupt-"); len(quarantined) != 1 {
+		t.Errorf("Expected corrupt file to be kept aside, got %v", quarantined)
+	}
+}
+
+func TestNewWorkspaceRepository_NullData(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "workspaces.json"), []byte("null"), 0600); err != nil {
+		t.Fatalf("Failed to write data file: %v", err)
+	}
+
+	if _, err := NewWorkspaceRepository(dir); !errors.Is(err, ErrCorruptData) {
+		t.Fatalf("Expected ErrCorruptData, got %v", err)
+	}
+}
+
+func TestNewWorkspaceRepository_NewerFormat(t *testing.T) {
+	dir := t.TempDir()
+	data, _ := json.Marshal(map[string]any{"version": currentDataVersion + 1, "workspaces": map[string]any{}})
+	if err := os.WriteFile(filepath.Join(dir, "workspaces.json"), data, 0600); err != nil {
+		t.Fatalf("Failed to write data file: %v", err)
+	}
+
+	// Data from a newer build is neither migrated nor discarded, even with recovery
+	if _, err := NewWorkspaceRepositoryWithRecovery(dir, true); !errors.Is(err, ErrUnsupportedDataVersion) {
+		t.Fatalf("Expected ErrUnsupportedDataVersion, got %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+// SessionRepository defines the interface for login session storage operations
+// Sessions are keyed by the hash of their token.
+type SessionRepository interface {
+	Create(session *domain.Session) error
+	Get(tokenHash string) (*domain.Session, error)
+	List() ([]*domain.Session, error)
+	Delete(tokenHash string) error
+	DeleteByUser(userID string) error
+}
+
+// sessionData represents the session data structure saved to disk
+type sessionData struct {
+	Sessions map[string]*domain.Session `json:"sessions"`
+}
+
+// FileSessionRepository implements SessionRepository with file-based persistence,
+// so logins survive server restarts
+type FileSessionRepository struct {
+	mu       sync.RWMutex
+	store    map[string]*domain.Session
+	dataFile string
+}
+
+// NewSessionRepository creates a new file-based session repository
+// dataDir: directory where the sessions.json file will be stored
+func NewSessionRepository(dataDir string) (*FileSessionRepository, error) {
+	utils.Info("Initializing file-based session repository", "dataDir", dataDir)
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		utils.Error("Failed to create data directory", "error", err, "dataDir", dataDir)
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	repo := &FileSessionRepository{
+		store:    make(map[string]*domain.Session),
+		dataFile: filepath.Join(dataDir, "sessions.json"),
+	}
+
+	var data sessionData
+	if err := readJSONFile(repo.dataFile, &data); err != nil {
+		if !os.IsNotExist(err) {
+			utils.Error("Failed to load session data", "error", err, "file", repo.dataFile)
+			return nil, fmt.Errorf("failed to load sessions: %w", err)
+		}
+		utils.Info("No existing session data found, starting with empty repository")
+	} else if data.Sessions != nil {
+		repo.store = data.Sessions
+		utils.Info("Loaded sessions from disk", "count", len(repo.store))
+	}
+
+	return repo, nil
+}
+
+// NewMemorySessionRepository creates a session repository without persistence
+func NewMemorySessionRepository() *FileSessionRepository {
+	return &FileSessionRepository{
+		store: make(map[string]*domain.Session),
+	}
+}
+
+// save writes all sessions to disk
+func (r *FileSessionRepository) save() error {
+	if r.dataFile == "" {
+		// Persistence disabled
+		return nil
+	}
+
+	if err := writeJSONFile(r.dataFile, sessionData{Sessions: r.store}); err != nil {
+		utils.Error("Failed to save session data", "error", err, "file", r.dataFile)
+		return err
+	}
+
+	utils.Debug("Session data saved to disk", "file", r.dataFile, "count", len(r.store))
+	return nil
+}
+
+// Create adds a new session to the repository and persists to disk
+func (r *FileSessionRepository) Create(session *domain.Session) error {
+	if session == nil {
+		return fmt.Errorf("session cannot be nil")
+	}
+	if session.TokenHash == "" {
+		return fmt.Errorf("session token hash cannot be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.store[session.TokenHash]; exists {
+		return fmt.Errorf("session already exists")
+	}
+
+	stored := *session
+	r.store[session.TokenHash] = &stored
+
+	if err := r.save(); err != nil {
+		delete(r.store, session.TokenHash)
+		return fmt.Errorf("failed to persist session: %w", err)
+	}
+
+	return nil
+}
+
+// Get retrieves a session by the hash of its token
+func (r *FileSessionRepository) Get(tokenHash string) (*domain.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, exists := r.store[tokenHash]
+	if !exists {
+		return nil, fmt.Errorf("session not found")
+	}
+
+	found := *session
+	return &found, nil
+}
+
+// List returns all sessions in the repository
+func (r *FileSessionRepository) List() ([]*domain.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sessions := make([]*domain.Session, 0, len(r.store))
+	for _, session := range r.store {
+		found := *session
+		sessions = append(sessions, &found)
+	}
+
+	return sessions, nil
+}
+
+// Delete removes a session from the repository and persists to disk
+func (r *FileSessionRepository) Delete(tokenHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, exists := r.store[tokenHash]
+	if !exists {
+		return fmt.Errorf("session not found")
+	}
+
+	delete(r.store, tokenHash)
+
+	if err := r.save(); err != nil {
+		r.store[tokenHash] = session
+		return fmt.Errorf("failed to persist session deletion: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteByUser removes all sessions of a user and persists to disk
+func (r *FileSessionRepository) DeleteByUser(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	removed := make(map[string]*domain.Session)
+	for tokenHash, session := range r.store {
+		if session.UserID == userID {
+			removed[tokenHash] = session
+			delete(r.store, tokenHash)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	if err := r.save(); err != nil {
+		for tokenHash, session := range removed {
+			r.store[tokenHash] = session
+		}
+		return fmt.Errorf("failed to persist session deletion: %w", err)
+	}
+
+	utils.Info("Sessions of user deleted from repository", "userID", userID, "count", len(removed))
+	return nil
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+// ShareRepository defines the interface for port share link storage operations
+type ShareRepository interface {
+	Create(share *domain.PortShare) error
+	Get(id string) (*domain.PortShare, error)
+	GetByTokenHash(tokenHash string) (*domain.PortShare, error)
+	List() ([]*domain.PortShare, error)
+	Delete(id string) error
+}
+
+// shareData represents the share data structure saved to disk
+type shareData struct {
+	Shares map[string]*domain.PortShare `json:"shares"`
+}
+
+// FileShareRepository implements ShareRepository with file-based persistence
+// Records are copied on the way in and out so callers never share state with the store.
+type FileShareRepository struct {
+	mu       sync.RWMutex
+	store    map[string]*domain.PortShare
+	dataFile string
+}
+
+// NewShareRepository creates a new file-based share repository
+// dataDir: directory where the shares.json file will be stored
+func NewShareRepository(dataDir string) (*FileShareRepository, error) {
+	utils.Info("Initializing file-based share repository", "dataDir", dataDir)
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		utils.Error("Failed to create data directory", "error", err, "dataDir", dataDir)
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	repo := &FileShareRepository{
+		store:    make(map[string]*domain.PortShare),
+		dataFile: filepath.Join(dataDir, "shares.json"),
+	}
+
+	var data shareData
+	if err := readJSONFile(repo.dataFile, &data); err != nil {
+		if !os.IsNotExist(err) {
+			utils.Error("Failed to load share data", "error", err, "file", repo.dataFile)
+			return nil, fmt.Errorf("failed to load shares: %w", err)
+		}
+		utils.Info("No existing share data found, starting with empty repository")
+	} else if data.Shares != nil {
+		repo.store = data.Shares
+		utils.Info("Loaded shares from disk", "count", len(repo.store))
+	}
+
+	return repo, nil
+}
+
+// NewMemoryShareRepository creates a share repository without persistence
+func NewMemoryShareRepository() *FileShareRepository {
+	return &FileShareRepository{
+		store: make(map[string]*domain.PortShare),
+	}
+}
+
+// save writes all shares to disk
+func (r *FileShareRepository) save() error {
+	if r.dataFile == "" {
+		// Persistence disabled
+		return nil
+	}
+
+	if err := writeJSONFile(r.dataFile, shareData{Shares: r.store}); err != nil {
+		utils.Error("Failed to save share data", "error", err, "file", r.dataFile)
+		return err
+	}
+
+	utils.Debug("Share data saved to disk", "file", r.dataFile, "count", len(r.store))
+	return nil
+}
+
+// Create adds a new share to the repository and persists to disk
+func (r *FileShareRepository) Create(share *domain.PortShare) error {
+	if share == nil {
+		return fmt.Errorf("share cannot be nil")
+	}
+	if share.ID == "" {
+		return fmt.Errorf("share ID cannot be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.store[share.ID]; exists {
+		return fmt.Errorf("share with ID %s already exists", share.ID)
+	}
+
+	stored := *share
+	r.store[share.ID] = &stored
+
+	if err := r.save(); err != nil {
+		delete(r.store, share.ID)
+		return fmt.Errorf("failed to persist share: %w", err)
+	}
+
+	utils.Info("Share created in repository", "id", share.ID, "workspaceID", share.WorkspaceID, "port", share.Port)
+	return nil
+}
+
+// Get retrieves a share by ID
+func (r *FileShareRepository) Get(id string) (*domain.PortShare, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	share, exists := r.store[id]
+	if !exists {
+		return nil, fmt.Errorf("share with ID %s not found", id)
+	}
+
+	found := *share
+	return &found, nil
+}
+
+// GetByTokenHash retrieves a share by the hash of its token
+func (r *FileShareRepository) GetByTokenHash(tokenHash string) (*domain.PortShare, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, share := range r.store {
+		if share.TokenHash == tokenHash {
+			found := *share
+			return &found, nil
+		}
+	}
+
+	return nil, fmt.Errorf("share not found")
+}
+
+// List returns all shares in the repository
+func (r *FileShareRepository) List() ([]*domain.PortShare, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	shares := make([]*domain.PortShare, 0, len(r.store))
+	for _, share := range r.store {
+		found := *share
+		shares = append(shares, &found)
+	}
+
+	return shares, nil
+}
+
+// Delete removes a share from the repository and persists to disk
+func (r *FileShareRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	share, exists := r.store[id]
+	if !exists {
+		return fmt.Errorf("share with ID %s not found", id)
+	}
+
+	delete(r.store, id)
+
+	if err := r.save(); err != nil {
+		r.store[id] = share
+		return fmt.Errorf("failed to persist share deletion: %w", err)
+	}
+
+	utils.Info("Share deleted from repository", "id", id)
+	return nil
+}
@@ -0,0 +1,327 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
+
+	_ "modernc.org/sqlite" // registers the pure-Go "sqlite" driver
+)
+
+// sqliteTimeFormat stores timestamps with fixed-width fractions so they sort as text
+const sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// sqliteMigrations upgrade the schema one step at a time. The number of
+// migrations applied is stored in PRAGMA user_version; append new steps,
+// never edit or reorder existing ones.
+var sqliteMigrations = []string{
+	// 1: workspaces, with the full record as JSON and the columns used for lookups
+	`CREATE TABLE workspaces (
+		id         TEXT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		owner      TEXT NOT NULL DEFAULT '',
+		status     TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		data       TEXT NOT NULL
+	);
+	CREATE INDEX idx_workspaces_owner ON workspaces(owner);`,
+	// 2: optimistic concurrency; rows written before keep version 0 like their JSON
+	`ALTER TABLE workspaces ADD COLUMN version INTEGER NOT NULL DEFAULT 0;`,
+}
+
+// SQLiteRepository implements WorkspaceRepository on a SQLite database.
+// Each change writes a single row instead of rewriting every workspace.
+type SQLiteRepository struct {
+	db   *sql.DB
+	path string
+}
+
+// NewSQLiteRepository opens (creating if needed) the database at path and
+// applies pending schema migrations
+func NewSQLiteRepository(path string) (*SQLiteRepository, error) {
+	utils.Info("Initializing SQLite workspace repository", "path", path)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		utils.Error("Failed to create data directory", "error", err, "path", path)
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// SQLite allows one writer at a time; a single connection serializes
+	// writes in Go instead of failing with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	repo := &SQLiteRepository{db: db, path: path}
+	if err := repo.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+// migrate applies the migrations newer than the database's schema version
+func (r *SQLiteRepository) migrate() error {
+	var version int
+	if err := r.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d)", version, len(sqliteMigrations))
+	}
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := r.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to start migration: %w", err)
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %w", i+1, err)
+		}
+		// PRAGMA does not accept bound parameters
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", i+1, err)
+		}
+		utils.Info("Applied database migration", "version", i+1)
+	}
+	return nil
+}
+
+// ImportJSON copies the workspaces of a file repository's workspaces.json in
+// dataDir into the database, then renames the file to workspaces.json.imported
+// so the import runs only once. Workspaces already in the database are kept.
+// It returns the number of workspaces imported; a missing file imports none.
+func (r *SQLiteRepository) ImportJSON(dataDir string) (int, error) {
+	dataFile := filepath.Join(dataDir, "workspaces.json")
+
+	raw, err := os.ReadFile(dataFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read %s: %w", dataFile, err)
+	}
+	// Older formats are migrated in memory; the file itself is kept as is
+	data, _, err := decodePersistentData(raw)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", dataFile, err)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start import: %w", err)
+	}
+	defer tx.Rollback()
+
+	imported := 0
+	for id, ws := range data.Workspaces {
+		if ws == nil {
+			continue
+		}
+		if ws.ID == "" {
+			ws.ID = id
+		}
+		args, err := workspaceRow(ws)
+		if err != nil {
+			return 0, err
+		}
+		result, err := tx.Exec(`INSERT INTO workspaces (id, name, owner, status, created_at, updated_at, data, version)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(id) DO NOTHING`, args...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to import workspace %s: %w", ws.ID, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			imported++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit import: %w", err)
+	}
+
+	if err := os.Rename(dataFile, dataFile+".imported"); err != nil {
+		return imported, fmt.Errorf("imported workspaces but failed to rename %s: %w", dataFile, err)
+	}
+
+	utils.Info("Imported workspaces from JSON file", "file", dataFile, "count", imported)
+	return imported, nil
+}
+
+// Close closes the database
+func (r *SQLiteRepository) Close() error {
+	return r.db.Close()
+}
+
+// Create adds a new workspace to the database
+func (r *SQLiteRepository) Create(ws *domain.Workspace) error {
+	if ws == nil {
+		return fmt.Errorf("workspace cannot be nil")
+	}
+	if ws.ID == "" {
+		return fmt.Errorf("workspace ID cannot be empty")
+	}
+
+	if ws.Version == 0 {
+		ws.Version = 1
+	}
+	args, err := workspaceRow(ws)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec(`INSERT INTO workspaces (id, name, owner, status, created_at, updated_at, data, version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(id) DO NOTHING`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to persist workspace: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		utils.Warn("Attempted to create duplicate workspace", "id", ws.ID)
+		return fmt.Errorf("workspace with ID %s already exists", ws.ID)
+	}
+
+	utils.Info("Workspace created in repository", "id", ws.ID, "name", ws.Name)
+	return nil
+}
+
+// Get retrieves a workspace by ID
+func (r *SQLiteRepository) Get(id string) (*domain.Workspace, error) {
+	if id == "" {
+		return nil, fmt.Errorf("workspace ID cannot be empty")
+	}
+
+	var data string
+	err := r.db.QueryRow(`SELECT data FROM workspaces WHERE id = ?`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		utils.Debug("Workspace not found in repository", "id", id)
+		return nil, fmt.Errorf("workspace with ID %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace: %w", err)
+	}
+
+	var ws domain.Workspace
+	if err := json.Unmarshal([]byte(data), &ws); err != nil {
+		return nil, fmt.Errorf("failed to decode workspace %s: %w", id, err)
+	}
+	return &ws, nil
+}
+
+// List returns all workspaces, oldest first
+func (r *SQLiteRepository) List() ([]*domain.Workspace, error) {
+	rows, err := r.db.Query(`SELECT id, data FROM workspaces ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+	defer rows.Close()
+
+	workspaces := make([]*domain.Workspace, 0)
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, fmt.Errorf("failed to read workspace: %w", err)
+		}
+		var ws domain.Workspace
+		if err := json.Unmarshal([]byte(data), &ws); err != nil {
+			// One corrupt row must not hide every other workspace
+			utils.Error("Failed to decode workspace, skipping", "id", id, "error", err)
+			continue
+		}
+		workspaces = append(workspaces, &ws)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+
+	utils.Debug("Listed workspaces from repository", "count", len(workspaces))
+	return workspaces, nil
+}
+
+// Update replaces an existing workspace in the database
+func (r *SQLiteRepository) Update(ws *domain.Workspace) error {
+	if ws == nil {
+		return fmt.Errorf("workspace cannot be nil")
+	}
+	if ws.ID == "" {
+		return fmt.Errorf("workspace ID cannot be empty")
+	}
+
+	expected := ws.Version
+	ws.Version++
+	args, err := workspaceRow(ws)
+	if err != nil {
+		ws.Version = expected
+		return err
+	}
+	result, err := r.db.Exec(`UPDATE workspaces SET name = ?, owner = ?, status = ?, created_at = ?, updated_at = ?, data = ?, version = ?
+		WHERE id = ? AND version = ?`, append(args[1:], ws.ID, expected)...)
+	if err != nil {
+		ws.Version = expected
+		return fmt.Errorf("failed to persist workspace: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		ws.Version = expected
+		var current int64
+		err := r.db.QueryRow(`SELECT version FROM workspaces WHERE id = ?`, ws.ID).Scan(&current)
+		if err == sql.ErrNoRows {
+			utils.Warn("Attempted to update non-existent workspace", "id", ws.ID)
+			return fmt.Errorf("workspace with ID %s not found", ws.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read workspace version: %w", err)
+		}
+		utils.Warn("Rejected stale workspace update", "id", ws.ID, "version", expected, "current", current)
+		return &VersionConflictError{ID: ws.ID, Expected: expected, Current: current}
+	}
+
+	utils.Info("Workspace updated in repository", "id", ws.ID, "status", ws.Status)
+	return nil
+}
+
+// Delete removes a workspace from the database
+func (r *SQLiteRepository) Delete(id string) error {
+	if id == "" {
+		return fmt.Errorf("workspace ID cannot be empty")
+	}
+
+	result, err := r.db.Exec(`DELETE FROM workspaces WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to persist workspace deletion: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		utils.Warn("Attempted to delete non-existent workspace", "id", id)
+		return fmt.Errorf("workspace with ID %s not found", id)
+	}
+
+	utils.Info("Workspace deleted from repository", "id", id)
+	return nil
+}
+
+// workspaceRow returns the column values of a workspace in table order
+func workspaceRow(ws *domain.Workspace) ([]any, error) {
+	data, err := json.Marshal(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal workspace: %w", err)
+	}
+	return []any{
+		ws.ID,
+		ws.Name,
+		ws.Owner,
+		string(ws.Status),
+		ws.CreatedAt.UTC().Format(sqliteTimeFormat),
+		ws.UpdatedAt.UTC().Format(sqliteTimeFormat),
+		string(data),
+		ws.Version,
+	}, nil
+}
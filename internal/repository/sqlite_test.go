@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+)
+
+func newTestSQLiteRepository(t *testing.T, dir string) *SQLiteRepository {
+	t.Helper()
+	repo, err := NewSQLiteRepository(filepath.Join(dir, "vibox.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository failed: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+func TestSQLiteRepository_CRUD(t *testing.T) {
+	repo := newTestSQLiteRepository(t, t.TempDir())
+
+	now := time.Now()
+	ws := &domain.Workspace{
+		ID:        "ws-sqlite-1",
+		Name:      "first",
+		Status:    domain.StatusCreating,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Config:    domain.WorkspaceConfig{Image: "ubuntu:22.04"},
+		Ports:     map[string]string{"8080": "web"},
+		Owner:     "user-1",
+	}
+	if err := repo.Create(ws); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := repo.Create(ws); err == nil {
+		t.Fatal("Expected error creating duplicate workspace")
+	}
+	if err := repo.Create(&domain.Workspace{}); err == nil {
+		t.Fatal("Expected error creating workspace with empty ID")
+	}
+
+	got, err := repo.Get(ws.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Name != "first" || got.Ports["8080"] != "web" || got.Owner != "user-1" || got.Config.Image != "ubuntu:22.04" {
+		t.Errorf("Get returned %+v", got)
+	}
+
+	second := &domain.Workspace{ID: "ws-sqlite-2", Name: "second", CreatedAt: now.Add(time.Second)}
+	if err := repo.Create(second); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	got.Status = domain.StatusRunning
+	if err := repo.Update(got); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got.Version != 2 {
+		t.Errorf("Expected version 2 after update, got %d", got.Version)
+	}
+
+	// Writing back a copy read before the update is a conflict
+	stale := got.Clone()
+	stale.Version = 1
+	var conflict *VersionConflictError
+	if err := repo.Update(stale); !errors.As(err, &conflict) || conflict.Current != 2 {
+		t.Fatalf("Expected version conflict at version 2, got %v", err)
+	}
+	if err := repo.Update(&domain.Workspace{ID: "ws-missing"}); err == nil {
+		t.Fatal("Expected error updating non-existent workspace")
+	}
+
+	list, err := repo.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != ws.ID || list[1].ID != second.ID {
+		t.Fatalf("Expected workspaces oldest first, got %v", list)
+	}
+	if list[0].Status != domain.StatusRunning {
+		t.Errorf("Expected updated status, got %q", list[0].Status)
+	}
+
+	if err := repo.Delete(ws.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := repo.Delete(ws.ID); err == nil {
+		t.Fatal("Expected error deleting workspace twice")
+	}
+	if _, err := repo.Get(ws.ID); err == nil {
+		t.Fatal("Expected error getting deleted workspace")
+	}
+}
+
+func TestSQLiteRepository_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vibox.db")
+
+	repo, err := NewSQLiteRepository(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository failed: %v", err)
+	}
+	if err := repo.Create(&domain.Workspace{ID: "ws-persist", Name: "persist", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	repo.Close()
+
+	// Reopening must not re-run applied migrations
+	repo, err = NewSQLiteRepository(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer repo.Close()
+
+	if _, err := repo.Get("ws-persist"); err != nil {
+		t.Fatalf("Expected workspace to survive reopening: %v", err)
+	}
+
+	var version int
+	if err := repo.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatalf("Failed to read schema version: %v", err)
+	}
+	if version != len(sqliteMigrations) {
+		t.Errorf("Expected schema version %d, got %d", len(sqliteMigrations), version)
+	}
+}
+
+func TestSQLiteRepository_ImportJSON(t *testing.T) {
+	dir := t.TempDir()
+
+	fileRepo, err := NewWorkspaceRepository(dir)
+	if err != nil {
+		t.Fatalf("NewWorkspaceRepository failed: %v", err)
+	}
+	for _, id := range []string{"ws-a", "ws-b"} {
+		if err := fileRepo.Create(&domain.Workspace{ID: id, Name: id, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	repo := newTestSQLiteRepository(t, dir)
+	// A workspace already in the database is kept as is
+	if err := repo.Create(&domain.Workspace{ID: "ws-a", Name: "existing", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	imported, err := repo.ImportJSON(dir)
+	if err != nil {
+		t.Fatalf("ImportJSON failed: %v", err)
+	}
+	if imported != 1 {
+		t.Errorf("Expected 1 imported workspace, got %d", imported)
+	}
+	if ws, err := repo.Get("ws-a"); err != nil || ws.Name != "existing" {
+		t.Errorf("Expected existing workspace to be kept, got %+v, %v", ws, err)
+	}
+	if _, err := repo.Get("ws-b"); err != nil {
+		t.Errorf("Expected ws-b to be imported: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "workspaces.json")); !os.IsNotExist(err) {
+		t.Error("Expected workspaces.json to be renamed after import")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "workspaces.json.imported")); err != nil {
+		t.Errorf("Expected workspaces.json.imported: %v", err)
+	}
+
+	// The import runs once
+	imported, err = repo.ImportJSON(dir)
+	if err != nil || imported != 0 {
+		t.Errorf("Expected second import to be a no-op, got %d, %v", imported, err)
+	}
+}
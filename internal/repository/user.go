@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+// UserRepository defines the interface for user account storage operations
+type UserRepository interface {
+	Create(user *domain.User) error
+	Get(id string) (*domain.User, error)
+	GetByUsername(username string) (*domain.User, error)
+	List() ([]*domain.User, error)
+	Update(user *domain.User) error
+	Delete(id string) error
+}
+
+// userData represents the user data structure saved to disk
+type userData struct {
+	Users map[string]*domain.User `json:"users"`
+}
+
+// FileUserRepository implements UserRepository with file-based persistence
+// Records are copied on the way in and out so callers never share state with the store.
+type FileUserRepository struct {
+	mu       sync.RWMutex
+	store    map[string]*domain.User
+	dataFile string
+}
+
+// NewUserRepository creates a new file-based user repository
+// dataDir: directory where the users.json file will be stored
+func NewUserRepository(dataDir string) (*FileUserRepository, error) {
+	utils.Info("Initializing file-based user repository", "dataDir", dataDir)
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		utils.Error("Failed to create data directory", "error", err, "dataDir", dataDir)
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	repo := &FileUserRepository{
+		store:    make(map[string]*domain.User),
+		dataFile: filepath.Join(dataDir, "users.json"),
+	}
+
+	var data userData
+	if err := readJSONFile(repo.dataFile, &data); err != nil {
+		if !os.IsNotExist(err) {
+			utils.Error("Failed to load user data", "error", err, "file", repo.dataFile)
+			return nil, fmt.Errorf("failed to load users: %w", err)
+		}
+		utils.Info("No existing user data found, starting with empty repository")
+	} else if data.Users != nil {
+		repo.store = data.Users
+		utils.Info("Loaded users from disk", "count", len(repo.store))
+	}
+
+	return repo, nil
+}
+
+// NewMemoryUserRepository creates a user repository without persistence
+func NewMemoryUserRepository() *FileUserRepository {
+	return &FileUserRepository{
+		store: make(map[string]*domain.User),
+	}
+}
+
+// save writes all users to disk
+func (r *FileUserRepository) save() error {
+	if r.dataFile == "" {
+		// Persistence disabled
+		return nil
+	}
+
+	if err := writeJSONFile(r.dataFile, userData{Users: r.store}); err != nil {
+		utils.Error("Failed to save user data", "error", err, "file", r.dataFile)
+		return err
+	}
+
+	utils.Debug("User data saved to disk", "file", r.dataFile, "count", len(r.store))
+	return nil
+}
+
+// Create adds a new user to the repository and persists to disk
+// Usernames are unique.
+func (r *FileUserRepository) Create(user *domain.User) error {
+	if user == nil {
+		return fmt.Errorf("user cannot be nil")
+	}
+	if user.ID == "" {
+		return fmt.Errorf("user ID cannot be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.store[user.ID]; exists {
+		return fmt.Errorf("user with ID %s already exists", user.ID)
+	}
+	for _, existing := range r.store {
+		if existing.Username == user.Username {
+			return fmt.Errorf("username %s is already taken", user.Username)
+		}
+	}
+
+	stored := *user
+	r.store[user.ID] = &stored
+
+	if err := r.save(); err != nil {
+		delete(r.store, user.ID)
+		return fmt.Errorf("failed to persist user: %w", err)
+	}
+
+	utils.Info("User created in repository", "id", user.ID, "username", user.Username)
+	return nil
+}
+
+// Get retrieves a user by ID
+func (r *FileUserRepository) Get(id string) (*domain.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, exists := r.store[id]
+	if !exists {
+		return nil, fmt.Errorf("user with ID %s not found", id)
+	}
+
+	found := *user
+	return &found, nil
+}
+
+// GetByUsername retrieves a user by username
+func (r *FileUserRepository) GetByUsername(username string) (*domain.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.store {
+		if user.Username == username {
+			found := *user
+			return &found, nil
+		}
+	}
+
+	return nil, fmt.Errorf("user %s not found", username)
+}
+
+// List returns all users in the repository
+func (r *FileUserRepository) List() ([]*domain.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]*domain.User, 0, len(r.store))
+	for _, user := range r.store {
+		found := *user
+		users = append(users, &found)
+	}
+
+	return users, nil
+}
+
+// Update updates an existing user and persists to disk
+func (r *FileUserRepository) Update(user *domain.User) error {
+	if user == nil {
+		return fmt.Errorf("user cannot be nil")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, exists := r.store[user.ID]
+	if !exists {
+		return fmt.Errorf("user with ID %s not found", user.ID)
+	}
+	for _, existing := range r.store {
+		if existing.ID != user.ID && existing.Username == user.Username {
+			return fmt.Errorf("username %s is already taken", user.Username)
+		}
+	}
+
+	stored := *user
+	r.store[user.ID] = &stored
+
+	if err := r.save(); err != nil {
+		r.store[user.ID] = old
+		return fmt.Errorf("failed to persist user update: %w", err)
+	}
+
+	utils.Info("User updated in repository", "id", user.ID)
+	return nil
+}
+
+// Delete removes a user from the repository and persists to disk
+func (r *FileUserRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, exists := r.store[id]
+	if !exists {
+		return fmt.Errorf("user with ID %s not found", id)
+	}
+
+	delete(r.store, id)
+
+	if err := r.save(); err != nil {
+		r.store[id] = user
+		return fmt.Errorf("failed to persist user deletion: %w", err)
+	}
+
+	utils.Info("User deleted from repository", "id", id)
+	return nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,17 +12,47 @@ import (
 	"github.com/1PercentSync/vibox/pkg/utils"
 )
 
-// WorkspaceRepository defines the interface for workspace storage operations
+// WorkspaceRepository defines the interface for workspace storage operations.
+// Workspaces are copied in and out: callers own the values they pass and get,
+// and changes only reach the repository through Create and Update.
 type WorkspaceRepository interface {
+	// Create stores a new workspace, starting at version 1 unless one is set
 	Create(ws *domain.Workspace) error
 	Get(id string) (*domain.Workspace, error)
 	List() ([]*domain.Workspace, error)
+	// Update replaces a workspace if ws.Version matches the stored version
+	// (a *VersionConflictError otherwise) and increments ws.Version
 	Update(ws *domain.Workspace) error
 	Delete(id string) error
 }
 
+// ErrVersionConflict is matched (with errors.Is) by the errors of updates
+// carrying a stale version
+var ErrVersionConflict = errors.New("workspace was modified concurrently")
+
+// VersionConflictError is returned by Update when the workspace was changed
+// since the caller read it
+type VersionConflictError struct {
+	ID       string
+	Expected int64 // Version carried by the update
+	Current  int64 // Version in the repository
+}
+
+// Error implements error
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("workspace %s was modified concurrently (version %d, current %d)", e.ID, e.Expected, e.Current)
+}
+
+// Is makes errors.Is(err, ErrVersionConflict) match
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
 // PersistentData represents the data structure saved to disk
 type PersistentData struct {
+	// Version is the file format version; older files are migrated on load
+	// (see dataMigrations). Files without one are version 0.
+	Version    int                          `json:"version"`
 	Workspaces map[string]*domain.Workspace `json:"workspaces"`
 }
 
@@ -34,7 +65,15 @@ type FileRepository struct {
 
 // NewWorkspaceRepository creates a new file-based repository
 // dataDir: directory where the workspaces.json file will be stored
+// A workspaces.json that cannot be read is an error (see ErrCorruptData).
 func NewWorkspaceRepository(dataDir string) (*FileRepository, error) {
+	return NewWorkspaceRepositoryWithRecovery(dataDir, false)
+}
+
+// NewWorkspaceRepositoryWithRecovery creates a new file-based repository.
+// With recoverCorrupt, a corrupt workspaces.json is moved aside and the
+// repository starts empty instead of failing.
+func NewWorkspaceRepositoryWithRecovery(dataDir string, recoverCorrupt bool) (*FileRepository, error) {
 	utils.Info("Initializing file-based workspace repository", "dataDir", dataDir)
 
 	// Create data directory if it doesn't exist
@@ -50,11 +89,18 @@ func NewWorkspaceRepository(dataDir string) (*FileRepository, error) {
 
 	// Load existing data from disk
 	if err := repo.load(); err != nil {
-		// If file doesn't exist or is corrupted, start with empty store
-		if !os.IsNotExist(err) {
-			utils.Warn("Failed to load existing data, starting fresh", "error", err)
-		} else {
+		switch {
+		case os.IsNotExist(err):
 			utils.Info("No existing data found, starting with empty repository")
+		case recoverCorrupt && errors.Is(err, ErrCorruptData):
+			// Never overwrite the only copy of the data: keep it for manual repair
+			utils.Error("Workspace data is corrupt, starting with empty repository", "error", err)
+			if _, err := quarantineDataFile(repo.dataFile); err != nil {
+				return nil, err
+			}
+		default:
+			utils.Error("Failed to load workspace data", "error", err, "file", repo.dataFile)
+			return nil, fmt.Errorf("failed to load workspaces: %w", err)
 		}
 		repo.store = make(map[string]*domain.Workspace)
 	} else {
@@ -88,6 +134,7 @@ func (r *FileRepository) save() error {
 
 	// Prepare data for serialization
 	data := PersistentData{
+		Version:    currentDataVersion,
 		Workspaces: r.store,
 	}
 
@@ -130,11 +177,11 @@ func (r *FileRepository) load() error {
 		return err
 	}
 
-	// Unmarshal JSON
-	var data PersistentData
-	if err := json.Unmarshal(jsonData, &data); err != nil {
-		utils.Error("Failed to unmarshal workspace data", "error", err)
-		return fmt.Errorf("failed to unmarshal data: %w", err)
+	// Decode, migrating older formats
+	data, version, err := decodePersistentData(jsonData)
+	if err != nil {
+		utils.Error("Failed to decode workspace data", "error", err)
+		return err
 	}
 
 	// Load workspaces into store
@@ -144,6 +191,18 @@ func (r *FileRepository) load() error {
 		r.store = make(map[string]*domain.Workspace)
 	}
 
+	// Rewrite migrated data in the current format, keeping the original
+	if version < currentDataVersion {
+		backup, err := backupDataFile(r.dataFile, version)
+		if err != nil {
+			return fmt.Errorf("failed to back up data before migration: %w", err)
+		}
+		if err := r.save(); err != nil {
+			return fmt.Errorf("failed to save migrated data: %w", err)
+		}
+		utils.Info("Migrated workspace data", "file", r.dataFile, "from", version, "to", currentDataVersion, "backup", backup)
+	}
+
 	utils.Debug("Workspace data loaded from disk", "file", r.dataFile, "count", len(r.store))
 	return nil
 }
@@ -165,7 +224,10 @@ func (r *FileRepository) Create(ws *domain.Workspace) error {
 		return fmt.Errorf("workspace with ID %s already exists", ws.ID)
 	}
 
-	r.store[ws.ID] = ws
+	if ws.Version == 0 {
+		ws.Version = 1
+	}
+	r.store[ws.ID] = ws.Clone()
 
 	// Persist to disk
 	if err := r.save(); err != nil {
@@ -194,7 +256,7 @@ func (r *FileRepository) Get(id string) (*domain.Workspace, error) {
 	}
 
 	utils.Debug("Workspace retrieved from repository", "id", id)
-	return ws, nil
+	return ws.Clone(), nil
 }
 
 // List returns all workspaces in the repository
@@ -204,7 +266,7 @@ func (r *FileRepository) List() ([]*domain.Workspace, error) {
 
 	workspaces := make([]*domain.Workspace, 0, len(r.store))
 	for _, ws := range r.store {
-		workspaces = append(workspaces, ws)
+		workspaces = append(workspaces, ws.Clone())
 	}
 
 	utils.Debug("Listed workspaces from repository", "count", len(workspaces))
@@ -223,19 +285,24 @@ func (r *FileRepository) Update(ws *domain.Workspace) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.store[ws.ID]; !exists {
+	oldWs, exists := r.store[ws.ID]
+	if !exists {
 		utils.Warn("Attempted to update non-existent workspace", "id", ws.ID)
 		return fmt.Errorf("workspace with ID %s not found", ws.ID)
 	}
+	if ws.Version != oldWs.Version {
+		utils.Warn("Rejected stale workspace update", "id", ws.ID, "version", ws.Version, "current", oldWs.Version)
+		return &VersionConflictError{ID: ws.ID, Expected: ws.Version, Current: oldWs.Version}
+	}
 
-	// Store old workspace for rollback
-	oldWs := r.store[ws.ID]
-	r.store[ws.ID] = ws
+	ws.Version++
+	r.store[ws.ID] = ws.Clone()
 
 	// Persist to disk
 	if err := r.save(); err != nil {
 		// Rollback in-memory change
 		r.store[ws.ID] = oldWs
+		ws.Version--
 		return fmt.Errorf("failed to persist workspace: %w", err)
 	}
 
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"testing"
@@ -161,6 +162,10 @@ func TestUpdate(t *testing.T) {
 	}
 	repo.Create(ws)
 
+	// Updates carry the version the workspace was read at
+	ws, _ = repo.Get("ws-test-006")
+	readVersion := ws.Version
+
 	// Test successful update
 	ws.Name = "updated-name"
 	ws.Status = domain.StatusRunning
@@ -177,6 +182,23 @@ func TestUpdate(t *testing.T) {
 	if retrieved.Status != domain.StatusRunning {
 		t.Errorf("Expected Status %s, got %s", domain.StatusRunning, retrieved.Status)
 	}
+	if retrieved.Version != readVersion+1 || ws.Version != readVersion+1 {
+		t.Errorf("Expected version %d after update, got %d (caller %d)", readVersion+1, retrieved.Version, ws.Version)
+	}
+
+	// Test stale update
+	stale := retrieved.Clone()
+	stale.Version = readVersion
+	err = repo.Update(stale)
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("Expected version conflict for stale update, got %v", err)
+	}
+
+	// Returned workspaces are copies
+	retrieved.Name = "changed-without-update"
+	if again, _ := repo.Get("ws-test-006"); again.Name != "updated-name" {
+		t.Errorf("Expected stored workspace to be unaffected by caller changes, got %s", again.Name)
+	}
 
 	// Test update non-existent workspace
 	wsNonExistent := &domain.Workspace{
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+const (
+	// apiKeyDisplayPrefixLength is how much of a secret is kept to recognise the key
+	apiKeyDisplayPrefixLength = len(domain.APIKeyPrefix) + 8
+	// apiKeyLastUsedResolution limits how often last-used timestamps are persisted
+	apiKeyLastUsedResolution = time.Minute
+)
+
+var (
+	// ErrAPIKeyNotFound is returned when an API key does not exist for the user
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrAPIKeyInvalid is returned when an API key secret is unknown or expired
+	ErrAPIKeyInvalid = errors.New("invalid or expired API key")
+	// ErrInvalidAPIKeyRequest is returned when a key's name, scopes or expiry are not acceptable
+	ErrInvalidAPIKeyRequest = errors.New("invalid API key request")
+)
+
+// CreateAPIKeyRequest represents a request to create an API key
+type CreateAPIKeyRequest struct {
+	Name      string   `json:"name" binding:"required"`
+	Scopes    []string `json:"scopes" binding:"required"`
+	ExpiresIn int      `json:"expires_in,omitempty"` // Seconds; 0 = never expires
+}
+
+// CreateAPIKey creates an API key for a user and returns it along with the plain
+// secret. The secret is only available here; just its hash is stored.
+func (s *AuthService) CreateAPIKey(userID string, req CreateAPIKeyRequest) (*domain.APIKey, string, error) {
+	utils.Info("Creating API key", "userID", userID, "name", req.Name, "scopes", req.Scopes)
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 100 {
+		return nil, "", fmt.Errorf("%w: name must be 1-100 characters", ErrInvalidAPIKeyRequest)
+	}
+	if len(req.Scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKeyRequest)
+	}
+	scopes := make([]string, 0, len(req.Scopes))
+	seen := make(map[string]bool, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !domain.IsValidScope(scope) {
+			return nil, "", fmt.Errorf("%w: unknown scope %q (valid: %s)", ErrInvalidAPIKeyRequest, scope, strings.Join(domain.APIKeyScopes, ", "))
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if req.ExpiresIn < 0 {
+		return nil, "", fmt.Errorf("%w: expires_in cannot be negative", ErrInvalidAPIKeyRequest)
+	}
+
+	secret, err := utils.GenerateToken(domain.APIKeyPrefix)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	key := &domain.APIKey{
+		ID:         utils.GenerateAPIKeyID(),
+		UserID:     userID,
+		Name:       name,
+		Prefix:     secret[:apiKeyDisplayPrefixLength],
+		SecretHash: utils.HashToken(secret),
+		Scopes:     scopes,
+		CreatedAt:  now,
+	}
+	if req.ExpiresIn > 0 {
+		expiresAt := now.Add(time.Duration(req.ExpiresIn) * time.Second)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := s.apiKeys.Create(key); err != nil {
+		utils.Error("Failed to save API key", "error", err)
+		return nil, "", fmt.Errorf("failed to save API key: %w", err)
+	}
+
+	return key, secret, nil
+}
+
+// ListAPIKeys returns the API keys of a user, newest first
+func (s *AuthService) ListAPIKeys(userID string) ([]*domain.APIKey, error) {
+	keys, err := s.apiKeys.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	result := make([]*domain.APIKey, 0)
+	for _, key := range keys {
+		if key.UserID == userID {
+			result = append(result, key)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+// RevokeAPIKey deletes an API key of a user
+func (s *AuthService) RevokeAPIKey(userID, keyID string) error {
+	utils.Info("Revoking API key", "userID", userID, "keyID", keyID)
+
+	key, err := s.apiKeys.Get(keyID)
+	if err != nil || key.UserID != userID {
+		return fmt.Errorf("%w: %s", ErrAPIKeyNotFound, keyID)
+	}
+
+	if err := s.apiKeys.Delete(keyID); err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	return nil
+}
+
+// AuthenticateAPIKey resolves an API key secret to its key and user, recording
+// when the key was last used
+func (s *AuthService) AuthenticateAPIKey(secret string) (*domain.User, *domain.APIKey, error) {
+	if !domain.IsAPIKey(secret) {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	key, err := s.apiKeys.GetBySecretHash(utils.HashToken(secret))
+	if err != nil {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+	now := time.Now()
+	if key.IsExpired(now) {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	user, err := s.users.Get(key.UserID)
+	if err != nil {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	// Persist the last-used time at a coarse resolution to avoid a write per request
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyLastUsedResolution {
+		key.LastUsedAt = &now
+		if err := s.apiKeys.Update(key); err != nil {
+			utils.Warn("Failed to record API key use", "keyID", key.ID, "error", err)
+		}
+	}
+
+	return user, key, nil
+}
+
+// deleteAPIKeysOf removes all API keys of a user
+func (s *AuthService) deleteAPIKeysOf(userID string) {
+	keys, err := s.ListAPIKeys(userID)
+	if err != nil {
+		utils.Warn("Failed to list API keys of deleted user", "userID", userID, "error", err)
+		return
+	}
+	for _, key := range keys {
+		if err := s.apiKeys.Delete(key.ID); err != nil {
+			utils.Warn("Failed to delete API key of deleted user", "keyID", key.ID, "error", err)
+		}
+	}
+}
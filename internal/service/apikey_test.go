@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+)
+
+func TestAuthService_APIKeyLifecycle(t *testing.T) {
+	svc := newTestAuthService(t)
+
+	alice, err := svc.CreateUser(CreateUserRequest{Username: "alice", Password: "correct-horse"})
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	if _, _, err := svc.CreateAPIKey(alice.ID, CreateAPIKeyRequest{Name: "ci", Scopes: []string{"root"}}); !errors.Is(err, ErrInvalidAPIKeyRequest) {
+		t.Errorf("Expected ErrInvalidAPIKeyRequest for unknown scope, got %v", err)
+	}
+
+	key, secret, err := svc.CreateAPIKey(alice.ID, CreateAPIKeyRequest{
+		Name:   "ci",
+		Scopes: []string{domain.ScopeWorkspacesWrite, domain.ScopeExec},
+	})
+	if err != nil {
+		t.Fatalf("CreateAPIKey failed: %v", err)
+	}
+	if !strings.HasPrefix(secret, domain.APIKeyPrefix) || !strings.HasPrefix(secret, key.Prefix) {
+		t.Errorf("Expected secret %q to start with %q", secret, key.Prefix)
+	}
+	if key.SecretHash == secret {
+		t.Error("Expected only the secret hash to be stored")
+	}
+
+	user, authKey, err := svc.AuthenticateAPIKey(secret)
+	if err != nil || user.ID != alice.ID || authKey.ID != key.ID {
+		t.Fatalf("Expected key to authenticate as alice, got %+v, %v", user, err)
+	}
+	if !authKey.HasScope(domain.ScopeWorkspacesRead) || authKey.HasScope(domain.ScopeTerminal) {
+		t.Errorf("Unexpected scopes %v", authKey.Scopes)
+	}
+	if keys, _ := svc.ListAPIKeys(alice.ID); len(keys) != 1 || keys[0].LastUsedAt == nil {
+		t.Errorf("Expected last-used time to be recorded, got %+v", keys)
+	}
+
+	// Keys belong to their user
+	admin, _ := svc.tokenUser()
+	if err := svc.RevokeAPIKey(admin.ID, key.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("Expected ErrAPIKeyNotFound revoking another user's key, got %v", err)
+	}
+	if err := svc.RevokeAPIKey(alice.ID, key.ID); err != nil {
+		t.Fatalf("RevokeAPIKey failed: %v", err)
+	}
+	if _, _, err := svc.AuthenticateAPIKey(secret); !errors.Is(err, ErrAPIKeyInvalid) {
+		t.Errorf("Expected revoked key to be rejected, got %v", err)
+	}
+}
+
+func TestAuthService_APIKeyExpiryAndUserDeletion(t *testing.T) {
+	svc := newTestAuthService(t)
+
+	alice, _ := svc.CreateUser(CreateUserRequest{Username: "alice", Password: "correct-horse"})
+
+	expiring, expiringSecret, err := svc.CreateAPIKey(alice.ID, CreateAPIKeyRequest{
+		Name: "short", Scopes: []string{domain.ScopeForward}, ExpiresIn: 60,
+	})
+	if err != nil {
+		t.Fatalf("CreateAPIKey failed: %v", err)
+	}
+	past := time.Now().Add(-time.Second)
+	expiring.ExpiresAt = &past
+	if err := svc.apiKeys.Update(expiring); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if _, _, err := svc.AuthenticateAPIKey(expiringSecret); !errors.Is(err, ErrAPIKeyInvalid) {
+		t.Errorf("Expected expired key to be rejected, got %v", err)
+	}
+
+	_, secret, _ := svc.CreateAPIKey(alice.ID, CreateAPIKeyRequest{Name: "ci", Scopes: []string{domain.ScopeForward}})
+	if err := svc.DeleteUser(alice.ID); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if _, _, err := svc.AuthenticateAPIKey(secret); !errors.Is(err, ErrAPIKeyInvalid) {
+		t.Errorf("Expected keys of a deleted user to be rejected, got %v", err)
+	}
+	if keys, _ := svc.apiKeys.List(); len(keys) != 0 {
+		t.Errorf("Expected keys of a deleted user to be removed, got %d", len(keys))
+	}
+}
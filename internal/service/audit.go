@@ -0,0 +1,266 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/config"
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+const (
+	// auditFileName is the active audit log under the data directory
+	auditFileName = "audit.log"
+	// defaultAuditMaxSize rotates the audit log when no size is configured
+	defaultAuditMaxSize = 10 * 1024 * 1024
+	// defaultAuditMaxFiles is the number of rotated files kept when none is configured
+	defaultAuditMaxFiles = 5
+	// defaultAuditQueryLimit is the number of entries returned when no limit is given
+	defaultAuditQueryLimit = 100
+	// maxAuditQueryLimit bounds the entries returned by one query
+	maxAuditQueryLimit = 1000
+)
+
+// AuditFilter selects audit entries; zero fields match everything
+type AuditFilter struct {
+	Actor       string // User ID or username
+	WorkspaceID string
+	Action      string
+	Since       time.Time
+	Until       time.Time
+	Limit       int // Newest entries first; defaults to 100, at most 1000
+}
+
+// matches reports whether an entry passes the filter
+func (f AuditFilter) matches(entry *domain.AuditEntry) bool {
+	if f.Actor != "" && entry.ActorID != f.Actor && entry.Actor != f.Actor {
+		return false
+	}
+	if f.WorkspaceID != "" && entry.WorkspaceID != f.WorkspaceID {
+		return false
+	}
+	if f.Action != "" && entry.Action != f.Action {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && entry.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// AuditService appends audit entries to JSON-lines files under the data
+// directory, rotating them by size (audit.log, audit.log.1, ... audit.log.N)
+type AuditService struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	mu   sync.Mutex // Guards the active file; held by every Record
+	file *os.File
+	size int64
+
+	// rotateMu keeps the files from being rotated while a query reads them.
+	// Taken after mu, never the other way around.
+	rotateMu sync.RWMutex
+}
+
+// NewAuditService creates a new audit service instance and opens the active log file
+func NewAuditService(cfg *config.Config) (*AuditService, error) {
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	maxSize := cfg.AuditMaxSize
+	if maxSize <= 0 {
+		maxSize = defaultAuditMaxSize
+	}
+	maxFiles := cfg.AuditMaxFiles
+	if maxFiles <= 0 {
+		maxFiles = defaultAuditMaxFiles
+	}
+
+	s := &AuditService{
+		path:     filepath.Join(cfg.DataDir, auditFileName),
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	utils.Info("Initializing audit service", "path", s.path, "maxSize", maxSize, "maxFiles", maxFiles)
+	return s, nil
+}
+
+// Record appends an entry to the audit log. Failures are logged, never returned,
+// so auditing cannot break the action being audited. A nil service records nothing.
+func (s *AuditService) Record(entry domain.AuditEntry) {
+	if s == nil {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		utils.Error("Failed to encode audit entry", "action", entry.Action, "error", err)
+		return
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		utils.Error("Audit log is closed, dropping entry", "action", entry.Action)
+		return
+	}
+	if s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		s.rotateMu.Lock()
+		err := s.rotate()
+		s.rotateMu.Unlock()
+		if err != nil {
+			utils.Error("Failed to rotate audit log", "error", err)
+		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		utils.Error("Failed to write audit entry", "action", entry.Action, "error", err)
+	}
+}
+
+// Query returns the entries matching the filter, newest first, searching the
+// active and all rotated log files. It does not block Record, except for a
+// rotation that falls due while it runs.
+func (s *AuditService) Query(filter AuditFilter) ([]*domain.AuditEntry, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditQueryLimit
+	}
+	if limit > maxAuditQueryLimit {
+		limit = maxAuditQueryLimit
+	}
+
+	s.rotateMu.RLock()
+	defer s.rotateMu.RUnlock()
+
+	// Newest file first; within a file entries are in append order. A line
+	// still being appended to the active file fails to decode and is skipped.
+	results := make([]*domain.AuditEntry, 0)
+	for i := 0; i <= s.maxFiles && len(results) < limit; i++ {
+		entries, err := readAuditFile(s.rotatedPath(i), filter)
+		if err != nil {
+			return nil, err
+		}
+		for j := len(entries) - 1; j >= 0 && len(results) < limit; j-- {
+			results = append(results, entries[j])
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Time.After(results[j].Time)
+	})
+	return results, nil
+}
+
+// Close closes the active log file
+func (s *AuditService) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// open opens the active log file for appending
+func (s *AuditService) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+// rotate shifts audit.log.N-1 to audit.log.N (dropping the oldest) and starts a new active file
+// Must be called with both mutexes held.
+func (s *AuditService) rotate() error {
+	if err := s.file.Close(); err != nil {
+		utils.Warn("Failed to close audit log before rotation", "error", err)
+	}
+	s.file = nil
+
+	if err := os.Remove(s.rotatedPath(s.maxFiles)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		utils.Warn("Failed to remove oldest audit log", "error", err)
+	}
+	for i := s.maxFiles - 1; i >= 0; i-- {
+		if err := os.Rename(s.rotatedPath(i), s.rotatedPath(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			utils.Warn("Failed to rotate audit log file", "file", s.rotatedPath(i), "error", err)
+		}
+	}
+
+	utils.Info("Rotated audit log", "path", s.path)
+	return s.open()
+}
+
+// rotatedPath returns the path of the i-th log file (0 = active)
+func (s *AuditService) rotatedPath(i int) string {
+	if i == 0 {
+		return s.path
+	}
+	return s.path + "." + strconv.Itoa(i)
+}
+
+// readAuditFile reads the matching entries of one log file; a missing file has none
+func readAuditFile(path string, filter AuditFilter) ([]*domain.AuditEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	var entries []*domain.AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry domain.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A torn final line (e.g. after a crash) must not hide the rest
+			continue
+		}
+		if filter.matches(&entry) {
+			entries = append(entries, &entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/config"
+	"github.com/1PercentSync/vibox/internal/domain"
+)
+
+func TestAuditService_RecordAndQuery(t *testing.T) {
+	dir := t.TempDir()
+	svc, err := NewAuditService(&config.Config{DataDir: dir})
+	if err != nil {
+		t.Fatalf("NewAuditService failed: %v", err)
+	}
+	defer svc.Close()
+
+	base := time.Now().Add(-time.Hour)
+	svc.Record(domain.AuditEntry{Time: base, ActorID: "user-a", Actor: "alice", Action: domain.AuditActionWorkspaceCreate, WorkspaceID: "ws-1", Outcome: domain.AuditOutcomeSuccess})
+	svc.Record(domain.AuditEntry{Time: base.Add(time.Minute), ActorID: "user-b", Actor: "bob", Action: domain.AuditActionWorkspaceReset, WorkspaceID: "ws-1", Outcome: domain.AuditOutcomeDenied})
+	svc.Record(domain.AuditEntry{Time: base.Add(2 * time.Minute), ActorID: "user-a", Actor: "alice", Action: domain.AuditActionWorkspaceDelete, WorkspaceID: "ws-2", Outcome: domain.AuditOutcomeSuccess})
+
+	all, err := svc.Query(AuditFilter{})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(all) != 3 || all[0].Action != domain.AuditActionWorkspaceDelete {
+		t.Fatalf("Expected 3 entries newest first, got %+v", all)
+	}
+
+	if byActor, _ := svc.Query(AuditFilter{Actor: "alice"}); len(byActor) != 2 {
+		t.Errorf("Expected 2 entries by username, got %d", len(byActor))
+	}
+	if byID, _ := svc.Query(AuditFilter{Actor: "user-b", WorkspaceID: "ws-1"}); len(byID) != 1 || byID[0].Outcome != domain.AuditOutcomeDenied {
+		t.Errorf("Expected bob's denied reset, got %+v", byID)
+	}
+	if ranged, _ := svc.Query(AuditFilter{Since: base.Add(30 * time.Second), Until: base.Add(90 * time.Second)}); len(ranged) != 1 {
+		t.Errorf("Expected 1 entry in time range, got %d", len(ranged))
+	}
+	if limited, _ := svc.Query(AuditFilter{Limit: 1}); len(limited) != 1 {
+		t.Errorf("Expected limit to apply, got %d", len(limited))
+	}
+
+	// Entries survive a restart
+	svc.Close()
+	reopened, err := NewAuditService(&config.Config{DataDir: dir})
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer reopened.Close()
+	if entries, _ := reopened.Query(AuditFilter{}); len(entries) != 3 {
+		t.Errorf("Expected 3 entries after reopen, got %d", len(entries))
+	}
+}
+
+func TestAuditService_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	svc, err := NewAuditService(&config.Config{DataDir: dir, AuditMaxSize: 400, AuditMaxFiles: 2})
+	if err != nil {
+		t.Fatalf("NewAuditService failed: %v", err)
+	}
+	defer svc.Close()
+
+	for i := 0; i < 20; i++ {
+		svc.Record(domain.AuditEntry{Actor: "alice", Action: domain.AuditActionExec, WorkspaceID: "ws-1", Outcome: domain.AuditOutcomeSuccess})
+	}
+
+	for _, name := range []string{"audit.log", "audit.log.1", "audit.log.2"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Expected %s to exist: %v", name, err)
+		}
+		if info.Size() > 400 {
+			t.Errorf("Expected %s to stay under the size limit, got %d bytes", name, info.Size())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "audit.log.3")); !os.IsNotExist(err) {
+		t.Errorf("Expected only 2 rotated files to be kept, got %v", err)
+	}
+
+	entries, err := svc.Query(AuditFilter{})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(entries) == 0 || len(entries) >= 20 {
+		t.Errorf("Expected the oldest entries to be rotated away, got %d", len(entries))
+	}
+}
+
+func TestAuditService_QueryDoesNotBlockRecord(t *testing.T) {
+	svc, err := NewAuditService(&config.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewAuditService failed: %v", err)
+	}
+	defer svc.Close()
+
+	// A query in progress holds the rotation lock for reading
+	svc.rotateMu.RLock()
+	done := make(chan struct{})
+	go func() {
+		svc.Record(domain.AuditEntry{Action: domain.AuditActionWorkspaceCreate, Outcome: domain.AuditOutcomeSuccess})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Error("Record blocked behind a running query")
+	}
+	svc.rotateMu.RUnlock()
+	<-done
+
+	if entries, _ := svc.Query(AuditFilter{}); len(entries) != 1 {
+		t.Errorf("Expected the recorded entry, got %d", len(entries))
+	}
+}
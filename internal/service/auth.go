@@ -0,0 +1,591 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/config"
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/repository"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// sessionTokenPrefix identifies login session tokens
+	sessionTokenPrefix = "vbsess_"
+	// defaultSessionTTL applies when no session lifetime is configured
+	defaultSessionTTL = 7 * 24 * time.Hour
+	// bootstrapAdminUsername is the account created from API_TOKEN on first run
+	bootstrapAdminUsername = "admin"
+	// maxBcryptPasswordLength is the longest password bcrypt accepts in bytes
+	maxBcryptPasswordLength = 72
+	// minPasswordLength is the shortest password accepted for new accounts
+	minPasswordLength = 8
+)
+
+var (
+	// ErrInvalidCredentials is returned when a username/password or token does not match
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrSessionInvalid is returned when a session token is unknown or expired
+	ErrSessionInvalid = errors.New("invalid or expired session")
+	// ErrUserNotFound is returned when a user does not exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUsernameTaken is returned when creating a user with an existing username
+	ErrUsernameTaken = errors.New("username is already taken")
+	// ErrInvalidUser is returned when a username, password or role is not acceptable
+	ErrInvalidUser = errors.New("invalid user")
+	// ErrLastAdmin is returned when an operation would leave no admin account
+	ErrLastAdmin = errors.New("cannot remove the last admin")
+)
+
+// usernamePattern restricts usernames to a URL- and log-friendly character set
+var usernamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+// CreateUserRequest represents a request to create a user account
+type CreateUserRequest struct {
+	Username string          `json:"username" binding:"required"`
+	Password string          `json:"password" binding:"required"`
+	Role     domain.UserRole `json:"role,omitempty"` // Defaults to "user"
+}
+
+// AuthService manages user accounts, their login sessions and API keys
+type AuthService struct {
+	users      repository.UserRepository
+	sessions   repository.SessionRepository
+	apiKeys    repository.APIKeyRepository
+	apiToken   string
+	sessionTTL time.Duration
+
+	ticketKey       []byte // Signs tickets; random per process
+	ticketTTL       time.Duration
+	allowQueryToken bool
+
+	listenersMu         sync.RWMutex
+	userDeleteListeners []func(userID string)
+}
+
+// NewAuthService creates a new auth service instance
+// The API token stays valid as a credential of the bootstrap admin.
+func NewAuthService(users repository.UserRepository, sessions repository.SessionRepository, apiKeys repository.APIKeyRepository, cfg *config.Config) *AuthService {
+	sessionTTL := time.Duration(cfg.SessionTTL) * time.Second
+	if sessionTTL <= 0 {
+		sessionTTL = defaultSessionTTL
+	}
+
+	ticketTTL := time.Duration(cfg.TicketTTL) * time.Second
+	if ticketTTL <= 0 {
+		ticketTTL = defaultTicketTTL
+	}
+	ticketTTL = min(ticketTTL, maxTicketTTL)
+	ticketKey := make([]byte, 32)
+	rand.Read(ticketKey)
+
+	utils.Info("Initializing auth service", "sessionTTL", sessionTTL.String(), "ticketTTL", ticketTTL.String(), "allowQueryToken", cfg.AllowQueryToken)
+	return &AuthService{
+		users:           users,
+		sessions:        sessions,
+		apiKeys:         apiKeys,
+		apiToken:        cfg.APIToken,
+		sessionTTL:      sessionTTL,
+		ticketKey:       ticketKey,
+		ticketTTL:       ticketTTL,
+		allowQueryToken: cfg.AllowQueryToken,
+	}
+}
+
+// SessionTTL returns the lifetime of new login sessions
+func (s *AuthService) SessionTTL() time.Duration {
+	return s.sessionTTL
+}
+
+// Bootstrap creates the "admin" account with the API token as its password when
+// no user exists yet, so that existing installations keep working after upgrading
+func (s *AuthService) Bootstrap() error {
+	users, err := s.users.List()
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+	if len(users) > 0 {
+		return nil
+	}
+	if s.apiToken == "" {
+		return fmt.Errorf("cannot bootstrap admin account: API token is empty")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword(bcryptInput(s.apiToken), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	now := time.Now()
+	admin := &domain.User{
+		ID:           utils.GenerateUserID(),
+		Username:     bootstrapAdminUsername,
+		PasswordHash: string(hash),
+		Role:         domain.UserRoleAdmin,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := s.users.Create(admin); err != nil {
+		return fmt.Errorf("failed to create admin account: %w", err)
+	}
+
+	utils.Info("Created admin account from API token", "username", admin.Username, "userID", admin.ID)
+	return nil
+}
+
+// Login verifies a username and password and starts a new session.
+// It returns the user and the plain session token; only the token's hash is stored.
+func (s *AuthService) Login(username, password string) (*domain.User, *domain.Session, string, error) {
+	user, err := s.users.GetByUsername(normalizeUsername(username))
+	if err != nil {
+		// Spend the same time as a real comparison so usernames cannot be probed
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash(), bcryptInput(password))
+		return nil, nil, "", ErrInvalidCredentials
+	}
+	if user.PasswordHash == "" {
+		// Single sign-on accounts have no password
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash(), bcryptInput(password))
+		return nil, nil, "", ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), bcryptInput(password)) != nil {
+		return nil, nil, "", ErrInvalidCredentials
+	}
+
+	return s.startSession(user)
+}
+
+// LoginWithToken starts a session for the admin account the API token belongs to.
+// It keeps the original token-based login working.
+func (s *AuthService) LoginWithToken(token string) (*domain.User, *domain.Session, string, error) {
+	if !s.isAPIToken(token) {
+		return nil, nil, "", ErrInvalidCredentials
+	}
+
+	user, err := s.tokenUser()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return s.startSession(user)
+}
+
+// LoginWithIdentity starts a session for an identity verified by a single sign-on
+// provider. subject identifies the identity ("<issuer>#<sub>"); the account linked
+// to it is created on first login with the given username. A non-empty role is
+// applied on every login so that group changes at the provider take effect.
+func (s *AuthService) LoginWithIdentity(subject, username string, role domain.UserRole) (*domain.User, *domain.Session, string, error) {
+	users, err := s.users.List()
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to list users: %w", err)
+	}
+
+	var user *domain.User
+	for _, candidate := range users {
+		if candidate.OIDCSubject == subject {
+			user = candidate
+			break
+		}
+	}
+
+	if user == nil {
+		username = normalizeUsername(username)
+		if !usernamePattern.MatchString(username) {
+			return nil, nil, "", fmt.Errorf("%w: username %q from the identity provider is not valid", ErrInvalidUser, username)
+		}
+		// Never link to an existing account by name: that would let the provider take it over
+		if _, err := s.users.GetByUsername(username); err == nil {
+			return nil, nil, "", fmt.Errorf("%w: %s", ErrUsernameTaken, username)
+		}
+		if role == "" {
+			role = domain.UserRoleUser
+		}
+
+		now := time.Now()
+		user = &domain.User{
+			ID:          utils.GenerateUserID(),
+			Username:    username,
+			Role:        role,
+			OIDCSubject: subject,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := s.users.Create(user); err != nil {
+			utils.Error("Failed to save user", "error", err)
+			return nil, nil, "", fmt.Errorf("failed to save user: %w", err)
+		}
+		utils.Info("Created single sign-on account", "username", user.Username, "userID", user.ID, "role", user.Role)
+		return s.startSession(user)
+	}
+
+	if role != "" && role != user.Role {
+		if user.IsAdmin() {
+			if admins, err := s.countAdmins(); err != nil || admins <= 1 {
+				utils.Warn("Keeping admin role of the last admin despite provider groups", "userID", user.ID)
+				return s.startSession(user)
+			}
+		}
+		utils.Info("Updating role from provider groups", "userID", user.ID, "from", user.Role, "to", role)
+		user.Role = role
+		user.UpdatedAt = time.Now()
+		if err := s.users.Update(user); err != nil {
+			return nil, nil, "", fmt.Errorf("failed to update user: %w", err)
+		}
+	}
+	return s.startSession(user)
+}
+
+// Authenticate resolves a credential to a user. It accepts a session token or
+// the API token (which maps to the bootstrap admin).
+func (s *AuthService) Authenticate(token string) (*domain.User, error) {
+	if token == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	if !strings.HasPrefix(token, sessionTokenPrefix) {
+		if !s.isAPIToken(token) {
+			return nil, ErrInvalidCredentials
+		}
+		return s.tokenUser()
+	}
+
+	tokenHash := utils.HashToken(token)
+	session, err := s.sessions.Get(tokenHash)
+	if err != nil {
+		return nil, ErrSessionInvalid
+	}
+	if session.IsExpired(time.Now()) {
+		if err := s.sessions.Delete(tokenHash); err != nil {
+			utils.Warn("Failed to delete expired session", "userID", session.UserID, "error", err)
+		}
+		return nil, ErrSessionInvalid
+	}
+
+	user, err := s.users.Get(session.UserID)
+	if err != nil {
+		return nil, ErrSessionInvalid
+	}
+	return user, nil
+}
+
+// Logout ends the session of a session token. Other tokens are ignored.
+func (s *AuthService) Logout(token string) error {
+	if !strings.HasPrefix(token, sessionTokenPrefix) {
+		return nil
+	}
+	if err := s.sessions.Delete(utils.HashToken(token)); err != nil {
+		return ErrSessionInvalid
+	}
+	return nil
+}
+
+// CreateUser creates a user account
+func (s *AuthService) CreateUser(req CreateUserRequest) (*domain.User, error) {
+	username := normalizeUsername(req.Username)
+	utils.Info("Creating user", "username", username, "role", req.Role)
+
+	if !usernamePattern.MatchString(username) {
+		return nil, fmt.Errorf("%w: username must be 1-64 characters of a-z, 0-9, '.', '_' or '-'", ErrInvalidUser)
+	}
+	role := req.Role
+	if role == "" {
+		role = domain.UserRoleUser
+	}
+	if role != domain.UserRoleAdmin && role != domain.UserRoleUser {
+		return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidUser, role)
+	}
+	if _, err := s.users.GetByUsername(username); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUsernameTaken, username)
+	}
+
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &domain.User{
+		ID:           utils.GenerateUserID(),
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := s.users.Create(user); err != nil {
+		utils.Error("Failed to save user", "error", err)
+		return nil, fmt.Errorf("failed to save user: %w", err)
+	}
+
+	return user, nil
+}
+
+// GetUser retrieves a user by ID
+func (s *AuthService) GetUser(id string) (*domain.User, error) {
+	user, err := s.users.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, id)
+	}
+	return user, nil
+}
+
+// ListUsers returns all users, oldest first
+func (s *AuthService) ListUsers() ([]*domain.User, error) {
+	users, err := s.users.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].CreatedAt.Before(users[j].CreatedAt)
+	})
+	return users, nil
+}
+
+// DeleteUser deletes a user account, ends its sessions, revokes its API keys
+// and notifies the user deletion listeners
+func (s *AuthService) DeleteUser(id string) error {
+	utils.Info("Deleting user", "userID", id)
+
+	user, err := s.GetUser(id)
+	if err != nil {
+		return err
+	}
+	if user.IsAdmin() {
+		admins, err := s.countAdmins()
+		if err != nil {
+			return err
+		}
+		if admins <= 1 {
+			return ErrLastAdmin
+		}
+	}
+
+	if err := s.users.Delete(id); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if err := s.sessions.DeleteByUser(id); err != nil {
+		utils.Warn("Failed to delete sessions of deleted user", "userID", id, "error", err)
+	}
+	s.deleteAPIKeysOf(id)
+	s.notifyUserDeleted(id)
+	return nil
+}
+
+// OnUserDeleted registers a listener that is called with the user ID after a
+// user account is deleted, so other services can remove what the user owned
+func (s *AuthService) OnUserDeleted(listener func(userID string)) {
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+	s.userDeleteListeners = append(s.userDeleteListeners, listener)
+}
+
+// notifyUserDeleted calls the registered user deletion listeners
+func (s *AuthService) notifyUserDeleted(userID string) {
+	s.listenersMu.RLock()
+	listeners := s.userDeleteListeners
+	s.listenersMu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(userID)
+	}
+}
+
+// VerifyPassword checks a user's current password. It returns
+// ErrInvalidCredentials when it does not match, including for single sign-on
+// accounts that have none.
+func (s *AuthService) VerifyPassword(id, password string) error {
+	user, err := s.GetUser(id)
+	if err != nil {
+		return err
+	}
+	if user.PasswordHash == "" || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), bcryptInput(password)) != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// ChangePassword sets a new password for a user and ends all of the user's
+// sessions except the one of keepToken (pass "" to end all of them)
+func (s *AuthService) ChangePassword(id, password, keepToken string) error {
+	utils.Info("Changing user password", "userID", id)
+
+	user, err := s.GetUser(id)
+	if err != nil {
+		return err
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	user.UpdatedAt = time.Now()
+	if err := s.users.Update(user); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	sessions, err := s.sessions.List()
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+	keepHash := ""
+	if keepToken != "" {
+		keepHash = utils.HashToken(keepToken)
+	}
+	for _, session := range sessions {
+		if session.UserID == id && session.TokenHash != keepHash {
+			if err := s.sessions.Delete(session.TokenHash); err != nil {
+				utils.Warn("Failed to end session after password change", "userID", id, "error", err)
+			}
+		}
+	}
+	return nil
+}
+
+// SetGitIdentity sets the name and email git records as the author of commits
+// a user makes in their workspaces (empty values clear them)
+func (s *AuthService) SetGitIdentity(id, name, email string) (*domain.User, error) {
+	utils.Info("Setting user git identity", "userID", id)
+
+	name = strings.TrimSpace(name)
+	email = strings.TrimSpace(email)
+	if strings.ContainsAny(name, "\r\n\x00") || len(name) > 256 {
+		return nil, fmt.Errorf("%w: invalid git name", ErrInvalidUser)
+	}
+	if strings.ContainsAny(email, "\r\n\x00<> ") || len(email) > 256 || (email != "" && !strings.Contains(email, "@")) {
+		return nil, fmt.Errorf("%w: invalid git email", ErrInvalidUser)
+	}
+
+	user, err := s.GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+	user.GitName = name
+	user.GitEmail = email
+	user.UpdatedAt = time.Now()
+	if err := s.users.Update(user); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	return user, nil
+}
+
+// startSession creates a session for user and returns its plain token
+func (s *AuthService) startSession(user *domain.User) (*domain.User, *domain.Session, string, error) {
+	token, err := utils.GenerateToken(sessionTokenPrefix)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	now := time.Now()
+	session := &domain.Session{
+		TokenHash: utils.HashToken(token),
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.sessionTTL),
+	}
+	if err := s.sessions.Create(session); err != nil {
+		utils.Error("Failed to save session", "error", err)
+		return nil, nil, "", fmt.Errorf("failed to save session: %w", err)
+	}
+
+	// Opportunistically drop sessions that have already expired
+	s.purgeExpired(now)
+
+	utils.Info("User logged in", "userID", user.ID, "username", user.Username)
+	return user, session, token, nil
+}
+
+// isAPIToken reports whether token is the configured API token
+func (s *AuthService) isAPIToken(token string) bool {
+	return s.apiToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.apiToken)) == 1
+}
+
+// tokenUser returns the account the API token acts as: the bootstrap admin,
+// or the oldest admin if it has been deleted
+func (s *AuthService) tokenUser() (*domain.User, error) {
+	if user, err := s.users.GetByUsername(bootstrapAdminUsername); err == nil && user.IsAdmin() {
+		return user, nil
+	}
+
+	users, err := s.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if user.IsAdmin() {
+			return user, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no admin account", ErrUserNotFound)
+}
+
+// countAdmins returns the number of admin accounts
+func (s *AuthService) countAdmins() (int, error) {
+	users, err := s.users.List()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	count := 0
+	for _, user := range users {
+		if user.IsAdmin() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// purgeExpired deletes sessions that expired before now
+func (s *AuthService) purgeExpired(now time.Time) {
+	sessions, err := s.sessions.List()
+	if err != nil {
+		return
+	}
+	for _, session := range sessions {
+		if session.IsExpired(now) {
+			if err := s.sessions.Delete(session.TokenHash); err != nil {
+				utils.Warn("Failed to purge expired session", "userID", session.UserID, "error", err)
+			}
+		}
+	}
+}
+
+// dummyPasswordHash is compared against when a login names an unknown user
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("vibox-dummy-password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// hashPassword validates and bcrypt-hashes a new password
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("%w: password must be at least %d characters", ErrInvalidUser, minPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword(bcryptInput(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// bcryptInput returns the bytes bcrypt hashes for a password. bcrypt rejects
+// more than 72 bytes, so longer passwords (such as a long API token, the
+// bootstrap admin's first password) are reduced to their SHA-256 digest.
+// Shorter ones are used as they are, which keeps existing hashes valid.
+func bcryptInput(password string) []byte {
+	if len(password) <= maxBcryptPasswordLength {
+		return []byte(password)
+	}
+	return []byte(utils.HashToken(password))
+}
+
+// normalizeUsername makes usernames case-insensitive
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
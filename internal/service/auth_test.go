@@ -0,0 +1,224 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/config"
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/repository"
+)
+
+func newTestAuthService(t *testing.T) *AuthService {
+	t.Helper()
+	svc := NewAuthService(repository.NewMemoryUserRepository(), repository.NewMemorySessionRepository(),
+		repository.NewMemoryAPIKeyRepository(), &config.Config{APIToken: "legacy-api-token"})
+	if err := svc.Bootstrap(); err != nil {
+		t.Fatalf("Bootstrap failed: %v", err)
+	}
+	return svc
+}
+
+func TestAuthService_BootstrapAdmin(t *testing.T) {
+	svc := newTestAuthService(t)
+
+	users, err := svc.ListUsers()
+	if err != nil {
+		t.Fatalf("ListUsers failed: %v", err)
+	}
+	if len(users) != 1 || users[0].Username != "admin" || !users[0].IsAdmin() {
+		t.Fatalf("Expected a single admin account, got %+v", users)
+	}
+	if users[0].PasswordHash == "legacy-api-token" {
+		t.Error("Expected the password to be hashed")
+	}
+
+	// Bootstrapping again must not create another account
+	if err := svc.Bootstrap(); err != nil {
+		t.Fatalf("Second Bootstrap failed: %v", err)
+	}
+	if users, _ := svc.ListUsers(); len(users) != 1 {
+		t.Errorf("Expected bootstrap to be idempotent, got %d users", len(users))
+	}
+
+	// The API token is the admin's password and keeps working as a credential
+	if _, _, _, err := svc.Login("Admin", "legacy-api-token"); err != nil {
+		t.Errorf("Expected admin login with API token password, got %v", err)
+	}
+	user, err := svc.Authenticate("legacy-api-token")
+	if err != nil || user.Username != "admin" {
+		t.Errorf("Expected API token to authenticate as admin, got %+v, %v", user, err)
+	}
+}
+
+func TestAuthService_LoginSessions(t *testing.T) {
+	svc := newTestAuthService(t)
+
+	created, err := svc.CreateUser(CreateUserRequest{Username: "Alice", Password: "correct-horse"})
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	if created.Username != "alice" || created.Role != domain.UserRoleUser {
+		t.Errorf("Expected lowercase regular user, got %+v", created)
+	}
+
+	if _, _, _, err := svc.Login("alice", "wrong-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for wrong password, got %v", err)
+	}
+	if _, _, _, err := svc.Login("bob", "correct-horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for unknown user, got %v", err)
+	}
+
+	_, session, token, err := svc.Login("alice", "correct-horse")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if session.TokenHash == token {
+		t.Error("Expected only the token hash to be stored")
+	}
+
+	user, err := svc.Authenticate(token)
+	if err != nil || user.ID != created.ID {
+		t.Fatalf("Expected session to authenticate alice, got %+v, %v", user, err)
+	}
+
+	if err := svc.Logout(token); err != nil {
+		t.Fatalf("Logout failed: %v", err)
+	}
+	if _, err := svc.Authenticate(token); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("Expected ErrSessionInvalid after logout, got %v", err)
+	}
+}
+
+func TestAuthService_SessionExpiry(t *testing.T) {
+	svc := newTestAuthService(t)
+	svc.sessionTTL = time.Millisecond
+
+	_, _, token, err := svc.LoginWithToken("legacy-api-token")
+	if err != nil {
+		t.Fatalf("LoginWithToken failed: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := svc.Authenticate(token); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("Expected ErrSessionInvalid for expired session, got %v", err)
+	}
+}
+
+func TestAuthService_LongPasswords(t *testing.T) {
+	// Long random API tokens exceed the 72 bytes bcrypt accepts
+	token := strings.Repeat("a1b2c3d4", 16)
+	svc := NewAuthService(repository.NewMemoryUserRepository(), repository.NewMemorySessionRepository(),
+		repository.NewMemoryAPIKeyRepository(), &config.Config{APIToken: token})
+	if err := svc.Bootstrap(); err != nil {
+		t.Fatalf("Bootstrap with a %d byte token failed: %v", len(token), err)
+	}
+	if _, _, _, err := svc.Login("admin", token); err != nil {
+		t.Errorf("Expected admin login with the long token, got %v", err)
+	}
+	if _, _, _, err := svc.Login("admin", token[:len(token)-1]+"x"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected a different long password to be rejected, got %v", err)
+	}
+
+	user, err := svc.CreateUser(CreateUserRequest{Username: "dave", Password: strings.Repeat("p", 100)})
+	if err != nil {
+		t.Fatalf("CreateUser with a long password failed: %v", err)
+	}
+	if err := svc.VerifyPassword(user.ID, strings.Repeat("p", 100)); err != nil {
+		t.Errorf("Expected the long password to verify, got %v", err)
+	}
+}
+
+func TestAuthService_ChangePasswordEndsOtherSessions(t *testing.T) {
+	svc := newTestAuthService(t)
+
+	user, err := svc.CreateUser(CreateUserRequest{Username: "carol", Password: "first-password"})
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	_, _, current, _ := svc.Login("carol", "first-password")
+	_, _, other, _ := svc.Login("carol", "first-password")
+
+	if err := svc.VerifyPassword(user.ID, "wrong-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for wrong password, got %v", err)
+	}
+	if err := svc.VerifyPassword(user.ID, "first-password"); err != nil {
+		t.Errorf("Expected current password to verify, got %v", err)
+	}
+	if err := svc.ChangePassword(user.ID, "short", current); !errors.Is(err, ErrInvalidUser) {
+		t.Errorf("Expected ErrInvalidUser for short password, got %v", err)
+	}
+	if err := svc.ChangePassword(user.ID, "second-password", current); err != nil {
+		t.Fatalf("ChangePassword failed: %v", err)
+	}
+
+	if _, err := svc.Authenticate(current); err != nil {
+		t.Errorf("Expected the current session to survive, got %v", err)
+	}
+	if _, err := svc.Authenticate(other); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("Expected other sessions to end, got %v", err)
+	}
+	if _, _, _, err := svc.Login("carol", "second-password"); err != nil {
+		t.Errorf("Expected login with new password, got %v", err)
+	}
+}
+
+func TestAuthService_SetGitIdentity(t *testing.T) {
+	svc := newTestAuthService(t)
+
+	user, err := svc.CreateUser(CreateUserRequest{Username: "erin", Password: "erin-password"})
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	if _, err := svc.SetGitIdentity(user.ID, "Erin\n[core]", "erin@example.com"); !errors.Is(err, ErrInvalidUser) {
+		t.Errorf("Expected ErrInvalidUser for a multi-line name, got %v", err)
+	}
+	if _, err := svc.SetGitIdentity(user.ID, "Erin", "not-an-email"); !errors.Is(err, ErrInvalidUser) {
+		t.Errorf("Expected ErrInvalidUser for an invalid email, got %v", err)
+	}
+	if _, err := svc.SetGitIdentity("missing", "Erin", "erin@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+
+	updated, err := svc.SetGitIdentity(user.ID, " Erin Example ", "erin@example.com")
+	if err != nil {
+		t.Fatalf("SetGitIdentity failed: %v", err)
+	}
+	if updated.GitName != "Erin Example" || updated.GitEmail != "erin@example.com" {
+		t.Errorf("Unexpected git identity %q <%s>", updated.GitName, updated.GitEmail)
+	}
+	if stored, _ := svc.GetUser(user.ID); stored.GitName != "Erin Example" {
+		t.Errorf("Expected the git identity to be stored, got %+v", stored)
+	}
+}
+
+func TestAuthService_DeleteUser(t *testing.T) {
+	svc := newTestAuthService(t)
+
+	admin, err := svc.tokenUser()
+	if err != nil {
+		t.Fatalf("tokenUser failed: %v", err)
+	}
+	if err := svc.DeleteUser(admin.ID); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("Expected ErrLastAdmin, got %v", err)
+	}
+
+	user, _ := svc.CreateUser(CreateUserRequest{Username: "dave", Password: "dave-password"})
+	_, _, token, _ := svc.Login("dave", "dave-password")
+	if _, err := svc.CreateUser(CreateUserRequest{Username: "DAVE", Password: "dave-password"}); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("Expected ErrUsernameTaken, got %v", err)
+	}
+
+	if err := svc.DeleteUser(user.ID); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if _, err := svc.Authenticate(token); err == nil {
+		t.Error("Expected sessions of a deleted user to be invalid")
+	}
+	if err := svc.DeleteUser(user.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
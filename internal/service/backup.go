@@ -0,0 +1,484 @@
+package service
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/repository"
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+const (
+	// backupFormat is the archive layout version written by Backup
+	backupFormat = 1
+	// maxBackupDataSize bounds each JSON document read from an archive
+	maxBackupDataSize = 64 * 1024 * 1024
+	// restoredImagePrefix names the images imported from workspace filesystems
+	restoredImagePrefix = "vibox-restore/"
+)
+
+// Archive entries
+const (
+	backupManifestFile   = "manifest.json"
+	backupWorkspacesFile = "workspaces.json"
+	backupUsersFile      = "users.json"
+	backupAPIKeysFile    = "api_keys.json"
+	backupSharesFile     = "shares.json"
+	backupGitCredsFile   = "git_credentials.json"
+	backupContainersDir  = "containers/"
+)
+
+var (
+	// ErrInvalidBackup is returned when an archive is not a ViBox backup
+	ErrInvalidBackup = errors.New("invalid backup archive")
+	// ErrRestoreNotEmpty is returned when restoring into an installation that already has workspaces
+	ErrRestoreNotEmpty = errors.New("restore requires an installation without workspaces")
+)
+
+// BackupOptions selects what a backup contains besides the ViBox data
+type BackupOptions struct {
+	// IncludeFilesystems adds the filesystem of every workspace container
+	// (docker export). Restored workspaces start from an image built from it.
+	IncludeFilesystems bool
+}
+
+// backupManifest describes a backup archive
+type backupManifest struct {
+	Format      int       `json:"format"`
+	CreatedAt   time.Time `json:"created_at"`
+	Workspaces  int       `json:"workspaces"`
+	Filesystems []string  `json:"filesystems,omitempty"` // IDs of the workspaces whose filesystem is included
+}
+
+// RestoreResult counts what a restore recreated
+type RestoreResult struct {
+	Workspaces     int `json:"workspaces"`
+	Users          int `json:"users"`
+	APIKeys        int `json:"api_keys"`
+	Shares         int `json:"shares"`
+	GitCredentials int `json:"git_credentials"`
+	Filesystems    int `json:"filesystems"`
+}
+
+// BackupService writes and restores archives of a whole installation:
+// workspaces, user accounts, API keys, share links, git credentials and optionally the
+// filesystems of the workspace containers
+type BackupService struct {
+	dockerSvc    *DockerService
+	workspaceSvc *WorkspaceService
+	workspaces   repository.WorkspaceRepository
+	users        repository.UserRepository
+	apiKeys      repository.APIKeyRepository
+	shares       repository.ShareRepository
+	gitCreds     repository.GitCredentialRepository
+
+	maxRestoreSize int64 // largest archive accepted by Restore in bytes (0 = unlimited)
+}
+
+// NewBackupService creates a new backup service instance
+func NewBackupService(dockerSvc *DockerService, workspaceSvc *WorkspaceService, workspaces repository.WorkspaceRepository, users repository.UserRepository, apiKeys repository.APIKeyRepository, shares repository.ShareRepository, gitCreds repository.GitCredentialRepository, maxRestoreSize int64) *BackupService {
+	utils.Info("Initializing backup service")
+	return &BackupService{
+		dockerSvc:    dockerSvc,
+		workspaceSvc: workspaceSvc,
+		workspaces:   workspaces,
+		users:        users,
+		apiKeys:      apiKeys,
+		shares:       shares,
+		gitCreds:     gitCreds,
+
+		maxRestoreSize: maxRestoreSize,
+	}
+}
+
+// MaxRestoreSize returns the largest archive accepted by a restore in bytes (0 = unlimited)
+func (s *BackupService) MaxRestoreSize() int64 {
+	return s.maxRestoreSize
+}
+
+// Backup writes a tar.gz archive of the installation to w. Everything that can
+// fail (reading the data, exporting containers) happens before the first byte
+// is written, so callers can still report errors in their own way.
+func (s *BackupService) Backup(ctx context.Context, w io.Writer, opts BackupOptions) error {
+	utils.Info("Creating backup", "includeFilesystems", opts.IncludeFilesystems)
+
+	workspaces, err := s.workspaces.List()
+	if err != nil {
+		return fmt.Errorf("failed to list workspaces: %w", err)
+	}
+	users, err := s.users.List()
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+	apiKeys, err := s.apiKeys.List()
+	if err != nil {
+		return fmt.Errorf("failed to list API keys: %w", err)
+	}
+	shares, err := s.shares.List()
+	if err != nil {
+		return fmt.Errorf("failed to list share links: %w", err)
+	}
+	gitCreds, err := s.gitCreds.List()
+	if err != nil {
+		return fmt.Errorf("failed to list git credentials: %w", err)
+	}
+
+	manifest := backupManifest{
+		Format:     backupFormat,
+		CreatedAt:  time.Now().UTC(),
+		Workspaces: len(workspaces),
+	}
+
+	// Spool container exports first: their size must be known for the tar
+	// headers, and a failed export must not leave a half-written archive
+	filesystems := make(map[string]*os.File)
+	defer func() {
+		for _, file := range filesystems {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+	if opts.IncludeFilesystems {
+		for _, ws := range workspaces {
+			if ws.ContainerID == "" {
+				continue
+			}
+			file, err := s.spoolExport(ctx, ws.ContainerID)
+			if err != nil {
+				return fmt.Errorf("failed to export workspace %s: %w", ws.ID, err)
+			}
+			filesystems[ws.ID] = file
+			manifest.Filesystems = append(manifest.Filesystems, ws.ID)
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, entry := range []struct {
+		name string
+		v    any
+	}{
+		{backupManifestFile, manifest},
+		{backupWorkspacesFile, workspaces},
+		{backupUsersFile, users},
+		{backupAPIKeysFile, apiKeys},
+		{backupSharesFile, shares},
+		{backupGitCredsFile, gitCreds},
+	} {
+		if err := writeTarJSON(tw, entry.name, entry.v); err != nil {
+			return err
+		}
+	}
+	for _, id := range manifest.Filesystems {
+		if err := writeTarFile(tw, backupContainersDir+id+".tar", filesystems[id]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	utils.Info("Backup created", "workspaces", len(workspaces), "users", len(users), "filesystems", len(manifest.Filesystems))
+	return nil
+}
+
+// Restore loads a backup archive into an installation without workspaces,
+// recreating the workspaces with new containers. Users are matched by ID;
+// an existing user with the same username but another ID (such as the
+// bootstrap admin of a fresh install) is replaced by the restored one.
+func (s *BackupService) Restore(ctx context.Context, r io.Reader) (*RestoreResult, error) {
+	utils.Info("Restoring backup")
+
+	existing, err := s.workspaces.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+	if len(existing) > 0 {
+		return nil, ErrRestoreNotEmpty
+	}
+
+	archive, err := readBackupArchive(r)
+	if archive != nil {
+		defer archive.cleanup()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RestoreResult{}
+
+	for _, user := range archive.users {
+		if err := s.restoreUser(user); err != nil {
+			return result, err
+		}
+		result.Users++
+	}
+	for _, key := range archive.apiKeys {
+		if _, err := s.apiKeys.Get(key.ID); err == nil {
+			continue
+		}
+		if err := s.apiKeys.Create(key); err != nil {
+			return result, fmt.Errorf("failed to restore API key %s: %w", key.ID, err)
+		}
+		result.APIKeys++
+	}
+	for _, share := range archive.shares {
+		if _, err := s.shares.Get(share.ID); err == nil {
+			continue
+		}
+		if err := s.shares.Create(share); err != nil {
+			return result, fmt.Errorf("failed to restore share link %s: %w", share.ID, err)
+		}
+		result.Shares++
+	}
+	for _, credential := range archive.gitCreds {
+		if _, err := s.gitCreds.Get(credential.ID); err == nil {
+			continue
+		}
+		if err := s.gitCreds.Create(credential); err != nil {
+			return result, fmt.Errorf("failed to restore git credential %s: %w", credential.ID, err)
+		}
+		result.GitCredentials++
+	}
+
+	for _, ws := range archive.workspaces {
+		if file, ok := archive.filesystems[ws.ID]; ok {
+			ref := restoredImagePrefix + strings.ToLower(ws.ID) + ":" + time.Now().UTC().Format("20060102150405")
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return result, fmt.Errorf("failed to read filesystem of workspace %s: %w", ws.ID, err)
+			}
+			if err := s.dockerSvc.ImportImage(ctx, file, ref); err != nil {
+				return result, fmt.Errorf("failed to restore filesystem of workspace %s: %w", ws.ID, err)
+			}
+			// The filesystem already holds any snapshot the workspace ran from
+			ws.Config.Image = ref
+			ws.Snapshot = ""
+			result.Filesystems++
+		}
+		if err := s.workspaceSvc.ImportWorkspace(ctx, ws); err != nil {
+			return result, fmt.Errorf("failed to restore workspace %s: %w", ws.ID, err)
+		}
+		result.Workspaces++
+	}
+
+	utils.Info("Backup restored", "workspaces", result.Workspaces, "users", result.Users, "apiKeys", result.APIKeys, "shares", result.Shares, "gitCredentials", result.GitCredentials, "filesystems", result.Filesystems)
+	return result, nil
+}
+
+// restoreUser creates or overwrites a user from a backup
+func (s *BackupService) restoreUser(user *domain.User) error {
+	if _, err := s.users.Get(user.ID); err == nil {
+		if err := s.users.Update(user); err != nil {
+			return fmt.Errorf("failed to restore user %s: %w", user.Username, err)
+		}
+		return nil
+	}
+	if other, err := s.users.GetByUsername(user.Username); err == nil {
+		utils.Warn("Replacing existing user with restored one", "username", user.Username, "userID", other.ID)
+		if err := s.users.Delete(other.ID); err != nil {
+			return fmt.Errorf("failed to replace user %s: %w", user.Username, err)
+		}
+	}
+	if err := s.users.Create(user); err != nil {
+		return fmt.Errorf("failed to restore user %s: %w", user.Username, err)
+	}
+	return nil
+}
+
+// spoolExport exports a container's filesystem into a temporary file
+func (s *BackupService) spoolExport(ctx context.Context, containerID string) (*os.File, error) {
+	reader, err := s.dockerSvc.ExportContainer(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return spoolToTempFile(reader)
+}
+
+// backupArchive is the decoded content of a backup
+type backupArchive struct {
+	manifest    *backupManifest
+	workspaces  []*domain.Workspace
+	users       []*domain.User
+	apiKeys     []*domain.APIKey
+	shares      []*domain.PortShare
+	gitCreds    []*domain.GitCredential
+	filesystems map[string]*os.File // Workspace ID -> spooled filesystem archive
+}
+
+// cleanup removes the spooled filesystem archives
+func (a *backupArchive) cleanup() {
+	for _, file := range a.filesystems {
+		file.Close()
+		os.Remove(file.Name())
+	}
+}
+
+// readBackupArchive decodes a tar.gz backup. Filesystem archives are spooled
+// to temporary files; call cleanup on the result even when an error is returned.
+func readBackupArchive(r io.Reader) (*backupArchive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+	}
+	defer gz.Close()
+
+	archive := &backupArchive{filesystems: make(map[string]*os.File)}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return archive, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(header.Name)
+		var target any
+		switch name {
+		case backupManifestFile:
+			target = &archive.manifest
+		case backupWorkspacesFile:
+			target = &archive.workspaces
+		case backupUsersFile:
+			target = &archive.users
+		case backupAPIKeysFile:
+			target = &archive.apiKeys
+		case backupSharesFile:
+			target = &archive.shares
+		case backupGitCredsFile:
+			target = &archive.gitCreds
+		default:
+			id, ok := strings.CutSuffix(strings.TrimPrefix(name, backupContainersDir), ".tar")
+			if !ok || !strings.HasPrefix(name, backupContainersDir) || id == "" || strings.Contains(id, "/") {
+				utils.Warn("Skipping unknown backup entry", "name", header.Name)
+				continue
+			}
+			// Backup writes the manifest first; only the filesystems it lists are spooled to disk
+			if archive.manifest == nil {
+				return archive, fmt.Errorf("%w: %s before %s", ErrInvalidBackup, name, backupManifestFile)
+			}
+			if !slices.Contains(archive.manifest.Filesystems, id) {
+				return archive, fmt.Errorf("%w: %s is not listed in %s", ErrInvalidBackup, name, backupManifestFile)
+			}
+			if _, ok := archive.filesystems[id]; ok {
+				return archive, fmt.Errorf("%w: duplicate %s", ErrInvalidBackup, name)
+			}
+			file, err := spoolToTempFile(tr)
+			if err != nil {
+				return archive, err
+			}
+			archive.filesystems[id] = file
+			continue
+		}
+
+		if err := json.NewDecoder(io.LimitReader(tr, maxBackupDataSize)).Decode(target); err != nil {
+			return archive, fmt.Errorf("%w: failed to decode %s: %w", ErrInvalidBackup, name, err)
+		}
+		if name == backupManifestFile {
+			if archive.manifest == nil {
+				return archive, fmt.Errorf("%w: empty %s", ErrInvalidBackup, backupManifestFile)
+			}
+			if archive.manifest.Format != backupFormat {
+				return archive, fmt.Errorf("%w: unsupported format %d", ErrInvalidBackup, archive.manifest.Format)
+			}
+		}
+	}
+
+	if archive.manifest == nil {
+		return archive, fmt.Errorf("%w: %s is missing", ErrInvalidBackup, backupManifestFile)
+	}
+	for _, ws := range archive.workspaces {
+		if ws == nil || ws.ID == "" {
+			return archive, fmt.Errorf("%w: workspace without ID", ErrInvalidBackup)
+		}
+	}
+	for _, user := range archive.users {
+		if user == nil || user.ID == "" || user.Username == "" {
+			return archive, fmt.Errorf("%w: user without ID or username", ErrInvalidBackup)
+		}
+	}
+	for _, credential := range archive.gitCreds {
+		if credential == nil || credential.ID == "" {
+			return archive, fmt.Errorf("%w: git credential without ID", ErrInvalidBackup)
+		}
+	}
+	return archive, nil
+}
+
+// writeTarJSON adds v as a JSON file to a tar archive
+func writeTarJSON(tw *tar.Writer, name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// writeTarFile adds the content of a spooled file to a tar archive
+func writeTarFile(tw *tar.Writer, name string, file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", name, err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    info.Size(),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := io.Copy(tw, file); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// spoolToTempFile copies r into a new temporary file, removed again on error
+func spoolToTempFile(r io.Reader) (*os.File, error) {
+	file, err := os.CreateTemp("", "vibox-backup-*.tar")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to spool archive: %w", err)
+	}
+	return file, nil
+}
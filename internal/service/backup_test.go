@@ -0,0 +1,190 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/repository"
+)
+
+// newTestBackupService returns a backup service over memory repositories.
+// Without Docker it can back up anything but only restore archives without workspaces.
+func newTestBackupService(workspaces repository.WorkspaceRepository) (*BackupService, *repository.FileUserRepository, *repository.FileAPIKeyRepository, *repository.FileShareRepository) {
+	users := repository.NewMemoryUserRepository()
+	apiKeys := repository.NewMemoryAPIKeyRepository()
+	shares := repository.NewMemoryShareRepository()
+	return NewBackupService(nil, nil, workspaces, users, apiKeys, shares, repository.NewMemoryGitCredentialRepository(), 0), users, apiKeys, shares
+}
+
+func TestBackupService_Backup(t *testing.T) {
+	workspaces := newTestWorkspaceRepository(t)
+	svc, users, _, shares := newTestBackupService(workspaces)
+
+	now := time.Now()
+	if err := workspaces.Create(&domain.Workspace{ID: "ws-1", Name: "one", ContainerID: "abc", CreatedAt: now}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := users.Create(&domain.User{ID: "user-1", Username: "alice", PasswordHash: "hash", Role: domain.UserRoleAdmin, CreatedAt: now}); err != nil {
+		t.Fatalf("Create user failed: %v", err)
+	}
+	if err := shares.Create(&domain.PortShare{ID: "share-1", WorkspaceID: "ws-1", Port: 8080, TokenHash: "t", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("Create share failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := svc.Backup(context.Background(), &buf, BackupOptions{}); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	archive, err := readBackupArchive(&buf)
+	if archive != nil {
+		defer archive.cleanup()
+	}
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if archive.manifest.Format != backupFormat || archive.manifest.Workspaces != 1 {
+		t.Errorf("Unexpected manifest %+v", archive.manifest)
+	}
+	if len(archive.workspaces) != 1 || archive.workspaces[0].Name != "one" {
+		t.Errorf("Expected workspace in backup, got %v", archive.workspaces)
+	}
+	if len(archive.users) != 1 || archive.users[0].PasswordHash != "hash" {
+		t.Errorf("Expected user with password hash in backup, got %v", archive.users)
+	}
+	if len(archive.shares) != 1 || len(archive.filesystems) != 0 {
+		t.Errorf("Expected one share and no filesystems, got %d and %d", len(archive.shares), len(archive.filesystems))
+	}
+}
+
+func TestBackupService_Restore(t *testing.T) {
+	source, users, apiKeys, _ := newTestBackupService(newTestWorkspaceRepository(t))
+	now := time.Now()
+	if err := users.Create(&domain.User{ID: "user-old", Username: "admin", PasswordHash: "old", Role: domain.UserRoleAdmin, CreatedAt: now}); err != nil {
+		t.Fatalf("Create user failed: %v", err)
+	}
+	if err := apiKeys.Create(&domain.APIKey{ID: "key-1", UserID: "user-old", Name: "ci", SecretHash: "s", CreatedAt: now}); err != nil {
+		t.Fatalf("Create key failed: %v", err)
+	}
+	if err := source.gitCreds.Create(&domain.GitCredential{ID: "gitcred-1", UserID: "user-old", Name: "github", Type: domain.GitCredentialToken, Secret: "ghp_x", CreatedAt: now}); err != nil {
+		t.Fatalf("Create git credential failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := source.Backup(context.Background(), &buf, BackupOptions{}); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	// A fresh install has its own bootstrap admin, which the restored one replaces
+	target, targetUsers, targetKeys, _ := newTestBackupService(newTestWorkspaceRepository(t))
+	if err := targetUsers.Create(&domain.User{ID: "user-new", Username: "admin", PasswordHash: "new", Role: domain.UserRoleAdmin, CreatedAt: now}); err != nil {
+		t.Fatalf("Create user failed: %v", err)
+	}
+
+	result, err := target.Restore(context.Background(), bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if result.Users != 1 || result.APIKeys != 1 || result.GitCredentials != 1 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	admin, err := targetUsers.GetByUsername("admin")
+	if err != nil || admin.ID != "user-old" || admin.PasswordHash != "old" {
+		t.Errorf("Expected restored admin, got %+v, %v", admin, err)
+	}
+	if _, err := targetKeys.Get("key-1"); err != nil {
+		t.Errorf("Expected restored API key: %v", err)
+	}
+	if credential, err := target.gitCreds.Get("gitcred-1"); err != nil || credential.Secret != "ghp_x" || credential.UserID != "user-old" {
+		t.Errorf("Expected restored git credential, got %+v, %v", credential, err)
+	}
+
+	// Restoring twice keeps existing keys
+	result, err = target.Restore(context.Background(), bytes.NewReader(buf.Bytes()))
+	if err != nil || result.APIKeys != 0 || result.GitCredentials != 0 {
+		t.Errorf("Expected second restore to skip existing keys, got %+v, %v", result, err)
+	}
+}
+
+func TestBackupService_RestoreRejects(t *testing.T) {
+	workspaces := newTestWorkspaceRepository(t)
+	svc, _, _, _ := newTestBackupService(workspaces)
+
+	if _, err := svc.Restore(context.Background(), strings.NewReader("not a backup")); !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("Expected ErrInvalidBackup, got %v", err)
+	}
+
+	if err := workspaces.Create(&domain.Workspace{ID: "ws-1", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := svc.Backup(context.Background(), &buf, BackupOptions{}); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if _, err := svc.Restore(context.Background(), &buf); !errors.Is(err, ErrRestoreNotEmpty) {
+		t.Errorf("Expected ErrRestoreNotEmpty, got %v", err)
+	}
+}
+
+// writeTestArchive writes a tar.gz archive of the given entries, in order
+func writeTestArchive(t *testing.T, entries ...[2]string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		if err := tw.WriteHeader(&tar.Header{Name: entry[0], Mode: 0600, Size: int64(len(entry[1]))}); err != nil {
+			t.Fatalf("WriteHeader failed: %v", err)
+		}
+		if _, err := tw.Write([]byte(entry[1])); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return &buf
+}
+
+func TestReadBackupArchive_Filesystems(t *testing.T) {
+	manifest := `{"format":1,"workspaces":1,"filesystems":["ws-1"]}`
+
+	tests := []struct {
+		name    string
+		entries [][2]string
+		valid   bool
+	}{
+		{"listed filesystem", [][2]string{{backupManifestFile, manifest}, {backupContainersDir + "ws-1.tar", "fs"}}, true},
+		{"filesystem before manifest", [][2]string{{backupContainersDir + "ws-1.tar", "fs"}, {backupManifestFile, manifest}}, false},
+		{"unlisted filesystem", [][2]string{{backupManifestFile, manifest}, {backupContainersDir + "ws-2.tar", "fs"}}, false},
+		{"duplicate filesystem", [][2]string{{backupManifestFile, manifest}, {backupContainersDir + "ws-1.tar", "fs"}, {backupContainersDir + "ws-1.tar", "fs"}}, false},
+		{"unsupported format", [][2]string{{backupManifestFile, `{"format":2,"filesystems":["ws-1"]}`}, {backupContainersDir + "ws-1.tar", "fs"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive, err := readBackupArchive(writeTestArchive(t, tt.entries...))
+			if archive != nil {
+				defer archive.cleanup()
+			}
+			if tt.valid {
+				if err != nil || len(archive.filesystems) != 1 {
+					t.Errorf("Expected one filesystem, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidBackup) {
+				t.Errorf("Expected ErrInvalidBackup, got %v", err)
+			}
+		})
+	}
+}
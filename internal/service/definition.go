@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	// WorkspaceDefinitionVersion is the definition format written by this build
+	WorkspaceDefinitionVersion = 1
+	// WorkspaceDefinitionKind identifies workspace definition documents
+	WorkspaceDefinitionKind = "Workspace"
+)
+
+// ErrInvalidDefinition is returned for workspace definitions that cannot be imported
+var ErrInvalidDefinition = errors.New("invalid workspace definition")
+
+// WorkspaceDefinition is the portable description of a workspace: everything
+// needed to create an equivalent one on any ViBox server, and nothing tied to
+// this one (IDs, owners, members, containers, snapshots, port access policies,
+// git credentials).
+// It is written as YAML or JSON, so it can be kept in git next to a project.
+//
+// Example:
+//
+//	version: 1
+//	kind: Workspace
+//	name: api-dev
+//	image: node:20
+//	scripts:
+//	  - name: deps
+//	    content: |
+//	      #!/bin/sh
+//	      npm ci
+//	    order: 1
+//	repositories:
+//	  - url: https://github.com/example/api.git
+//	    path: /root/api
+//	ports:
+//	  "3000": api
+type WorkspaceDefinition struct {
+	Version      int                    `json:"version"`
+	Kind         string                 `json:"kind"`
+	Name         string                 `json:"name"`
+	Image        string                 `json:"image,omitempty"` // Defaults to the server's default image
+	Scripts      []domain.Script        `json:"scripts,omitempty"`
+	Repositories []domain.GitRepository `json:"repositories,omitempty"` // Credentials must belong to the importing user
+	Ports        map[string]string      `json:"ports,omitempty"`        // Port label mappings
+	Env          map[string]string      `json:"env,omitempty"`
+	Labels       map[string]string      `json:"labels,omitempty"`
+	MemoryLimit  int64                  `json:"memory_limit,omitempty"` // Bytes; 0 uses the server default
+	CPULimit     int64                  `json:"cpu_limit,omitempty"`    // Nano CPUs; 0 uses the server default
+}
+
+// ParseWorkspaceDefinition decodes and validates a workspace definition.
+// YAML and JSON are both accepted; unknown fields are rejected, so settings
+// this server does not support are not silently dropped.
+func ParseWorkspaceDefinition(data []byte) (*WorkspaceDefinition, error) {
+	var def WorkspaceDefinition
+	if err := yaml.UnmarshalWithOptions(data, &def, yaml.Strict()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDefinition, err)
+	}
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// Validate checks that the definition can be imported by this server
+func (d *WorkspaceDefinition) Validate() error {
+	switch {
+	case d.Version == 0:
+		return fmt.Errorf("%w: version is required", ErrInvalidDefinition)
+	case d.Version > WorkspaceDefinitionVersion:
+		return fmt.Errorf("%w: version %d is newer than this server supports (%d)", ErrInvalidDefinition, d.Version, WorkspaceDefinitionVersion)
+	case d.Version < 0:
+		return fmt.Errorf("%w: invalid version %d", ErrInvalidDefinition, d.Version)
+	case d.Kind != WorkspaceDefinitionKind:
+		return fmt.Errorf("%w: kind must be %q", ErrInvalidDefinition, WorkspaceDefinitionKind)
+	case strings.TrimSpace(d.Name) == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidDefinition)
+	}
+
+	config := domain.WorkspaceConfig{
+		Image:        d.Image,
+		Scripts:      d.Scripts,
+		Repositories: d.Repositories,
+		Env:          d.Env,
+		Labels:       d.Labels,
+		MemoryLimit:  d.MemoryLimit,
+		CPULimit:     d.CPULimit,
+	}
+	if err := validateWorkspaceConfig(config); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDefinition, err)
+	}
+	if err := validatePorts(d.Ports); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDefinition, err)
+	}
+	return nil
+}
+
+// ExportDefinition returns the portable definition of a workspace
+func (s *WorkspaceService) ExportDefinition(id string) (*WorkspaceDefinition, error) {
+	utils.Debug("Exporting workspace definition", "id", id)
+
+	workspace, err := s.GetWorkspace(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Credential IDs only mean something on this server
+	var repositories []domain.GitRepository
+	for _, repo := range workspace.Config.Repositories {
+		repo.Credential = ""
+		repositories = append(repositories, repo)
+	}
+
+	return &WorkspaceDefinition{
+		Version:      WorkspaceDefinitionVersion,
+		Kind:         WorkspaceDefinitionKind,
+		Name:         workspace.Name,
+		Image:        workspace.Config.Image,
+		Scripts:      workspace.Config.Scripts,
+		Repositories: repositories,
+		Ports:        workspace.Ports,
+		Env:          workspace.Config.Env,
+		Labels:       workspace.Config.Labels,
+		MemoryLimit:  workspace.Config.MemoryLimit,
+		CPULimit:     workspace.Config.CPULimit,
+	}, nil
+}
+
+// ImportDefinition creates a workspace owned by owner from a definition
+func (s *WorkspaceService) ImportDefinition(ctx context.Context, def *WorkspaceDefinition, owner string) (*domain.Workspace, error) {
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+	utils.Info("Importing workspace definition", "name", def.Name, "version", def.Version)
+
+	return s.CreateWorkspace(ctx, CreateWorkspaceRequest{
+		Name:         def.Name,
+		Image:        def.Image,
+		Scripts:      def.Scripts,
+		Repositories: def.Repositories,
+		Ports:        def.Ports,
+		Env:          def.Env,
+		Labels:       def.Labels,
+		MemoryLimit:  def.MemoryLimit,
+		CPULimit:     def.CPULimit,
+		Owner:        owner,
+	})
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/config"
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/goccy/go-yaml"
+)
+
+func TestExportDefinition_RoundTrip(t *testing.T) {
+	repo := newTestWorkspaceRepository(t)
+	workspaceSvc := NewWorkspaceService(nil, repo, &config.Config{})
+	if err := repo.Create(&domain.Workspace{
+		ID:        "ws-export1",
+		Name:      "api-dev",
+		CreatedAt: time.Now(),
+		Config: domain.WorkspaceConfig{
+			Image:   "node:20",
+			Scripts: []domain.Script{{Name: "deps", Content: "#!/bin/sh\nnpm ci\n", Order: 1}},
+		},
+		Ports: map[string]string{"3000": "api"},
+		Owner: "user-1",
+	}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	definition, err := workspaceSvc.ExportDefinition("ws-export1")
+	if err != nil {
+		t.Fatalf("ExportDefinition failed: %v", err)
+	}
+	data, err := yaml.Marshal(definition)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	parsed, err := ParseWorkspaceDefinition(data)
+	if err != nil {
+		t.Fatalf("ParseWorkspaceDefinition failed: %v\n%s", err, data)
+	}
+	if parsed.Name != "api-dev" || parsed.Image != "node:20" || parsed.Ports["3000"] != "api" {
+		t.Errorf("Unexpected definition %+v", parsed)
+	}
+	if len(parsed.Scripts) != 1 || parsed.Scripts[0].Content != "#!/bin/sh\nnpm ci\n" {
+		t.Errorf("Expected script to survive the round trip, got %+v", parsed.Scripts)
+	}
+}
+
+func TestParseWorkspaceDefinition(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		valid bool
+	}{
+		{"yaml", "version: 1\nkind: Workspace\nname: dev\n", true},
+		{"json", `{"version": 1, "kind": "Workspace", "name": "dev", "ports": {"8080": "web"}}`, true},
+		{"missing version", "kind: Workspace\nname: dev\n", false},
+		{"newer version", "version: 2\nkind: Workspace\nname: dev\n", false},
+		{"wrong kind", "version: 1\nkind: Preset\nname: dev\n", false},
+		{"missing name", "version: 1\nkind: Workspace\n", false},
+		{"env and limits", "version: 1\nkind: Workspace\nname: dev\nenv:\n  FOO: bar\nmemory_limit: 1073741824\n", true},
+		{"unknown field", "version: 1\nkind: Workspace\nname: dev\nvolumes:\n  - /data\n", false},
+		{"reserved label", "version: 1\nkind: Workspace\nname: dev\nlabels:\n  vibox.workspace: x\n", false},
+		{"invalid port", "version: 1\nkind: Workspace\nname: dev\nports:\n  http: web\n", false},
+		{"empty script", "version: 1\nkind: Workspace\nname: dev\nscripts:\n  - name: setup\n", false},
+		{"malformed", "version: [", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseWorkspaceDefinition([]byte(tt.input))
+			if tt.valid && err != nil {
+				t.Errorf("Expected definition to be valid, got %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidDefinition) {
+				t.Errorf("Expected ErrInvalidDefinition, got %v", err)
+			}
+		})
+	}
+}
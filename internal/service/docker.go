@@ -4,14 +4,18 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/1PercentSync/vibox/internal/config"
 	"github.com/1PercentSync/vibox/pkg/utils"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/network"
@@ -23,6 +27,8 @@ import (
 type ContainerConfig struct {
 	Image       string
 	Name        string
+	Env         []string          // "KEY=value" entries
+	Labels      map[string]string // Added to the ViBox labels
 	MemoryLimit int64
 	CPULimit    int64
 }
@@ -31,6 +37,9 @@ type ContainerConfig struct {
 type DockerService struct {
 	client *client.Client
 	config *config.Config
+
+	listenersMu     sync.RWMutex
+	changeListeners []func(containerID string)
 }
 
 // NewDockerService creates a new Docker service instance
@@ -85,16 +94,16 @@ func (s *DockerService) CreateContainer(ctx context.Context, cfg ContainerConfig
 	}
 
 	// Pull image if not exists
-	utils.Debug("Pulling image if needed", "image", imageName)
-	reader, err := s.client.ImagePull(ctx, imageName, image.PullOptions{})
-	if err != nil {
-		utils.Error("Failed to pull image", "image", imageName, "error", err)
-		return "", fmt.Errorf("failed to pull image %s: %w", imageName, err)
+	if err := s.pullImage(ctx, imageName); err != nil {
+		return "", err
 	}
-	// Consume the reader to ensure pull completes
-	_, _ = io.Copy(io.Discard, reader)
-	reader.Close()
-	utils.Debug("Image pulled successfully", "image", imageName)
+
+	// Add label to identify ViBox workspace containers for cleanup
+	labels := make(map[string]string, len(cfg.Labels)+1)
+	for key, value := range cfg.Labels {
+		labels[key] = value
+	}
+	labels["vibox.workspace"] = "true"
 
 	// Create container configuration
 	containerConfig := &container.Config{
@@ -105,11 +114,9 @@ func (s *DockerService) CreateContainer(ctx context.Context, cfg ContainerConfig
 		AttachStdout: true,
 		AttachStderr: true,
 		// Keep container running - use /bin/sh for maximum compatibility (including Alpine)
-		Cmd: []string{"/bin/sh"},
-		// Add label to identify ViBox workspace containers for cleanup
-		Labels: map[string]string{
-			"vibox.workspace": "true",
-		},
+		Cmd:    []string{"/bin/sh"},
+		Env:    cfg.Env,
+		Labels: labels,
 	}
 
 	// Host configuration with resource limits
@@ -149,6 +156,127 @@ func (s *DockerService) CreateContainer(ctx context.Context, cfg ContainerConfig
 	return resp.ID, nil
 }
 
+// pullImage pulls an image, falling back to a local copy when it cannot be pulled
+func (s *DockerService) pullImage(ctx context.Context, imageName string) error {
+	utils.Debug("Pulling image if needed", "image", imageName)
+	reader, err := s.client.ImagePull(ctx, imageName, image.PullOptions{})
+	if err != nil {
+		// Images only known to this host (imported from backups or snapshots)
+		// cannot be pulled but are usable as they are
+		if _, inspectErr := s.client.ImageInspect(ctx, imageName); inspectErr != nil {
+			utils.Error("Failed to pull image", "image", imageName, "error", err)
+			return fmt.Errorf("failed to pull image %s: %w", imageName, err)
+		}
+		utils.Debug("Using local image", "image", imageName)
+		return nil
+	}
+
+	// Consume the reader to ensure pull completes
+	_, _ = io.Copy(io.Discard, reader)
+	reader.Close()
+	utils.Debug("Image pulled successfully", "image", imageName)
+	return nil
+}
+
+// HelperConfig describes a short-lived container that runs a single command
+type HelperConfig struct {
+	Image string
+	Cmd   []string // Entrypoint and arguments
+	Env   []string // "KEY=value" entries
+}
+
+// CreateHelperContainer creates a container that runs a single command (see
+// RunContainer). It is not a workspace: it is not attached to the workspace
+// network and no API reaches it. The caller must remove it.
+func (s *DockerService) CreateHelperContainer(ctx context.Context, cfg HelperConfig) (string, error) {
+	utils.Info("Creating helper container", "image", cfg.Image)
+
+	if err := s.pullImage(ctx, cfg.Image); err != nil {
+		return "", err
+	}
+
+	resp, err := s.client.ContainerCreate(ctx,
+		&container.Config{
+			Image:      cfg.Image,
+			Entrypoint: cfg.Cmd,
+			Env:        cfg.Env,
+			Labels:     map[string]string{"vibox.helper": "true"},
+		},
+		&container.HostConfig{
+			Resources: container.Resources{
+				Memory:   s.config.MemoryLimit,
+				NanoCPUs: s.config.CPULimit,
+			},
+		},
+		nil, nil, "",
+	)
+	if err != nil {
+		utils.Error("Failed to create helper container", "image", cfg.Image, "error", err)
+		return "", fmt.Errorf("failed to create helper container: %w", err)
+	}
+	return resp.ID, nil
+}
+
+// RunContainer starts a created container, waits for its command to exit and
+// writes its output to output. It returns the exit code of the command.
+func (s *DockerService) RunContainer(ctx context.Context, containerID string, output io.Writer) (int, error) {
+	utils.Debug("Running container", "containerID", utils.ShortID(containerID))
+
+	waitCh, errCh := s.client.ContainerWait(ctx, containerID, container.WaitConditionNextExit)
+	if err := s.client.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
+		return -1, fmt.Errorf("failed to start container: %w", err)
+	}
+
+	var exitCode int
+	select {
+	case result := <-waitCh:
+		if result.Error != nil {
+			return -1, fmt.Errorf("failed to wait for container: %s", result.Error.Message)
+		}
+		exitCode = int(result.StatusCode)
+	case err := <-errCh:
+		return -1, fmt.Errorf("failed to wait for container: %w", err)
+	}
+
+	logs, err := s.client.ContainerLogs(ctx, containerID, container.LogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return exitCode, fmt.Errorf("failed to read container output: %w", err)
+	}
+	defer logs.Close()
+	if _, err := stdcopy.StdCopy(output, output, logs); err != nil {
+		return exitCode, fmt.Errorf("failed to read container output: %w", err)
+	}
+	return exitCode, nil
+}
+
+// UpdateContainerResources changes the memory and CPU limits of a running
+// container (0 uses the configured defaults, as in CreateContainer)
+func (s *DockerService) UpdateContainerResources(ctx context.Context, containerID string, memoryLimit, cpuLimit int64) error {
+	if memoryLimit == 0 {
+		memoryLimit = s.config.MemoryLimit
+	}
+	if cpuLimit == 0 {
+		cpuLimit = s.config.CPULimit
+	}
+	utils.Info("Updating container resources", "containerID", utils.ShortID(containerID), "memoryLimit", memoryLimit, "cpuLimit", cpuLimit)
+
+	resources := container.Resources{
+		Memory:   memoryLimit,
+		NanoCPUs: cpuLimit,
+	}
+	if memoryLimit > 0 {
+		// Keep the swap allowance Docker gives new containers (as much again as
+		// the memory); the old one may be smaller than the new memory limit
+		resources.MemorySwap = 2 * memoryLimit
+	}
+
+	if _, err := s.client.ContainerUpdate(ctx, containerID, container.UpdateConfig{Resources: resources}); err != nil {
+		utils.Error("Failed to update container resources", "containerID", utils.ShortID(containerID), "error", err)
+		return fmt.Errorf("failed to update container resources: %w", err)
+	}
+	return nil
+}
+
 // StartContainer starts a container
 func (s *DockerService) StartContainer(ctx context.Context, containerID string) error {
 	utils.Info("Starting container", "containerID", utils.ShortID(containerID))
@@ -159,6 +287,7 @@ func (s *DockerService) StartContainer(ctx context.Context, containerID string)
 		return fmt.Errorf("failed to start container: %w", err)
 	}
 
+	s.notifyContainerChange(containerID)
 	utils.Info("Container started successfully", "containerID", utils.ShortID(containerID))
 	return nil
 }
@@ -174,6 +303,7 @@ func (s *DockerService) StopContainer(ctx context.Context, containerID string, t
 	}
 
 	err := s.client.ContainerStop(ctx, containerID, stopOptions)
+	s.notifyContainerChange(containerID)
 	if err != nil {
 		utils.Error("Failed to stop container", "containerID", utils.ShortID(containerID), "error", err)
 		return fmt.Errorf("failed to stop container: %w", err)
@@ -190,6 +320,7 @@ func (s *DockerService) RemoveContainer(ctx context.Context, containerID string)
 	err := s.client.ContainerRemove(ctx, containerID, container.RemoveOptions{
 		Force: true, // Force remove even if running
 	})
+	s.notifyContainerChange(containerID)
 	if err != nil {
 		utils.Error("Failed to remove container", "containerID", utils.ShortID(containerID), "error", err)
 		return fmt.Errorf("failed to remove container: %w", err)
@@ -209,6 +340,11 @@ func (s *DockerService) GetContainerIP(ctx context.Context, containerID string)
 		return "", fmt.Errorf("failed to inspect container: %w", err)
 	}
 
+	return containerIP(containerID, &inspect)
+}
+
+// containerIP extracts a container's IP address from its inspect data
+func containerIP(containerID string, inspect *types.ContainerJSON) (string, error) {
 	// Get IP from default network
 	if inspect.NetworkSettings != nil && inspect.NetworkSettings.IPAddress != "" {
 		ip := inspect.NetworkSettings.IPAddress
@@ -260,50 +396,95 @@ func (s *DockerService) InspectContainer(ctx context.Context, containerID string
 	return &inspect, nil
 }
 
+// ExecOptions configures a non-interactive command execution
+type ExecOptions struct {
+	Cmd        []string
+	Env        []string // KEY=VALUE pairs
+	User       string
+	WorkingDir string
+	Stdin      io.Reader // Optional; closed for writing once fully copied
+}
+
 // ExecCommand executes a command in a container and returns the output
 func (s *DockerService) ExecCommand(ctx context.Context, containerID string, cmd []string) (string, error) {
 	utils.Debug("Executing command in container", "containerID", utils.ShortID(containerID), "cmd", strings.Join(cmd, " "))
 
+	var stdout, stderr bytes.Buffer
+	if _, err := s.ExecStream(ctx, containerID, ExecOptions{Cmd: cmd}, &stdout, &stderr); err != nil {
+		return "", err
+	}
+
+	// Combine stdout and stderr
+	var output bytes.Buffer
+	output.Write(stdout.Bytes())
+	output.Write(stderr.Bytes())
+
+	outputStr := output.String()
+	utils.Debug("Command executed successfully", "containerID", utils.ShortID(containerID), "outputLength", len(outputStr))
+	return outputStr, nil
+}
+
+// ExecStream executes a command in a container without a TTY, copying its stdout and
+// stderr to the given writers as the output arrives, and returns the exit code.
+// If ctx is cancelled the stream is closed and ctx.Err() is returned; the process
+// itself keeps running inside the container.
+func (s *DockerService) ExecStream(ctx context.Context, containerID string, opts ExecOptions, stdout, stderr io.Writer) (int, error) {
 	// Create exec instance
 	execConfig := container.ExecOptions{
+		AttachStdin:  opts.Stdin != nil,
 		AttachStdout: true,
 		AttachStderr: true,
 		Tty:          false, // No TTY for script execution (keeps stdout/stderr separate)
-		Cmd:          cmd,
+		Cmd:          opts.Cmd,
+		Env:          opts.Env,
+		User:         opts.User,
+		WorkingDir:   opts.WorkingDir,
 	}
 
 	execID, err := s.client.ContainerExecCreate(ctx, containerID, execConfig)
 	if err != nil {
 		utils.Error("Failed to create exec instance", "containerID", utils.ShortID(containerID), "error", err)
-		return "", fmt.Errorf("failed to create exec instance: %w", err)
+		return -1, fmt.Errorf("failed to create exec instance: %w", err)
 	}
 
 	// Attach to exec instance
 	resp, err := s.client.ContainerExecAttach(ctx, execID.ID, container.ExecStartOptions{})
 	if err != nil {
 		utils.Error("Failed to attach to exec instance", "execID", utils.ShortID(execID.ID), "error", err)
-		return "", fmt.Errorf("failed to attach to exec instance: %w", err)
+		return -1, fmt.Errorf("failed to attach to exec instance: %w", err)
 	}
 	defer resp.Close()
 
+	// Unblock the reader below when the caller gives up
+	stop := context.AfterFunc(ctx, resp.Close)
+	defer stop()
+
+	if opts.Stdin != nil {
+		go func() {
+			_, _ = io.Copy(resp.Conn, opts.Stdin)
+			_ = resp.CloseWrite()
+		}()
+	}
+
 	// Read output
 	// When Tty=false, Docker uses stream multiplexing with 8-byte headers
 	// We need to use stdcopy.StdCopy to properly demultiplex stdout/stderr
-	var stdout, stderr bytes.Buffer
-	_, err = stdcopy.StdCopy(&stdout, &stderr, resp.Reader)
+	_, err = stdcopy.StdCopy(stdout, stderr, resp.Reader)
+	if ctx.Err() != nil {
+		return -1, ctx.Err()
+	}
 	if err != nil {
 		utils.Error("Failed to read exec output", "execID", utils.ShortID(execID.ID), "error", err)
-		return "", fmt.Errorf("failed to read exec output: %w", err)
+		return -1, fmt.Errorf("failed to read exec output: %w", err)
 	}
 
-	// Combine stdout and stderr
-	var output bytes.Buffer
-	output.Write(stdout.Bytes())
-	output.Write(stderr.Bytes())
+	inspect, err := s.client.ContainerExecInspect(ctx, execID.ID)
+	if err != nil {
+		utils.Error("Failed to inspect exec instance", "execID", utils.ShortID(execID.ID), "error", err)
+		return -1, fmt.Errorf("failed to inspect exec instance: %w", err)
+	}
 
-	outputStr := output.String()
-	utils.Debug("Command executed successfully", "containerID", utils.ShortID(containerID), "outputLength", len(outputStr))
-	return outputStr, nil
+	return inspect.ExitCode, nil
 }
 
 // CopyToContainer copies a file to a container
@@ -358,6 +539,142 @@ func (s *DockerService) CopyToContainer(ctx context.Context, containerID string,
 	return nil
 }
 
+// StatPath returns information about a path inside a container without following
+// a trailing symbolic link
+func (s *DockerService) StatPath(ctx context.Context, containerID, path string) (container.PathStat, error) {
+	utils.Debug("Stating path in container", "containerID", utils.ShortID(containerID), "path", path)
+
+	stat, err := s.client.ContainerStatPath(ctx, containerID, path)
+	if err != nil {
+		return container.PathStat{}, fmt.Errorf("failed to stat path: %w", err)
+	}
+	return stat, nil
+}
+
+// CopyFromContainer streams a file or directory of a container as a tar archive
+// whose entries are named after the base name of path. The caller must close the
+// returned reader.
+func (s *DockerService) CopyFromContainer(ctx context.Context, containerID, path string) (io.ReadCloser, error) {
+	utils.Debug("Copying from container", "containerID", utils.ShortID(containerID), "path", path)
+
+	reader, _, err := s.client.CopyFromContainer(ctx, containerID, path)
+	if err != nil {
+		utils.Error("Failed to copy from container", "containerID", utils.ShortID(containerID), "path", path, "error", err)
+		return nil, fmt.Errorf("failed to copy from container: %w", err)
+	}
+	return reader, nil
+}
+
+// CopyArchiveToContainer extracts a tar archive into an existing directory of a
+// container. Extracted files are owned by the container's configured user.
+func (s *DockerService) CopyArchiveToContainer(ctx context.Context, containerID, dir string, archive io.Reader) error {
+	utils.Debug("Copying archive to container", "containerID", utils.ShortID(containerID), "dir", dir)
+
+	err := s.client.CopyToContainer(ctx, containerID, dir, archive, container.CopyToContainerOptions{CopyUIDGID: true})
+	if err != nil {
+		utils.Error("Failed to copy archive to container", "containerID", utils.ShortID(containerID), "dir", dir, "error", err)
+		return fmt.Errorf("failed to copy archive to container: %w", err)
+	}
+	return nil
+}
+
+// ExportContainer streams the filesystem of a container as a tar archive.
+// The caller must close the returned reader.
+func (s *DockerService) ExportContainer(ctx context.Context, containerID string) (io.ReadCloser, error) {
+	utils.Debug("Exporting container", "containerID", utils.ShortID(containerID))
+
+	reader, err := s.client.ContainerExport(ctx, containerID)
+	if err != nil {
+		utils.Error("Failed to export container", "containerID", utils.ShortID(containerID), "error", err)
+		return nil, fmt.Errorf("failed to export container: %w", err)
+	}
+	return reader, nil
+}
+
+// ImportImage creates an image tagged ref from a filesystem tar archive
+// (as written by ExportContainer)
+func (s *DockerService) ImportImage(ctx context.Context, source io.Reader, ref string) error {
+	utils.Info("Importing image", "ref", ref)
+
+	reader, err := s.client.ImageImport(ctx, image.ImportSource{Source: source, SourceName: "-"}, ref, image.ImportOptions{})
+	if err != nil {
+		utils.Error("Failed to import image", "ref", ref, "error", err)
+		return fmt.Errorf("failed to import image: %w", err)
+	}
+	defer reader.Close()
+
+	// The daemon reports failures inside the progress stream
+	decoder := json.NewDecoder(reader)
+	for {
+		var message struct {
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&message); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read import progress: %w", err)
+		}
+		if message.Error != "" {
+			utils.Error("Failed to import image", "ref", ref, "error", message.Error)
+			return fmt.Errorf("failed to import image: %s", message.Error)
+		}
+	}
+
+	utils.Info("Image imported successfully", "ref", ref)
+	return nil
+}
+
+// CommitContainer creates an image tagged ref from the current state of a
+// container and returns the image ID. The container keeps running; it is only
+// paused while its filesystem is captured.
+func (s *DockerService) CommitContainer(ctx context.Context, containerID, ref string, labels map[string]string) (string, error) {
+	utils.Info("Committing container", "containerID", utils.ShortID(containerID), "ref", ref)
+
+	changes := make([]string, 0, len(labels))
+	for key, value := range labels {
+		changes = append(changes, fmt.Sprintf("LABEL %s=%q", key, value))
+	}
+
+	resp, err := s.client.ContainerCommit(ctx, containerID, container.CommitOptions{
+		Reference: ref,
+		Comment:   "ViBox snapshot",
+		Changes:   changes,
+		Pause:     true,
+	})
+	if err != nil {
+		utils.Error("Failed to commit container", "containerID", utils.ShortID(containerID), "ref", ref, "error", err)
+		return "", fmt.Errorf("failed to commit container: %w", err)
+	}
+
+	utils.Info("Container committed successfully", "containerID", utils.ShortID(containerID), "imageID", utils.ShortID(resp.ID))
+	return resp.ID, nil
+}
+
+// ListImages lists the images whose reference matches the given pattern
+// (e.g. "repo" for every tag of repo)
+func (s *DockerService) ListImages(ctx context.Context, reference string) ([]image.Summary, error) {
+	utils.Debug("Listing images", "reference", reference)
+
+	images, err := s.client.ImageList(ctx, image.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("reference", reference)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	return images, nil
+}
+
+// RemoveImage removes an image tag, deleting the image once no tag is left
+func (s *DockerService) RemoveImage(ctx context.Context, ref string) error {
+	utils.Info("Removing image", "ref", ref)
+
+	if _, err := s.client.ImageRemove(ctx, ref, image.RemoveOptions{PruneChildren: true}); err != nil {
+		utils.Error("Failed to remove image", "ref", ref, "error", err)
+		return fmt.Errorf("failed to remove image: %w", err)
+	}
+	return nil
+}
+
 // ListContainers lists containers matching the given filters
 func (s *DockerService) ListContainers(ctx context.Context, filterMap map[string]string) ([]types.Container, error) {
 	utils.Debug("Listing containers", "filters", filterMap)
@@ -393,6 +710,73 @@ func (s *DockerService) ListContainers(ctx context.Context, filterMap map[string
 	return containers, nil
 }
 
+// OnContainerChange registers a listener that is called with the container ID whenever
+// a container is started, stopped or removed, through this service or (once
+// WatchEvents is running, for workspace containers) by anyone else on the Docker host
+func (s *DockerService) OnContainerChange(listener func(containerID string)) {
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+	s.changeListeners = append(s.changeListeners, listener)
+}
+
+// notifyContainerChange calls the registered container change listeners
+func (s *DockerService) notifyContainerChange(containerID string) {
+	s.listenersMu.RLock()
+	listeners := s.changeListeners
+	s.listenersMu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(containerID)
+	}
+}
+
+// WatchEvents subscribes to lifecycle events of workspace containers and notifies the
+// container change listeners until ctx is cancelled. The subscription is
+// re-established after errors (e.g. a Docker daemon restart).
+func (s *DockerService) WatchEvents(ctx context.Context) {
+	// Only workspace containers matter to the listeners; other containers on the
+	// host (including git helpers) would just churn the caches
+	eventFilters := filters.NewArgs(
+		filters.Arg("type", string(events.ContainerEventType)),
+		filters.Arg("label", "vibox.workspace"),
+	)
+	for _, action := range []events.Action{
+		events.ActionStart, events.ActionRestart, events.ActionDie, events.ActionStop,
+		events.ActionKill, events.ActionDestroy, events.ActionPause, events.ActionUnPause,
+	} {
+		eventFilters.Add("event", string(action))
+	}
+
+	const retryDelay = 5 * time.Second
+	for {
+		utils.Debug("Subscribing to Docker events")
+		messages, errs := s.client.Events(ctx, events.ListOptions{Filters: eventFilters})
+
+	receive:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-messages:
+				utils.Debug("Docker container event", "containerID", utils.ShortID(msg.Actor.ID), "action", string(msg.Action))
+				s.notifyContainerChange(msg.Actor.ID)
+			case err := <-errs:
+				if ctx.Err() != nil {
+					return
+				}
+				utils.Warn("Docker event stream interrupted, resubscribing", "error", err, "retryIn", retryDelay.String())
+				break receive
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
 // Close closes the Docker client connection
 func (s *DockerService) Close() error {
 	utils.Info("Closing Docker client")
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+// Event types published on the event bus
+const (
+	EventPortOpened = "port.opened"
+	EventPortClosed = "port.closed"
+)
+
+// eventBufferSize is the number of events buffered per subscriber before events are dropped
+const eventBufferSize = 64
+
+// Event is a notification about a change in a workspace
+type Event struct {
+	Type        string    `json:"type"`
+	WorkspaceID string    `json:"workspace_id"`
+	Data        any       `json:"data,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// EventBus fans out events to subscribers (e.g. the /api/events stream)
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+	closed      bool
+}
+
+// NewEventBus creates a new event bus
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe registers a new subscriber. The returned function must be called to
+// unsubscribe; it closes the channel.
+func (b *EventBus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBufferSize)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Publish delivers an event to all subscribers. Slow subscribers whose buffer is
+// full miss the event rather than blocking the publisher.
+func (b *EventBus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			utils.Warn("Dropping event for slow subscriber", "type", event.Type, "workspaceID", event.WorkspaceID)
+		}
+	}
+}
+
+// Close closes all subscriber channels so that streaming clients finish, e.g. on shutdown
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
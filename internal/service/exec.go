@@ -0,0 +1,384 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+const (
+	// defaultExecTimeout applies when a request does not specify a timeout
+	defaultExecTimeout = 5 * time.Minute
+	// maxExecTimeout is the upper bound accepted for a single command
+	maxExecTimeout = time.Hour
+	// maxExecOutput caps the buffered stdout/stderr of a non-streaming exec (per stream)
+	maxExecOutput = 10 * 1024 * 1024
+	// execKillGracePeriod is how long an interrupted command gets to exit after
+	// SIGTERM before its process group is killed
+	execKillGracePeriod = 5 * time.Second
+)
+
+var (
+	// ErrExecNotFound is returned when cancelling an exec that is not running
+	ErrExecNotFound = errors.New("exec not found")
+	// ErrInvalidExecRequest is returned when an exec request's command, environment or timeout is not acceptable
+	ErrInvalidExecRequest = errors.New("invalid exec request")
+)
+
+// ExecRequest represents a request to run a non-interactive command in a workspace
+type ExecRequest struct {
+	Cmd     []string          `json:"cmd" binding:"required,min=1"`
+	Env     map[string]string `json:"env,omitempty"`
+	User    string            `json:"user,omitempty"`
+	Workdir string            `json:"workdir,omitempty"`
+	Stdin   string            `json:"stdin,omitempty"`
+	Timeout int               `json:"timeout,omitempty"` // Seconds (default 300, max 3600)
+}
+
+// Validate checks the command, environment and timeout of the request, so
+// callers can reject it before starting to respond
+func (r ExecRequest) Validate() error {
+	if len(r.Cmd) == 0 {
+		return fmt.Errorf("%w: cmd cannot be empty", ErrInvalidExecRequest)
+	}
+	if _, err := buildExecEnv(r.Env); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidExecRequest, err)
+	}
+	if _, err := execTimeout(r.Timeout); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidExecRequest, err)
+	}
+	return nil
+}
+
+// ExecResult holds the outcome of a completed non-streaming exec
+type ExecResult struct {
+	ExecID     string `json:"exec_id"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	ExitCode   int    `json:"exit_code"`
+	TimedOut   bool   `json:"timed_out,omitempty"`
+	Canceled   bool   `json:"canceled,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"` // Output exceeded the buffer limit
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// ExecChunk is a single event of a streaming exec
+type ExecChunk struct {
+	Type     string `json:"type"` // "start", "stdout", "stderr", "exit", "error"
+	ExecID   string `json:"exec_id,omitempty"`
+	Data     string `json:"data,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	TimedOut bool   `json:"timed_out,omitempty"`
+	Canceled bool   `json:"canceled,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// runningExec tracks an in-flight exec so that it can be cancelled
+type runningExec struct {
+	workspaceID string
+	containerID string
+	pidFile     string
+	cancel      context.CancelFunc
+
+	mu       sync.Mutex
+	canceled bool
+}
+
+// ExecService runs non-interactive commands inside workspace containers
+type ExecService struct {
+	dockerSvc *DockerService
+	running   sync.Map // map[execID]*runningExec
+}
+
+// NewExecService creates a new exec service instance
+func NewExecService(dockerSvc *DockerService) *ExecService {
+	utils.Info("Initializing exec service")
+	return &ExecService{
+		dockerSvc: dockerSvc,
+	}
+}
+
+// Run executes a command and waits for it to finish, returning its buffered output
+func (s *ExecService) Run(ctx context.Context, workspaceID, containerID string, req ExecRequest) (*ExecResult, error) {
+	stdout := &limitedBuffer{limit: maxExecOutput}
+	stderr := &limitedBuffer{limit: maxExecOutput}
+
+	result := &ExecResult{}
+	outcome, err := s.execute(ctx, workspaceID, containerID, req, func(execID string) {
+		result.ExecID = execID
+	}, stdout, stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	result.ExitCode = outcome.exitCode
+	result.TimedOut = outcome.timedOut
+	result.Canceled = outcome.canceled
+	result.Truncated = stdout.truncated || stderr.truncated
+	result.DurationMs = outcome.duration.Milliseconds()
+	return result, nil
+}
+
+// Stream executes a command and reports its output through emit as it is produced.
+// If emit fails (e.g. the client went away) the command is cancelled.
+func (s *ExecService) Stream(ctx context.Context, workspaceID, containerID string, req ExecRequest, emit func(ExecChunk) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var emitMu sync.Mutex
+	send := func(chunk ExecChunk) {
+		emitMu.Lock()
+		defer emitMu.Unlock()
+		if err := emit(chunk); err != nil {
+			utils.Warn("Failed to emit exec output, cancelling", "error", err)
+			cancel()
+		}
+	}
+
+	stdout := chunkWriter{kind: "stdout", send: send}
+	stderr := chunkWriter{kind: "stderr", send: send}
+
+	var execID string
+	outcome, err := s.execute(ctx, workspaceID, containerID, req, func(id string) {
+		execID = id
+		send(ExecChunk{Type: "start", ExecID: id})
+	}, stdout, stderr)
+	if err != nil {
+		send(ExecChunk{Type: "error", ExecID: execID, Error: err.Error()})
+		return err
+	}
+
+	exitCode := outcome.exitCode
+	send(ExecChunk{
+		Type:     "exit",
+		ExecID:   execID,
+		ExitCode: &exitCode,
+		TimedOut: outcome.timedOut,
+		Canceled: outcome.canceled,
+	})
+	return nil
+}
+
+// Cancel stops a running exec belonging to the given workspace
+func (s *ExecService) Cancel(workspaceID, execID string) error {
+	value, ok := s.running.Load(execID)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrExecNotFound, execID)
+	}
+	run := value.(*runningExec)
+	if run.workspaceID != workspaceID {
+		return fmt.Errorf("%w: %s", ErrExecNotFound, execID)
+	}
+
+	utils.Info("Cancelling exec", "execID", execID, "workspaceID", workspaceID)
+	run.mu.Lock()
+	run.canceled = true
+	run.mu.Unlock()
+	run.cancel()
+	return nil
+}
+
+// execOutcome describes how an exec finished
+type execOutcome struct {
+	exitCode int
+	timedOut bool
+	canceled bool
+	duration time.Duration
+}
+
+// execute runs the command, registering it for cancellation while it is in flight
+func (s *ExecService) execute(
+	ctx context.Context,
+	workspaceID, containerID string,
+	req ExecRequest,
+	started func(execID string),
+	stdout, stderr io.Writer,
+) (*execOutcome, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	env, _ := buildExecEnv(req.Env)
+	timeout, _ := execTimeout(req.Timeout)
+
+	execID := utils.GenerateExecID()
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	run := &runningExec{
+		workspaceID: workspaceID,
+		containerID: containerID,
+		pidFile:     fmt.Sprintf("/tmp/vibox-%s.pid", execID),
+		cancel:      cancel,
+	}
+	s.running.Store(execID, run)
+	defer s.running.Delete(execID)
+
+	utils.Info("Running exec", "execID", execID, "workspaceID", workspaceID, "cmd", strings.Join(req.Cmd, " "), "timeout", timeout.String())
+	started(execID)
+
+	opts := ExecOptions{
+		Cmd:        wrapExecCommand(run.pidFile, req.Cmd),
+		Env:        env,
+		User:       req.User,
+		WorkingDir: req.Workdir,
+	}
+	if req.Stdin != "" {
+		opts.Stdin = strings.NewReader(req.Stdin)
+	}
+
+	start := time.Now()
+	exitCode, err := s.dockerSvc.ExecStream(runCtx, containerID, opts, stdout, stderr)
+	outcome := &execOutcome{exitCode: exitCode, duration: time.Since(start)}
+
+	if runCtx.Err() != nil {
+		// The stream is gone but the process may still be running in the container
+		s.killExec(containerID, run.pidFile)
+
+		run.mu.Lock()
+		outcome.canceled = run.canceled
+		run.mu.Unlock()
+		outcome.timedOut = errors.Is(runCtx.Err(), context.DeadlineExceeded)
+		outcome.exitCode = -1
+
+		utils.Warn("Exec interrupted", "execID", execID, "timedOut", outcome.timedOut, "canceled", outcome.canceled)
+		return outcome, nil
+	}
+	if err != nil {
+		utils.Error("Exec failed", "execID", execID, "error", err)
+		return nil, err
+	}
+
+	// Best-effort cleanup of the PID file
+	_, _ = s.dockerSvc.ExecCommand(context.Background(), containerID, []string{"rm", "-f", run.pidFile})
+
+	utils.Info("Exec finished", "execID", execID, "exitCode", exitCode, "duration", outcome.duration.String())
+	return outcome, nil
+}
+
+// killExec terminates the process group recorded in pidFile
+func (s *ExecService) killExec(containerID, pidFile string) {
+	ctx, cancel := context.WithTimeout(context.Background(), execKillGracePeriod+10*time.Second)
+	defer cancel()
+
+	_, err := s.dockerSvc.ExecCommand(ctx, containerID, killExecCommand(pidFile, execKillGracePeriod))
+	if err != nil {
+		utils.Warn("Failed to kill exec process", "containerID", utils.ShortID(containerID), "error", err)
+	}
+}
+
+// killExecCommand sends SIGTERM to the process group recorded in pidFile (so
+// children the command started go too) and SIGKILL if it is still alive after
+// grace. Without setsid the recorded PID is not a group leader and only it is signalled.
+func killExecCommand(pidFile string, grace time.Duration) []string {
+	const script = `[ -f "$0" ] || exit 0
+pid=$(cat "$0"); rm -f "$0"
+[ -n "$pid" ] || exit 0
+target=-$pid
+kill -0 "$target" 2>/dev/null || target=$pid
+kill -TERM "$target" 2>/dev/null || exit 0
+i=0
+while [ "$i" -lt "$1" ] && kill -0 "$target" 2>/dev/null; do sleep 1; i=$((i+1)); done
+kill -KILL "$target" 2>/dev/null
+exit 0`
+	return []string{"/bin/sh", "-c", script, pidFile, strconv.Itoa(int(grace / time.Second))}
+}
+
+// wrapExecCommand runs the command in its own session and process group (via
+// setsid, when the image has it) and records its PID, which is also the group
+// ID, so the command and everything it spawned can be killed later.
+// Stdin is passed through fd 3 because background jobs otherwise read /dev/null.
+func wrapExecCommand(pidFile string, cmd []string) []string {
+	const script = `exec 3<&0
+if command -v setsid >/dev/null 2>&1; then setsid "$@" <&3 3<&- & else "$@" <&3 3<&- & fi
+pid=$!; exec 3<&-
+echo "$pid" > "$0"
+wait "$pid"`
+	wrapped := []string{"/bin/sh", "-c", script, pidFile}
+	return append(wrapped, cmd...)
+}
+
+// buildExecEnv converts an env map into sorted KEY=VALUE pairs
+func buildExecEnv(env map[string]string) ([]string, error) {
+	if len(env) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		if key == "" || strings.ContainsAny(key, "=\x00") {
+			return nil, fmt.Errorf("invalid environment variable name: %q", key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+env[key])
+	}
+	return pairs, nil
+}
+
+// execTimeout validates a timeout in seconds and applies the default
+func execTimeout(seconds int) (time.Duration, error) {
+	if seconds < 0 {
+		return 0, fmt.Errorf("timeout cannot be negative")
+	}
+	if seconds == 0 {
+		return defaultExecTimeout, nil
+	}
+	timeout := time.Duration(seconds) * time.Second
+	if timeout > maxExecTimeout {
+		return 0, fmt.Errorf("timeout cannot exceed %d seconds", int(maxExecTimeout.Seconds()))
+	}
+	return timeout, nil
+}
+
+// limitedBuffer buffers up to limit bytes and silently drops the rest
+type limitedBuffer struct {
+	buf       strings.Builder
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	remaining := b.limit - b.buf.Len()
+	if remaining <= 0 {
+		b.truncated = len(p) > 0 || b.truncated
+		return len(p), nil
+	}
+	if len(p) > remaining {
+		b.buf.Write(p[:remaining])
+		b.truncated = true
+		return len(p), nil
+	}
+	b.buf.Write(p)
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
+
+// chunkWriter forwards writes as streaming exec chunks
+type chunkWriter struct {
+	kind string
+	send func(ExecChunk)
+}
+
+func (w chunkWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		w.send(ExecChunk{Type: w.kind, Data: string(p)})
+	}
+	return len(p), nil
+}
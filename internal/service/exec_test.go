@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/config"
+)
+
+func TestBuildExecEnv(t *testing.T) {
+	env, err := buildExecEnv(map[string]string{"B": "2", "A": "1=one"})
+	if err != nil {
+		t.Fatalf("Expected valid env, got error: %v", err)
+	}
+	if strings.Join(env, ",") != "A=1=one,B=2" {
+		t.Errorf("Expected sorted KEY=VALUE pairs, got %v", env)
+	}
+
+	if _, err := buildExecEnv(map[string]string{"BAD=KEY": "x"}); err == nil {
+		t.Error("Expected error for variable name containing '='")
+	}
+	if env, err := buildExecEnv(nil); err != nil || env != nil {
+		t.Errorf("Expected nil env for empty map, got %v, %v", env, err)
+	}
+}
+
+func TestExecTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		seconds int
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "default", seconds: 0, want: defaultExecTimeout},
+		{name: "explicit", seconds: 30, want: 30 * time.Second},
+		{name: "negative", seconds: -1, wantErr: true},
+		{name: "too long", seconds: int(maxExecTimeout.Seconds()) + 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := execTimeout(tt.seconds)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("execTimeout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("execTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecRequestValidate(t *testing.T) {
+	if err := (ExecRequest{Cmd: []string{"true"}, Env: map[string]string{"A": "1"}, Timeout: 10}).Validate(); err != nil {
+		t.Errorf("Expected valid request, got %v", err)
+	}
+	for _, req := range []ExecRequest{
+		{},
+		{Cmd: []string{"env"}, Env: map[string]string{"": "x"}},
+		{Cmd: []string{"true"}, Timeout: -1},
+		{Cmd: []string{"true"}, Timeout: 3601},
+	} {
+		if err := req.Validate(); !errors.Is(err, ErrInvalidExecRequest) {
+			t.Errorf("Expected ErrInvalidExecRequest for %+v, got %v", req, err)
+		}
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	buf := &limitedBuffer{limit: 5}
+	buf.Write([]byte("abc"))
+	buf.Write([]byte("defg"))
+	buf.Write([]byte("h"))
+
+	if buf.String() != "abcde" {
+		t.Errorf("Expected buffer to hold 'abcde', got %q", buf.String())
+	}
+	if !buf.truncated {
+		t.Error("Expected buffer to be marked as truncated")
+	}
+}
+
+// TestWrapExecCommand runs the wrapper with the local shell to verify that the
+// recorded PID belongs to the command itself and arguments pass through intact
+func TestWrapExecCommand(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("/bin/sh not available")
+	}
+
+	pidFile := filepath.Join(t.TempDir(), "exec.pid")
+	args := wrapExecCommand(pidFile, []string{"/bin/sh", "-c", `echo "$$ $1"`, "sh", "hello world"})
+
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		t.Fatalf("Failed to run wrapped command: %v", err)
+	}
+
+	fields := strings.SplitN(strings.TrimSpace(string(out)), " ", 2)
+	if len(fields) != 2 || fields[1] != "hello world" {
+		t.Fatalf("Unexpected output: %q", out)
+	}
+
+	recorded, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("Expected PID file to be written: %v", err)
+	}
+	if strings.TrimSpace(string(recorded)) != fields[0] {
+		t.Errorf("Expected recorded PID %s to match command PID %s", strings.TrimSpace(string(recorded)), fields[0])
+	}
+	if _, err := strconv.Atoi(fields[0]); err != nil {
+		t.Errorf("Expected numeric PID, got %q", fields[0])
+	}
+}
+
+// TestKillExecCommand runs a wrapped command whose shell and child ignore
+// SIGTERM with the local shell, and verifies the whole process group is killed
+func TestKillExecCommand(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("/bin/sh not available")
+	}
+	if _, err := exec.LookPath("setsid"); err != nil {
+		t.Skip("setsid not available")
+	}
+
+	pidFile := filepath.Join(t.TempDir(), "exec.pid")
+	args := wrapExecCommand(pidFile, []string{"/bin/sh", "-c", `trap "" TERM; sleep 60 & echo $!; wait`})
+	cmd := exec.Command(args[0], args[1:]...)
+	stdout, _ := cmd.StdoutPipe()
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start wrapped command: %v", err)
+	}
+	defer cmd.Process.Kill()
+
+	line := make([]byte, 32)
+	n, _ := stdout.Read(line)
+	child := strings.TrimSpace(string(line[:n]))
+	if _, err := strconv.Atoi(child); err != nil {
+		t.Fatalf("Expected child PID, got %q", child)
+	}
+
+	killArgs := killExecCommand(pidFile, time.Second)
+	if out, err := exec.Command(killArgs[0], killArgs[1:]...).CombinedOutput(); err != nil {
+		t.Fatalf("Kill script failed: %v: %s", err, out)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected wrapped command to exit after the kill")
+	}
+
+	// The grandchild must be gone as well (or only await reaping)
+	if stat, err := os.ReadFile("/proc/" + child + "/stat"); err == nil {
+		if fields := strings.Fields(string(stat)); len(fields) > 2 && fields[2] != "Z" {
+			t.Errorf("Expected child process %s to be killed, state %s", child, fields[2])
+		}
+	}
+	if _, err := os.Stat(pidFile); !os.IsNotExist(err) {
+		t.Error("Expected PID file to be removed")
+	}
+}
+
+func TestExecServiceRun(t *testing.T) {
+	cfg := &config.Config{
+		DockerHost:   "unix:///var/run/docker.sock",
+		DefaultImage: "alpine:latest",
+		MemoryLimit:  128 * 1024 * 1024,
+		CPULimit:     500000000,
+	}
+
+	dockerSvc, err := NewDockerService(cfg)
+	if err != nil {
+		t.Skipf("Skipping test: Docker is not available: %v", err)
+	}
+	defer dockerSvc.Close()
+
+	ctx := context.Background()
+	containerID, err := dockerSvc.CreateContainer(ctx, ContainerConfig{
+		Image: "alpine:latest",
+		Name:  "vibox-test-exec-api",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create container: %v", err)
+	}
+	defer dockerSvc.RemoveContainer(ctx, containerID)
+
+	if err := dockerSvc.StartContainer(ctx, containerID); err != nil {
+		t.Fatalf("Failed to start container: %v", err)
+	}
+
+	execSvc := NewExecService(dockerSvc)
+	result, err := execSvc.Run(ctx, "ws-test", containerID, ExecRequest{
+		Cmd:   []string{"/bin/sh", "-c", `cat; echo "$GREETING" >&2; exit 3`},
+		Env:   map[string]string{"GREETING": "hi"},
+		Stdin: "from stdin",
+	})
+	if err != nil {
+		t.Fatalf("Failed to run exec: %v", err)
+	}
+
+	if result.Stdout != "from stdin" {
+		t.Errorf("Expected stdout 'from stdin', got %q", result.Stdout)
+	}
+	if result.Stderr != "hi\n" {
+		t.Errorf("Expected stderr 'hi\\n', got %q", result.Stderr)
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("Expected exit code 3, got %d", result.ExitCode)
+	}
+
+	// A timed out command is reported rather than returned as an error
+	result, err = execSvc.Run(ctx, "ws-test", containerID, ExecRequest{
+		Cmd:     []string{"sleep", "30"},
+		Timeout: 1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to run exec: %v", err)
+	}
+	if !result.TimedOut || result.ExitCode != -1 {
+		t.Errorf("Expected timed out exec with exit code -1, got %+v", result)
+	}
+}
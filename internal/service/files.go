@@ -0,0 +1,705 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/config"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// maxListEntries caps the entries returned for a single directory
+const maxListEntries = 10000
+
+// Archive formats of directory downloads
+const (
+	ArchiveFormatTar = "tar"
+	ArchiveFormatZip = "zip"
+)
+
+var (
+	// ErrInvalidPath is returned for paths that are not absolute, contain NUL
+	// bytes or name the root directory where that is not allowed
+	ErrInvalidPath = errors.New("invalid path")
+	// ErrFileNotFound is returned when a path does not exist in the container
+	ErrFileNotFound = errors.New("file not found")
+	// ErrNotDirectory is returned when a directory is expected but the path is something else
+	ErrNotDirectory = errors.New("not a directory")
+	// ErrIsDirectory is returned when a file is expected but the path is a directory
+	ErrIsDirectory = errors.New("is a directory")
+	// ErrFileExists is returned when creating or renaming onto an existing path
+	ErrFileExists = errors.New("file already exists")
+	// ErrFileTooLarge is returned when a download exceeds the configured limit
+	ErrFileTooLarge = errors.New("file too large")
+	// ErrInvalidArchive is returned for uploaded tar archives that cannot be extracted safely
+	ErrInvalidArchive = errors.New("invalid archive")
+)
+
+// FileInfo describes a file or directory inside a workspace container
+type FileInfo struct {
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	Type       string    `json:"type"` // "file", "directory", "symlink" or "other"
+	Size       int64     `json:"size"`
+	Mode       string    `json:"mode"` // e.g. "-rw-r--r--"
+	ModTime    time.Time `json:"mtime"`
+	LinkTarget string    `json:"link_target,omitempty"`
+}
+
+// DirectoryListing is the content of a directory, directories first
+type DirectoryListing struct {
+	Path      string      `json:"path"`
+	Entries   []*FileInfo `json:"entries"`
+	Truncated bool        `json:"truncated,omitempty"` // More than maxListEntries entries
+}
+
+// UploadFile is a single file to upload into a directory
+type UploadFile struct {
+	Name    string
+	Size    int64
+	Content io.Reader
+}
+
+// FileService browses and modifies the filesystem of workspace containers.
+// Reads and writes go through Docker's archive API; listing, renaming and
+// deleting run find/stat, mv and rm in the container, which must be running.
+type FileService struct {
+	dockerSvc       *DockerService
+	maxUploadSize   int64
+	maxDownloadSize int64
+}
+
+// NewFileService creates a new file service
+func NewFileService(dockerSvc *DockerService, cfg *config.Config) *FileService {
+	return &FileService{
+		dockerSvc:       dockerSvc,
+		maxUploadSize:   cfg.FilesMaxUploadSize,
+		maxDownloadSize: cfg.FilesMaxDownloadSize,
+	}
+}
+
+// MaxUploadSize returns the largest accepted upload in bytes (0 = unlimited)
+func (s *FileService) MaxUploadSize() int64 {
+	return s.maxUploadSize
+}
+
+// Stat returns information about a path. A symbolic link is reported with the
+// type, size and mode of what it points to.
+func (s *FileService) Stat(ctx context.Context, containerID, filePath string) (*FileInfo, error) {
+	filePath, err := cleanFilePath(filePath)
+	if err != nil {
+		return nil, err
+	}
+	info, _, err := s.resolve(ctx, containerID, filePath)
+	return info, err
+}
+
+// List returns the entries of a directory
+func (s *FileService) List(ctx context.Context, containerID, dir string) (*DirectoryListing, error) {
+	utils.Debug("Listing directory", "containerID", utils.ShortID(containerID), "path", dir)
+
+	dir, err := cleanFilePath(dir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.directory(ctx, containerID, dir); err != nil {
+		return nil, err
+	}
+
+	// The trailing slash makes find follow a symbolic link to a directory
+	cmd := []string{"find", strings.TrimSuffix(dir, "/") + "/", "-mindepth", "1", "-maxdepth", "1",
+		"-exec", "stat", "-c", "%s %f %Y %n", "{}", "+"}
+	stdout := &limitedBuffer{limit: maxExecOutput}
+	var stderr strings.Builder
+	exitCode, err := s.dockerSvc.ExecStream(ctx, containerID, ExecOptions{Cmd: cmd}, stdout, &stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list directory: %w", err)
+	}
+
+	lines := strings.Split(stdout.String(), "\n")
+	if stdout.truncated && len(lines) > 0 {
+		lines = lines[:len(lines)-1] // Drop the partial last line
+	}
+	listing := &DirectoryListing{Path: dir, Entries: []*FileInfo{}, Truncated: stdout.truncated}
+	for _, line := range lines {
+		entry, ok := parseStatLine(dir, line)
+		if !ok {
+			continue
+		}
+		if len(listing.Entries) == maxListEntries {
+			listing.Truncated = true
+			break
+		}
+		listing.Entries = append(listing.Entries, entry)
+	}
+	// find exits non-zero when some entries could not be read; keep what it found
+	if exitCode != 0 && len(listing.Entries) == 0 {
+		return nil, fmt.Errorf("failed to list directory: %s", commandError(exitCode, stderr.String()))
+	}
+
+	slices.SortFunc(listing.Entries, func(a, b *FileInfo) int {
+		if aDir, bDir := a.Type == "directory", b.Type == "directory"; aDir != bDir {
+			if aDir {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return listing, nil
+}
+
+// DownloadFile writes the content of a file to w
+func (s *FileService) DownloadFile(ctx context.Context, containerID, filePath string, w io.Writer) error {
+	utils.Debug("Downloading file", "containerID", utils.ShortID(containerID), "path", filePath)
+
+	filePath, err := cleanFilePath(filePath)
+	if err != nil {
+		return err
+	}
+	info, target, err := s.resolve(ctx, containerID, filePath)
+	if err != nil {
+		return err
+	}
+	if info.Type == "directory" {
+		return fmt.Errorf("%w: %s", ErrIsDirectory, filePath)
+	}
+	if s.maxDownloadSize > 0 && info.Size > s.maxDownloadSize {
+		return fmt.Errorf("%w: %d bytes exceeds the limit of %d", ErrFileTooLarge, info.Size, s.maxDownloadSize)
+	}
+
+	reader, err := s.dockerSvc.CopyFromContainer(ctx, containerID, target)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	tr := tar.NewReader(reader)
+	if _, err := tr.Next(); err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	if _, err := io.Copy(w, tr); err != nil {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+	return nil
+}
+
+// DownloadArchive writes a file or directory to w as a tar or zip archive
+// whose entries are named after the base name of the path. The download stops
+// with ErrFileTooLarge once it exceeds the configured limit.
+func (s *FileService) DownloadArchive(ctx context.Context, containerID, filePath, format string, w io.Writer) error {
+	utils.Debug("Downloading archive", "containerID", utils.ShortID(containerID), "path", filePath, "format", format)
+
+	if format != ArchiveFormatTar && format != ArchiveFormatZip {
+		return fmt.Errorf("%w: unsupported archive format %q", ErrInvalidPath, format)
+	}
+	filePath, err := cleanFilePath(filePath)
+	if err != nil {
+		return err
+	}
+	_, target, err := s.resolve(ctx, containerID, filePath)
+	if err != nil {
+		return err
+	}
+
+	reader, err := s.dockerSvc.CopyFromContainer(ctx, containerID, target)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	var source io.Reader = reader
+	if s.maxDownloadSize > 0 {
+		source = &cappedReader{r: reader, limit: s.maxDownloadSize}
+	}
+
+	if format == ArchiveFormatZip {
+		return tarToZip(w, source)
+	}
+	if _, err := io.Copy(w, source); err != nil {
+		return fmt.Errorf("failed to download archive: %w", err)
+	}
+	return nil
+}
+
+// Upload writes files into an existing directory, replacing files of the same name
+func (s *FileService) Upload(ctx context.Context, containerID, dir string, files []UploadFile) error {
+	utils.Info("Uploading files", "containerID", utils.ShortID(containerID), "path", dir, "count", len(files))
+
+	dir, err := cleanFilePath(dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if !isValidFileName(file.Name) {
+			return fmt.Errorf("%w: file name %q", ErrInvalidPath, file.Name)
+		}
+	}
+	target, err := s.directory(ctx, containerID, dir)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return s.copyTar(ctx, containerID, target, func(tw *tar.Writer) error {
+		for _, file := range files {
+			header := &tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     file.Name,
+				Mode:     0644,
+				Size:     file.Size,
+				ModTime:  now,
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return fmt.Errorf("failed to write tar header: %w", err)
+			}
+			if _, err := io.Copy(tw, file.Content); err != nil {
+				return fmt.Errorf("failed to upload %s: %w", file.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// UploadArchive extracts a tar archive into an existing directory. Only regular
+// files, directories and symbolic links are accepted, and no entry may leave
+// the directory.
+func (s *FileService) UploadArchive(ctx context.Context, containerID, dir string, archive io.Reader) error {
+	utils.Info("Uploading archive", "containerID", utils.ShortID(containerID), "path", dir)
+
+	dir, err := cleanFilePath(dir)
+	if err != nil {
+		return err
+	}
+	target, err := s.directory(ctx, containerID, dir)
+	if err != nil {
+		return err
+	}
+
+	// Entries are copied into a fresh archive so only vetted headers reach Docker
+	return s.copyTar(ctx, containerID, target, func(tw *tar.Writer) error {
+		tr := tar.NewReader(archive)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+			}
+
+			name, err := archiveEntryName(header.Name)
+			if err != nil {
+				return err
+			}
+			if name == "" {
+				continue // The archive's own root ("./")
+			}
+			clean := &tar.Header{
+				Name:    name,
+				Mode:    header.Mode & 0777,
+				ModTime: header.ModTime,
+			}
+			switch header.Typeflag {
+			case tar.TypeReg:
+				clean.Typeflag = tar.TypeReg
+				clean.Size = header.Size
+			case tar.TypeDir:
+				clean.Typeflag = tar.TypeDir
+				clean.Name += "/"
+			case tar.TypeSymlink:
+				clean.Typeflag = tar.TypeSymlink
+				clean.Linkname = header.Linkname
+			default:
+				return fmt.Errorf("%w: unsupported entry type of %s", ErrInvalidArchive, header.Name)
+			}
+
+			if err := tw.WriteHeader(clean); err != nil {
+				return fmt.Errorf("failed to write tar header: %w", err)
+			}
+			if clean.Typeflag == tar.TypeReg {
+				if _, err := io.Copy(tw, tr); err != nil {
+					return fmt.Errorf("failed to upload %s: %w", name, err)
+				}
+			}
+		}
+	})
+}
+
+// MakeDirectory creates a directory whose parent already exists
+func (s *FileService) MakeDirectory(ctx context.Context, containerID, dir string) error {
+	utils.Info("Creating directory", "containerID", utils.ShortID(containerID), "path", dir)
+
+	dir, err := cleanFilePath(dir)
+	if err != nil {
+		return err
+	}
+	if dir == "/" {
+		return fmt.Errorf("%w: / already exists", ErrInvalidPath)
+	}
+	if err := s.ensureAbsent(ctx, containerID, dir); err != nil {
+		return err
+	}
+	parent, err := s.directory(ctx, containerID, path.Dir(dir))
+	if err != nil {
+		return err
+	}
+
+	return s.copyTar(ctx, containerID, parent, func(tw *tar.Writer) error {
+		return tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     path.Base(dir) + "/",
+			Mode:     0755,
+			ModTime:  time.Now(),
+		})
+	})
+}
+
+// Rename moves a file or directory to a path that does not exist yet
+func (s *FileService) Rename(ctx context.Context, containerID, from, to string) error {
+	utils.Info("Renaming file", "containerID", utils.ShortID(containerID), "from", from, "to", to)
+
+	from, err := cleanFilePath(from)
+	if err != nil {
+		return err
+	}
+	to, err = cleanFilePath(to)
+	if err != nil {
+		return err
+	}
+	if from == "/" || to == "/" {
+		return fmt.Errorf("%w: cannot rename /", ErrInvalidPath)
+	}
+	if to == from || strings.HasPrefix(to, from+"/") {
+		return fmt.Errorf("%w: cannot move %s into itself", ErrInvalidPath, from)
+	}
+	if _, err := s.lstat(ctx, containerID, from); err != nil {
+		return err
+	}
+	if err := s.ensureAbsent(ctx, containerID, to); err != nil {
+		return err
+	}
+	if _, err := s.directory(ctx, containerID, path.Dir(to)); err != nil {
+		return err
+	}
+
+	return s.run(ctx, containerID, "rename", "mv", "--", from, to)
+}
+
+// Delete removes a file, or a directory with everything in it
+func (s *FileService) Delete(ctx context.Context, containerID, filePath string) error {
+	utils.Info("Deleting file", "containerID", utils.ShortID(containerID), "path", filePath)
+
+	filePath, err := cleanFilePath(filePath)
+	if err != nil {
+		return err
+	}
+	if filePath == "/" {
+		return fmt.Errorf("%w: cannot delete /", ErrInvalidPath)
+	}
+	if _, err := s.lstat(ctx, containerID, filePath); err != nil {
+		return err
+	}
+
+	return s.run(ctx, containerID, "delete", "rm", "-rf", "--", filePath)
+}
+
+// lstat returns information about a path without following a trailing symbolic link
+func (s *FileService) lstat(ctx context.Context, containerID, filePath string) (container.PathStat, error) {
+	stat, err := s.dockerSvc.StatPath(ctx, containerID, filePath)
+	if client.IsErrNotFound(err) {
+		return stat, fmt.Errorf("%w: %s", ErrFileNotFound, filePath)
+	}
+	return stat, err
+}
+
+// resolve returns information about a path, following a trailing symbolic
+// link, together with the path that link resolves to
+func (s *FileService) resolve(ctx context.Context, containerID, filePath string) (*FileInfo, string, error) {
+	stat, err := s.lstat(ctx, containerID, filePath)
+	if err != nil {
+		return nil, "", err
+	}
+	target := filePath
+	if stat.Mode&os.ModeSymlink != 0 && stat.LinkTarget != "" {
+		// Docker reports the link target fully resolved inside the container
+		target = stat.LinkTarget
+		linkTarget := stat.LinkTarget
+		if stat, err = s.lstat(ctx, containerID, target); err != nil {
+			return nil, "", err
+		}
+		stat.LinkTarget = linkTarget
+	}
+	return fileInfoFromStat(filePath, stat), target, nil
+}
+
+// directory verifies a path is a directory and returns the path it resolves to
+func (s *FileService) directory(ctx context.Context, containerID, dir string) (string, error) {
+	info, target, err := s.resolve(ctx, containerID, dir)
+	if err != nil {
+		return "", err
+	}
+	if info.Type != "directory" {
+		return "", fmt.Errorf("%w: %s", ErrNotDirectory, dir)
+	}
+	return target, nil
+}
+
+// ensureAbsent returns ErrFileExists if the path exists
+func (s *FileService) ensureAbsent(ctx context.Context, containerID, filePath string) error {
+	_, err := s.lstat(ctx, containerID, filePath)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %s", ErrFileExists, filePath)
+	case errors.Is(err, ErrFileNotFound):
+		return nil
+	default:
+		return err
+	}
+}
+
+// copyTar streams the archive produced by write into a directory of the container
+func (s *FileService) copyTar(ctx context.Context, containerID, dir string, write func(tw *tar.Writer) error) error {
+	reader, writer := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		tw := tar.NewWriter(writer)
+		err := write(tw)
+		if err == nil {
+			err = tw.Close()
+		}
+		writer.CloseWithError(err)
+		done <- err
+	}()
+
+	err := s.dockerSvc.CopyArchiveToContainer(ctx, containerID, dir, reader)
+	// Unblock the writer if Docker stopped reading early
+	reader.CloseWithError(io.ErrClosedPipe)
+	if writeErr := <-done; writeErr != nil && !errors.Is(writeErr, io.ErrClosedPipe) {
+		return writeErr
+	}
+	return err
+}
+
+// run executes a command in the container and turns a non-zero exit into an error
+func (s *FileService) run(ctx context.Context, containerID, operation string, cmd ...string) error {
+	var stderr strings.Builder
+	exitCode, err := s.dockerSvc.ExecStream(ctx, containerID, ExecOptions{Cmd: cmd}, io.Discard, &stderr)
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", operation, err)
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("failed to %s: %s", operation, commandError(exitCode, stderr.String()))
+	}
+	return nil
+}
+
+// commandError describes a failed command by its error output
+func commandError(exitCode int, stderr string) string {
+	if exitCode == 126 || exitCode == 127 {
+		return "required command not available in the container"
+	}
+	if message := strings.TrimSpace(stderr); message != "" {
+		return message
+	}
+	return fmt.Sprintf("exit code %d", exitCode)
+}
+
+// cleanFilePath validates an absolute path inside a container and returns it
+// in canonical form; ".." can never climb above the root
+func cleanFilePath(filePath string) (string, error) {
+	if filePath == "" {
+		return "", fmt.Errorf("%w: path is required", ErrInvalidPath)
+	}
+	if !strings.HasPrefix(filePath, "/") {
+		return "", fmt.Errorf("%w: %q is not absolute", ErrInvalidPath, filePath)
+	}
+	if strings.ContainsRune(filePath, 0) {
+		return "", fmt.Errorf("%w: path contains a NUL byte", ErrInvalidPath)
+	}
+	return path.Clean(filePath), nil
+}
+
+// isValidFileName reports whether name can be used as a single path element
+func isValidFileName(name string) bool {
+	return name != "" && name != "." && name != ".." &&
+		!strings.ContainsAny(name, "/\x00")
+}
+
+// archiveEntryName validates the name of an uploaded tar entry and returns it
+// cleaned and relative; the archive root itself yields ""
+func archiveEntryName(name string) (string, error) {
+	if strings.HasPrefix(name, "/") || strings.ContainsRune(name, 0) {
+		return "", fmt.Errorf("%w: entry %q is not a relative path", ErrInvalidArchive, name)
+	}
+	clean := path.Clean(name)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%w: entry %q leaves the target directory", ErrInvalidArchive, name)
+	}
+	if clean == "." {
+		return "", nil
+	}
+	return clean, nil
+}
+
+// parseStatLine parses a line of `stat -c "%s %f %Y %n"` output
+func parseStatLine(dir, line string) (*FileInfo, bool) {
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) != 4 {
+		return nil, false
+	}
+	size, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	rawMode, err := strconv.ParseUint(fields[1], 16, 32)
+	if err != nil {
+		return nil, false
+	}
+	mtime, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	name := path.Base(fields[3])
+	if !isValidFileName(name) {
+		return nil, false
+	}
+
+	return fileInfoFromStat(path.Join(dir, name), container.PathStat{
+		Name:  name,
+		Size:  size,
+		Mode:  unixFileMode(uint32(rawMode)),
+		Mtime: time.Unix(mtime, 0),
+	}), true
+}
+
+// fileInfoFromStat converts a Docker path stat into a FileInfo for filePath
+func fileInfoFromStat(filePath string, stat container.PathStat) *FileInfo {
+	info := &FileInfo{
+		Name:       path.Base(filePath),
+		Path:       filePath,
+		Size:       stat.Size,
+		Mode:       stat.Mode.String(),
+		ModTime:    stat.Mtime,
+		LinkTarget: stat.LinkTarget,
+	}
+	switch {
+	case stat.Mode.IsDir():
+		info.Type = "directory"
+	case stat.Mode&os.ModeSymlink != 0:
+		info.Type = "symlink"
+	case stat.Mode.IsRegular():
+		info.Type = "file"
+	default:
+		info.Type = "other"
+	}
+	return info
+}
+
+// unixFileMode converts a raw Unix st_mode into an os.FileMode
+func unixFileMode(raw uint32) os.FileMode {
+	mode := os.FileMode(raw & 0777)
+	switch raw & 0170000 {
+	case 0040000:
+		mode |= os.ModeDir
+	case 0120000:
+		mode |= os.ModeSymlink
+	case 0010000:
+		mode |= os.ModeNamedPipe
+	case 0140000:
+		mode |= os.ModeSocket
+	case 0020000:
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case 0060000:
+		mode |= os.ModeDevice
+	}
+	if raw&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if raw&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if raw&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+// tarToZip rewrites a tar stream as a zip archive. Hard links are skipped as
+// their content is not part of the tar stream.
+func tarToZip(w io.Writer, r io.Reader) error {
+	zw := zip.NewWriter(w)
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		zipHeader, err := zip.FileInfoHeader(header.FileInfo())
+		if err != nil {
+			return fmt.Errorf("failed to convert %s: %w", header.Name, err)
+		}
+		zipHeader.Name = header.Name
+		switch header.Typeflag {
+		case tar.TypeDir:
+			zipHeader.Name = strings.TrimSuffix(header.Name, "/") + "/"
+			zipHeader.Method = zip.Store
+			if _, err := zw.CreateHeader(zipHeader); err != nil {
+				return fmt.Errorf("failed to write %s: %w", header.Name, err)
+			}
+		case tar.TypeReg:
+			zipHeader.Method = zip.Deflate
+			entry, err := zw.CreateHeader(zipHeader)
+			if err != nil {
+				return fmt.Errorf("failed to write %s: %w", header.Name, err)
+			}
+			if _, err := io.Copy(entry, tr); err != nil {
+				return fmt.Errorf("failed to write %s: %w", header.Name, err)
+			}
+		case tar.TypeSymlink:
+			zipHeader.Method = zip.Store
+			entry, err := zw.CreateHeader(zipHeader)
+			if err != nil {
+				return fmt.Errorf("failed to write %s: %w", header.Name, err)
+			}
+			if _, err := io.WriteString(entry, header.Linkname); err != nil {
+				return fmt.Errorf("failed to write %s: %w", header.Name, err)
+			}
+		}
+	}
+	return zw.Close()
+}
+
+// cappedReader fails with ErrFileTooLarge once more than limit bytes were read
+type cappedReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (r *cappedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	if r.read > r.limit {
+		n -= int(r.read - r.limit)
+		r.read = r.limit
+		return max(n, 0), fmt.Errorf("%w: download exceeds the limit of %d bytes", ErrFileTooLarge, r.limit)
+	}
+	return n, err
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/config"
+)
+
+func TestCleanFilePath(t *testing.T) {
+	valid := map[string]string{
+		"/":               "/",
+		"/root/":          "/root",
+		"/a/./b//c":       "/a/b/c",
+		"/../../etc":      "/etc",
+		"/home/user/../x": "/home/x",
+	}
+	for input, expected := range valid {
+		if got, err := cleanFilePath(input); err != nil || got != expected {
+			t.Errorf("cleanFilePath(%q) = %q, %v; expected %q", input, got, err, expected)
+		}
+	}
+
+	for _, input := range []string{"", "relative", "../etc", "/a\x00b"} {
+		if _, err := cleanFilePath(input); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("Expected ErrInvalidPath for %q, got %v", input, err)
+		}
+	}
+}
+
+func TestArchiveEntryName(t *testing.T) {
+	valid := map[string]string{
+		"./":          "",
+		"file.txt":    "file.txt",
+		"./dir/":      "dir",
+		"dir/../file": "file",
+	}
+	for input, expected := range valid {
+		if got, err := archiveEntryName(input); err != nil || got != expected {
+			t.Errorf("archiveEntryName(%q) = %q, %v; expected %q", input, got, err, expected)
+		}
+	}
+
+	for _, input := range []string{"/etc/passwd", "../escape", "dir/../../escape", "a\x00b"} {
+		if _, err := archiveEntryName(input); !errors.Is(err, ErrInvalidArchive) {
+			t.Errorf("Expected ErrInvalidArchive for %q, got %v", input, err)
+		}
+	}
+}
+
+func TestParseStatLine(t *testing.T) {
+	entry, ok := parseStatLine("/home", "4096 41ed 1700000000 /home/user name")
+	if !ok {
+		t.Fatal("Expected line to parse")
+	}
+	if entry.Name != "user name" || entry.Path != "/home/user name" || entry.Type != "directory" {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+	if entry.Mode != "drwxr-xr-x" || entry.Size != 4096 || !entry.ModTime.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+
+	entry, ok = parseStatLine("/", "7 a1ff 1700000000 //link")
+	if !ok || entry.Path != "/link" || entry.Type != "symlink" {
+		t.Errorf("Expected symlink at /link, got %+v", entry)
+	}
+
+	for _, line := range []string{"", "garbage", "x 81a4 1 /f", "1 zz 1 /f", "1 81a4 1 /dir/.."} {
+		if _, ok := parseStatLine("/", line); ok {
+			t.Errorf("Expected %q to be rejected", line)
+		}
+	}
+}
+
+func TestUnixFileMode(t *testing.T) {
+	tests := map[uint32]os.FileMode{
+		0100644: 0644,
+		0040755: os.ModeDir | 0755,
+		0041777: os.ModeDir | os.ModeSticky | 0777,
+		0120777: os.ModeSymlink | 0777,
+		0104755: os.ModeSetuid | 0755,
+		0020620: os.ModeDevice | os.ModeCharDevice | 0620,
+	}
+	for raw, expected := range tests {
+		if got := unixFileMode(raw); got != expected {
+			t.Errorf("unixFileMode(%o) = %v, expected %v", raw, got, expected)
+		}
+	}
+}
+
+func TestTarToZip(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	entries := []struct {
+		header  tar.Header
+		content string
+	}{
+		{tar.Header{Typeflag: tar.TypeDir, Name: "project/", Mode: 0755}, ""},
+		{tar.Header{Typeflag: tar.TypeReg, Name: "project/main.go", Mode: 0644, Size: 12}, "package main"},
+		{tar.Header{Typeflag: tar.TypeSymlink, Name: "project/link", Linkname: "main.go", Mode: 0777}, ""},
+	}
+	for _, entry := range entries {
+		if err := tw.WriteHeader(&entry.header); err != nil {
+			t.Fatalf("WriteHeader failed: %v", err)
+		}
+		if _, err := tw.Write([]byte(entry.content)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := tarToZip(&out, &archive); err != nil {
+		t.Fatalf("tarToZip failed: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatalf("Invalid zip: %v", err)
+	}
+	if len(zr.File) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(zr.File))
+	}
+	if !zr.File[0].FileInfo().IsDir() || zr.File[0].Name != "project/" {
+		t.Errorf("Expected directory entry first, got %s", zr.File[0].Name)
+	}
+	file, err := zr.File[1].Open()
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer file.Close()
+	if content, _ := io.ReadAll(file); string(content) != "package main" {
+		t.Errorf("Unexpected content %q", content)
+	}
+	if zr.File[2].Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected symlink entry, got mode %v", zr.File[2].Mode())
+	}
+}
+
+func TestCappedReader(t *testing.T) {
+	reader := &cappedReader{r: strings.NewReader("0123456789"), limit: 4}
+	data, err := io.ReadAll(reader)
+	if !errors.Is(err, ErrFileTooLarge) || string(data) != "0123" {
+		t.Errorf("Expected 4 bytes and ErrFileTooLarge, got %q, %v", data, err)
+	}
+
+	reader = &cappedReader{r: strings.NewReader("0123"), limit: 4}
+	if data, err := io.ReadAll(reader); err != nil || string(data) != "0123" {
+		t.Errorf("Expected content within the limit, got %q, %v", data, err)
+	}
+}
+
+func TestFileService(t *testing.T) {
+	cfg := &config.Config{
+		DockerHost:   "unix:///var/run/docker.sock",
+		DefaultImage: "alpine:latest",
+	}
+
+	dockerSvc, err := NewDockerService(cfg)
+	if err != nil {
+		t.Skipf("Docker not available: %v", err)
+	}
+	defer dockerSvc.Close()
+
+	workspaceSvc := NewWorkspaceService(dockerSvc, newTestWorkspaceRepository(t), cfg)
+	fileSvc := NewFileService(dockerSvc, &config.Config{FilesMaxDownloadSize: 1024})
+
+	ctx := context.Background()
+	workspace, err := workspaceSvc.CreateWorkspace(ctx, CreateWorkspaceRequest{Name: "test-files", Image: "alpine:latest"})
+	if err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+	defer func() { _ = workspaceSvc.DeleteWorkspace(ctx, workspace.ID) }()
+
+	// Wait for background operation to complete
+	time.Sleep(5 * time.Second)
+
+	current, err := workspaceSvc.GetWorkspace(workspace.ID)
+	if err != nil || current.ContainerID == "" {
+		t.Fatalf("Workspace not ready: %+v, %v", current, err)
+	}
+	containerID := current.ContainerID
+
+	if err := fileSvc.MakeDirectory(ctx, containerID, "/work"); err != nil {
+		t.Fatalf("MakeDirectory failed: %v", err)
+	}
+	if err := fileSvc.MakeDirectory(ctx, containerID, "/work"); !errors.Is(err, ErrFileExists) {
+		t.Errorf("Expected ErrFileExists, got %v", err)
+	}
+	if err := fileSvc.MakeDirectory(ctx, containerID, "/missing/dir"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound, got %v", err)
+	}
+
+	content := "hello"
+	if err := fileSvc.Upload(ctx, containerID, "/work", []UploadFile{{Name: "hello.txt", Size: int64(len(content)), Content: strings.NewReader(content)}}); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	listing, err := fileSvc.List(ctx, containerID, "/work")
+	if err != nil || len(listing.Entries) != 1 || listing.Entries[0].Name != "hello.txt" || listing.Entries[0].Size != 5 {
+		t.Fatalf("Expected hello.txt in listing, got %+v, %v", listing, err)
+	}
+	if _, err := fileSvc.List(ctx, containerID, "/work/hello.txt"); !errors.Is(err, ErrNotDirectory) {
+		t.Errorf("Expected ErrNotDirectory, got %v", err)
+	}
+
+	var downloaded bytes.Buffer
+	if err := fileSvc.DownloadFile(ctx, containerID, "/work/hello.txt", &downloaded); err != nil || downloaded.String() != content {
+		t.Errorf("Expected %q, got %q, %v", content, downloaded.String(), err)
+	}
+	if err := fileSvc.DownloadFile(ctx, containerID, "/etc", io.Discard); !errors.Is(err, ErrIsDirectory) {
+		t.Errorf("Expected ErrIsDirectory, got %v", err)
+	}
+	if err := fileSvc.DownloadArchive(ctx, containerID, "/usr", ArchiveFormatTar, io.Discard); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge, got %v", err)
+	}
+
+	if err := fileSvc.Rename(ctx, containerID, "/work/hello.txt", "/work/renamed.txt"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if err := fileSvc.Rename(ctx, containerID, "/work", "/work/inside"); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("Expected ErrInvalidPath, got %v", err)
+	}
+	if err := fileSvc.Delete(ctx, containerID, "/work"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := fileSvc.Stat(ctx, containerID, "/work"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound after delete, got %v", err)
+	}
+	if err := fileSvc.Delete(ctx, containerID, "/"); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("Expected ErrInvalidPath for /, got %v", err)
+	}
+}
@@ -0,0 +1,488 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/repository"
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+const (
+	// gitCloneTimeout bounds a single clone
+	gitCloneTimeout = 10 * time.Minute
+	// maxGitCloneOutput caps the clone output kept in the log file
+	maxGitCloneOutput = 1024 * 1024
+	// defaultGitTokenUsername is sent with a token that has no username;
+	// hosts accepting tokens over HTTPS ignore it
+	defaultGitTokenUsername = "git"
+	// defaultGitHelperImage clones repositories that need a credential
+	defaultGitHelperImage = "alpine/git:latest"
+	// gitHelperCloneDir and gitHelperSecretDir are directories of the helper container
+	gitHelperCloneDir  = "/vibox-clone"
+	gitHelperSecretDir = "/tmp"
+)
+
+var (
+	// ErrGitCredentialNotFound is returned when a git credential does not exist for the user
+	ErrGitCredentialNotFound = errors.New("git credential not found")
+	// ErrInvalidGitCredential is returned when a credential's name, type or secret are not acceptable
+	ErrInvalidGitCredential = errors.New("invalid git credential")
+)
+
+// CreateGitCredentialRequest represents a request to store a git credential
+type CreateGitCredentialRequest struct {
+	Name       string                   `json:"name" binding:"required"`
+	Type       domain.GitCredentialType `json:"type" binding:"required"`
+	Username   string                   `json:"username,omitempty"` // token only (default "git")
+	Secret     string                   `json:"secret" binding:"required"`
+	KnownHosts string                   `json:"known_hosts,omitempty"` // ssh_key only
+}
+
+// GitService stores the git credentials of users and clones the repositories
+// of workspaces into their containers
+type GitService struct {
+	dockerSvc   *DockerService
+	credentials repository.GitCredentialRepository
+	users       repository.UserRepository
+	helperImage string // image of the containers that clone with a credential
+}
+
+// NewGitService creates a new git service. Repositories that need a credential
+// are cloned in containers of helperImage ("" = alpine/git), not in the workspace.
+func NewGitService(dockerSvc *DockerService, credentials repository.GitCredentialRepository, users repository.UserRepository, helperImage string) *GitService {
+	if helperImage == "" {
+		helperImage = defaultGitHelperImage
+	}
+	return &GitService{
+		dockerSvc:   dockerSvc,
+		credentials: credentials,
+		users:       users,
+		helperImage: helperImage,
+	}
+}
+
+// CreateCredential stores a git credential for a user
+func (s *GitService) CreateCredential(userID string, req CreateGitCredentialRequest) (*domain.GitCredential, error) {
+	utils.Info("Creating git credential", "userID", userID, "name", req.Name, "type", req.Type)
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 100 {
+		return nil, fmt.Errorf("%w: name must be 1-100 characters", ErrInvalidGitCredential)
+	}
+
+	credential := &domain.GitCredential{
+		ID:        utils.GenerateGitCredentialID(),
+		UserID:    userID,
+		Name:      name,
+		Type:      req.Type,
+		CreatedAt: time.Now(),
+	}
+	switch req.Type {
+	case domain.GitCredentialToken:
+		if req.KnownHosts != "" {
+			return nil, fmt.Errorf("%w: known_hosts only applies to SSH keys", ErrInvalidGitCredential)
+		}
+		token := strings.TrimSpace(req.Secret)
+		if token == "" || strings.ContainsAny(token, " \t\r\n") {
+			return nil, fmt.Errorf("%w: the token must be a single word", ErrInvalidGitCredential)
+		}
+		if strings.ContainsAny(req.Username, ": \t\r\n") {
+			return nil, fmt.Errorf("%w: invalid username", ErrInvalidGitCredential)
+		}
+		credential.Secret = token
+		credential.Username = req.Username
+	case domain.GitCredentialSSHKey:
+		if req.Username != "" {
+			return nil, fmt.Errorf("%w: username only applies to tokens; put it in the repository URL", ErrInvalidGitCredential)
+		}
+		key := strings.TrimSpace(req.Secret)
+		if !strings.HasPrefix(key, "-----BEGIN ") || !strings.Contains(key, "PRIVATE KEY-----") {
+			return nil, fmt.Errorf("%w: the secret must be a PEM or OpenSSH private key", ErrInvalidGitCredential)
+		}
+		credential.Secret = key + "\n" // ssh rejects keys without a final newline
+		credential.KnownHosts = strings.TrimSpace(req.KnownHosts)
+	default:
+		return nil, fmt.Errorf("%w: type must be %q or %q", ErrInvalidGitCredential, domain.GitCredentialToken, domain.GitCredentialSSHKey)
+	}
+
+	if err := s.credentials.Create(credential); err != nil {
+		return nil, fmt.Errorf("failed to save git credential: %w", err)
+	}
+
+	utils.Info("Git credential created", "userID", userID, "credentialID", credential.ID)
+	return credential, nil
+}
+
+// ListCredentials returns the git credentials of a user, oldest first
+func (s *GitService) ListCredentials(userID string) ([]*domain.GitCredential, error) {
+	all, err := s.credentials.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list git credentials: %w", err)
+	}
+
+	credentials := make([]*domain.GitCredential, 0)
+	for _, credential := range all {
+		if credential.UserID == userID {
+			credentials = append(credentials, credential)
+		}
+	}
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentials[i].CreatedAt.Before(credentials[j].CreatedAt)
+	})
+	return credentials, nil
+}
+
+// DeleteCredential deletes a git credential of a user. Workspaces referring to
+// it fail to clone the repository on their next reset.
+func (s *GitService) DeleteCredential(userID, credentialID string) error {
+	utils.Info("Deleting git credential", "userID", userID, "credentialID", credentialID)
+
+	if _, err := s.credential(userID, credentialID); err != nil {
+		return err
+	}
+	if err := s.credentials.Delete(credentialID); err != nil {
+		return fmt.Errorf("failed to delete git credential: %w", err)
+	}
+	return nil
+}
+
+// DeleteCredentialsOf removes all git credentials of a user; it is registered
+// as a user deletion listener so secrets do not outlive their account
+func (s *GitService) DeleteCredentialsOf(userID string) {
+	credentials, err := s.ListCredentials(userID)
+	if err != nil {
+		utils.Warn("Failed to list git credentials of deleted user", "userID", userID, "error", err)
+		return
+	}
+	for _, credential := range credentials {
+		if err := s.credentials.Delete(credential.ID); err != nil {
+			utils.Warn("Failed to delete git credential of deleted user", "credentialID", credential.ID, "error", err)
+		}
+	}
+}
+
+// CheckCredentials verifies the credentials of repositories belong to the
+// workspace owner, so nobody can clone with someone else's secret
+func (s *GitService) CheckCredentials(owner string, repositories []domain.GitRepository) error {
+	for _, repo := range repositories {
+		if repo.Credential == "" {
+			continue
+		}
+		credential, err := s.credential(owner, repo.Credential)
+		if err != nil {
+			return fmt.Errorf("%w: repository %s: %w", ErrInvalidWorkspaceConfig, repo.URL, err)
+		}
+		if err := checkCredentialURL(credential, repo.URL); err != nil {
+			return fmt.Errorf("%w: repository %s: %w", ErrInvalidWorkspaceConfig, repo.URL, err)
+		}
+	}
+	return nil
+}
+
+// CloneRepositories sets up the git identity of the workspace owner in a
+// container and clones the workspace repositories whose target path does not
+// exist yet. Each clone is logged next to the initialization script logs.
+func (s *GitService) CloneRepositories(ctx context.Context, containerID string, workspace *domain.Workspace) error {
+	s.configureIdentity(ctx, containerID, workspace)
+
+	repositories := workspace.Config.Repositories
+	if len(repositories) == 0 {
+		return nil
+	}
+
+	utils.Info("Cloning repositories", "workspaceID", workspace.ID, "count", len(repositories))
+	if _, err := s.dockerSvc.ExecCommand(ctx, containerID, []string{"mkdir", "-p", scriptLogDir}); err != nil {
+		utils.Warn("Failed to create log directory", "containerID", utils.ShortID(containerID), "error", err)
+	}
+
+	for i, repo := range repositories {
+		utils.Info("Cloning repository", "workspaceID", workspace.ID, "url", repo.URL, "path", repo.Path, "progress", fmt.Sprintf("%d/%d", i+1, len(repositories)))
+		if err := s.cloneRepository(ctx, containerID, workspace.Owner, repo); err != nil {
+			return err
+		}
+	}
+
+	utils.Info("All repositories cloned successfully", "workspaceID", workspace.ID)
+	return nil
+}
+
+// cloneRepository clones a single repository unless its path already exists
+func (s *GitService) cloneRepository(ctx context.Context, containerID, owner string, repo domain.GitRepository) error {
+	testExit, err := s.dockerSvc.ExecStream(ctx, containerID, ExecOptions{Cmd: []string{"test", "-e", repo.Path}}, &bytes.Buffer{}, &bytes.Buffer{})
+	if err != nil {
+		return fmt.Errorf("failed to check repository path %s: %w", repo.Path, err)
+	}
+	if testExit == 0 {
+		utils.Info("Repository path already exists, skipping clone", "path", repo.Path)
+		return nil
+	}
+
+	cloneCtx, cancel := context.WithTimeout(ctx, gitCloneTimeout)
+	defer cancel()
+	output := &limitedBuffer{limit: maxGitCloneOutput}
+	var exitCode int
+	if repo.Credential != "" {
+		credential, err := s.credential(owner, repo.Credential)
+		if err != nil {
+			return fmt.Errorf("repository %s: %w", repo.URL, err)
+		}
+		exitCode, err = s.cloneWithCredential(cloneCtx, containerID, credential, repo, output)
+		if err != nil {
+			return fmt.Errorf("failed to clone repository %s: %w", repo.URL, err)
+		}
+	} else {
+		opts := ExecOptions{
+			Cmd: append([]string{"git"}, gitCloneArgs(repo, repo.Path)...),
+			Env: []string{"GIT_TERMINAL_PROMPT=0"},
+		}
+		exitCode, err = s.dockerSvc.ExecStream(cloneCtx, containerID, opts, output, output)
+		if err != nil {
+			return fmt.Errorf("failed to clone repository %s: %w", repo.URL, err)
+		}
+	}
+
+	// Record the outcome like an initialization script
+	logFile := fmt.Sprintf("%s/git-%s.log", scriptLogDir, sanitizeScriptName(strings.TrimPrefix(repo.Path, "/")))
+	if err := s.dockerSvc.CopyToContainer(ctx, containerID, logFile, []byte(output.String())); err != nil {
+		utils.Warn("Failed to write clone log", "logFile", logFile, "error", err)
+	}
+	if err := s.dockerSvc.CopyToContainer(ctx, containerID, logFile+".exit", []byte(strconv.Itoa(exitCode)+"\n")); err != nil {
+		utils.Warn("Failed to write clone exit code", "logFile", logFile, "error", err)
+	}
+
+	if exitCode != 0 {
+		utils.Error("Repository clone failed", "url", repo.URL, "exitCode", exitCode, "output", output.String())
+		return fmt.Errorf("repository %s failed to clone with exit code %d. Check logs at %s in container", repo.URL, exitCode, logFile)
+	}
+
+	utils.Info("Repository cloned successfully", "url", repo.URL, "path", repo.Path, "logFile", logFile)
+	return nil
+}
+
+// cloneWithCredential clones a repository in a short-lived helper container
+// and copies the tree into the workspace container. The credential never
+// enters the workspace container, whose image, binaries and processes its
+// editors control. It returns the exit code of git clone.
+func (s *GitService) cloneWithCredential(ctx context.Context, containerID string, credential *domain.GitCredential, repo domain.GitRepository, output io.Writer) (int, error) {
+	env, files, err := credentialEnv(credential, repo.URL)
+	if err != nil {
+		return -1, err
+	}
+
+	dest := path.Join(gitHelperCloneDir, path.Base(repo.Path))
+	helperID, err := s.dockerSvc.CreateHelperContainer(ctx, HelperConfig{
+		Image: s.helperImage,
+		Cmd:   append([]string{"git"}, gitCloneArgs(repo, dest)...),
+		Env:   append([]string{"GIT_TERMINAL_PROMPT=0"}, env...),
+	})
+	if err != nil {
+		return -1, err
+	}
+	defer func() {
+		if err := s.dockerSvc.RemoveContainer(context.Background(), helperID); err != nil {
+			utils.Warn("Failed to remove git helper container", "containerID", utils.ShortID(helperID), "error", err)
+		}
+	}()
+
+	if len(files) > 0 {
+		// Files are written with mode 0600, as ssh requires
+		var archive bytes.Buffer
+		tw := tar.NewWriter(&archive)
+		for name, content := range files {
+			if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: path.Base(name), Mode: 0600, Size: int64(len(content)), ModTime: time.Now()}); err != nil {
+				return -1, fmt.Errorf("failed to write tar header: %w", err)
+			}
+			if _, err := tw.Write([]byte(content)); err != nil {
+				return -1, fmt.Errorf("failed to write tar content: %w", err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			return -1, fmt.Errorf("failed to close tar writer: %w", err)
+		}
+		if err := s.dockerSvc.CopyArchiveToContainer(ctx, helperID, gitHelperSecretDir, &archive); err != nil {
+			return -1, err
+		}
+	}
+
+	exitCode, err := s.dockerSvc.RunContainer(ctx, helperID, output)
+	if err != nil || exitCode != 0 {
+		return exitCode, err
+	}
+
+	tree, err := s.dockerSvc.CopyFromContainer(ctx, helperID, dest)
+	if err != nil {
+		return -1, err
+	}
+	defer tree.Close()
+	parent := path.Dir(repo.Path)
+	if _, err := s.dockerSvc.ExecCommand(ctx, containerID, []string{"mkdir", "-p", parent}); err != nil {
+		return -1, fmt.Errorf("failed to create %s: %w", parent, err)
+	}
+	if err := s.dockerSvc.CopyArchiveToContainer(ctx, containerID, parent, tree); err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// credentialEnv returns the environment that makes a credential available to
+// git in the helper container, and the files it refers to by their path: a
+// token as an HTTP header scoped to the repository host, an SSH key as a file.
+func credentialEnv(credential *domain.GitCredential, repoURL string) ([]string, map[string]string, error) {
+	if err := checkCredentialURL(credential, repoURL); err != nil {
+		return nil, nil, err
+	}
+
+	if credential.Type == domain.GitCredentialToken {
+		u, _ := url.Parse(repoURL)
+		username := credential.Username
+		if username == "" {
+			username = defaultGitTokenUsername
+		}
+		auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + credential.Secret))
+		return []string{
+			"GIT_CONFIG_COUNT=1",
+			fmt.Sprintf("GIT_CONFIG_KEY_0=http.%s://%s/.extraHeader", u.Scheme, u.Host),
+			"GIT_CONFIG_VALUE_0=Authorization: Basic " + auth,
+		}, nil, nil
+	}
+
+	keyFile := path.Join(gitHelperSecretDir, "key")
+	files := map[string]string{keyFile: credential.Secret}
+	sshCommand := "ssh -i " + keyFile + " -o IdentitiesOnly=yes -o BatchMode=yes"
+	if credential.KnownHosts != "" {
+		knownHostsFile := path.Join(gitHelperSecretDir, "known_hosts")
+		files[knownHostsFile] = credential.KnownHosts + "\n"
+		sshCommand += " -o StrictHostKeyChecking=yes -o UserKnownHostsFile=" + knownHostsFile
+	} else {
+		sshCommand += " -o StrictHostKeyChecking=accept-new"
+	}
+	return []string{"GIT_SSH_COMMAND=" + sshCommand}, files, nil
+}
+
+// gitCloneArgs returns the git arguments that clone a repository into dest
+func gitCloneArgs(repo domain.GitRepository, dest string) []string {
+	args := []string{"clone"}
+	if repo.Ref != "" {
+		args = append(args, "--branch", repo.Ref)
+	}
+	return append(args, "--", repo.URL, dest)
+}
+
+// configureIdentity sets the global git user.name and user.email of the
+// container to the identity of the workspace owner, if they set one.
+// Failures are only logged: images without git simply keep no identity.
+func (s *GitService) configureIdentity(ctx context.Context, containerID string, workspace *domain.Workspace) {
+	if workspace.Owner == "" {
+		return
+	}
+	owner, err := s.users.Get(workspace.Owner)
+	if err != nil {
+		utils.Warn("Failed to load workspace owner for git identity", "workspaceID", workspace.ID, "owner", workspace.Owner, "error", err)
+		return
+	}
+
+	settings := [][2]string{{"user.name", owner.GitName}, {"user.email", owner.GitEmail}}
+	for _, setting := range settings {
+		if setting[1] == "" {
+			continue
+		}
+		cmd := []string{"git", "config", "--global", setting[0], setting[1]}
+		var stderr bytes.Buffer
+		exitCode, err := s.dockerSvc.ExecStream(ctx, containerID, ExecOptions{Cmd: cmd}, &bytes.Buffer{}, &stderr)
+		if err != nil || exitCode != 0 {
+			utils.Warn("Failed to configure git identity", "workspaceID", workspace.ID, "setting", setting[0], "exitCode", exitCode, "error", err, "stderr", stderr.String())
+			return
+		}
+	}
+}
+
+// credential returns a credential of a user
+func (s *GitService) credential(userID, credentialID string) (*domain.GitCredential, error) {
+	credential, err := s.credentials.Get(credentialID)
+	if err != nil || credential.UserID != userID {
+		return nil, fmt.Errorf("%w: %s", ErrGitCredentialNotFound, credentialID)
+	}
+	return credential, nil
+}
+
+// checkCredentialURL verifies a credential can be used with a repository URL:
+// tokens over HTTP(S), SSH keys over SSH
+func checkCredentialURL(credential *domain.GitCredential, repoURL string) error {
+	switch credential.Type {
+	case domain.GitCredentialToken:
+		if u, err := url.Parse(repoURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("token credentials require an http(s) repository URL")
+		}
+	case domain.GitCredentialSSHKey:
+		if !strings.HasPrefix(repoURL, "ssh://") && !isSCPLikeGitURL(repoURL) {
+			return fmt.Errorf("SSH key credentials require an ssh:// or user@host:path repository URL")
+		}
+	}
+	return nil
+}
+
+// validateRepositories checks the repositories of a workspace configuration
+func validateRepositories(repositories []domain.GitRepository) error {
+	paths := make(map[string]bool, len(repositories))
+	for _, repo := range repositories {
+		if !isValidGitURL(repo.URL) {
+			return fmt.Errorf("%w: unsupported repository URL %q (use https://, ssh://, git://, file://, user@host:path or an absolute path)", ErrInvalidWorkspaceConfig, repo.URL)
+		}
+		if !strings.HasPrefix(repo.Path, "/") || path.Clean(repo.Path) != repo.Path || repo.Path == "/" || strings.ContainsRune(repo.Path, 0) {
+			return fmt.Errorf("%w: repository %s needs a clean absolute target path other than /", ErrInvalidWorkspaceConfig, repo.URL)
+		}
+		if paths[repo.Path] {
+			return fmt.Errorf("%w: several repositories are cloned into %s", ErrInvalidWorkspaceConfig, repo.Path)
+		}
+		paths[repo.Path] = true
+		if repo.Ref != "" && (strings.HasPrefix(repo.Ref, "-") || strings.ContainsAny(repo.Ref, " \t\r\n\x00~^:?*[\\") || strings.Contains(repo.Ref, "..")) {
+			return fmt.Errorf("%w: invalid ref %q of repository %s", ErrInvalidWorkspaceConfig, repo.Ref, repo.URL)
+		}
+	}
+	return nil
+}
+
+// isValidGitURL reports whether a repository URL uses a transport ViBox
+// allows (no ext:: or other command-running transports)
+func isValidGitURL(repoURL string) bool {
+	if repoURL == "" || strings.HasPrefix(repoURL, "-") || strings.ContainsAny(repoURL, " \t\r\n\x00") {
+		return false
+	}
+	if strings.HasPrefix(repoURL, "/") || isSCPLikeGitURL(repoURL) {
+		return true
+	}
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "https", "http", "ssh", "git":
+		return u.Host != ""
+	case "file":
+		return u.Path != ""
+	}
+	return false
+}
+
+// isSCPLikeGitURL reports whether a repository URL has the scp-like form
+// [user@]host:path that git treats as SSH
+func isSCPLikeGitURL(repoURL string) bool {
+	if strings.Contains(repoURL, "://") || strings.Contains(repoURL, "::") {
+		return false
+	}
+	colon := strings.Index(repoURL, ":")
+	return colon > 0 && !strings.Contains(repoURL[:colon], "/")
+}
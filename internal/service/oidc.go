@@ -0,0 +1,450 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/config"
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+const (
+	// oidcLoginTimeout is how long a started login may take to come back to the callback
+	oidcLoginTimeout = 10 * time.Minute
+	// oidcMetadataTTL is how long the discovery document and signing keys are cached
+	oidcMetadataTTL = time.Hour
+	// oidcKeyRefreshInterval limits JWKS refetches triggered by unknown key IDs
+	oidcKeyRefreshInterval = time.Minute
+	// oidcMaxResponseSize bounds the provider responses that are read
+	oidcMaxResponseSize = 1 << 20
+)
+
+var (
+	// ErrOIDCLoginInvalid is returned when a callback does not belong to a pending login
+	ErrOIDCLoginInvalid = errors.New("invalid or expired single sign-on attempt")
+	// ErrOIDCTokenInvalid is returned when the provider's ID token fails verification
+	ErrOIDCTokenInvalid = errors.New("invalid ID token")
+	// ErrOIDCAccessDenied is returned when the identity is not in an allowed group
+	ErrOIDCAccessDenied = errors.New("not a member of a group allowed to use ViBox")
+	// ErrOIDCProvider is returned when the provider cannot be reached or misbehaves
+	ErrOIDCProvider = errors.New("identity provider error")
+)
+
+// oidcDiscovery holds the parts of the provider's discovery document ViBox uses
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcPendingLogin is a login that was sent to the provider and awaits its callback
+type oidcPendingLogin struct {
+	nonce        string
+	codeVerifier string
+	redirectURL  string
+	expiresAt    time.Time
+}
+
+// OIDCService implements OpenID Connect single sign-on (authorization code flow
+// with PKCE) and maps verified identities to ViBox accounts
+type OIDCService struct {
+	auth          *AuthService
+	issuer        string
+	clientID      string
+	clientSecret  string
+	scopes        []string
+	redirectURL   string
+	usernameClaim string
+	groupsClaim   string
+	adminGroups   []string
+	allowedGroups []string
+	client        *http.Client
+
+	mu            sync.Mutex
+	discovery     *oidcDiscovery
+	discoveredAt  time.Time
+	keys          map[string]crypto.PublicKey // by key ID
+	keysFetchedAt time.Time
+	pending       map[string]*oidcPendingLogin // by state
+}
+
+// NewOIDCService creates a new OIDC service instance
+// The provider is contacted lazily, so ViBox starts even while it is unreachable.
+func NewOIDCService(auth *AuthService, cfg *config.Config) *OIDCService {
+	scopes := []string{"openid"}
+	for _, scope := range cfg.OIDCScopes {
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	utils.Info("Initializing OIDC service", "issuer", cfg.OIDCIssuer, "clientID", cfg.OIDCClientID, "scopes", scopes)
+	return &OIDCService{
+		auth:          auth,
+		issuer:        strings.TrimSuffix(cfg.OIDCIssuer, "/"),
+		clientID:      cfg.OIDCClientID,
+		clientSecret:  cfg.OIDCClientSecret,
+		scopes:        scopes,
+		redirectURL:   cfg.OIDCRedirectURL,
+		usernameClaim: cfg.OIDCUsernameClaim,
+		groupsClaim:   cfg.OIDCGroupsClaim,
+		adminGroups:   cfg.OIDCAdminGroups,
+		allowedGroups: cfg.OIDCAllowedGroups,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		pending:       make(map[string]*oidcPendingLogin),
+	}
+}
+
+// StartLogin begins a login and returns the provider URL to send the browser to,
+// along with the state that the callback will carry.
+// defaultRedirectURL is the callback URL used unless one is configured.
+func (s *OIDCService) StartLogin(ctx context.Context, defaultRedirectURL string) (authURL, state string, err error) {
+	discovery, err := s.getDiscovery(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err = utils.GenerateToken("")
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := utils.GenerateToken("")
+	if err != nil {
+		return "", "", err
+	}
+	codeVerifier, err := utils.GenerateToken("")
+	if err != nil {
+		return "", "", err
+	}
+
+	redirectURL := s.redirectURL
+	if redirectURL == "" {
+		redirectURL = defaultRedirectURL
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	for key, login := range s.pending {
+		if now.After(login.expiresAt) {
+			delete(s.pending, key)
+		}
+	}
+	s.pending[state] = &oidcPendingLogin{
+		nonce:        nonce,
+		codeVerifier: codeVerifier,
+		redirectURL:  redirectURL,
+		expiresAt:    now.Add(oidcLoginTimeout),
+	}
+	s.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {s.clientID},
+		"redirect_uri":          {redirectURL},
+		"scope":                 {strings.Join(s.scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), state, nil
+}
+
+// FinishLogin completes a login from the provider's callback: it redeems the
+// authorization code, verifies the ID token and starts a ViBox session for the
+// identity, creating its account on first login
+func (s *OIDCService) FinishLogin(ctx context.Context, state, code string) (*domain.User, *domain.Session, string, error) {
+	s.mu.Lock()
+	login, ok := s.pending[state]
+	delete(s.pending, state)
+	s.mu.Unlock()
+	if !ok || state == "" || time.Now().After(login.expiresAt) {
+		return nil, nil, "", ErrOIDCLoginInvalid
+	}
+	if code == "" {
+		return nil, nil, "", fmt.Errorf("%w: missing authorization code", ErrOIDCLoginInvalid)
+	}
+
+	rawIDToken, err := s.exchangeCode(ctx, code, login)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	claims, err := s.verifyIDToken(ctx, rawIDToken, login.nonce)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, nil, "", fmt.Errorf("%w: missing subject", ErrOIDCTokenInvalid)
+	}
+
+	groups := stringListClaim(claims[s.groupsClaim])
+	if len(s.allowedGroups) > 0 && !containsAny(groups, s.allowedGroups) && !containsAny(groups, s.adminGroups) {
+		utils.Warn("Single sign-on denied by group membership", "subject", sub)
+		return nil, nil, "", ErrOIDCAccessDenied
+	}
+
+	var role domain.UserRole
+	if len(s.adminGroups) > 0 {
+		role = domain.UserRoleUser
+		if containsAny(groups, s.adminGroups) {
+			role = domain.UserRoleAdmin
+		}
+	}
+
+	return s.auth.LoginWithIdentity(s.issuer+"#"+sub, s.usernameFromClaims(claims), role)
+}
+
+// usernameFromClaims picks the ViBox username for a new account: the configured
+// claim, falling back to the preferred username, the e-mail's local part and the subject
+func (s *OIDCService) usernameFromClaims(claims map[string]any) string {
+	for _, name := range []string{s.usernameClaim, "preferred_username", "email", "sub"} {
+		value, _ := claims[name].(string)
+		if name == "email" {
+			value, _, _ = strings.Cut(value, "@")
+		}
+		if username := normalizeUsername(value); usernamePattern.MatchString(username) {
+			return username
+		}
+	}
+	return ""
+}
+
+// exchangeCode redeems an authorization code at the token endpoint and returns the raw ID token
+func (s *OIDCService) exchangeCode(ctx context.Context, code string, login *oidcPendingLogin) (string, error) {
+	discovery, err := s.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {login.redirectURL},
+		"code_verifier": {login.codeVerifier},
+	}
+	if s.clientSecret == "" {
+		form.Set("client_id", s.clientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrOIDCProvider, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(s.clientID), url.QueryEscape(s.clientSecret))
+	}
+
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := s.doJSON(req, &tokens)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK {
+		if tokens.Error == "invalid_grant" {
+			return "", fmt.Errorf("%w: %s", ErrOIDCLoginInvalid, tokens.ErrorDescription)
+		}
+		return "", fmt.Errorf("%w: token endpoint returned %d %s %s", ErrOIDCProvider, status, tokens.Error, tokens.ErrorDescription)
+	}
+	if tokens.IDToken == "" {
+		return "", fmt.Errorf("%w: token response has no id_token", ErrOIDCProvider)
+	}
+	return tokens.IDToken, nil
+}
+
+// getDiscovery returns the provider's discovery document, fetching it when the cache is stale
+func (s *OIDCService) getDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	s.mu.Lock()
+	cached, fetchedAt := s.discovery, s.discoveredAt
+	s.mu.Unlock()
+	if cached != nil && time.Since(fetchedAt) < oidcMetadataTTL {
+		return cached, nil
+	}
+
+	discovery, err := s.fetchDiscovery(ctx)
+	if err != nil {
+		if cached != nil {
+			utils.Warn("Failed to refresh OIDC discovery, using cached copy", "error", err)
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.discovery, s.discoveredAt = discovery, time.Now()
+	s.mu.Unlock()
+	return discovery, nil
+}
+
+// fetchDiscovery downloads and validates <issuer>/.well-known/openid-configuration
+func (s *OIDCService) fetchDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCProvider, err)
+	}
+
+	var discovery oidcDiscovery
+	status, err := s.doJSON(req, &discovery)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: discovery returned %d", ErrOIDCProvider, status)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != s.issuer {
+		return nil, fmt.Errorf("%w: discovery issuer %q does not match %q", ErrOIDCProvider, discovery.Issuer, s.issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: discovery document is missing endpoints", ErrOIDCProvider)
+	}
+
+	utils.Debug("Fetched OIDC discovery document", "issuer", discovery.Issuer)
+	return &discovery, nil
+}
+
+// signingKey returns the provider key with the given ID (any key when kid is empty),
+// refetching the key set when it is stale or the key is unknown (a rotation)
+func (s *OIDCService) signingKey(ctx context.Context, kid string) ([]crypto.PublicKey, error) {
+	s.mu.Lock()
+	keys, fetchedAt := s.keys, s.keysFetchedAt
+	s.mu.Unlock()
+
+	if matches := matchKeys(keys, kid); len(matches) > 0 && time.Since(fetchedAt) < oidcMetadataTTL {
+		return matches, nil
+	}
+	if keys != nil && time.Since(fetchedAt) < oidcKeyRefreshInterval {
+		return nil, fmt.Errorf("%w: unknown signing key %q", ErrOIDCTokenInvalid, kid)
+	}
+
+	fetched, err := s.fetchKeys(ctx)
+	if err != nil {
+		if matches := matchKeys(keys, kid); len(matches) > 0 {
+			utils.Warn("Failed to refresh OIDC signing keys, using cached keys", "error", err)
+			return matches, nil
+		}
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.keys, s.keysFetchedAt = fetched, time.Now()
+	s.mu.Unlock()
+
+	if matches := matchKeys(fetched, kid); len(matches) > 0 {
+		return matches, nil
+	}
+	return nil, fmt.Errorf("%w: unknown signing key %q", ErrOIDCTokenInvalid, kid)
+}
+
+// fetchKeys downloads the provider's JSON Web Key Set
+func (s *OIDCService) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	discovery, err := s.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery.JWKSURI, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCProvider, err)
+	}
+
+	var set jsonWebKeySet
+	status, err := s.doJSON(req, &set)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: JWKS returned %d", ErrOIDCProvider, status)
+	}
+
+	keys := set.publicKeys()
+	utils.Debug("Fetched OIDC signing keys", "count", len(keys))
+	return keys, nil
+}
+
+// doJSON performs a request against the provider and decodes its JSON response
+func (s *OIDCService) doJSON(req *http.Request, v any) (int, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrOIDCProvider, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, oidcMaxResponseSize))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrOIDCProvider, err)
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("%w: invalid response from %s: %v", ErrOIDCProvider, req.URL.Path, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// matchKeys returns the key with the given ID, or all keys when kid is empty
+func matchKeys(keys map[string]crypto.PublicKey, kid string) []crypto.PublicKey {
+	if kid != "" {
+		if key, ok := keys[kid]; ok {
+			return []crypto.PublicKey{key}
+		}
+		return nil
+	}
+	matches := make([]crypto.PublicKey, 0, len(keys))
+	for _, key := range keys {
+		matches = append(matches, key)
+	}
+	return matches
+}
+
+// stringListClaim reads a claim holding a list of strings (or a single string)
+func stringListClaim(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// containsAny reports whether any of values is in list
+func containsAny(list, values []string) bool {
+	for _, value := range values {
+		if slices.Contains(list, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/config"
+	"github.com/1PercentSync/vibox/internal/domain"
+)
+
+// mockOIDCProvider is a minimal OpenID provider: discovery, JWKS and a token
+// endpoint that issues RS256 ID tokens for codes registered by authorize
+type mockOIDCProvider struct {
+	server *httptest.Server
+	t      *testing.T
+
+	mu         sync.Mutex
+	key        *rsa.PrivateKey
+	kid        string
+	signingKey *rsa.PrivateKey // Signs tokens; differs from key to simulate forgery
+	codes      map[string]mockAuthorization
+	jwksHits   int
+}
+
+// mockAuthorization is what the provider remembers about an issued code
+type mockAuthorization struct {
+	nonce     string
+	challenge string
+	claims    map[string]any
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+	p := &mockOIDCProvider{t: t, codes: make(map[string]mockAuthorization)}
+	p.rotateKey("key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.jwksHits++
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": p.kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if id, secret, ok := r.BasicAuth(); !ok || id != "vibox" || secret != "client-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+
+		p.mu.Lock()
+		auth, ok := p.codes[r.Form.Get("code")]
+		delete(p.codes, r.Form.Get("code"))
+		p.mu.Unlock()
+		verifier := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != auth.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "bad code or verifier"})
+			return
+		}
+
+		claims := map[string]any{
+			"iss":   p.server.URL,
+			"aud":   "vibox",
+			"exp":   time.Now().Add(5 * time.Minute).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": auth.nonce,
+		}
+		for k, v := range auth.claims {
+			claims[k] = v
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": p.sign(claims)})
+	})
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// rotateKey replaces the provider's signing key
+func (p *mockOIDCProvider) rotateKey(kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		p.t.Fatalf("GenerateKey failed: %v", err)
+	}
+	p.mu.Lock()
+	p.key, p.signingKey, p.kid = key, key, kid
+	p.mu.Unlock()
+}
+
+// sign encodes and signs claims as an RS256 JWT
+func (p *mockOIDCProvider) sign(claims map[string]any) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": p.kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.signingKey, crypto.SHA256, digest[:])
+	if err != nil {
+		p.t.Fatalf("SignPKCS1v15 failed: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// authorize plays the browser and provider login: it checks the authorization
+// URL and returns the state and a code that will yield the given claims
+func (p *mockOIDCProvider) authorize(authURL string, claims map[string]any) (state, code string) {
+	p.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil || u.Host != p.server.Listener.Addr().String() || u.Path != "/authorize" {
+		p.t.Fatalf("Unexpected authorization URL %q", authURL)
+	}
+	q := u.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != "vibox" || q.Get("code_challenge_method") != "S256" {
+		p.t.Fatalf("Unexpected authorization parameters %v", q)
+	}
+
+	code = "code-" + q.Get("state")[:8]
+	p.mu.Lock()
+	p.codes[code] = mockAuthorization{nonce: q.Get("nonce"), challenge: q.Get("code_challenge"), claims: claims}
+	p.mu.Unlock()
+	return q.Get("state"), code
+}
+
+func newTestOIDCService(t *testing.T, provider *mockOIDCProvider, allowedGroups ...string) (*OIDCService, *AuthService) {
+	t.Helper()
+	auth := newTestAuthService(t)
+	svc := NewOIDCService(auth, &config.Config{
+		OIDCIssuer:        provider.server.URL,
+		OIDCClientID:      "vibox",
+		OIDCClientSecret:  "client-secret",
+		OIDCScopes:        []string{"profile", "email"},
+		OIDCUsernameClaim: "preferred_username",
+		OIDCGroupsClaim:   "groups",
+		OIDCAdminGroups:   []string{"vibox-admins"},
+		OIDCAllowedGroups: allowedGroups,
+	})
+	return svc, auth
+}
+
+func TestOIDCService_LoginFlow(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	svc, auth := newTestOIDCService(t, provider)
+	ctx := context.Background()
+
+	login := func(claims map[string]any) (*domain.User, string, error) {
+		t.Helper()
+		authURL, _, err := svc.StartLogin(ctx, "http://vibox.test/api/auth/oidc/callback")
+		if err != nil {
+			t.Fatalf("StartLogin failed: %v", err)
+		}
+		state, code := provider.authorize(authURL, claims)
+		user, _, token, err := svc.FinishLogin(ctx, state, code)
+		return user, token, err
+	}
+
+	// First login creates the account, with the role from the groups claim
+	user, token, err := login(map[string]any{"sub": "1234", "preferred_username": "Carol", "groups": []string{"vibox-admins"}})
+	if err != nil {
+		t.Fatalf("FinishLogin failed: %v", err)
+	}
+	if user.Username != "carol" || !user.IsAdmin() || user.OIDCSubject != provider.server.URL+"#1234" {
+		t.Errorf("Unexpected account %+v", user)
+	}
+	if authed, err := auth.Authenticate(token); err != nil || authed.ID != user.ID {
+		t.Errorf("Expected session token to authenticate, got %v", err)
+	}
+	if _, _, _, err := auth.Login("carol", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected password login to fail for single sign-on accounts, got %v", err)
+	}
+
+	// Later logins reuse the account (even if the username claim changed) and follow group changes
+	again, _, err := login(map[string]any{"sub": "1234", "preferred_username": "carol2"})
+	if err != nil {
+		t.Fatalf("Second FinishLogin failed: %v", err)
+	}
+	if again.ID != user.ID || again.Username != "carol" || again.IsAdmin() {
+		t.Errorf("Expected the same account demoted to user, got %+v", again)
+	}
+
+	// A different identity may not take over an existing username
+	if _, _, err := login(map[string]any{"sub": "5678", "preferred_username": "admin"}); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("Expected ErrUsernameTaken, got %v", err)
+	}
+
+	// Without a usable username claim the e-mail's local part is used
+	dave, _, err := login(map[string]any{"sub": "9999", "email": "Dave@example.com"})
+	if err != nil || dave.Username != "dave" {
+		t.Errorf("Expected account named after the e-mail, got %+v, %v", dave, err)
+	}
+}
+
+func TestOIDCService_RejectsInvalidLogins(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	svc, _ := newTestOIDCService(t, provider, "vibox-users")
+	ctx := context.Background()
+
+	authURL, _, err := svc.StartLogin(ctx, "http://vibox.test/api/auth/oidc/callback")
+	if err != nil {
+		t.Fatalf("StartLogin failed: %v", err)
+	}
+	state, code := provider.authorize(authURL, map[string]any{"sub": "1", "preferred_username": "eve", "groups": []string{"vibox-users"}})
+
+	// Unknown state
+	if _, _, _, err := svc.FinishLogin(ctx, "forged-state", code); !errors.Is(err, ErrOIDCLoginInvalid) {
+		t.Errorf("Expected ErrOIDCLoginInvalid for unknown state, got %v", err)
+	}
+
+	// Forged signature
+	forger, _ := rsa.GenerateKey(rand.Reader, 2048)
+	provider.mu.Lock()
+	provider.signingKey = forger
+	provider.mu.Unlock()
+	if _, _, _, err := svc.FinishLogin(ctx, state, code); !errors.Is(err, ErrOIDCTokenInvalid) {
+		t.Errorf("Expected ErrOIDCTokenInvalid for a forged token, got %v", err)
+	}
+	provider.mu.Lock()
+	provider.signingKey = provider.key
+	provider.mu.Unlock()
+
+	// The state is single-use
+	if _, _, _, err := svc.FinishLogin(ctx, state, code); !errors.Is(err, ErrOIDCLoginInvalid) {
+		t.Errorf("Expected replayed state to be rejected, got %v", err)
+	}
+
+	// Group restrictions
+	authURL, _, _ = svc.StartLogin(ctx, "http://vibox.test/api/auth/oidc/callback")
+	state, code = provider.authorize(authURL, map[string]any{"sub": "2", "preferred_username": "mallory", "groups": []string{"others"}})
+	if _, _, _, err := svc.FinishLogin(ctx, state, code); !errors.Is(err, ErrOIDCAccessDenied) {
+		t.Errorf("Expected ErrOIDCAccessDenied, got %v", err)
+	}
+}
+
+func TestOIDCService_KeyRotation(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	svc, _ := newTestOIDCService(t, provider)
+	ctx := context.Background()
+
+	login := func(sub string) error {
+		authURL, _, err := svc.StartLogin(ctx, "http://vibox.test/api/auth/oidc/callback")
+		if err != nil {
+			return err
+		}
+		state, code := provider.authorize(authURL, map[string]any{"sub": sub, "preferred_username": "user" + sub})
+		_, _, _, err = svc.FinishLogin(ctx, state, code)
+		return err
+	}
+
+	if err := login("1"); err != nil {
+		t.Fatalf("First login failed: %v", err)
+	}
+	if err := login("1"); err != nil {
+		t.Fatalf("Second login failed: %v", err)
+	}
+	if provider.jwksHits != 1 {
+		t.Errorf("Expected signing keys to be cached, fetched %d times", provider.jwksHits)
+	}
+
+	// A new key ID triggers a refetch once the refresh interval has passed
+	provider.rotateKey("key-2")
+	svc.mu.Lock()
+	svc.keysFetchedAt = time.Now().Add(-2 * oidcKeyRefreshInterval)
+	svc.mu.Unlock()
+	if err := login("1"); err != nil {
+		t.Fatalf("Login after key rotation failed: %v", err)
+	}
+	if provider.jwksHits != 2 {
+		t.Errorf("Expected one refetch after rotation, fetched %d times", provider.jwksHits)
+	}
+}
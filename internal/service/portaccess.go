@@ -0,0 +1,239 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxRateLimitBuckets bounds the number of tracked clients before idle ones are pruned
+const maxRateLimitBuckets = 10000
+
+// ErrInvalidPortSettings is returned when port settings fail validation
+var ErrInvalidPortSettings = errors.New("invalid port settings")
+
+// buildPortSettings validates a port settings request and converts it to the
+// stored form, hashing the basic-auth password. current is the existing
+// basic-auth configuration, kept when the password is omitted.
+func buildPortSettings(req *PortSettingsRequest, current *domain.PortBasicAuth) (*domain.PortSettings, error) {
+	settings := &domain.PortSettings{
+		Visibility: req.Visibility,
+		CORS:       req.CORS,
+		RateLimit:  req.RateLimit,
+	}
+
+	switch req.Visibility {
+	case "", domain.PortVisibilityPrivate, domain.PortVisibilityTeam, domain.PortVisibilityPublic:
+	default:
+		return nil, fmt.Errorf("unknown visibility %q (expected private, team or public)", req.Visibility)
+	}
+
+	for _, method := range req.AllowedMethods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if method == "" || strings.ContainsAny(method, " ,") {
+			return nil, fmt.Errorf("invalid HTTP method %q", method)
+		}
+		settings.AllowedMethods = append(settings.AllowedMethods, method)
+	}
+
+	if req.BasicAuth != nil {
+		if req.BasicAuth.Username == "" || strings.Contains(req.BasicAuth.Username, ":") {
+			return nil, fmt.Errorf("basic auth username must be non-empty and must not contain ':'")
+		}
+		auth := &domain.PortBasicAuth{Username: req.BasicAuth.Username}
+		switch {
+		case req.BasicAuth.Password != "":
+			hash, err := bcrypt.GenerateFromPassword([]byte(req.BasicAuth.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return nil, fmt.Errorf("failed to hash password: %w", err)
+			}
+			auth.PasswordHash = string(hash)
+		case current != nil && current.Username == req.BasicAuth.Username:
+			auth.PasswordHash = current.PasswordHash
+		default:
+			return nil, fmt.Errorf("basic auth password is required")
+		}
+		settings.BasicAuth = auth
+	}
+
+	if req.CORS != nil && len(req.CORS.AllowedOrigins) == 0 {
+		return nil, fmt.Errorf("cors.allowed_origins cannot be empty")
+	}
+	if req.CORS != nil && req.CORS.MaxAge < 0 {
+		return nil, fmt.Errorf("cors.max_age cannot be negative")
+	}
+
+	if req.RateLimit != nil {
+		if req.RateLimit.RequestsPerMinute <= 0 {
+			return nil, fmt.Errorf("rate_limit.requests_per_minute must be positive")
+		}
+		if req.RateLimit.Burst < 0 {
+			return nil, fmt.Errorf("rate_limit.burst cannot be negative")
+		}
+	}
+
+	return settings, nil
+}
+
+// rateBucket is a token bucket for one client of one port
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// PortAccessService enforces the runtime parts of port policies: rate limits and
+// basic-auth checks (successful bcrypt checks are cached so that every proxied
+// request does not pay for a hash)
+type PortAccessService struct {
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+
+	verified sync.Map // sha256(hash|username|password) -> struct{}
+}
+
+// NewPortAccessService creates a new port access service instance
+func NewPortAccessService() *PortAccessService {
+	utils.Info("Initializing port access service")
+	return &PortAccessService{
+		buckets: make(map[string]*rateBucket),
+	}
+}
+
+// Allow takes a token from the bucket of a client of a workspace port. When the
+// request is not allowed it returns how long the client should wait.
+func (s *PortAccessService) Allow(workspaceID string, port int, clientIP string, limit *domain.PortRateLimit) (bool, time.Duration) {
+	if limit == nil || limit.RequestsPerMinute <= 0 {
+		return true, 0
+	}
+
+	rate := float64(limit.RequestsPerMinute) / 60 // tokens per second
+	burst := float64(limit.Burst)
+	if burst <= 0 {
+		burst = float64(limit.RequestsPerMinute)
+	}
+
+	key := fmt.Sprintf("%s:%d:%s", workspaceID, port, clientIP)
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		if len(s.buckets) >= maxRateLimitBuckets {
+			s.pruneBuckets(now)
+		}
+		bucket = &rateBucket{tokens: burst, last: now}
+		s.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+// pruneBuckets drops buckets that have not been used for a while (caller holds mu)
+func (s *PortAccessService) pruneBuckets(now time.Time) {
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.last) > 10*time.Minute {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// CheckBasicAuth reports whether the credentials match the port's basic-auth configuration
+func (s *PortAccessService) CheckBasicAuth(auth *domain.PortBasicAuth, username, password string) bool {
+	if auth == nil {
+		return true
+	}
+	if username != auth.Username {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(auth.PasswordHash + "|" + username + "|" + password))
+	key := hex.EncodeToString(sum[:])
+	if _, ok := s.verified.Load(key); ok {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(auth.PasswordHash), []byte(password)) != nil {
+		return false
+	}
+	s.verified.Store(key, struct{}{})
+	return true
+}
+
+// CORSHeaders returns the CORS response headers for a request from origin, or
+// nil if the policy does not allow the origin
+func CORSHeaders(cors *domain.PortCORS, origin string) http.Header {
+	if cors == nil || origin == "" || !cors.AllowsOrigin(origin) {
+		return nil
+	}
+
+	headers := http.Header{}
+	if cors.AllowCredentials {
+		// Credentials cannot be combined with a wildcard origin
+		headers.Set("Access-Control-Allow-Origin", origin)
+		headers.Set("Access-Control-Allow-Credentials", "true")
+	} else if len(cors.AllowedOrigins) == 1 && cors.AllowedOrigins[0] == "*" {
+		headers.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		headers.Set("Access-Control-Allow-Origin", origin)
+	}
+	if headers.Get("Access-Control-Allow-Origin") != "*" {
+		headers.Set("Vary", "Origin")
+	}
+	return headers
+}
+
+// CORSPreflightHeaders returns the response headers for a CORS preflight request,
+// or nil if the origin or requested method is not allowed. Without configured
+// allowed headers, the requested headers are allowed.
+func CORSPreflightHeaders(cors *domain.PortCORS, origin, requestMethod, requestHeaders string) http.Header {
+	headers := CORSHeaders(cors, origin)
+	if headers == nil {
+		return nil
+	}
+
+	methods := cors.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	}
+	allowed := false
+	for _, method := range methods {
+		if strings.EqualFold(method, requestMethod) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil
+	}
+
+	headers.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if len(cors.AllowedHeaders) > 0 {
+		headers.Set("Access-Control-Allow-Headers", strings.Join(cors.AllowedHeaders, ", "))
+	} else if requestHeaders != "" {
+		headers.Set("Access-Control-Allow-Headers", requestHeaders)
+	}
+	if cors.MaxAge > 0 {
+		headers.Set("Access-Control-Max-Age", fmt.Sprint(cors.MaxAge))
+	}
+	return headers
+}
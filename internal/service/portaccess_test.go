@@ -0,0 +1,75 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+)
+
+func TestBuildPortSettings(t *testing.T) {
+	settings, err := buildPortSettings(&PortSettingsRequest{
+		Visibility:     domain.PortVisibilityTeam,
+		AllowedMethods: []string{"get", " post "},
+		BasicAuth:      &BasicAuthRequest{Username: "admin", Password: "pw"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("buildPortSettings failed: %v", err)
+	}
+	if len(settings.AllowedMethods) != 2 || settings.AllowedMethods[0] != "GET" || settings.AllowedMethods[1] != "POST" {
+		t.Errorf("Expected normalized methods, got %v", settings.AllowedMethods)
+	}
+	if settings.BasicAuth.PasswordHash == "" || settings.BasicAuth.PasswordHash == "pw" {
+		t.Error("Expected password to be hashed")
+	}
+
+	// Omitting the password keeps the current one for the same user
+	kept, err := buildPortSettings(&PortSettingsRequest{BasicAuth: &BasicAuthRequest{Username: "admin"}}, settings.BasicAuth)
+	if err != nil || kept.BasicAuth.PasswordHash != settings.BasicAuth.PasswordHash {
+		t.Errorf("Expected password hash to be kept, got %v", err)
+	}
+	if _, err := buildPortSettings(&PortSettingsRequest{BasicAuth: &BasicAuthRequest{Username: "other"}}, settings.BasicAuth); err == nil {
+		t.Error("Expected error when changing the username without a password")
+	}
+
+	invalid := []*PortSettingsRequest{
+		{Visibility: "everyone"},
+		{AllowedMethods: []string{"GET, POST"}},
+		{CORS: &domain.PortCORS{}},
+		{RateLimit: &domain.PortRateLimit{RequestsPerMinute: 0}},
+	}
+	for _, req := range invalid {
+		if _, err := buildPortSettings(req, nil); err == nil {
+			t.Errorf("Expected error for %+v", req)
+		}
+	}
+}
+
+func TestPortAccessService_RateLimit(t *testing.T) {
+	svc := NewPortAccessService()
+	limit := &domain.PortRateLimit{RequestsPerMinute: 600, Burst: 2} // 10 per second
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := svc.Allow("ws-1", 8080, "192.0.2.1", limit); !ok {
+			t.Fatalf("Expected request %d within the burst to be allowed", i+1)
+		}
+	}
+	ok, wait := svc.Allow("ws-1", 8080, "192.0.2.1", limit)
+	if ok || wait <= 0 || wait > 100*time.Millisecond {
+		t.Errorf("Expected request to be limited with a short wait, got %v %v", ok, wait)
+	}
+
+	// Other clients and ports have their own buckets
+	if ok, _ := svc.Allow("ws-1", 8080, "192.0.2.2", limit); !ok {
+		t.Error("Expected another client to be allowed")
+	}
+	if ok, _ := svc.Allow("ws-1", 3000, "192.0.2.1", limit); !ok {
+		t.Error("Expected another port to be allowed")
+	}
+
+	// Tokens refill over time
+	time.Sleep(wait + 10*time.Millisecond)
+	if ok, _ := svc.Allow("ws-1", 8080, "192.0.2.1", limit); !ok {
+		t.Error("Expected request to be allowed after the bucket refilled")
+	}
+}
@@ -0,0 +1,355 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/config"
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+// portScanTimeout bounds a single scan of one container
+const portScanTimeout = 10 * time.Second
+
+// portScanScript prints the TCP socket tables, the socket inodes held by each
+// process and the process names, separated by marker lines.
+// /dev/null is listed alongside the fd directories so that ls always prints the
+// "/proc/<pid>/fd:" headers, even when there is only one process.
+const portScanScript = `cat /proc/net/tcp /proc/net/tcp6 2>/dev/null
+echo '#vibox-fds'
+ls -l /dev/null /proc/[0-9]*/fd 2>/dev/null
+echo '#vibox-comm'
+for p in /proc/[0-9]*; do read -r c 2>/dev/null < "$p/comm" && echo "${p#/proc/} $c"; done
+true`
+
+var (
+	// fdDirPattern matches the "/proc/<pid>/fd:" headers printed by ls
+	fdDirPattern = regexp.MustCompile(`^/proc/(\d+)/fd:$`)
+	// socketLinkPattern matches a socket fd symlink target in ls -l output
+	socketLinkPattern = regexp.MustCompile(`socket:\[(\d+)\]$`)
+)
+
+// ListeningPort is a TCP port on which a process in a workspace container is listening
+type ListeningPort struct {
+	Port    int    `json:"port"`
+	Address string `json:"address"`           // Bind address, e.g. "0.0.0.0", "::" or "127.0.0.1"
+	Process string `json:"process,omitempty"` // Owning process name, when it could be determined
+	PID     int    `json:"pid,omitempty"`
+}
+
+// PortScanResult holds the last scan of a workspace
+type PortScanResult struct {
+	WorkspaceID string          `json:"workspace_id"`
+	Ports       []ListeningPort `json:"ports"`
+	ScannedAt   time.Time       `json:"scanned_at"`
+}
+
+// PortScanner periodically discovers listening ports inside running workspaces
+// and publishes port.opened / port.closed events when they change
+type PortScanner struct {
+	dockerSvc    *DockerService
+	workspaceSvc *WorkspaceService
+	events       *EventBus
+	interval     time.Duration
+
+	mu      sync.RWMutex
+	results map[string]*PortScanResult // workspaceID -> last scan
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewPortScanner creates a new port scanner (call Start to begin background scanning)
+func NewPortScanner(dockerSvc *DockerService, workspaceSvc *WorkspaceService, events *EventBus, cfg *config.Config) *PortScanner {
+	utils.Info("Initializing port scanner", "interval", cfg.PortScanInterval)
+	return &PortScanner{
+		dockerSvc:    dockerSvc,
+		workspaceSvc: workspaceSvc,
+		events:       events,
+		interval:     time.Duration(cfg.PortScanInterval) * time.Second,
+		results:      make(map[string]*PortScanResult),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Start begins scanning all running workspaces in the background.
+// It does nothing when the scan interval is 0.
+func (s *PortScanner) Start() {
+	if s.interval <= 0 {
+		utils.Info("Background port scanning disabled")
+		close(s.done)
+		return
+	}
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.scanAll()
+			}
+		}
+	}()
+}
+
+// Stop stops background scanning and waits for an in-flight scan to finish
+func (s *PortScanner) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+}
+
+// LastScan returns the most recent scan of a workspace, if any
+func (s *PortScanner) LastScan(workspaceID string) (*PortScanResult, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result, ok := s.results[workspaceID]
+	if !ok {
+		return nil, false
+	}
+	copied := *result
+	copied.Ports = append([]ListeningPort(nil), result.Ports...)
+	return &copied, true
+}
+
+// ScanWorkspace scans a workspace container now, updating the cached result and
+// publishing events for ports that appeared or disappeared since the last scan
+func (s *PortScanner) ScanWorkspace(ctx context.Context, workspace *domain.Workspace) (*PortScanResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, portScanTimeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	exitCode, err := s.dockerSvc.ExecStream(ctx, workspace.ContainerID, ExecOptions{
+		Cmd: []string{"/bin/sh", "-c", portScanScript},
+	}, &stdout, io.Discard)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan ports: %w", err)
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("failed to scan ports: scan exited with code %d", exitCode)
+	}
+
+	result := &PortScanResult{
+		WorkspaceID: workspace.ID,
+		Ports:       parsePortScan(stdout.String()),
+		ScannedAt:   time.Now(),
+	}
+	s.update(workspace.ID, result)
+
+	copied := *result
+	copied.Ports = append([]ListeningPort(nil), result.Ports...)
+	return &copied, nil
+}
+
+// scanAll scans every running workspace and forgets workspaces that are gone
+func (s *PortScanner) scanAll() {
+	workspaces, err := s.workspaceSvc.ListWorkspaces()
+	if err != nil {
+		utils.Warn("Port scan skipped: failed to list workspaces", "error", err)
+		return
+	}
+
+	active := make(map[string]bool, len(workspaces))
+	for _, workspace := range workspaces {
+		// Containers of workspaces with failed scripts are still running
+		if workspace.ContainerID == "" || (workspace.Status != domain.StatusRunning && workspace.Status != domain.StatusError) {
+			continue
+		}
+		active[workspace.ID] = true
+
+		if _, err := s.ScanWorkspace(context.Background(), workspace); err != nil {
+			utils.Debug("Port scan failed", "workspaceID", workspace.ID, "error", err)
+		}
+	}
+
+	s.mu.RLock()
+	var stale []string
+	for workspaceID := range s.results {
+		if !active[workspaceID] {
+			stale = append(stale, workspaceID)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, workspaceID := range stale {
+		s.forget(workspaceID)
+	}
+}
+
+// update stores a scan result and publishes the differences to the previous one
+func (s *PortScanner) update(workspaceID string, result *PortScanResult) {
+	s.mu.Lock()
+	previous := s.results[workspaceID]
+	s.results[workspaceID] = result
+	s.mu.Unlock()
+
+	var before []ListeningPort
+	if previous != nil {
+		before = previous.Ports
+	}
+	s.publishChanges(workspaceID, before, result.Ports)
+}
+
+// forget drops the cached result of a workspace, reporting its ports as closed
+func (s *PortScanner) forget(workspaceID string) {
+	s.mu.Lock()
+	previous, ok := s.results[workspaceID]
+	delete(s.results, workspaceID)
+	s.mu.Unlock()
+
+	if ok {
+		s.publishChanges(workspaceID, previous.Ports, nil)
+	}
+}
+
+// publishChanges publishes port.opened / port.closed events for the difference between two scans
+func (s *PortScanner) publishChanges(workspaceID string, before, after []ListeningPort) {
+	if s.events == nil {
+		return
+	}
+
+	old := make(map[int]bool, len(before))
+	for _, port := range before {
+		old[port.Port] = true
+	}
+	current := make(map[int]bool, len(after))
+	for _, port := range after {
+		current[port.Port] = true
+		if !old[port.Port] {
+			utils.Info("Port opened in workspace", "workspaceID", workspaceID, "port", port.Port, "process", port.Process)
+			s.events.Publish(Event{Type: EventPortOpened, WorkspaceID: workspaceID, Data: port})
+		}
+	}
+	for _, port := range before {
+		if !current[port.Port] {
+			utils.Info("Port closed in workspace", "workspaceID", workspaceID, "port", port.Port)
+			s.events.Publish(Event{Type: EventPortClosed, WorkspaceID: workspaceID, Data: port})
+		}
+	}
+}
+
+// parsePortScan parses the output of portScanScript into listening ports sorted by
+// port number. A port bound on several addresses (e.g. IPv4 and IPv6) is reported once.
+func parsePortScan(output string) []ListeningPort {
+	inodes := make(map[string]ListeningPort) // socket inode -> port
+	socketOwners := make(map[string]int)     // socket inode -> pid
+	processNames := make(map[int]string)     // pid -> comm
+
+	section := "tcp"
+	currentPID := 0
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "#vibox-fds":
+			section = "fds"
+			continue
+		case "#vibox-comm":
+			section = "comm"
+			continue
+		}
+
+		switch section {
+		case "tcp":
+			if port, inode, ok := parseProcNetTCPLine(line); ok {
+				inodes[inode] = port
+			}
+		case "fds":
+			if match := fdDirPattern.FindStringSubmatch(line); match != nil {
+				currentPID, _ = strconv.Atoi(match[1])
+			} else if match := socketLinkPattern.FindStringSubmatch(line); match != nil && currentPID != 0 {
+				socketOwners[match[1]] = currentPID
+			}
+		case "comm":
+			pidStr, name, found := strings.Cut(line, " ")
+			if pid, err := strconv.Atoi(pidStr); err == nil && found {
+				processNames[pid] = name
+			}
+		}
+	}
+
+	byPort := make(map[int]ListeningPort)
+	for inode, port := range inodes {
+		if pid, ok := socketOwners[inode]; ok {
+			port.PID = pid
+			port.Process = processNames[pid]
+		}
+		existing, seen := byPort[port.Port]
+		if !seen || (existing.Process == "" && port.Process != "") {
+			byPort[port.Port] = port
+		}
+	}
+
+	ports := make([]ListeningPort, 0, len(byPort))
+	for _, port := range byPort {
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		return ports[i].Port < ports[j].Port
+	})
+	return ports
+}
+
+// parseProcNetTCPLine parses a line of /proc/net/tcp or /proc/net/tcp6, returning the
+// port and socket inode if the socket is in the LISTEN state
+func parseProcNetTCPLine(line string) (ListeningPort, string, bool) {
+	fields := strings.Fields(line)
+	// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+	if len(fields) < 10 || fields[3] != "0A" {
+		return ListeningPort{}, "", false
+	}
+
+	addrHex, portHex, found := strings.Cut(fields[1], ":")
+	if !found {
+		return ListeningPort{}, "", false
+	}
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil || port == 0 {
+		return ListeningPort{}, "", false
+	}
+	address, ok := parseProcNetAddress(addrHex)
+	if !ok {
+		return ListeningPort{}, "", false
+	}
+
+	return ListeningPort{Port: int(port), Address: address}, fields[9], true
+}
+
+// parseProcNetAddress decodes an address from /proc/net/tcp{,6}, which is stored as
+// native-endian (little-endian on common platforms) 32-bit words
+func parseProcNetAddress(addrHex string) (string, bool) {
+	raw, err := hex.DecodeString(addrHex)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return "", false
+	}
+
+	ip := make(net.IP, len(raw))
+	for word := 0; word < len(raw); word += 4 {
+		for i := 0; i < 4; i++ {
+			ip[word+i] = raw[word+3-i]
+		}
+	}
+	return ip.String(), true
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const samplePortScanOutput = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 1111 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1538 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 2222 1 0000000000000000 100 0 0 10 0
+   2: 0200A8C0:1F90 0300A8C0:D431 01 00000000:00000000 00:00000000 00000000  1000        0 3333 1 0000000000000000 20 4 30 10 -1
+  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:1F90 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 4444 1 0000000000000000 100 0 0 10 0
+   1: 00000000000000000000000001000000:0CEA 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 5555 1 0000000000000000 100 0 0 10 0
+#vibox-fds
+crw-rw-rw- 1 root root 1, 3 Oct 18 10:00 /dev/null
+
+/proc/1/fd:
+total 0
+lrwx------ 1 root root 64 Oct 18 10:00 0 -> /dev/null
+
+/proc/42/fd:
+total 0
+lrwx------ 1 dev dev 64 Oct 18 10:00 3 -> socket:[1111]
+lrwx------ 1 dev dev 64 Oct 18 10:00 4 -> socket:[4444]
+
+/proc/77/fd:
+lrwx------ 1 postgres postgres 64 Oct 18 10:00 5 -> socket:[2222]
+#vibox-comm
+1 sleep
+42 node server
+77 postgres
+`
+
+func TestParsePortScan(t *testing.T) {
+	ports := parsePortScan(samplePortScanOutput)
+
+	expected := []ListeningPort{
+		{Port: 3306, Address: "::1"},
+		{Port: 5432, Address: "127.0.0.1", Process: "postgres", PID: 77},
+		{Port: 8080, Address: ports[2].Address, Process: "node server", PID: 42},
+	}
+	if !reflect.DeepEqual(ports, expected) {
+		t.Fatalf("Unexpected ports:\n got  %+v\n want %+v", ports, expected)
+	}
+
+	// Port 8080 is bound on both IPv4 and IPv6 and reported once
+	if addr := ports[2].Address; addr != "0.0.0.0" && addr != "::" {
+		t.Errorf("Expected wildcard address for port 8080, got %s", addr)
+	}
+}
+
+func TestParsePortScan_Empty(t *testing.T) {
+	if ports := parsePortScan(""); len(ports) != 0 {
+		t.Errorf("Expected no ports, got %+v", ports)
+	}
+}
+
+func TestPortScanner_PublishesChanges(t *testing.T) {
+	bus := NewEventBus()
+	events, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	scanner := &PortScanner{events: bus, results: make(map[string]*PortScanResult)}
+
+	scanner.update("ws-1", &PortScanResult{Ports: []ListeningPort{{Port: 3000}, {Port: 8080}}, ScannedAt: time.Now()})
+	scanner.update("ws-1", &PortScanResult{Ports: []ListeningPort{{Port: 8080}, {Port: 9000}}, ScannedAt: time.Now()})
+	scanner.forget("ws-1")
+
+	var got []string
+	for len(events) > 0 {
+		event := <-events
+		got = append(got, event.Type+":"+strconv.Itoa(event.Data.(ListeningPort).Port))
+	}
+
+	want := []string{
+		"port.opened:3000", "port.opened:8080",
+		"port.opened:9000", "port.closed:3000",
+		"port.closed:8080", "port.closed:9000",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected events:\n got  %v\n want %v", got, want)
+	}
+
+	if _, ok := scanner.LastScan("ws-1"); ok {
+		t.Error("Expected forgotten workspace to have no scan result")
+	}
+}
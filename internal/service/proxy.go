@@ -2,43 +2,253 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/1PercentSync/vibox/internal/domain"
 	"github.com/1PercentSync/vibox/pkg/utils"
 )
 
 // ProxyService handles HTTP proxying to containers
+//
+// All requests share one transport (and thus one keep-alive connection pool), and
+// container IPs are cached by container ID. Cache entries, and the connections
+// to their IP, are dropped when the container changes state (see
+// DockerService.OnContainerChange) or when the container cannot be reached.
 type ProxyService struct {
 	dockerSvc *DockerService
+	transport *http.Transport
+	conns     *connTracker
+
+	mu      sync.RWMutex
+	ipCache map[string]string // containerID -> IP of a running container
 }
 
 // NewProxyService creates a new proxy service instance
 func NewProxyService(dockerSvc *DockerService) *ProxyService {
 	utils.Info("Initializing Proxy service")
-	return &ProxyService{
+	conns := newConnTracker()
+	s := &ProxyService{
 		dockerSvc: dockerSvc,
+		transport: newProxyTransport(conns),
+		conns:     conns,
+		ipCache:   make(map[string]string),
+	}
+	if dockerSvc != nil {
+		dockerSvc.OnContainerChange(s.InvalidateContainer)
+	}
+	return s
+}
+
+// newProxyTransport creates the transport used for all proxied requests.
+// Connections are registered with conns so those of one container can be closed.
+func newProxyTransport(conns *connTracker) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second, // Connection timeout
+		KeepAlive: 30 * time.Second, // Keep-alive period
+	}
+	return &http.Transport{
+		// Connection settings
+		DialContext: conns.dialContext(dialer.DialContext),
+
+		// Timeout settings
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+
+		// Connection pool settings (shared by all containers)
+		MaxIdleConns:        200,
+		MaxIdleConnsPerHost: 32,
+		MaxConnsPerHost:     100,
+
+		// Disable compression (let the client and container handle it)
+		DisableCompression: false,
+	}
+}
+
+// connTracker records the open upstream connections by remote host, so the
+// connections of one container can be closed without emptying the whole pool
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[string]map[*trackedConn]struct{} // host -> open connections
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{conns: make(map[string]map[*trackedConn]struct{})}
+}
+
+// dialContext wraps dial so that every connection it opens is tracked until closed
+func (t *connTracker) dialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		tracked := &trackedConn{Conn: conn, tracker: t, host: host}
+
+		t.mu.Lock()
+		if t.conns[host] == nil {
+			t.conns[host] = make(map[*trackedConn]struct{})
+		}
+		t.conns[host][tracked] = struct{}{}
+		t.mu.Unlock()
+		return tracked, nil
+	}
+}
+
+// closeHost closes all open connections to host and returns how many there were
+func (t *connTracker) closeHost(host string) int {
+	t.mu.Lock()
+	conns := t.conns[host]
+	delete(t.conns, host)
+	t.mu.Unlock()
+
+	for conn := range conns {
+		conn.Close()
+	}
+	return len(conns)
+}
+
+// remove stops tracking a closed connection
+func (t *connTracker) remove(conn *trackedConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if conns, ok := t.conns[conn.host]; ok {
+		delete(conns, conn)
+		if len(conns) == 0 {
+			delete(t.conns, conn.host)
+		}
+	}
+}
+
+// trackedConn is an upstream connection registered with a connTracker
+type trackedConn struct {
+	net.Conn
+	tracker *connTracker
+	host    string
+	once    sync.Once
+}
+
+// Close closes the connection and stops tracking it
+func (c *trackedConn) Close() error {
+	c.once.Do(func() { c.tracker.remove(c) })
+	return c.Conn.Close()
+}
+
+// ContainerStatus returns the status of a container, answering "running" from
+// the IP cache when possible. Inspecting a running container caches its IP.
+func (s *ProxyService) ContainerStatus(ctx context.Context, containerID string) (string, error) {
+	if _, ok := s.cachedIP(containerID); ok {
+		return "running", nil
+	}
+
+	inspect, err := s.dockerSvc.InspectContainer(ctx, containerID)
+	if err != nil {
+		return "", err
+	}
+	if inspect.State == nil {
+		return "", fmt.Errorf("container state unavailable")
+	}
+	if inspect.State.Running {
+		if ip, err := containerIP(containerID, inspect); err == nil {
+			s.cacheIP(containerID, ip)
+		}
 	}
+	return inspect.State.Status, nil
+}
+
+// InvalidateContainer drops the cached IP of a container and closes the
+// connections to it; other containers keep their pooled connections
+func (s *ProxyService) InvalidateContainer(containerID string) {
+	s.mu.Lock()
+	ip, cached := s.ipCache[containerID]
+	delete(s.ipCache, containerID)
+	s.mu.Unlock()
+
+	if cached {
+		// Pooled connections may point at an address that is now gone or reused
+		closed := s.conns.closeHost(ip)
+		utils.Debug("Container IP cache invalidated", "containerID", utils.ShortID(containerID), "closedConns", closed)
+	}
+}
+
+// cachedIP returns the cached IP of a container
+func (s *ProxyService) cachedIP(containerID string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ip, ok := s.ipCache[containerID]
+	return ip, ok
+}
+
+// cacheIP stores the IP of a running container
+func (s *ProxyService) cacheIP(containerID, ip string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ipCache[containerID] = ip
+}
+
+// resolveContainerIP returns the IP of a container, from the cache when possible
+func (s *ProxyService) resolveContainerIP(ctx context.Context, containerID string) (string, error) {
+	if ip, ok := s.cachedIP(containerID); ok {
+		return ip, nil
+	}
+
+	ip, err := s.dockerSvc.GetContainerIP(ctx, containerID)
+	if err != nil {
+		return "", err
+	}
+	s.cacheIP(containerID, ip)
+	return ip, nil
+}
+
+// ProxyOptions controls how a request is forwarded to a container
+type ProxyOptions struct {
+	// Prefix is the public path prefix the app is mounted under (e.g. /forward/ws-abc/8080).
+	// It is sent upstream as X-Forwarded-Prefix; empty for root-mounted (subdomain) forwards.
+	Prefix string
+	// Rewrite enables rewriting of Location headers, Set-Cookie paths and absolute
+	// links in HTML/CSS responses so that they stay under Prefix
+	Rewrite bool
+	// CORSHeaders, when non-nil, replace any Access-Control-* headers of the
+	// upstream response (ViBox answers CORS on behalf of the app)
+	CORSHeaders http.Header
 }
 
 // ProxyRequest proxies an HTTP request to a container's port
 // This is the main entry point for forwarding requests to containers
 func (s *ProxyService) ProxyRequest(w http.ResponseWriter, r *http.Request, containerID string, port int) error {
+	return s.ProxyRequestWithOptions(w, r, containerID, port, ProxyOptions{})
+}
+
+// ProxyRequestWithOptions proxies an HTTP request to a container's port using the given options
+func (s *ProxyService) ProxyRequestWithOptions(w http.ResponseWriter, r *http.Request, containerID string, port int, opts ProxyOptions) error {
 	utils.Debug("Proxying request to container",
 		"containerID", utils.ShortID(containerID),
 		"port", port,
 		"method", r.Method,
 		"path", r.URL.Path,
+		"prefix", opts.Prefix,
+		"rewrite", opts.Rewrite,
 	)
 
 	// Get container IP address
 	// Use request context to respect client cancellation
 	ctx := r.Context()
-	containerIP, err := s.dockerSvc.GetContainerIP(ctx, containerID)
+	containerIP, err := s.resolveContainerIP(ctx, containerID)
 	if err != nil {
 		utils.Error("Failed to get container IP",
 			"containerID", utils.ShortID(containerID),
@@ -49,7 +259,7 @@ func (s *ProxyService) ProxyRequest(w http.ResponseWriter, r *http.Request, cont
 	}
 
 	// Create and configure reverse proxy
-	proxy := s.createReverseProxy(containerIP, port)
+	proxy := s.createReverseProxy(containerID, containerIP, port, opts)
 
 	// Proxy the request
 	proxy.ServeHTTP(w, r)
@@ -62,57 +272,58 @@ func (s *ProxyService) ProxyRequest(w http.ResponseWriter, r *http.Request, cont
 	return nil
 }
 
-// createReverseProxy creates a configured reverse proxy for the given target
-func (s *ProxyService) createReverseProxy(containerIP string, port int) *httputil.ReverseProxy {
+// createReverseProxy creates a configured reverse proxy for the given target.
+// The proxy itself is cheap; connections are pooled by the shared transport.
+func (s *ProxyService) createReverseProxy(containerID, containerIP string, port int, opts ProxyOptions) *httputil.ReverseProxy {
 	// Build target URL
 	targetURL := &url.URL{
 		Scheme: "http",
-		Host:   fmt.Sprintf("%s:%d", containerIP, port),
+		Host:   net.JoinHostPort(containerIP, strconv.Itoa(port)),
 	}
 
-	utils.Debug("Creating reverse proxy", "target", targetURL.String())
-
 	// Create the reverse proxy
 	proxy := httputil.NewSingleHostReverseProxy(targetURL)
-
-	// Configure transport with timeouts and keep-alive
-	proxy.Transport = &http.Transport{
-		// Connection settings
-		DialContext: (&net.Dialer{
-			Timeout:   10 * time.Second, // Connection timeout
-			KeepAlive: 30 * time.Second, // Keep-alive period
-		}).DialContext,
-
-		// Timeout settings
-		TLSHandshakeTimeout:   10 * time.Second,
-		ResponseHeaderTimeout: 30 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-		IdleConnTimeout:       90 * time.Second,
-
-		// Connection pool settings
-		MaxIdleConns:          100,
-		MaxIdleConnsPerHost:   10,
-		MaxConnsPerHost:       100,
-
-		// Disable compression (let the client and container handle it)
-		DisableCompression: false,
-	}
+	proxy.Transport = s.transport
 
 	// Custom director to modify the request before proxying
 	originalDirector := proxy.Director
 	proxy.Director = func(req *http.Request) {
+		// Capture what the client saw before the director retargets the request
+		clientHost := req.Host
+		clientScheme := "http"
+		if req.TLS != nil {
+			clientScheme = "https"
+		}
+
 		// Call the original director first
 		originalDirector(req)
 
+		// Let prefix-aware apps self-configure (keep values set by an outer proxy such as Caddy)
+		if req.Header.Get("X-Forwarded-Host") == "" {
+			req.Header.Set("X-Forwarded-Host", clientHost)
+		}
+		if req.Header.Get("X-Forwarded-Proto") == "" {
+			req.Header.Set("X-Forwarded-Proto", clientScheme)
+		}
+		if opts.Prefix != "" {
+			req.Header.Set("X-Forwarded-Prefix", opts.Prefix)
+		}
+
+		// Rewriting needs plain-text bodies: without a client Accept-Encoding the
+		// transport negotiates gzip itself and decompresses the response transparently
+		if opts.Rewrite {
+			req.Header.Del("Accept-Encoding")
+		}
+
 		// Remove ViBox authentication to prevent leaking to container
 		// This allows container applications to use their own authentication
 
 		// 1. Remove ViBox authentication cookie
 		if cookies := req.Cookies(); len(cookies) > 0 {
-			// Filter out vibox-token cookie
+			// Filter out ViBox credential cookies (login session, legacy token, share link and forward ticket)
 			filteredCookies := make([]*http.Cookie, 0, len(cookies))
 			for _, cookie := range cookies {
-				if cookie.Name != "vibox-token" {
+				if !slices.Contains(domain.CredentialCookieNames, cookie.Name) {
 					filteredCookies = append(filteredCookies, cookie)
 				}
 			}
@@ -144,6 +355,11 @@ func (s *ProxyService) createReverseProxy(containerIP string, port int) *httputi
 		)
 
 		// Determine appropriate error response
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			// The container may have been restarted with a new IP
+			s.InvalidateContainer(containerID)
+		}
 		if err == context.DeadlineExceeded {
 			http.Error(w, "Gateway timeout", http.StatusGatewayTimeout)
 		} else if _, ok := err.(net.Error); ok {
@@ -165,7 +381,24 @@ func (s *ProxyService) createReverseProxy(containerIP string, port int) *httputi
 
 		// Call original modifier if it exists
 		if originalModifyResponse != nil {
-			return originalModifyResponse(resp)
+			if err := originalModifyResponse(resp); err != nil {
+				return err
+			}
+		}
+
+		if opts.CORSHeaders != nil {
+			for key := range resp.Header {
+				if strings.HasPrefix(key, "Access-Control-") {
+					resp.Header.Del(key)
+				}
+			}
+			for key, values := range opts.CORSHeaders {
+				resp.Header[key] = values
+			}
+		}
+
+		if opts.Rewrite && opts.Prefix != "" {
+			return rewriteResponse(resp, opts.Prefix, targetURL.Host)
 		}
 
 		return nil
@@ -177,5 +410,5 @@ func (s *ProxyService) createReverseProxy(containerIP string, port int) *httputi
 // GetContainerIP is a convenience method to get a container's IP address
 // This can be useful for API handlers that need to check if a container is accessible
 func (s *ProxyService) GetContainerIP(ctx context.Context, containerID string) (string, error) {
-	return s.dockerSvc.GetContainerIP(ctx, containerID)
+	return s.resolveContainerIP(ctx, containerID)
 }
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+// maxRewriteBodySize is the largest response body that is rewritten; larger
+// bodies are passed through unchanged
+const maxRewriteBodySize = 10 * 1024 * 1024
+
+var (
+	// htmlURLAttrPattern matches URL-bearing HTML attributes with a root-relative value
+	htmlURLAttrPattern = regexp.MustCompile(`(?i)(\s(?:href|src|action|formaction|poster|data)\s*=\s*["']?)(/[^"'\s>]*)`)
+	// cssURLPattern matches root-relative url(...) references in CSS (and inline styles)
+	cssURLPattern = regexp.MustCompile(`(?i)(url\(\s*["']?)(/[^"')\s]*)`)
+	// cssImportPattern matches root-relative @import "..." references
+	cssImportPattern = regexp.MustCompile(`(?i)(@import\s+["'])(/[^"']*)`)
+	// cookiePathPattern matches the Path attribute of a Set-Cookie header
+	cookiePathPattern = regexp.MustCompile(`(?i)(;\s*path=)([^;]*)`)
+)
+
+// rewriteResponse keeps redirects, cookies and absolute links of a proxied
+// response under prefix. upstreamHost is the container host:port the request
+// was sent to, used to recognise absolute redirects back to the app itself.
+func rewriteResponse(resp *http.Response, prefix, upstreamHost string) error {
+	if location := resp.Header.Get("Location"); location != "" {
+		resp.Header.Set("Location", rewriteLocation(location, prefix, upstreamHost))
+	}
+
+	if cookies := resp.Header.Values("Set-Cookie"); len(cookies) > 0 {
+		resp.Header.Del("Set-Cookie")
+		for _, cookie := range cookies {
+			resp.Header.Add("Set-Cookie", rewriteCookiePath(cookie, prefix))
+		}
+	}
+
+	return rewriteBody(resp, prefix)
+}
+
+// rewriteLocation prefixes root-relative redirects and turns absolute redirects
+// to the upstream container into prefixed paths
+func rewriteLocation(location, prefix, upstreamHost string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+
+	if u.IsAbs() || u.Host != "" {
+		if !strings.EqualFold(u.Host, upstreamHost) {
+			// Redirect to another site - leave it alone
+			return location
+		}
+		u.Scheme = ""
+		u.Host = ""
+		u.User = nil
+		if u.Path == "" {
+			u.Path = "/"
+		}
+	}
+
+	if !strings.HasPrefix(u.Path, "/") {
+		// Relative redirects already resolve under the prefix
+		return u.String()
+	}
+
+	u.Path = prefixPath(u.Path, prefix)
+	u.RawPath = ""
+	return u.String()
+}
+
+// rewriteCookiePath scopes a cookie's Path attribute to the prefix
+func rewriteCookiePath(cookie, prefix string) string {
+	return cookiePathPattern.ReplaceAllStringFunc(cookie, func(match string) string {
+		parts := cookiePathPattern.FindStringSubmatch(match)
+		path := strings.TrimSpace(parts[2])
+		if !strings.HasPrefix(path, "/") {
+			return match
+		}
+		return parts[1] + prefixPath(path, prefix)
+	})
+}
+
+// rewriteBody rewrites absolute links in HTML and CSS bodies
+func rewriteBody(resp *http.Response, prefix string) error {
+	if resp.Body == nil || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	isHTML := mediaType == "text/html" || mediaType == "application/xhtml+xml"
+	isCSS := mediaType == "text/css"
+	if !isHTML && !isCSS {
+		return nil
+	}
+
+	// Compressed bodies cannot be rewritten (Accept-Encoding is stripped, but some apps ignore it)
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		utils.Debug("Skipping rewrite of encoded response", "encoding", encoding)
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRewriteBodySize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxRewriteBodySize {
+		// Too large - stream it through untouched
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return nil
+	}
+	resp.Body.Close()
+
+	content := string(body)
+	if isHTML {
+		content = replaceRootPaths(htmlURLAttrPattern, content, prefix)
+	}
+	content = replaceRootPaths(cssURLPattern, content, prefix)
+	content = replaceRootPaths(cssImportPattern, content, prefix)
+
+	resp.Body = io.NopCloser(strings.NewReader(content))
+	resp.ContentLength = int64(len(content))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(content)))
+	return nil
+}
+
+// replaceRootPaths prefixes the path captured by the second group of pattern
+func replaceRootPaths(pattern *regexp.Regexp, content, prefix string) string {
+	return pattern.ReplaceAllStringFunc(content, func(match string) string {
+		parts := pattern.FindStringSubmatch(match)
+		return parts[1] + prefixPath(parts[2], prefix)
+	})
+}
+
+// prefixPath prepends prefix to a root-relative path unless it is
+// protocol-relative (//host/...) or already prefixed
+func prefixPath(path, prefix string) string {
+	if strings.HasPrefix(path, "//") {
+		return path
+	}
+	if path == prefix || strings.HasPrefix(path, prefix+"/") {
+		return path
+	}
+	return prefix + path
+}
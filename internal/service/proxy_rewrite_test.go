@@ -0,0 +1,145 @@
+package service
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRewriteLocation(t *testing.T) {
+	prefix := "/forward/ws-abc12345/8080"
+	upstream := "172.18.0.5:8080"
+
+	tests := []struct {
+		name     string
+		location string
+		want     string
+	}{
+		{"root relative", "/login?next=/app", prefix + "/login?next=/app"},
+		{"root", "/", prefix + "/"},
+		{"already prefixed", prefix + "/login", prefix + "/login"},
+		{"relative", "login", "login"},
+		{"absolute to upstream", "http://172.18.0.5:8080/dashboard#top", prefix + "/dashboard#top"},
+		{"absolute to upstream without path", "http://172.18.0.5:8080", prefix + "/"},
+		{"external site", "https://github.com/login", "https://github.com/login"},
+		{"protocol relative", "//cdn.example.com/x.js", "//cdn.example.com/x.js"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rewriteLocation(tt.location, prefix, upstream); got != tt.want {
+				t.Errorf("rewriteLocation(%q) = %q, want %q", tt.location, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteCookiePath(t *testing.T) {
+	prefix := "/forward/ws-abc12345/8080"
+
+	tests := []struct {
+		cookie string
+		want   string
+	}{
+		{"sid=1; Path=/; HttpOnly", "sid=1; Path=" + prefix + "/; HttpOnly"},
+		{"sid=1; path=/admin", "sid=1; path=" + prefix + "/admin"},
+		{"sid=1; HttpOnly", "sid=1; HttpOnly"},
+		{"sid=1; Path=" + prefix, "sid=1; Path=" + prefix},
+	}
+
+	for _, tt := range tests {
+		if got := rewriteCookiePath(tt.cookie, prefix); got != tt.want {
+			t.Errorf("rewriteCookiePath(%q) = %q, want %q", tt.cookie, got, tt.want)
+		}
+	}
+}
+
+func TestReplaceRootPaths(t *testing.T) {
+	prefix := "/p"
+
+	html := `<a href="/docs">x</a><img src='/logo.png'><script src="//cdn.example.com/a.js"></script>` +
+		`<form action=/submit></form><a href="relative">y</a><div style="background:url(/bg.png)"></div>`
+	want := `<a href="/p/docs">x</a><img src='/p/logo.png'><script src="//cdn.example.com/a.js"></script>` +
+		`<form action=/p/submit></form><a href="relative">y</a><div style="background:url(/p/bg.png)"></div>`
+
+	got := replaceRootPaths(htmlURLAttrPattern, html, prefix)
+	got = replaceRootPaths(cssURLPattern, got, prefix)
+	if got != want {
+		t.Errorf("HTML rewrite mismatch:\n got: %s\nwant: %s", got, want)
+	}
+
+	css := `@import "/base.css"; body { background: url("/img/bg.png"); } .x { background: url(data:image/png;base64,AA) }`
+	wantCSS := `@import "/p/base.css"; body { background: url("/p/img/bg.png"); } .x { background: url(data:image/png;base64,AA) }`
+	gotCSS := replaceRootPaths(cssImportPattern, replaceRootPaths(cssURLPattern, css, prefix), prefix)
+	if gotCSS != wantCSS {
+		t.Errorf("CSS rewrite mismatch:\n got: %s\nwant: %s", gotCSS, wantCSS)
+	}
+}
+
+// TestReverseProxyRewrite proxies to an in-process upstream to check headers and body rewriting end to end
+func TestReverseProxyRewrite(t *testing.T) {
+	prefix := "/forward/ws-abc12345/8080"
+
+	var forwardedPrefix string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedPrefix = r.Header.Get("X-Forwarded-Prefix")
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusFound)
+		default:
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "1", Path: "/"})
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			io.WriteString(w, `<link href="/app.css" rel="stylesheet"><a href="/next">next</a>`)
+		}
+	}))
+	defer upstream.Close()
+
+	upstreamURL, _ := url.Parse(upstream.URL)
+	host, portStr, _ := net.SplitHostPort(upstreamURL.Host)
+	port, _ := strconv.Atoi(portStr)
+
+	proxySvc := NewProxyService(nil)
+
+	// Rewrite enabled
+	proxy := proxySvc.createReverseProxy("test-container", host, port, ProxyOptions{Prefix: prefix, Rewrite: true})
+
+	req := httptest.NewRequest("GET", "/page", nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	if !strings.Contains(body, `href="`+prefix+`/app.css"`) || !strings.Contains(body, `href="`+prefix+`/next"`) {
+		t.Errorf("Expected links to be prefixed, got %s", body)
+	}
+	if cookie := w.Header().Get("Set-Cookie"); !strings.Contains(cookie, "Path="+prefix+"/") {
+		t.Errorf("Expected cookie path to be prefixed, got %q", cookie)
+	}
+	if w.Header().Get("Content-Length") != strconv.Itoa(len(body)) {
+		t.Errorf("Expected Content-Length %d, got %s", len(body), w.Header().Get("Content-Length"))
+	}
+	if forwardedPrefix != prefix {
+		t.Errorf("Expected X-Forwarded-Prefix %q, got %q", prefix, forwardedPrefix)
+	}
+
+	req = httptest.NewRequest("GET", "/old", nil)
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if location := w.Header().Get("Location"); location != prefix+"/new" {
+		t.Errorf("Expected Location %q, got %q", prefix+"/new", location)
+	}
+
+	// Rewrite disabled: responses pass through untouched
+	proxy = proxySvc.createReverseProxy("test-container", host, port, ProxyOptions{Prefix: prefix})
+
+	req = httptest.NewRequest("GET", "/old", nil)
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if location := w.Header().Get("Location"); location != "/new" {
+		t.Errorf("Expected untouched Location '/new', got %q", location)
+	}
+}
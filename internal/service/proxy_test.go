@@ -3,12 +3,18 @@ package service
 import (
 	"context"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/1PercentSync/vibox/internal/config"
+	"github.com/1PercentSync/vibox/internal/domain"
 )
 
 // TestNewProxyService tests proxy service initialization
@@ -343,4 +349,210 @@ sleep 2
 	}
 }
 
+// startCountingUpstream starts an in-process HTTP server that counts the TCP
+// connections it accepts, returning its host and port
+func startCountingUpstream(tb testing.TB) (host string, port int, newConns *atomic.Int64) {
+	tb.Helper()
+	newConns = &atomic.Int64{}
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	upstream.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			newConns.Add(1)
+		}
+	}
+	upstream.Start()
+	tb.Cleanup(upstream.Close)
+
+	upstreamURL, _ := url.Parse(upstream.URL)
+	host, portStr, _ := net.SplitHostPort(upstreamURL.Host)
+	port, _ = strconv.Atoi(portStr)
+	return host, port, newConns
+}
+
+// TestProxyService_ReusesConnections verifies that requests share keep-alive
+// connections and do not need Docker once the container IP is cached
+func TestProxyService_ReusesConnections(t *testing.T) {
+	host, port, newConns := startCountingUpstream(t)
+
+	// No Docker service: every lookup must be served from the cache
+	proxySvc := NewProxyService(nil)
+	proxySvc.cacheIP("container-1", host)
+
+	for i := 0; i < 20; i++ {
+		w := httptest.NewRecorder()
+		if err := proxySvc.ProxyRequest(w, httptest.NewRequest("GET", "/", nil), "container-1", port); err != nil {
+			t.Fatalf("Proxy request failed: %v", err)
+		}
+		if w.Code != http.StatusOK || w.Body.String() != "ok" {
+			t.Fatalf("Unexpected response %d %q", w.Code, w.Body.String())
+		}
+	}
+
+	if got := newConns.Load(); got != 1 {
+		t.Errorf("Expected 1 upstream connection for sequential requests, got %d", got)
+	}
+
+	status, err := proxySvc.ContainerStatus(context.Background(), "container-1")
+	if err != nil || status != "running" {
+		t.Errorf("Expected cached container to be running, got %q (%v)", status, err)
+	}
+}
+
+// TestProxyService_InvalidateContainer verifies cache invalidation on lifecycle
+// changes and on unreachable containers
+func TestProxyService_InvalidateContainer(t *testing.T) {
+	proxySvc := NewProxyService(nil)
+
+	proxySvc.cacheIP("container-1", "10.0.0.1")
+	proxySvc.InvalidateContainer("container-1")
+	if _, ok := proxySvc.cachedIP("container-1"); ok {
+		t.Error("Expected container IP to be removed from the cache")
+	}
+
+	// A closed port: the dial fails and the stale entry is dropped
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	proxySvc.cacheIP("container-2", "127.0.0.1")
+	w := httptest.NewRecorder()
+	proxySvc.ProxyRequest(w, httptest.NewRequest("GET", "/", nil), "container-2", port)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502 for unreachable container, got %d", w.Code)
+	}
+	if _, ok := proxySvc.cachedIP("container-2"); ok {
+		t.Error("Expected unreachable container to be removed from the cache")
+	}
+}
+
+// TestProxyService_InvalidateKeepsOtherConnections verifies that invalidating
+// one container closes only the connections to that container's IP
+func TestProxyService_InvalidateKeepsOtherConnections(t *testing.T) {
+	_, port, firstConns := startCountingUpstream(t)
+
+	// A second upstream on another loopback address (same port space, different host)
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("127.0.0.2 not available: %v", err)
+	}
+	secondConns := &atomic.Int64{}
+	second := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	second.Listener.Close()
+	second.Listener = listener
+	second.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			secondConns.Add(1)
+		}
+	}
+	second.Start()
+	defer second.Close()
+	secondPort := listener.Addr().(*net.TCPAddr).Port
+
+	proxySvc := NewProxyService(nil)
+	proxySvc.cacheIP("container-1", "127.0.0.1")
+	proxySvc.cacheIP("container-2", "127.0.0.2")
+
+	get := func(containerID string, port int) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		if err := proxySvc.ProxyRequest(w, httptest.NewRequest("GET", "/", nil), containerID, port); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("Proxy request to %s failed: %d %v", containerID, w.Code, err)
+		}
+	}
+	get("container-1", port)
+	get("container-2", secondPort)
+
+	proxySvc.InvalidateContainer("container-1")
+	proxySvc.cacheIP("container-1", "127.0.0.1")
+	get("container-1", port)
+	get("container-2", secondPort)
+
+	if got := firstConns.Load(); got != 2 {
+		t.Errorf("Expected the invalidated container to be redialed, got %d connections", got)
+	}
+	if got := secondConns.Load(); got != 1 {
+		t.Errorf("Expected the other container to keep its connection, got %d connections", got)
+	}
+}
+
+// TestProxyService_StripsCredentialCookies verifies that no ViBox credential
+// cookie reaches the container while the app's own cookies do
+func TestProxyService_StripsCredentialCookies(t *testing.T) {
+	var received []*http.Cookie
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Cookies()
+	}))
+	defer upstream.Close()
+	upstreamURL, _ := url.Parse(upstream.URL)
+	host, portStr, _ := net.SplitHostPort(upstreamURL.Host)
+	port, _ := strconv.Atoi(portStr)
+
+	proxySvc := NewProxyService(nil)
+	proxySvc.cacheIP("container-1", host)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	for _, name := range domain.CredentialCookieNames {
+		req.AddCookie(&http.Cookie{Name: name, Value: "secret"})
+	}
+	req.AddCookie(&http.Cookie{Name: "app-session", Value: "kept"})
+	w := httptest.NewRecorder()
+	if err := proxySvc.ProxyRequest(w, req, "container-1", port); err != nil {
+		t.Fatalf("Proxy request failed: %v", err)
+	}
+
+	if len(received) != 1 || received[0].Name != "app-session" {
+		t.Errorf("Expected only the app cookie upstream, got %v", received)
+	}
+}
+
+// BenchmarkProxyRequest_SharedTransport measures proxying with the shared
+// transport and a warm IP cache (the current design)
+func BenchmarkProxyRequest_SharedTransport(b *testing.B) {
+	host, port, newConns := startCountingUpstream(b)
+	proxySvc := NewProxyService(nil)
+	proxySvc.cacheIP("container-1", host)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		proxySvc.ProxyRequest(w, httptest.NewRequest("GET", "/", nil), "container-1", port)
+		if w.Code != http.StatusOK {
+			b.Fatalf("Unexpected status %d", w.Code)
+		}
+	}
+	b.ReportMetric(float64(newConns.Load())/float64(b.N), "conns/op")
+}
+
+// BenchmarkProxyRequest_TransportPerRequest measures the previous design, which
+// built a new transport (and connection pool) for every request. It still uses
+// the IP cache, so the ContainerInspect round trip the old design also paid per
+// request is not included.
+func BenchmarkProxyRequest_TransportPerRequest(b *testing.B) {
+	host, port, newConns := startCountingUpstream(b)
+	proxySvc := NewProxyService(nil)
+	proxySvc.cacheIP("container-1", host)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		transport := newProxyTransport(newConnTracker())
+		proxySvc.transport = transport
+
+		w := httptest.NewRecorder()
+		proxySvc.ProxyRequest(w, httptest.NewRequest("GET", "/", nil), "container-1", port)
+		if w.Code != http.StatusOK {
+			b.Fatalf("Unexpected status %d", w.Code)
+		}
+		// The old pools lingered until IdleConnTimeout; close them to avoid running out of sockets
+		transport.CloseIdleConnections()
+	}
+	b.ReportMetric(float64(newConns.Load())/float64(b.N), "conns/op")
+}
+
 // Note: TestMain is defined in docker_test.go and initializes the logger for all service tests
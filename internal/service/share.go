@@ -0,0 +1,180 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/internal/repository"
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// shareTokenPrefix identifies share tokens
+	shareTokenPrefix = "vbs_"
+	// defaultShareExpiry applies when a share request does not specify an expiry
+	defaultShareExpiry = 24 * time.Hour
+	// maxShareExpiry is the longest lifetime a share link may have
+	maxShareExpiry = 30 * 24 * time.Hour
+)
+
+var (
+	// ErrShareNotFound is returned when a share does not exist for the workspace and port
+	ErrShareNotFound = errors.New("share not found")
+	// ErrShareInvalid is returned when a share token is unknown, expired or for another port
+	ErrShareInvalid = errors.New("invalid or expired share link")
+)
+
+// CreateShareRequest represents a request to create a share link for a port
+type CreateShareRequest struct {
+	ExpiresIn int    `json:"expires_in,omitempty"` // Seconds (default 86400, max 2592000)
+	Password  string `json:"password,omitempty"`
+	ReadOnly  bool   `json:"read_only,omitempty"`
+}
+
+// ShareService manages public share links for forwarded ports
+type ShareService struct {
+	repo repository.ShareRepository
+}
+
+// NewShareService creates a new share service instance
+func NewShareService(repo repository.ShareRepository) *ShareService {
+	utils.Info("Initializing share service")
+	return &ShareService{
+		repo: repo,
+	}
+}
+
+// CreateShare creates a share link for a workspace port and returns it along with
+// the plain token. The token is only available here; just its hash is stored.
+func (s *ShareService) CreateShare(workspaceID string, port int, req CreateShareRequest) (*domain.PortShare, string, error) {
+	utils.Info("Creating share link", "workspaceID", workspaceID, "port", port, "readOnly", req.ReadOnly)
+
+	if req.ExpiresIn < 0 {
+		return nil, "", fmt.Errorf("expires_in cannot be negative")
+	}
+	expiry := defaultShareExpiry
+	if req.ExpiresIn > 0 {
+		expiry = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if expiry > maxShareExpiry {
+		return nil, "", fmt.Errorf("expires_in cannot exceed %d seconds", int(maxShareExpiry.Seconds()))
+	}
+
+	token, err := utils.GenerateToken(shareTokenPrefix)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	share := &domain.PortShare{
+		ID:          utils.GenerateShareID(),
+		WorkspaceID: workspaceID,
+		Port:        port,
+		TokenHash:   utils.HashToken(token),
+		ReadOnly:    req.ReadOnly,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(expiry),
+	}
+
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to hash password: %w", err)
+		}
+		share.PasswordHash = string(hash)
+	}
+
+	if err := s.repo.Create(share); err != nil {
+		utils.Error("Failed to save share", "error", err)
+		return nil, "", fmt.Errorf("failed to save share: %w", err)
+	}
+
+	// Opportunistically drop shares that have already expired
+	s.purgeExpired(now)
+
+	return share, token, nil
+}
+
+// ListShares returns the shares of a workspace, optionally restricted to one port (port 0 = all)
+func (s *ShareService) ListShares(workspaceID string, port int) ([]*domain.PortShare, error) {
+	shares, err := s.repo.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shares: %w", err)
+	}
+
+	result := make([]*domain.PortShare, 0)
+	for _, share := range shares {
+		if share.WorkspaceID == workspaceID && (port == 0 || share.Port == port) {
+			result = append(result, share)
+		}
+	}
+	return result, nil
+}
+
+// RevokeShare deletes a share link of a workspace port
+func (s *ShareService) RevokeShare(workspaceID string, port int, shareID string) error {
+	utils.Info("Revoking share link", "workspaceID", workspaceID, "port", port, "shareID", shareID)
+
+	share, err := s.repo.Get(shareID)
+	if err != nil || share.WorkspaceID != workspaceID || share.Port != port {
+		return fmt.Errorf("%w: %s", ErrShareNotFound, shareID)
+	}
+
+	if err := s.repo.Delete(shareID); err != nil {
+		return fmt.Errorf("failed to revoke share: %w", err)
+	}
+	return nil
+}
+
+// DeleteSharesOf removes all share links of a workspace; it is registered as a
+// workspace deletion listener so links do not outlive their workspace
+func (s *ShareService) DeleteSharesOf(workspaceID string) {
+	shares, err := s.ListShares(workspaceID, 0)
+	if err != nil {
+		utils.Warn("Failed to list shares of deleted workspace", "workspaceID", workspaceID, "error", err)
+		return
+	}
+	for _, share := range shares {
+		if err := s.repo.Delete(share.ID); err != nil {
+			utils.Warn("Failed to delete share of deleted workspace", "shareID", share.ID, "error", err)
+		}
+	}
+}
+
+// ValidateShare returns the share for token if it is valid for the workspace port
+func (s *ShareService) ValidateShare(token, workspaceID string, port int) (*domain.PortShare, error) {
+	share, err := s.repo.GetByTokenHash(utils.HashToken(token))
+	if err != nil {
+		return nil, ErrShareInvalid
+	}
+	if share.WorkspaceID != workspaceID || share.Port != port || share.IsExpired(time.Now()) {
+		return nil, ErrShareInvalid
+	}
+	return share, nil
+}
+
+// CheckSharePassword reports whether password unlocks the share
+func (s *ShareService) CheckSharePassword(share *domain.PortShare, password string) bool {
+	if !share.HasPassword() {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(share.PasswordHash), []byte(password)) == nil
+}
+
+// purgeExpired deletes shares that expired before now
+func (s *ShareService) purgeExpired(now time.Time) {
+	shares, err := s.repo.List()
+	if err != nil {
+		return
+	}
+	for _, share := range shares {
+		if share.IsExpired(now) {
+			if err := s.repo.Delete(share.ID); err != nil {
+				utils.Warn("Failed to purge expired share", "shareID", share.ID, "error", err)
+			}
+		}
+	}
+}
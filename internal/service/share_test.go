@@ -0,0 +1,145 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/repository"
+)
+
+func TestShareService_CreateAndValidate(t *testing.T) {
+	svc := NewShareService(repository.NewMemoryShareRepository())
+
+	share, token, err := svc.CreateShare("ws-1", 8080, CreateShareRequest{Password: "secret", ReadOnly: true})
+	if err != nil {
+		t.Fatalf("CreateShare failed: %v", err)
+	}
+	if token == "" || share.TokenHash == token {
+		t.Fatal("Expected a plain token distinct from the stored hash")
+	}
+	if !share.ReadOnly || !share.HasPassword() {
+		t.Errorf("Expected read-only password-protected share, got %+v", share)
+	}
+	if got := share.ExpiresAt.Sub(share.CreatedAt); got != defaultShareExpiry {
+		t.Errorf("Expected default expiry %v, got %v", defaultShareExpiry, got)
+	}
+
+	found, err := svc.ValidateShare(token, "ws-1", 8080)
+	if err != nil {
+		t.Fatalf("ValidateShare failed: %v", err)
+	}
+	if found.ID != share.ID {
+		t.Errorf("Expected share %s, got %s", share.ID, found.ID)
+	}
+
+	// The token only unlocks its own port
+	if _, err := svc.ValidateShare(token, "ws-1", 3000); !errors.Is(err, ErrShareInvalid) {
+		t.Errorf("Expected ErrShareInvalid for another port, got %v", err)
+	}
+	if _, err := svc.ValidateShare(token, "ws-2", 8080); !errors.Is(err, ErrShareInvalid) {
+		t.Errorf("Expected ErrShareInvalid for another workspace, got %v", err)
+	}
+	if _, err := svc.ValidateShare("vbs_unknown", "ws-1", 8080); !errors.Is(err, ErrShareInvalid) {
+		t.Errorf("Expected ErrShareInvalid for unknown token, got %v", err)
+	}
+
+	if !svc.CheckSharePassword(found, "secret") {
+		t.Error("Expected correct password to be accepted")
+	}
+	if svc.CheckSharePassword(found, "wrong") {
+		t.Error("Expected wrong password to be rejected")
+	}
+}
+
+func TestShareService_Expiry(t *testing.T) {
+	repo := repository.NewMemoryShareRepository()
+	svc := NewShareService(repo)
+
+	if _, _, err := svc.CreateShare("ws-1", 8080, CreateShareRequest{ExpiresIn: -1}); err == nil {
+		t.Error("Expected error for negative expiry")
+	}
+	if _, _, err := svc.CreateShare("ws-1", 8080, CreateShareRequest{ExpiresIn: int(maxShareExpiry.Seconds()) + 1}); err == nil {
+		t.Error("Expected error for expiry above the maximum")
+	}
+
+	share, token, err := svc.CreateShare("ws-1", 8080, CreateShareRequest{ExpiresIn: 60})
+	if err != nil {
+		t.Fatalf("CreateShare failed: %v", err)
+	}
+
+	// Age the share past its expiry
+	share.CreatedAt = time.Now().Add(-2 * time.Minute)
+	share.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := repo.Delete(share.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := repo.Create(share); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if _, err := svc.ValidateShare(token, "ws-1", 8080); !errors.Is(err, ErrShareInvalid) {
+		t.Errorf("Expected ErrShareInvalid for expired share, got %v", err)
+	}
+
+	// Creating another share purges the expired one
+	if _, _, err := svc.CreateShare("ws-1", 8080, CreateShareRequest{}); err != nil {
+		t.Fatalf("CreateShare failed: %v", err)
+	}
+	if _, err := repo.Get(share.ID); err == nil {
+		t.Error("Expected expired share to be purged")
+	}
+}
+
+func TestShareService_ListAndRevoke(t *testing.T) {
+	svc := NewShareService(repository.NewMemoryShareRepository())
+
+	first, token, _ := svc.CreateShare("ws-1", 8080, CreateShareRequest{})
+	_, _, _ = svc.CreateShare("ws-1", 3000, CreateShareRequest{})
+	_, _, _ = svc.CreateShare("ws-2", 8080, CreateShareRequest{})
+
+	shares, err := svc.ListShares("ws-1", 8080)
+	if err != nil {
+		t.Fatalf("ListShares failed: %v", err)
+	}
+	if len(shares) != 1 || shares[0].ID != first.ID {
+		t.Errorf("Expected only share %s, got %d shares", first.ID, len(shares))
+	}
+
+	all, _ := svc.ListShares("ws-1", 0)
+	if len(all) != 2 {
+		t.Errorf("Expected 2 shares for ws-1, got %d", len(all))
+	}
+
+	// Revoking through the wrong port must not delete the share
+	if err := svc.RevokeShare("ws-1", 3000, first.ID); !errors.Is(err, ErrShareNotFound) {
+		t.Errorf("Expected ErrShareNotFound, got %v", err)
+	}
+
+	if err := svc.RevokeShare("ws-1", 8080, first.ID); err != nil {
+		t.Fatalf("RevokeShare failed: %v", err)
+	}
+	if _, err := svc.ValidateShare(token, "ws-1", 8080); !errors.Is(err, ErrShareInvalid) {
+		t.Errorf("Expected revoked share to be invalid, got %v", err)
+	}
+}
+
+func TestShareService_DeleteSharesOf(t *testing.T) {
+	svc := NewShareService(repository.NewMemoryShareRepository())
+
+	_, token, _ := svc.CreateShare("ws-1", 8080, CreateShareRequest{})
+	_, _, _ = svc.CreateShare("ws-1", 3000, CreateShareRequest{})
+	kept, _, _ := svc.CreateShare("ws-2", 8080, CreateShareRequest{})
+
+	svc.DeleteSharesOf("ws-1")
+
+	if shares, _ := svc.ListShares("ws-1", 0); len(shares) != 0 {
+		t.Errorf("Expected no shares left for ws-1, got %d", len(shares))
+	}
+	if _, err := svc.ValidateShare(token, "ws-1", 8080); !errors.Is(err, ErrShareInvalid) {
+		t.Errorf("Expected share of deleted workspace to be invalid, got %v", err)
+	}
+	if shares, _ := svc.ListShares("ws-2", 0); len(shares) != 1 || shares[0].ID != kept.ID {
+		t.Errorf("Expected share of ws-2 to be kept, got %d shares", len(shares))
+	}
+}
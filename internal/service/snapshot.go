@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+var (
+	// ErrInvalidSnapshotName is returned for names that cannot be used as an image tag
+	ErrInvalidSnapshotName = errors.New("invalid snapshot name")
+	// ErrSnapshotNotFound is returned when a workspace has no snapshot of the given name
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+	// ErrSnapshotExists is returned when creating a snapshot under a name already taken
+	ErrSnapshotExists = errors.New("snapshot already exists")
+	// ErrSnapshotInUse is returned when deleting the snapshot a workspace runs from
+	ErrSnapshotInUse = errors.New("snapshot is in use by the workspace")
+	// ErrWorkspaceNotReady is returned when a workspace has no container to snapshot
+	ErrWorkspaceNotReady = errors.New("workspace has no container")
+)
+
+// snapshotWorkspaceLabel is set on snapshot images to the ID of their workspace
+const snapshotWorkspaceLabel = "vibox.snapshot.workspace"
+
+// CreateSnapshot commits the current filesystem of a workspace container into
+// the image of a new snapshot
+func (s *WorkspaceService) CreateSnapshot(ctx context.Context, id, name string) (*domain.Snapshot, error) {
+	utils.Info("Creating snapshot", "workspaceID", id, "name", name)
+
+	if !domain.IsValidSnapshotName(name) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSnapshotName, name)
+	}
+
+	workspace, err := s.GetWorkspace(id)
+	if err != nil {
+		return nil, err
+	}
+	if workspace.ContainerID == "" {
+		return nil, ErrWorkspaceNotReady
+	}
+	if _, err := s.findSnapshot(ctx, id, name); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotExists, name)
+	} else if !errors.Is(err, ErrSnapshotNotFound) {
+		return nil, err
+	}
+
+	ref := domain.SnapshotImage(id, name)
+	if _, err := s.dockerSvc.CommitContainer(ctx, workspace.ContainerID, ref, map[string]string{snapshotWorkspaceLabel: id}); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	utils.Info("Snapshot created successfully", "workspaceID", id, "name", name, "image", ref)
+	return &domain.Snapshot{Name: name, Image: ref, CreatedAt: time.Now()}, nil
+}
+
+// ListSnapshots returns the snapshots of a workspace, oldest first
+func (s *WorkspaceService) ListSnapshots(ctx context.Context, id string) ([]*domain.Snapshot, error) {
+	utils.Debug("Listing snapshots", "workspaceID", id)
+
+	repository := domain.SnapshotImageRepository + "/" + strings.ToLower(id)
+	images, err := s.dockerSvc.ListImages(ctx, repository)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]*domain.Snapshot, 0, len(images))
+	for _, img := range images {
+		for _, tag := range img.RepoTags {
+			name, ok := strings.CutPrefix(tag, repository+":")
+			if !ok {
+				continue
+			}
+			snapshots = append(snapshots, &domain.Snapshot{
+				Name:      name,
+				Image:     tag,
+				CreatedAt: time.Unix(img.Created, 0),
+				Size:      img.Size,
+			})
+		}
+	}
+	slices.SortFunc(snapshots, func(a, b *domain.Snapshot) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return snapshots, nil
+}
+
+// DeleteSnapshot removes a snapshot of a workspace
+func (s *WorkspaceService) DeleteSnapshot(ctx context.Context, id, name string) error {
+	utils.Info("Deleting snapshot", "workspaceID", id, "name", name)
+
+	workspace, err := s.GetWorkspace(id)
+	if err != nil {
+		return err
+	}
+	snapshot, err := s.findSnapshot(ctx, id, name)
+	if err != nil {
+		return err
+	}
+	if workspace.Snapshot == name {
+		return fmt.Errorf("%w: reset or restore another snapshot first", ErrSnapshotInUse)
+	}
+
+	if err := s.dockerSvc.RemoveImage(ctx, snapshot.Image); err != nil {
+		return fmt.Errorf("failed to delete snapshot: %w", err)
+	}
+
+	utils.Info("Snapshot deleted successfully", "workspaceID", id, "name", name)
+	return nil
+}
+
+// RestoreSnapshot replaces the container of a workspace with one created from
+// a snapshot. Initialization scripts are not run: their effects are part of
+// the snapshot. The workspace keeps using the snapshot until it is reset.
+func (s *WorkspaceService) RestoreSnapshot(ctx context.Context, id, name string) error {
+	utils.Info("Restoring snapshot", "workspaceID", id, "name", name)
+
+	if _, err := s.findSnapshot(ctx, id, name); err != nil {
+		return err
+	}
+
+	// 1. Switch the workspace to the snapshot, detaching the old container
+	var oldContainerID string
+	workspace, err := s.modifyWorkspace(ctx, id, func(ws *domain.Workspace) error {
+		oldContainerID = ws.ContainerID
+		ws.ContainerID = ""
+		ws.Status = domain.StatusCreating
+		ws.Error = ""
+		ws.Snapshot = name
+		ws.PendingChanges = snapshotPendingChanges(ws)
+		return nil
+	})
+	if err != nil {
+		utils.Error("Failed to update workspace state", "workspaceID", id, "error", err)
+		return err
+	}
+
+	// 2. Delete old container (if exists)
+	if oldContainerID != "" {
+		utils.Info("Stopping and removing old container", "workspaceID", id, "containerID", utils.ShortID(oldContainerID))
+		_ = s.dockerSvc.StopContainer(ctx, oldContainerID, 10)
+		_ = s.dockerSvc.RemoveContainer(ctx, oldContainerID)
+	}
+
+	// 3. Recreate container from the snapshot in background
+	go s.provisionWorkspace(workspace)
+	return nil
+}
+
+// findSnapshot returns the snapshot of a workspace with the given name
+func (s *WorkspaceService) findSnapshot(ctx context.Context, id, name string) (*domain.Snapshot, error) {
+	snapshots, err := s.ListSnapshots(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Name == name {
+			return snapshot, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, name)
+}
+
+// removeSnapshots deletes every snapshot of a workspace, logging failures
+func (s *WorkspaceService) removeSnapshots(ctx context.Context, id string) {
+	snapshots, err := s.ListSnapshots(ctx, id)
+	if err != nil {
+		utils.Warn("Failed to list snapshots for removal", "workspaceID", id, "error", err)
+		return
+	}
+	for _, snapshot := range snapshots {
+		if err := s.dockerSvc.RemoveImage(ctx, snapshot.Image); err != nil {
+			utils.Warn("Failed to remove snapshot", "workspaceID", id, "name", snapshot.Name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/config"
+	"github.com/1PercentSync/vibox/internal/domain"
+)
+
+func TestCreateSnapshot_InvalidName(t *testing.T) {
+	workspaceSvc := NewWorkspaceService(nil, newTestWorkspaceRepository(t), &config.Config{})
+
+	for _, name := range []string{"", ".hidden", "-flag", "a/b", "with space", "a:b"} {
+		if _, err := workspaceSvc.CreateSnapshot(context.Background(), "ws-12345678", name); !errors.Is(err, ErrInvalidSnapshotName) {
+			t.Errorf("Expected ErrInvalidSnapshotName for %q, got %v", name, err)
+		}
+	}
+}
+
+func TestSnapshotLifecycle(t *testing.T) {
+	cfg := &config.Config{
+		DockerHost:   "unix:///var/run/docker.sock",
+		DefaultImage: "alpine:latest",
+	}
+
+	dockerSvc, err := NewDockerService(cfg)
+	if err != nil {
+		t.Skipf("Docker not available: %v", err)
+	}
+	defer dockerSvc.Close()
+
+	repo := newTestWorkspaceRepository(t)
+	workspaceSvc := NewWorkspaceService(dockerSvc, repo, cfg)
+
+	ctx := context.Background()
+	workspace, err := workspaceSvc.CreateWorkspace(ctx, CreateWorkspaceRequest{
+		Name:  "test-snapshot",
+		Image: "alpine:latest",
+		Scripts: []domain.Script{
+			{Name: "marker", Content: "#!/bin/sh\necho initial > /marker\n", Order: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+	defer func() { _ = workspaceSvc.DeleteWorkspace(ctx, workspace.ID) }()
+
+	// Wait for background operation to complete
+	time.Sleep(5 * time.Second)
+
+	current, err := repo.Get(workspace.ID)
+	if err != nil || current.ContainerID == "" {
+		t.Fatalf("Workspace not ready: %+v, %v", current, err)
+	}
+	if _, err := dockerSvc.ExecCommand(ctx, current.ContainerID, []string{"sh", "-c", "echo changed > /marker"}); err != nil {
+		t.Fatalf("Failed to change workspace: %v", err)
+	}
+
+	if _, err := workspaceSvc.CreateSnapshot(ctx, workspace.ID, "checkpoint"); err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+	if _, err := workspaceSvc.CreateSnapshot(ctx, workspace.ID, "checkpoint"); !errors.Is(err, ErrSnapshotExists) {
+		t.Errorf("Expected ErrSnapshotExists, got %v", err)
+	}
+
+	snapshots, err := workspaceSvc.ListSnapshots(ctx, workspace.ID)
+	if err != nil || len(snapshots) != 1 || snapshots[0].Name != "checkpoint" {
+		t.Fatalf("Expected one snapshot, got %v, %v", snapshots, err)
+	}
+
+	if err := workspaceSvc.RestoreSnapshot(ctx, workspace.ID, "checkpoint"); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+	time.Sleep(5 * time.Second)
+
+	// The new container holds the snapshot state; scripts did not run again
+	restored, err := repo.Get(workspace.ID)
+	if err != nil || restored.ContainerID == "" || restored.Snapshot != "checkpoint" {
+		t.Fatalf("Expected workspace restored from snapshot, got %+v, %v", restored, err)
+	}
+	if output, err := dockerSvc.ExecCommand(ctx, restored.ContainerID, []string{"cat", "/marker"}); err != nil || output != "changed\n" {
+		t.Errorf("Expected snapshot content, got %q, %v", output, err)
+	}
+
+	// The snapshot in use cannot be deleted
+	if err := workspaceSvc.DeleteSnapshot(ctx, workspace.ID, "checkpoint"); !errors.Is(err, ErrSnapshotInUse) {
+		t.Errorf("Expected ErrSnapshotInUse, got %v", err)
+	}
+	if err := workspaceSvc.DeleteSnapshot(ctx, workspace.ID, "missing"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("Expected ErrSnapshotNotFound, got %v", err)
+	}
+}
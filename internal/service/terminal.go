@@ -3,33 +3,73 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/gorilla/websocket"
 
+	"github.com/1PercentSync/vibox/internal/config"
 	"github.com/1PercentSync/vibox/pkg/utils"
 )
 
+const (
+	// terminalWriteWait is the time allowed to write a message to the peer
+	terminalWriteWait = 10 * time.Second
+	// terminalPongWait is the time allowed to read the next pong message from the peer
+	terminalPongWait = 60 * time.Second
+	// terminalPingPeriod sends pings to the peer with this period (must be less than pongWait)
+	terminalPingPeriod = (terminalPongWait * 9) / 10
+)
+
+// ErrSessionLimitReached is returned when a new terminal session would exceed the configured caps
+var ErrSessionLimitReached = errors.New("terminal session limit reached")
+
 // TerminalService manages WebSocket terminal sessions connected to Docker containers
 type TerminalService struct {
 	dockerSvc *DockerService
 	sessions  sync.Map // map[sessionID]*TerminalSession
+
+	// Keepalive and idle settings
+	writeWait   time.Duration
+	pongWait    time.Duration
+	pingPeriod  time.Duration
+	idleTimeout time.Duration // 0 disables the idle-input timeout
+
+	// Concurrent session caps (0 = unlimited)
+	maxSessions             int
+	maxSessionsPerWorkspace int
+
+	slotsMu        sync.Mutex
+	activeTotal    int
+	activeSessions map[string]int // map[workspaceID]active session count
 }
 
 // TerminalSession represents an active terminal session
 type TerminalSession struct {
-	ID          string
-	ContainerID string
-	WebSocket   *websocket.Conn
-	ExecID      string
+	ID           string
+	WorkspaceID  string
+	ContainerID  string
+	WebSocket    *websocket.Conn
+	ExecID       string
 	HijackedConn io.Closer
-	CreatedAt   time.Time
-	CancelFunc  context.CancelFunc
-	Done        chan struct{}
+	CreatedAt    time.Time
+	CancelFunc   context.CancelFunc
+	Done         chan struct{}
+	ReadOnly     bool
+
+	writeMu   sync.Mutex   // gorilla/websocket supports only one concurrent writer
+	lastInput atomic.Int64 // Unix nanoseconds of the last input message
+	closeOnce sync.Once
+}
+
+// TerminalOptions controls how a terminal session behaves
+type TerminalOptions struct {
+	ReadOnly bool // Input from the client is discarded (output and resizing still work)
 }
 
 // TerminalMessage represents a message exchanged over WebSocket
@@ -41,19 +81,90 @@ type TerminalMessage struct {
 }
 
 // NewTerminalService creates a new terminal service
-func NewTerminalService(dockerSvc *DockerService) *TerminalService {
+func NewTerminalService(dockerSvc *DockerService, cfg *config.Config) *TerminalService {
 	utils.Info("Creating new terminal service")
-	return &TerminalService{
-		dockerSvc: dockerSvc,
-		sessions:  sync.Map{},
+	s := &TerminalService{
+		dockerSvc:      dockerSvc,
+		sessions:       sync.Map{},
+		writeWait:      terminalWriteWait,
+		pongWait:       terminalPongWait,
+		pingPeriod:     terminalPingPeriod,
+		activeSessions: make(map[string]int),
+	}
+	if cfg != nil {
+		s.idleTimeout = time.Duration(cfg.TerminalIdleTimeout) * time.Second
+		s.maxSessions = cfg.MaxTerminalSessions
+		s.maxSessionsPerWorkspace = cfg.MaxTerminalSessionsPerWorkspace
+	}
+	return s
+}
+
+// CheckSessionLimit reports whether a new session could currently be opened for the workspace.
+// It is advisory only; CreateSession enforces the limits atomically.
+func (s *TerminalService) CheckSessionLimit(workspaceID string) error {
+	s.slotsMu.Lock()
+	defer s.slotsMu.Unlock()
+	return s.checkSlotLocked(workspaceID)
+}
+
+// checkSlotLocked checks the session caps; slotsMu must be held
+func (s *TerminalService) checkSlotLocked(workspaceID string) error {
+	if s.maxSessions > 0 && s.activeTotal >= s.maxSessions {
+		return fmt.Errorf("%w: %d sessions open (max %d)", ErrSessionLimitReached, s.activeTotal, s.maxSessions)
+	}
+	if s.maxSessionsPerWorkspace > 0 && s.activeSessions[workspaceID] >= s.maxSessionsPerWorkspace {
+		return fmt.Errorf("%w: %d sessions open for workspace %s (max %d)",
+			ErrSessionLimitReached, s.activeSessions[workspaceID], workspaceID, s.maxSessionsPerWorkspace)
+	}
+	return nil
+}
+
+// acquireSlot reserves a session slot for the workspace
+func (s *TerminalService) acquireSlot(workspaceID string) error {
+	s.slotsMu.Lock()
+	defer s.slotsMu.Unlock()
+
+	if err := s.checkSlotLocked(workspaceID); err != nil {
+		return err
+	}
+	s.activeTotal++
+	s.activeSessions[workspaceID]++
+	return nil
+}
+
+// releaseSlot frees a slot previously reserved by acquireSlot
+func (s *TerminalService) releaseSlot(workspaceID string) {
+	s.slotsMu.Lock()
+	defer s.slotsMu.Unlock()
+
+	if s.activeTotal > 0 {
+		s.activeTotal--
+	}
+	if s.activeSessions[workspaceID] <= 1 {
+		delete(s.activeSessions, workspaceID)
+	} else {
+		s.activeSessions[workspaceID]--
 	}
 }
 
 // CreateSession creates a new terminal session with WebSocket and Docker Exec
-func (s *TerminalService) CreateSession(ctx context.Context, ws *websocket.Conn, containerID string) error {
+// Returns ErrSessionLimitReached (wrapped) if the workspace or global session cap is exceeded.
+func (s *TerminalService) CreateSession(ctx context.Context, ws *websocket.Conn, workspaceID, containerID string, opts TerminalOptions) error {
 	// Generate session ID
 	sessionID := utils.GenerateSessionID()
-	utils.Info("Creating terminal session", "sessionID", sessionID, "containerID", containerID)
+	utils.Info("Creating terminal session", "sessionID", sessionID, "workspaceID", workspaceID, "containerID", containerID, "readOnly", opts.ReadOnly)
+
+	// Reserve a session slot; released on cleanup or if setup fails
+	if err := s.acquireSlot(workspaceID); err != nil {
+		utils.Warn("Terminal session limit reached", "workspaceID", workspaceID, "error", err)
+		return err
+	}
+	established := false
+	defer func() {
+		if !established {
+			s.releaseSlot(workspaceID)
+		}
+	}()
 
 	// Verify container is running
 	status, err := s.dockerSvc.GetContainerStatus(ctx, containerID)
@@ -125,6 +236,7 @@ func (s *TerminalService) CreateSession(ctx context.Context, ws *websocket.Conn,
 	// Create session
 	session := &TerminalSession{
 		ID:           sessionID,
+		WorkspaceID:  workspaceID,
 		ContainerID:  containerID,
 		WebSocket:    ws,
 		ExecID:       execID.ID,
@@ -132,16 +244,21 @@ func (s *TerminalService) CreateSession(ctx context.Context, ws *websocket.Conn,
 		CreatedAt:    time.Now(),
 		CancelFunc:   cancel,
 		Done:         make(chan struct{}),
+		ReadOnly:     opts.ReadOnly,
 	}
 
+	session.lastInput.Store(time.Now().UnixNano())
+
 	// Store session
 	s.sessions.Store(sessionID, session)
+	established = true
 
 	utils.Info("Terminal session created", "sessionID", sessionID, "containerID", containerID)
 
-	// Start bidirectional data transfer
+	// Start bidirectional data transfer and keepalive
 	go s.handleWebSocketToExec(sessionCtx, session, hijackedResp.Conn)
 	go s.handleExecToWebSocket(sessionCtx, session, hijackedResp.Conn)
+	go s.keepAlive(sessionCtx, session)
 
 	// Wait for session to complete
 	<-session.Done
@@ -156,6 +273,14 @@ func (s *TerminalService) handleWebSocketToExec(ctx context.Context, session *Te
 		s.cleanupSession(session)
 	}()
 
+	// A peer that stops answering pings misses the read deadline and ends the session
+	ws := session.WebSocket
+	warnedReadOnly := false
+	_ = ws.SetReadDeadline(time.Now().Add(s.pongWait))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(s.pongWait))
+	})
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -163,22 +288,36 @@ func (s *TerminalService) handleWebSocketToExec(ctx context.Context, session *Te
 		default:
 			// Read message from WebSocket
 			var msg TerminalMessage
-			err := session.WebSocket.ReadJSON(&msg)
+			err := ws.ReadJSON(&msg)
 			if err != nil {
 				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
 					utils.Warn("WebSocket read error", "sessionID", session.ID, "error", err)
 				}
 				return
 			}
+			_ = ws.SetReadDeadline(time.Now().Add(s.pongWait))
 
 			// Handle different message types
 			switch msg.Type {
 			case "input":
+				if session.ReadOnly {
+					// Viewers may watch but not type; tell them once
+					if !warnedReadOnly {
+						warnedReadOnly = true
+						s.sendMessage(session, TerminalMessage{
+							Type: "error",
+							Data: "This terminal is read-only",
+						})
+					}
+					continue
+				}
+				session.lastInput.Store(time.Now().UnixNano())
+
 				// Send input to container
 				_, err := execConn.Write([]byte(msg.Data))
 				if err != nil {
 					utils.Error("Failed to write to exec", "sessionID", session.ID, "error", err)
-					s.sendMessage(session.WebSocket, TerminalMessage{
+					s.sendMessage(session, TerminalMessage{
 						Type: "error",
 						Data: "Failed to send input to container",
 					})
@@ -231,7 +370,7 @@ func (s *TerminalService) handleExecToWebSocket(ctx context.Context, session *Te
 					Data: string(buffer[:n]),
 				}
 
-				err := s.sendMessage(session.WebSocket, msg)
+				err := s.sendMessage(session, msg)
 				if err != nil {
 					utils.Error("Failed to send to WebSocket", "sessionID", session.ID, "error", err)
 					return
@@ -241,6 +380,47 @@ func (s *TerminalService) handleExecToWebSocket(ctx context.Context, session *Te
 	}
 }
 
+// keepAlive pings the WebSocket peer periodically and closes the session when
+// the connection is dead or no input has been received within the idle timeout
+func (s *TerminalService) keepAlive(ctx context.Context, session *TerminalSession) {
+	interval := s.pingPeriod
+	if s.idleTimeout > 0 && s.idleTimeout < interval {
+		interval = s.idleTimeout
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-session.Done:
+			return
+		case <-ticker.C:
+			if s.idleTimeout > 0 {
+				idle := time.Since(time.Unix(0, session.lastInput.Load()))
+				if idle >= s.idleTimeout {
+					utils.Info("Closing idle terminal session", "sessionID", session.ID, "idle", idle.String())
+					s.sendMessage(session, TerminalMessage{
+						Type: "error",
+						Data: "Session closed due to inactivity",
+					})
+					s.cleanupSession(session)
+					return
+				}
+			}
+
+			err := session.WebSocket.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.writeWait))
+			if err != nil {
+				utils.Warn("Failed to ping WebSocket", "sessionID", session.ID, "error", err)
+				s.cleanupSession(session)
+				return
+			}
+		}
+	}
+}
+
 // resizeTerminal resizes the terminal to the specified dimensions
 func (s *TerminalService) resizeTerminal(ctx context.Context, execID string, cols, rows int) error {
 	utils.Debug("Resizing terminal", "execID", execID, "cols", cols, "rows", rows)
@@ -258,14 +438,19 @@ func (s *TerminalService) resizeTerminal(ctx context.Context, execID string, col
 	return nil
 }
 
-// sendMessage sends a message to the WebSocket connection
-func (s *TerminalService) sendMessage(ws *websocket.Conn, msg TerminalMessage) error {
+// sendMessage sends a message to the session's WebSocket connection
+func (s *TerminalService) sendMessage(session *TerminalSession, msg TerminalMessage) error {
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
-	err = ws.WriteMessage(websocket.TextMessage, data)
+	session.writeMu.Lock()
+	defer session.writeMu.Unlock()
+
+	// A stalled peer must not block the writer forever
+	_ = session.WebSocket.SetWriteDeadline(time.Now().Add(s.writeWait))
+	err = session.WebSocket.WriteMessage(websocket.TextMessage, data)
 	if err != nil {
 		return fmt.Errorf("failed to write to websocket: %w", err)
 	}
@@ -276,39 +461,37 @@ func (s *TerminalService) sendMessage(ws *websocket.Conn, msg TerminalMessage) e
 // cleanupSession cleans up a terminal session
 func (s *TerminalService) cleanupSession(session *TerminalSession) {
 	// Use sync.Once to ensure cleanup only happens once
-	select {
-	case <-session.Done:
-		// Already cleaned up
-		return
-	default:
-		close(session.Done)
-	}
+	session.closeOnce.Do(func() {
+		utils.Info("Cleaning up terminal session", "sessionID", session.ID)
 
-	utils.Info("Cleaning up terminal session", "sessionID", session.ID)
+		// Cancel context
+		if session.CancelFunc != nil {
+			session.CancelFunc()
+		}
 
-	// Cancel context
-	if session.CancelFunc != nil {
-		session.CancelFunc()
-	}
+		// Close hijacked connection
+		if session.HijackedConn != nil {
+			session.HijackedConn.Close()
+		}
 
-	// Close hijacked connection
-	if session.HijackedConn != nil {
-		session.HijackedConn.Close()
-	}
+		// Close WebSocket
+		if session.WebSocket != nil {
+			s.sendMessage(session, TerminalMessage{
+				Type: "close",
+				Data: "Session closed",
+			})
+			session.WebSocket.Close()
+		}
 
-	// Close WebSocket
-	if session.WebSocket != nil {
-		s.sendMessage(session.WebSocket, TerminalMessage{
-			Type: "close",
-			Data: "Session closed",
-		})
-		session.WebSocket.Close()
-	}
+		// Remove from sessions map and free the slot
+		s.sessions.Delete(session.ID)
+		s.releaseSlot(session.WorkspaceID)
 
-	// Remove from sessions map
-	s.sessions.Delete(session.ID)
+		// Signal completion only once everything has been released
+		close(session.Done)
 
-	utils.Info("Terminal session cleaned up", "sessionID", session.ID)
+		utils.Info("Terminal session cleaned up", "sessionID", session.ID)
+	})
 }
 
 // CloseSession closes a terminal session by ID
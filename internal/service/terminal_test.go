@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -29,7 +31,7 @@ func TestNewTerminalService(t *testing.T) {
 	}
 	defer dockerSvc.Close()
 
-	terminalSvc := NewTerminalService(dockerSvc)
+	terminalSvc := NewTerminalService(dockerSvc, cfg)
 	if terminalSvc == nil {
 		t.Fatal("Expected terminal service to be created")
 	}
@@ -111,7 +113,7 @@ func TestTerminalSessionCreation(t *testing.T) {
 	}
 	defer dockerSvc.Close()
 
-	_ = NewTerminalService(dockerSvc)
+	_ = NewTerminalService(dockerSvc, cfg)
 	ctx := context.Background()
 
 	// Create a test container
@@ -153,7 +155,7 @@ func TestContainerNotRunning(t *testing.T) {
 	}
 	defer dockerSvc.Close()
 
-	_ = NewTerminalService(dockerSvc)
+	_ = NewTerminalService(dockerSvc, cfg)
 	ctx := context.Background()
 
 	// Create but don't start a container
@@ -195,7 +197,7 @@ func TestGetSessionCount(t *testing.T) {
 	}
 	defer dockerSvc.Close()
 
-	terminalSvc := NewTerminalService(dockerSvc)
+	terminalSvc := NewTerminalService(dockerSvc, cfg)
 
 	// Initially should have 0 sessions
 	count := terminalSvc.GetSessionCount()
@@ -218,7 +220,7 @@ func TestCloseAllSessions(t *testing.T) {
 	}
 	defer dockerSvc.Close()
 
-	terminalSvc := NewTerminalService(dockerSvc)
+	terminalSvc := NewTerminalService(dockerSvc, cfg)
 
 	// Should not panic when closing all sessions with no active sessions
 	terminalSvc.CloseAllSessions()
@@ -244,7 +246,7 @@ func TestWebSocketUpgrade(t *testing.T) {
 	}
 	defer dockerSvc.Close()
 
-	terminalSvc := NewTerminalService(dockerSvc)
+	terminalSvc := NewTerminalService(dockerSvc, cfg)
 	ctx := context.Background()
 
 	// Create and start a container
@@ -283,7 +285,7 @@ func TestWebSocketUpgrade(t *testing.T) {
 
 		// Start terminal session in background
 		go func() {
-			err := terminalSvc.CreateSession(ctx, ws, containerID)
+			err := terminalSvc.CreateSession(ctx, ws, "ws-test", containerID, TerminalOptions{})
 			if err != nil && !strings.Contains(err.Error(), "close") {
 				utils.Warn("Session error", "error", err)
 			}
@@ -344,7 +346,7 @@ func TestResizeTerminal(t *testing.T) {
 	}
 	defer dockerSvc.Close()
 
-	terminalSvc := NewTerminalService(dockerSvc)
+	terminalSvc := NewTerminalService(dockerSvc, cfg)
 	ctx := context.Background()
 
 	// Create and start a container
@@ -388,3 +390,107 @@ func TestResizeTerminal(t *testing.T) {
 		t.Log("Terminal resize successful")
 	}
 }
+
+func TestTerminalSessionLimits(t *testing.T) {
+	cfg := &config.Config{
+		MaxTerminalSessions:             3,
+		MaxTerminalSessionsPerWorkspace: 2,
+	}
+	terminalSvc := NewTerminalService(nil, cfg)
+
+	// Per-workspace cap
+	if err := terminalSvc.acquireSlot("ws-a"); err != nil {
+		t.Fatalf("Expected first slot to be acquired, got %v", err)
+	}
+	if err := terminalSvc.acquireSlot("ws-a"); err != nil {
+		t.Fatalf("Expected second slot to be acquired, got %v", err)
+	}
+	if err := terminalSvc.acquireSlot("ws-a"); !errors.Is(err, ErrSessionLimitReached) {
+		t.Errorf("Expected per-workspace limit error, got %v", err)
+	}
+	if err := terminalSvc.CheckSessionLimit("ws-a"); !errors.Is(err, ErrSessionLimitReached) {
+		t.Errorf("Expected CheckSessionLimit to report the limit, got %v", err)
+	}
+
+	// Global cap
+	if err := terminalSvc.acquireSlot("ws-b"); err != nil {
+		t.Fatalf("Expected slot for another workspace, got %v", err)
+	}
+	if err := terminalSvc.acquireSlot("ws-c"); !errors.Is(err, ErrSessionLimitReached) {
+		t.Errorf("Expected global limit error, got %v", err)
+	}
+
+	// Releasing frees capacity again
+	terminalSvc.releaseSlot("ws-a")
+	if err := terminalSvc.acquireSlot("ws-c"); err != nil {
+		t.Errorf("Expected slot after release, got %v", err)
+	}
+}
+
+func TestTerminalIdleTimeout(t *testing.T) {
+	terminalSvc := NewTerminalService(nil, &config.Config{})
+	terminalSvc.idleTimeout = 200 * time.Millisecond
+	terminalSvc.pingPeriod = 50 * time.Millisecond
+
+	serverConns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Failed to upgrade: %v", err)
+			return
+		}
+		serverConns <- ws
+	}))
+	defer server.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect WebSocket: %v", err)
+	}
+	defer client.Close()
+
+	// The client must keep reading so that pings are answered
+	go func() {
+		for {
+			if _, _, err := client.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	execSide, containerSide := net.Pipe()
+	defer containerSide.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	session := &TerminalSession{
+		ID:           "session-idle",
+		WorkspaceID:  "ws-idle",
+		WebSocket:    <-serverConns,
+		HijackedConn: execSide,
+		CreatedAt:    time.Now(),
+		CancelFunc:   cancel,
+		Done:         make(chan struct{}),
+	}
+	session.lastInput.Store(time.Now().UnixNano())
+	terminalSvc.sessions.Store(session.ID, session)
+	if err := terminalSvc.acquireSlot(session.WorkspaceID); err != nil {
+		t.Fatalf("Failed to acquire slot: %v", err)
+	}
+
+	go terminalSvc.handleWebSocketToExec(ctx, session, execSide)
+	go terminalSvc.keepAlive(ctx, session)
+
+	select {
+	case <-session.Done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected idle session to be closed")
+	}
+
+	if count := terminalSvc.GetSessionCount(); count != 0 {
+		t.Errorf("Expected 0 sessions after idle timeout, got %d", count)
+	}
+	if err := terminalSvc.CheckSessionLimit(session.WorkspaceID); err != nil {
+		t.Errorf("Expected slot to be released, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+const (
+	// defaultTicketTTL applies when no ticket lifetime is configured
+	defaultTicketTTL = time.Minute
+	// maxTicketTTL bounds configured ticket lifetimes; tickets end up in URLs
+	maxTicketTTL = 10 * time.Minute
+	// forwardCookieTTL is the lifetime of the cookie a forward ticket is
+	// exchanged for, so the page it opened can load its assets
+	forwardCookieTTL = time.Hour
+)
+
+var (
+	// ErrTicketInvalid is returned when a ticket is forged, expired or used for another target
+	ErrTicketInvalid = errors.New("invalid or expired ticket")
+	// ErrInvalidTicketRequest is returned when a ticket's target is not acceptable
+	ErrInvalidTicketRequest = errors.New("invalid ticket request")
+)
+
+// ticketPayload is the signed content of a ticket
+type ticketPayload struct {
+	UserID      string            `json:"u"`
+	Kind        domain.TicketKind `json:"k"`
+	WorkspaceID string            `json:"w"`
+	Port        int               `json:"p,omitempty"`
+	ExpiresAt   int64             `json:"e"`
+}
+
+// IssueTicket mints a short-lived ticket that authenticates a user for a single
+// target only. Tickets are HMAC-signed with a key that lives in memory, so they
+// do not survive a restart.
+func (s *AuthService) IssueTicket(userID string, target domain.TicketTarget) (string, time.Time, error) {
+	return s.issueTicket(userID, target, s.ticketTTL)
+}
+
+// IssueForwardCookie mints the ticket kept in a cookie once a forward ticket
+// has been used: bound to the same workspace port, but living long enough for
+// the forwarded page to load its scripts, styles and API requests
+func (s *AuthService) IssueForwardCookie(userID string, target domain.TicketTarget) (string, time.Time, error) {
+	if target.Kind != domain.TicketKindForward {
+		return "", time.Time{}, fmt.Errorf("%w: only forward tickets are kept in cookies", ErrInvalidTicketRequest)
+	}
+	return s.issueTicket(userID, target, forwardCookieTTL)
+}
+
+// issueTicket mints a ticket for a target that expires after ttl
+func (s *AuthService) issueTicket(userID string, target domain.TicketTarget, ttl time.Duration) (string, time.Time, error) {
+	if !target.Kind.IsValid() {
+		return "", time.Time{}, fmt.Errorf("%w: unknown kind %q", ErrInvalidTicketRequest, target.Kind)
+	}
+	if target.WorkspaceID == "" {
+		return "", time.Time{}, fmt.Errorf("%w: workspace_id is required", ErrInvalidTicketRequest)
+	}
+	switch {
+	case target.Kind == domain.TicketKindTerminal && target.Port != 0:
+		return "", time.Time{}, fmt.Errorf("%w: terminal tickets take no port", ErrInvalidTicketRequest)
+	case target.Kind != domain.TicketKindTerminal && (target.Port < 1 || target.Port > 65535):
+		return "", time.Time{}, fmt.Errorf("%w: port must be between 1 and 65535", ErrInvalidTicketRequest)
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	payload, err := json.Marshal(ticketPayload{
+		UserID:      userID,
+		Kind:        target.Kind,
+		WorkspaceID: target.WorkspaceID,
+		Port:        target.Port,
+		ExpiresAt:   expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to encode ticket: %w", err)
+	}
+
+	body := domain.TicketPrefix + base64.RawURLEncoding.EncodeToString(payload)
+	utils.Debug("Issued ticket", "userID", userID, "kind", target.Kind, "workspaceID", target.WorkspaceID, "port", target.Port)
+	return body + "." + base64.RawURLEncoding.EncodeToString(s.signTicket(body)), expiresAt, nil
+}
+
+// AuthenticateTicket verifies a ticket for the given target and returns its user
+func (s *AuthService) AuthenticateTicket(ticket string, target domain.TicketTarget) (*domain.User, error) {
+	body, signature, ok := strings.Cut(ticket, ".")
+	if !ok || !strings.HasPrefix(body, domain.TicketPrefix) {
+		return nil, ErrTicketInvalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.signTicket(body)) {
+		return nil, ErrTicketInvalid
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(body, domain.TicketPrefix))
+	if err != nil {
+		return nil, ErrTicketInvalid
+	}
+	var payload ticketPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, ErrTicketInvalid
+	}
+
+	if !time.Now().Before(time.Unix(payload.ExpiresAt, 0)) {
+		return nil, ErrTicketInvalid
+	}
+	if payload.Kind != target.Kind || payload.WorkspaceID != target.WorkspaceID || payload.Port != target.Port {
+		return nil, ErrTicketInvalid
+	}
+
+	user, err := s.users.Get(payload.UserID)
+	if err != nil {
+		return nil, ErrTicketInvalid
+	}
+	return user, nil
+}
+
+// AllowQueryToken reports whether long-lived credentials are accepted in ?token=
+func (s *AuthService) AllowQueryToken() bool {
+	return s.allowQueryToken
+}
+
+// signTicket returns the HMAC-SHA256 of a ticket body
+func (s *AuthService) signTicket(body string) []byte {
+	mac := hmac.New(sha256.New, s.ticketKey)
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
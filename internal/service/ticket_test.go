@@ -0,0 +1,74 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+)
+
+func TestAuthService_Tickets(t *testing.T) {
+	svc := newTestAuthService(t)
+	admin, _ := svc.tokenUser()
+
+	target := domain.TicketTarget{Kind: domain.TicketKindForward, WorkspaceID: "ws-1", Port: 3000}
+	ticket, expiresAt, err := svc.IssueTicket(admin.ID, target)
+	if err != nil {
+		t.Fatalf("IssueTicket failed: %v", err)
+	}
+	if !strings.HasPrefix(ticket, domain.TicketPrefix) || time.Until(expiresAt) > defaultTicketTTL {
+		t.Errorf("Unexpected ticket %q expiring at %v", ticket, expiresAt)
+	}
+
+	user, err := svc.AuthenticateTicket(ticket, target)
+	if err != nil || user.ID != admin.ID {
+		t.Fatalf("Expected ticket to authenticate as admin, got %+v, %v", user, err)
+	}
+
+	// Bound to its target
+	for _, other := range []domain.TicketTarget{
+		{Kind: domain.TicketKindForward, WorkspaceID: "ws-2", Port: 3000},
+		{Kind: domain.TicketKindForward, WorkspaceID: "ws-1", Port: 3001},
+		{Kind: domain.TicketKindTunnel, WorkspaceID: "ws-1", Port: 3000},
+	} {
+		if _, err := svc.AuthenticateTicket(ticket, other); !errors.Is(err, ErrTicketInvalid) {
+			t.Errorf("Expected ticket to be rejected for %+v, got %v", other, err)
+		}
+	}
+
+	// Tampering breaks the signature
+	body, signature, _ := strings.Cut(ticket, ".")
+	if _, err := svc.AuthenticateTicket(body+"x."+signature, target); !errors.Is(err, ErrTicketInvalid) {
+		t.Errorf("Expected tampered ticket to be rejected, got %v", err)
+	}
+	// Tickets of another process (key) are rejected
+	if _, err := newTestAuthService(t).AuthenticateTicket(ticket, target); !errors.Is(err, ErrTicketInvalid) {
+		t.Errorf("Expected ticket signed with another key to be rejected, got %v", err)
+	}
+
+	// Expired tickets are rejected
+	svc.ticketTTL = -time.Second
+	expired, _, _ := svc.IssueTicket(admin.ID, target)
+	if _, err := svc.AuthenticateTicket(expired, target); !errors.Is(err, ErrTicketInvalid) {
+		t.Errorf("Expected expired ticket to be rejected, got %v", err)
+	}
+
+	// Forward cookies outlive tickets but stay bound to their target
+	svc.ticketTTL = defaultTicketTTL
+	cookie, cookieExpiresAt, err := svc.IssueForwardCookie(admin.ID, target)
+	if err != nil || time.Until(cookieExpiresAt) <= defaultTicketTTL {
+		t.Errorf("Expected a forward cookie outliving tickets, got %v, %v", cookieExpiresAt, err)
+	}
+	if user, err := svc.AuthenticateTicket(cookie, target); err != nil || user.ID != admin.ID {
+		t.Errorf("Expected forward cookie to authenticate as admin, got %+v, %v", user, err)
+	}
+	if _, _, err := svc.IssueForwardCookie(admin.ID, domain.TicketTarget{Kind: domain.TicketKindTerminal, WorkspaceID: "ws-1"}); !errors.Is(err, ErrInvalidTicketRequest) {
+		t.Errorf("Expected terminal forward cookie to be rejected, got %v", err)
+	}
+
+	if _, _, err := svc.IssueTicket(admin.ID, domain.TicketTarget{Kind: domain.TicketKindTerminal, WorkspaceID: "ws-1", Port: 22}); !errors.Is(err, ErrInvalidTicketRequest) {
+		t.Errorf("Expected terminal ticket with a port to be rejected, got %v", err)
+	}
+}
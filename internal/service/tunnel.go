@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/1PercentSync/vibox/pkg/utils"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// tunnelDialTimeout bounds connecting to the container port
+	tunnelDialTimeout = 10 * time.Second
+	// tunnelBufferSize is the largest chunk sent in a single WebSocket message
+	tunnelBufferSize = 32 * 1024
+	// tunnelWriteWait is the time allowed to write a message to the peer
+	tunnelWriteWait = 10 * time.Second
+)
+
+// TunnelService bridges WebSocket connections to raw TCP ports inside workspace containers
+type TunnelService struct {
+	dockerSvc  *DockerService
+	pongWait   time.Duration
+	pingPeriod time.Duration
+}
+
+// NewTunnelService creates a new tunnel service instance
+func NewTunnelService(dockerSvc *DockerService) *TunnelService {
+	utils.Info("Initializing tunnel service")
+	return &TunnelService{
+		dockerSvc:  dockerSvc,
+		pongWait:   terminalPongWait,
+		pingPeriod: terminalPingPeriod,
+	}
+}
+
+// Dial opens a TCP connection to a port of a container
+func (s *TunnelService) Dial(ctx context.Context, containerID string, port int) (net.Conn, error) {
+	containerIP, err := s.dockerSvc.GetContainerIP(ctx, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container IP: %w", err)
+	}
+
+	dialer := net.Dialer{Timeout: tunnelDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(containerIP, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to port %d: %w", port, err)
+	}
+	return conn, nil
+}
+
+// Serve bridges an upgraded WebSocket to a TCP connection until either side closes.
+// The peer is pinged periodically so that dead clients are detected.
+func (s *TunnelService) Serve(ws *websocket.Conn, conn net.Conn) error {
+	ws.SetReadDeadline(time.Now().Add(s.pongWait))
+	ws.SetPongHandler(func(string) error {
+		ws.SetReadDeadline(time.Now().Add(s.pongWait))
+		return nil
+	})
+	return BridgeTunnel(ws, conn, s.pingPeriod)
+}
+
+// BridgeTunnel copies data between a WebSocket and a TCP connection: each binary
+// WebSocket message is written to the TCP connection, and everything read from
+// the TCP connection is sent as binary messages. It returns once either side is
+// closed, closing both. pingPeriod > 0 sends keepalive pings at that interval.
+//
+// It is used on both ends of a tunnel: by the server towards the container port,
+// and by the `vibox tunnel` client towards the local connection.
+func BridgeTunnel(ws *websocket.Conn, conn net.Conn, pingPeriod time.Duration) error {
+	var writeMu sync.Mutex
+	writeMessage := func(messageType int, data []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		ws.SetWriteDeadline(time.Now().Add(tunnelWriteWait))
+		return ws.WriteMessage(messageType, data)
+	}
+
+	done := make(chan struct{})
+	var closeOnce sync.Once
+	shutdown := func() {
+		closeOnce.Do(func() {
+			close(done)
+			conn.Close()
+		})
+	}
+	defer shutdown()
+
+	if pingPeriod > 0 {
+		go func() {
+			ticker := time.NewTicker(pingPeriod)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					writeMu.Lock()
+					err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(tunnelWriteWait))
+					writeMu.Unlock()
+					if err != nil {
+						shutdown()
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	// TCP → WebSocket
+	tcpErr := make(chan error, 1)
+	go func() {
+		defer shutdown()
+		buf := make([]byte, tunnelBufferSize)
+		for {
+			n, err := conn.Read(buf)
+			if n > 0 {
+				if werr := writeMessage(websocket.BinaryMessage, buf[:n]); werr != nil {
+					tcpErr <- werr
+					return
+				}
+			}
+			if err != nil {
+				if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+					// Tell the peer the stream ended normally
+					_ = writeMessage(websocket.CloseMessage,
+						websocket.FormatCloseMessage(websocket.CloseNormalClosure, "connection closed"))
+					tcpErr <- nil
+					return
+				}
+				_ = writeMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "connection error"))
+				tcpErr <- err
+				return
+			}
+		}
+	}()
+
+	// WebSocket → TCP
+	var wsErr error
+	for {
+		messageType, data, err := ws.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				wsErr = err
+			}
+			break
+		}
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+		if _, err := conn.Write(data); err != nil {
+			break
+		}
+	}
+	shutdown()
+
+	if err := <-tcpErr; err != nil && !errors.Is(err, net.ErrClosed) && wsErr == nil {
+		wsErr = err
+	}
+	return wsErr
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// startEchoServer starts a TCP server that echoes everything back
+func startEchoServer(t *testing.T) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener
+}
+
+func TestBridgeTunnel(t *testing.T) {
+	echo := startEchoServer(t)
+	svc := &TunnelService{pongWait: time.Minute, pingPeriod: 50 * time.Millisecond}
+
+	serverDone := make(chan error, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := net.Dial("tcp", echo.Addr().String())
+		if err != nil {
+			t.Errorf("Failed to dial echo server: %v", err)
+			return
+		}
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		defer ws.Close()
+		serverDone <- svc.Serve(ws, conn)
+	}))
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial tunnel: %v", err)
+	}
+	defer ws.Close()
+
+	// Large enough to be split across several messages
+	payload := bytes.Repeat([]byte("vibox-tunnel "), 10000)
+	if err := ws.WriteMessage(websocket.BinaryMessage, payload); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	var received []byte
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for len(received) < len(payload) {
+		messageType, data, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read echo after %d bytes: %v", len(received), err)
+		}
+		if messageType != websocket.BinaryMessage {
+			t.Fatalf("Expected binary message, got type %d", messageType)
+		}
+		received = append(received, data...)
+	}
+	if !bytes.Equal(received, payload) {
+		t.Fatal("Echoed data does not match what was sent")
+	}
+
+	// Closing the WebSocket ends the bridge cleanly
+	ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	select {
+	case err := <-serverDone:
+		if err != nil {
+			t.Errorf("Expected clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Bridge did not finish after the WebSocket was closed")
+	}
+}
+
+func TestBridgeTunnel_TCPClose(t *testing.T) {
+	// A TCP server that sends a greeting and hangs up
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write([]byte("hello"))
+		conn.Close()
+	}()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			return
+		}
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		defer ws.Close()
+		BridgeTunnel(ws, conn, 0)
+	}))
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial tunnel: %v", err)
+	}
+	defer ws.Close()
+
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := ws.ReadMessage()
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("Expected greeting, got %q (%v)", data, err)
+	}
+
+	_, _, err = ws.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("Expected normal close after the TCP side hung up, got %v", err)
+	}
+}
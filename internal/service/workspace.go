@@ -2,9 +2,12 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/1PercentSync/vibox/internal/config"
@@ -15,17 +18,86 @@ import (
 
 // CreateWorkspaceRequest represents a request to create a new workspace
 type CreateWorkspaceRequest struct {
-	Name    string            `json:"name" binding:"required"`
-	Image   string            `json:"image"`
-	Scripts []domain.Script   `json:"scripts,omitempty"`
-	Ports   map[string]string `json:"ports,omitempty"` // Port label mappings
+	Name         string                 `json:"name" binding:"required"`
+	Image        string                 `json:"image"`
+	Scripts      []domain.Script        `json:"scripts,omitempty"`
+	Repositories []domain.GitRepository `json:"repositories,omitempty"`
+	Ports        map[string]string      `json:"ports,omitempty"` // Port label mappings
+	Env          map[string]string      `json:"env,omitempty"`
+	Labels       map[string]string      `json:"labels,omitempty"`
+	MemoryLimit  int64                  `json:"memory_limit,omitempty"` // Bytes; 0 uses the server default
+	CPULimit     int64                  `json:"cpu_limit,omitempty"`    // Nano CPUs; 0 uses the server default
+	Owner        string                 `json:"-"`                      // ID of the creating user, set by the handler
+}
+
+// CloneWorkspaceRequest represents a request to create a workspace from an existing one
+type CloneWorkspaceRequest struct {
+	Name string `json:"name"` // Defaults to "<source name> (copy)"
+	// IncludeFilesystem starts the clone from the current filesystem of the
+	// source container instead of running the initialization scripts again
+	IncludeFilesystem bool   `json:"include_filesystem,omitempty"`
+	Owner             string `json:"-"` // ID of the cloning user, set by the handler
+}
+
+// PortSettingsRequest represents a request to set the access policy of a port
+type PortSettingsRequest struct {
+	Visibility     domain.PortVisibility `json:"visibility,omitempty"`
+	AllowedMethods []string              `json:"allowed_methods,omitempty"`
+	BasicAuth      *BasicAuthRequest     `json:"basic_auth,omitempty"`
+	CORS           *domain.PortCORS      `json:"cors,omitempty"`
+	RateLimit      *domain.PortRateLimit `json:"rate_limit,omitempty"`
+}
+
+// BasicAuthRequest holds basic-auth credentials for a port.
+// An empty password keeps the current password if the username is unchanged.
+type BasicAuthRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password,omitempty"`
+}
+
+// ErrInvalidMember is returned when a workspace member update is not acceptable
+var ErrInvalidMember = errors.New("invalid workspace member")
+
+// ErrPreconditionFailed is returned when a change was made conditional on a
+// workspace version (WithExpectedVersion) and the workspace has moved on
+var ErrPreconditionFailed = errors.New("workspace version does not match")
+
+// ErrWorkspaceConflict is returned when an update kept losing races with
+// concurrent writers
+var ErrWorkspaceConflict = errors.New("workspace is being modified concurrently")
+
+// scriptLogDir is where the output of initialization scripts and repository
+// clones is kept inside workspace containers
+const scriptLogDir = "/var/log/vibox"
+
+// maxUpdateAttempts bounds the retries of an update that loses a race
+const maxUpdateAttempts = 5
+
+// expectedVersionKey is the context key of the version a change requires
+type expectedVersionKey struct{}
+
+// WithExpectedVersion returns a context under which changes to a workspace
+// fail with ErrPreconditionFailed unless it is still at the given version
+// (the API's If-Match header)
+func WithExpectedVersion(ctx context.Context, version int64) context.Context {
+	return context.WithValue(ctx, expectedVersionKey{}, version)
+}
+
+// expectedVersion returns the version set with WithExpectedVersion, if any
+func expectedVersion(ctx context.Context) (int64, bool) {
+	version, ok := ctx.Value(expectedVersionKey{}).(int64)
+	return version, ok
 }
 
 // WorkspaceService handles workspace management operations
 type WorkspaceService struct {
 	dockerSvc *DockerService
+	gitSvc    *GitService
 	repo      repository.WorkspaceRepository
 	config    *config.Config
+
+	listenersMu     sync.RWMutex
+	deleteListeners []func(workspaceID string)
 }
 
 // NewWorkspaceService creates a new workspace service instance
@@ -38,6 +110,12 @@ func NewWorkspaceService(dockerSvc *DockerService, repo repository.WorkspaceRepo
 	}
 }
 
+// SetGitService sets the service that clones the repositories of workspaces.
+// Without it, workspaces with repositories fail to initialize.
+func (s *WorkspaceService) SetGitService(gitSvc *GitService) {
+	s.gitSvc = gitSvc
+}
+
 // CreateWorkspace creates a new workspace with a Docker container
 func (s *WorkspaceService) CreateWorkspace(ctx context.Context, req CreateWorkspaceRequest) (*domain.Workspace, error) {
 	utils.Info("Creating workspace", "name", req.Name)
@@ -61,11 +139,28 @@ func (s *WorkspaceService) CreateWorkspace(ctx context.Context, req CreateWorksp
 		CreatedAt: now,
 		UpdatedAt: now,
 		Config: domain.WorkspaceConfig{
-			Image:   image,
-			Scripts: req.Scripts,
+			Image:        image,
+			Scripts:      req.Scripts,
+			Env:          req.Env,
+			Labels:       req.Labels,
+			Repositories: req.Repositories,
+			MemoryLimit:  req.MemoryLimit,
+			CPULimit:     req.CPULimit,
 		},
 		Ports: req.Ports, // Set port mappings
+		Owner: req.Owner,
+	}
+	if err := validateWorkspaceConfig(workspace.Config); err != nil {
+		return nil, err
+	}
+	if err := validatePorts(workspace.Ports); err != nil {
+		return nil, err
 	}
+	if err := s.checkCredentials(workspace.Owner, workspace.Config.Repositories); err != nil {
+		return nil, err
+	}
+	containerCfg := containerConfig(workspace, image)
+	initial := workspace.Clone()
 
 	// Save workspace to repository with "creating" status
 	err := s.repo.Create(workspace)
@@ -80,11 +175,6 @@ func (s *WorkspaceService) CreateWorkspace(ctx context.Context, req CreateWorksp
 		bgCtx := context.Background()
 
 		// Create Docker container
-		containerCfg := ContainerConfig{
-			Image: image,
-			Name:  fmt.Sprintf("vibox-%s", workspaceID),
-		}
-
 		containerID, err := s.dockerSvc.CreateContainer(bgCtx, containerCfg)
 		if err != nil {
 			utils.Error("Failed to create container", "workspaceID", workspaceID, "error", err)
@@ -93,9 +183,7 @@ func (s *WorkspaceService) CreateWorkspace(ctx context.Context, req CreateWorksp
 		}
 
 		// Update workspace with container ID
-		workspace.ContainerID = containerID
-		workspace.UpdatedAt = time.Now()
-		if err := s.repo.Update(workspace); err != nil {
+		if err := s.setContainerID(workspaceID, containerID); err != nil {
 			utils.Error("Failed to update workspace with container ID", "workspaceID", workspaceID, "error", err)
 			// Try to clean up the container
 			_ = s.dockerSvc.RemoveContainer(bgCtx, containerID)
@@ -111,6 +199,14 @@ func (s *WorkspaceService) CreateWorkspace(ctx context.Context, req CreateWorksp
 			return
 		}
 
+		// Clone repositories before the scripts, which may build them
+		if err := s.cloneRepositories(bgCtx, containerID, initial); err != nil {
+			utils.Error("Repository clone failed", "workspaceID", workspaceID, "error", err)
+			s.updateWorkspaceStatus(workspaceID, domain.StatusError, fmt.Sprintf("Repository clone failed: %v", err))
+			// Keep container running for debugging
+			return
+		}
+
 		// Execute initialization scripts if any
 		if len(req.Scripts) > 0 {
 			utils.Info("Executing initialization scripts", "workspaceID", workspaceID, "scriptCount", len(req.Scripts))
@@ -159,7 +255,7 @@ func (s *WorkspaceService) ListWorkspaces() ([]*domain.Workspace, error) {
 	return workspaces, nil
 }
 
-// DeleteWorkspace deletes a workspace and its container
+// DeleteWorkspace deletes a workspace, its container and its snapshots
 func (s *WorkspaceService) DeleteWorkspace(ctx context.Context, id string) error {
 	utils.Info("Deleting workspace", "id", id)
 
@@ -169,6 +265,9 @@ func (s *WorkspaceService) DeleteWorkspace(ctx context.Context, id string) error
 		utils.Error("Failed to get workspace for deletion", "id", id, "error", err)
 		return fmt.Errorf("workspace not found: %w", err)
 	}
+	if version, ok := expectedVersion(ctx); ok && workspace.Version != version {
+		return fmt.Errorf("%w: current version is %d", ErrPreconditionFailed, workspace.Version)
+	}
 
 	// Delete container if it exists
 	if workspace.ContainerID != "" {
@@ -187,10 +286,33 @@ func (s *WorkspaceService) DeleteWorkspace(ctx context.Context, id string) error
 		return fmt.Errorf("failed to delete workspace: %w", err)
 	}
 
+	// Snapshots are useless without their workspace
+	s.removeSnapshots(ctx, id)
+	s.notifyWorkspaceDeleted(id)
+
 	utils.Info("Workspace deleted successfully", "id", id)
 	return nil
 }
 
+// OnWorkspaceDeleted registers a listener that is called with the workspace ID
+// after a workspace is deleted, so other services can remove what belonged to it
+func (s *WorkspaceService) OnWorkspaceDeleted(listener func(workspaceID string)) {
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+	s.deleteListeners = append(s.deleteListeners, listener)
+}
+
+// notifyWorkspaceDeleted calls the registered workspace deletion listeners
+func (s *WorkspaceService) notifyWorkspaceDeleted(workspaceID string) {
+	s.listenersMu.RLock()
+	listeners := s.deleteListeners
+	s.listenersMu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(workspaceID)
+	}
+}
+
 // sanitizeScriptName removes dangerous characters from script names to prevent path traversal
 func sanitizeScriptName(name string) string {
 	// Only allow alphanumeric, underscore, and hyphen characters
@@ -213,7 +335,7 @@ func (s *WorkspaceService) executeScripts(ctx context.Context, containerID strin
 	utils.Info("Starting script execution", "containerID", utils.ShortID(containerID), "scriptCount", len(sortedScripts))
 
 	// Create log directory in container
-	logDir := "/var/log/vibox"
+	logDir := scriptLogDir
 	_, err := s.dockerSvc.ExecCommand(ctx, containerID, []string{"mkdir", "-p", logDir})
 	if err != nil {
 		utils.Warn("Failed to create log directory", "containerID", utils.ShortID(containerID), "error", err)
@@ -286,19 +408,78 @@ func (s *WorkspaceService) executeScripts(ctx context.Context, containerID strin
 	return nil
 }
 
-// updateWorkspaceStatus updates the status of a workspace
-func (s *WorkspaceService) updateWorkspaceStatus(workspaceID string, status domain.WorkspaceStatus, errorMsg string) {
-	workspace, err := s.repo.Get(workspaceID)
-	if err != nil {
-		utils.Error("Failed to get workspace for status update", "workspaceID", workspaceID, "error", err)
-		return
+// cloneRepositories clones the repositories of a workspace into its new container
+func (s *WorkspaceService) cloneRepositories(ctx context.Context, containerID string, workspace *domain.Workspace) error {
+	if s.gitSvc == nil {
+		if len(workspace.Config.Repositories) > 0 {
+			return errors.New("git support is not configured")
+		}
+		return nil
 	}
+	return s.gitSvc.CloneRepositories(ctx, containerID, workspace)
+}
 
-	workspace.Status = status
-	workspace.Error = errorMsg
-	workspace.UpdatedAt = time.Now()
+// checkCredentials verifies the git credentials of repositories belong to owner
+func (s *WorkspaceService) checkCredentials(owner string, repositories []domain.GitRepository) error {
+	if s.gitSvc == nil {
+		return nil
+	}
+	return s.gitSvc.CheckCredentials(owner, repositories)
+}
+
+// modifyWorkspace applies change to the current state of a workspace and saves
+// it. When another writer saved the workspace in between, it starts over from
+// the new state, unless the context requires a version (WithExpectedVersion).
+func (s *WorkspaceService) modifyWorkspace(ctx context.Context, id string, change func(ws *domain.Workspace) error) (*domain.Workspace, error) {
+	version, pinned := expectedVersion(ctx)
+
+	for attempt := 1; ; attempt++ {
+		workspace, err := s.repo.Get(id)
+		if err != nil {
+			return nil, fmt.Errorf("workspace not found: %w", err)
+		}
+		if pinned && workspace.Version != version {
+			return nil, fmt.Errorf("%w: current version is %d", ErrPreconditionFailed, workspace.Version)
+		}
+
+		if err := change(workspace); err != nil {
+			return nil, err
+		}
+		workspace.UpdatedAt = time.Now()
 
-	err = s.repo.Update(workspace)
+		err = s.repo.Update(workspace)
+		if err == nil {
+			return workspace, nil
+		}
+		if !errors.Is(err, repository.ErrVersionConflict) {
+			return nil, fmt.Errorf("failed to update workspace: %w", err)
+		}
+		if pinned {
+			return nil, fmt.Errorf("%w: %v", ErrPreconditionFailed, err)
+		}
+		if attempt == maxUpdateAttempts {
+			return nil, fmt.Errorf("%w: %v", ErrWorkspaceConflict, err)
+		}
+		utils.Debug("Retrying workspace update after concurrent change", "id", id, "attempt", attempt)
+	}
+}
+
+// setContainerID records the container created for a workspace
+func (s *WorkspaceService) setContainerID(workspaceID, containerID string) error {
+	_, err := s.modifyWorkspace(context.Background(), workspaceID, func(ws *domain.Workspace) error {
+		ws.ContainerID = containerID
+		return nil
+	})
+	return err
+}
+
+// updateWorkspaceStatus updates the status of a workspace
+func (s *WorkspaceService) updateWorkspaceStatus(workspaceID string, status domain.WorkspaceStatus, errorMsg string) {
+	_, err := s.modifyWorkspace(context.Background(), workspaceID, func(ws *domain.Workspace) error {
+		ws.Status = status
+		ws.Error = errorMsg
+		return nil
+	})
 	if err != nil {
 		utils.Error("Failed to update workspace status", "workspaceID", workspaceID, "status", status, "error", err)
 	} else {
@@ -310,55 +491,158 @@ func (s *WorkspaceService) updateWorkspaceStatus(workspaceID string, status doma
 func (s *WorkspaceService) UpdatePorts(ctx context.Context, id string, ports map[string]string) error {
 	utils.Info("Updating ports for workspace", "id", id)
 
-	workspace, err := s.repo.Get(id)
+	_, err := s.modifyWorkspace(ctx, id, func(ws *domain.Workspace) error {
+		ws.Ports = ports
+		return nil
+	})
 	if err != nil {
-		utils.Error("Failed to get workspace for port update", "id", id, "error", err)
-		return fmt.Errorf("workspace not found: %w", err)
+		utils.Error("Failed to update workspace ports", "id", id, "error", err)
+		return err
 	}
 
-	workspace.Ports = ports
-	workspace.UpdatedAt = time.Now()
+	utils.Info("Workspace ports updated successfully", "id", id)
+	return nil
+}
+
+// UpdatePortSettings sets the access policy of a workspace port (nil removes it,
+// making the port private again)
+func (s *WorkspaceService) UpdatePortSettings(ctx context.Context, id string, port int, req *PortSettingsRequest) (*domain.Workspace, error) {
+	utils.Info("Updating port settings for workspace", "id", id, "port", port)
+
+	key := strconv.Itoa(port)
+	workspace, err := s.modifyWorkspace(ctx, id, func(ws *domain.Workspace) error {
+		settings := make(map[string]domain.PortSettings, len(ws.PortSettings)+1)
+		for k, v := range ws.PortSettings {
+			settings[k] = v
+		}
+
+		if req == nil {
+			delete(settings, key)
+		} else {
+			current := settings[key]
+			updated, err := buildPortSettings(req, current.BasicAuth)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidPortSettings, err)
+			}
+			settings[key] = *updated
+		}
+		if len(settings) == 0 {
+			settings = nil
+		}
 
-	err = s.repo.Update(workspace)
+		ws.PortSettings = settings
+		return nil
+	})
 	if err != nil {
-		utils.Error("Failed to update workspace ports", "id", id, "error", err)
-		return fmt.Errorf("failed to update workspace: %w", err)
+		utils.Error("Failed to update workspace port settings", "id", id, "error", err)
+		return nil, err
 	}
 
-	utils.Info("Workspace ports updated successfully", "id", id)
-	return nil
+	utils.Info("Workspace port settings updated successfully", "id", id, "port", port)
+	return workspace, nil
 }
 
-// ResetWorkspace resets a workspace to its initial state
-func (s *WorkspaceService) ResetWorkspace(ctx context.Context, id string) error {
-	utils.Info("Resetting workspace", "id", id)
+// SetMember grants a user a role on a workspace, replacing any previous role
+func (s *WorkspaceService) SetMember(ctx context.Context, id, userID string, role domain.WorkspaceRole) (*domain.Workspace, error) {
+	utils.Info("Setting workspace member", "id", id, "userID", userID, "role", role)
 
-	workspace, err := s.repo.Get(id)
+	if !role.IsValid() {
+		return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidMember, role)
+	}
+
+	workspace, err := s.modifyWorkspace(ctx, id, func(ws *domain.Workspace) error {
+		if userID == ws.Owner {
+			return fmt.Errorf("%w: user already owns the workspace", ErrInvalidMember)
+		}
+
+		members := make([]domain.WorkspaceMember, 0, len(ws.Members)+1)
+		for _, member := range ws.Members {
+			if member.UserID != userID {
+				members = append(members, member)
+			}
+		}
+		ws.Members = append(members, domain.WorkspaceMember{UserID: userID, Role: role})
+		return nil
+	})
 	if err != nil {
-		utils.Error("Failed to get workspace for reset", "id", id, "error", err)
-		return fmt.Errorf("workspace not found: %w", err)
+		utils.Error("Failed to update workspace members", "id", id, "error", err)
+		return nil, err
 	}
 
-	// 1. Delete old container (if exists)
-	if workspace.ContainerID != "" {
-		utils.Info("Stopping and removing old container", "workspaceID", id, "containerID", utils.ShortID(workspace.ContainerID))
+	utils.Info("Workspace member set successfully", "id", id, "userID", userID)
+	return workspace, nil
+}
 
-		// Stop container (ignore errors if already stopped)
-		_ = s.dockerSvc.StopContainer(ctx, workspace.ContainerID, 10)
+// RemoveMember revokes a user's access to a workspace
+func (s *WorkspaceService) RemoveMember(ctx context.Context, id, userID string) (*domain.Workspace, error) {
+	utils.Info("Removing workspace member", "id", id, "userID", userID)
 
-		// Remove container (ignore errors if already removed)
-		_ = s.dockerSvc.RemoveContainer(ctx, workspace.ContainerID)
+	workspace, err := s.modifyWorkspace(ctx, id, func(ws *domain.Workspace) error {
+		members := make([]domain.WorkspaceMember, 0, len(ws.Members))
+		for _, member := range ws.Members {
+			if member.UserID != userID {
+				members = append(members, member)
+			}
+		}
+		if len(members) == len(ws.Members) {
+			return fmt.Errorf("%w: user is not a member", ErrInvalidMember)
+		}
+		if len(members) == 0 {
+			members = nil
+		}
+
+		ws.Members = members
+		return nil
+	})
+	if err != nil {
+		utils.Error("Failed to update workspace members", "id", id, "error", err)
+		return nil, err
 	}
 
-	// 2. Reset workspace state
-	workspace.ContainerID = ""
-	workspace.Status = domain.StatusCreating
-	workspace.Error = ""
-	workspace.UpdatedAt = time.Now()
+	utils.Info("Workspace member removed successfully", "id", id, "userID", userID)
+	return workspace, nil
+}
+
+// PortVisibility returns the visibility of a workspace port (private if the
+// workspace or settings do not exist)
+func (s *WorkspaceService) PortVisibility(workspaceID string, port int) domain.PortVisibility {
+	workspace, err := s.repo.Get(workspaceID)
+	if err != nil {
+		return domain.PortVisibilityPrivate
+	}
+	return workspace.PortSettingsFor(port).EffectiveVisibility()
+}
+
+// ResetWorkspace resets a workspace to its initial state: a new container from
+// Config.Image, even if the workspace was restored from a snapshot
+func (s *WorkspaceService) ResetWorkspace(ctx context.Context, id string) error {
+	utils.Info("Resetting workspace", "id", id)
 
-	if err := s.repo.Update(workspace); err != nil {
+	// 1. Reset workspace state, detaching the old container
+	var oldContainerID string
+	workspace, err := s.modifyWorkspace(ctx, id, func(ws *domain.Workspace) error {
+		oldContainerID = ws.ContainerID
+		ws.ContainerID = ""
+		ws.Status = domain.StatusCreating
+		ws.Error = ""
+		ws.Snapshot = ""
+		ws.PendingChanges = nil
+		return nil
+	})
+	if err != nil {
 		utils.Error("Failed to update workspace state", "workspaceID", id, "error", err)
-		return fmt.Errorf("failed to update workspace: %w", err)
+		return err
+	}
+
+	// 2. Delete old container (if exists)
+	if oldContainerID != "" {
+		utils.Info("Stopping and removing old container", "workspaceID", id, "containerID", utils.ShortID(oldContainerID))
+
+		// Stop container (ignore errors if already stopped)
+		_ = s.dockerSvc.StopContainer(ctx, oldContainerID, 10)
+
+		// Remove container (ignore errors if already removed)
+		_ = s.dockerSvc.RemoveContainer(ctx, oldContainerID)
 	}
 
 	// 3. Recreate container in background
@@ -366,10 +650,7 @@ func (s *WorkspaceService) ResetWorkspace(ctx context.Context, id string) error
 		bgCtx := context.Background()
 
 		// Create Docker container
-		containerCfg := ContainerConfig{
-			Image: workspace.Config.Image,
-			Name:  fmt.Sprintf("vibox-%s", workspace.ID),
-		}
+		containerCfg := containerConfig(workspace, workspace.Config.Image)
 
 		containerID, err := s.dockerSvc.CreateContainer(bgCtx, containerCfg)
 		if err != nil {
@@ -379,9 +660,7 @@ func (s *WorkspaceService) ResetWorkspace(ctx context.Context, id string) error
 		}
 
 		// Update workspace with new container ID
-		workspace.ContainerID = containerID
-		workspace.UpdatedAt = time.Now()
-		if err := s.repo.Update(workspace); err != nil {
+		if err := s.setContainerID(id, containerID); err != nil {
 			utils.Error("Failed to update workspace with new container ID", "workspaceID", id, "error", err)
 			_ = s.dockerSvc.RemoveContainer(bgCtx, containerID)
 			s.updateWorkspaceStatus(id, domain.StatusFailed, fmt.Sprintf("Failed to update workspace: %v", err))
@@ -396,6 +675,13 @@ func (s *WorkspaceService) ResetWorkspace(ctx context.Context, id string) error
 			return
 		}
 
+		// Clone repositories that are missing from the new container
+		if err := s.cloneRepositories(bgCtx, containerID, workspace); err != nil {
+			utils.Error("Repository clone failed during reset", "workspaceID", id, "error", err)
+			s.updateWorkspaceStatus(id, domain.StatusError, fmt.Sprintf("Repository clone failed: %v", err))
+			return
+		}
+
 		// Execute initialization scripts if any
 		if len(workspace.Config.Scripts) > 0 {
 			utils.Info("Executing initialization scripts after reset", "workspaceID", id, "scriptCount", len(workspace.Config.Scripts))
@@ -415,6 +701,24 @@ func (s *WorkspaceService) ResetWorkspace(ctx context.Context, id string) error
 	return nil
 }
 
+// containerConfig returns the configuration of a workspace container created from image
+func containerConfig(workspace *domain.Workspace, image string) ContainerConfig {
+	env := make([]string, 0, len(workspace.Config.Env))
+	for key, value := range workspace.Config.Env {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+
+	return ContainerConfig{
+		Image:       image,
+		Name:        fmt.Sprintf("vibox-%s", workspace.ID),
+		Env:         env,
+		Labels:      workspace.Config.Labels,
+		MemoryLimit: workspace.Config.MemoryLimit,
+		CPULimit:    workspace.Config.CPULimit,
+	}
+}
+
 // RestoreWorkspaces restores all workspaces on startup
 func (s *WorkspaceService) RestoreWorkspaces(ctx context.Context) error {
 	utils.Info("Restoring workspaces on startup")
@@ -438,10 +742,11 @@ func (s *WorkspaceService) RestoreWorkspaces(ctx context.Context) error {
 	for _, ws := range workspaces {
 		utils.Info("Restoring workspace", "id", ws.ID, "name", ws.Name)
 
-		// Clear runtime fields
+		// Clear runtime fields; the new container has the current configuration
 		ws.ContainerID = ""
 		ws.Status = domain.StatusCreating
 		ws.Error = ""
+		ws.PendingChanges = snapshotPendingChanges(ws)
 		ws.UpdatedAt = time.Now()
 
 		// Save cleared state
@@ -451,61 +756,163 @@ func (s *WorkspaceService) RestoreWorkspaces(ctx context.Context) error {
 		}
 
 		// Recreate container in background
-		go func(workspace *domain.Workspace) {
-			bgCtx := context.Background()
+		go s.provisionWorkspace(ws)
+	}
 
-			// Create Docker container
-			containerCfg := ContainerConfig{
-				Image: workspace.Config.Image,
-				Name:  fmt.Sprintf("vibox-%s", workspace.ID),
-			}
+	utils.Info("Workspace restoration initiated", "count", len(workspaces))
+	return nil
+}
 
-			containerID, err := s.dockerSvc.CreateContainer(bgCtx, containerCfg)
-			if err != nil {
-				utils.Error("Failed to create container during restoration", "workspaceID", workspace.ID, "error", err)
-				s.updateWorkspaceStatus(workspace.ID, domain.StatusFailed, fmt.Sprintf("Failed to create container: %v", err))
-				return
-			}
+// provisionWorkspace creates and starts the container of a workspace stored
+// without one and runs its initialization scripts, recording the outcome in
+// the workspace status. It blocks; callers run it in the background.
+func (s *WorkspaceService) provisionWorkspace(workspace *domain.Workspace) {
+	bgCtx := context.Background()
+
+	// A workspace restored from a snapshot starts from its image; the effects
+	// of the initialization scripts are already part of it
+	image, scripts := workspace.Config.Image, workspace.Config.Scripts
+	if workspace.Snapshot != "" {
+		image, scripts = domain.SnapshotImage(workspace.ID, workspace.Snapshot), nil
+	}
 
-			// Update workspace with container ID
-			workspace.ContainerID = containerID
-			workspace.UpdatedAt = time.Now()
-			if err := s.repo.Update(workspace); err != nil {
-				utils.Error("Failed to update workspace with container ID during restoration", "workspaceID", workspace.ID, "error", err)
-				_ = s.dockerSvc.RemoveContainer(bgCtx, containerID)
-				s.updateWorkspaceStatus(workspace.ID, domain.StatusError, fmt.Sprintf("Failed to update workspace: %v", err))
-				return
-			}
+	// Create Docker container
+	containerCfg := containerConfig(workspace, image)
 
-			// Start container
-			err = s.dockerSvc.StartContainer(bgCtx, containerID)
-			if err != nil {
-				utils.Error("Failed to start container during restoration", "workspaceID", workspace.ID, "containerID", utils.ShortID(containerID), "error", err)
-				s.updateWorkspaceStatus(workspace.ID, domain.StatusFailed, fmt.Sprintf("Failed to start container: %v", err))
-				return
-			}
+	containerID, err := s.dockerSvc.CreateContainer(bgCtx, containerCfg)
+	if err != nil {
+		utils.Error("Failed to create container during provisioning", "workspaceID", workspace.ID, "error", err)
+		s.updateWorkspaceStatus(workspace.ID, domain.StatusFailed, fmt.Sprintf("Failed to create container: %v", err))
+		return
+	}
 
-			// Execute initialization scripts
-			if len(workspace.Config.Scripts) > 0 {
-				utils.Info("Executing initialization scripts during restoration", "workspaceID", workspace.ID, "scriptCount", len(workspace.Config.Scripts))
-				err = s.executeScripts(bgCtx, containerID, workspace.Config.Scripts)
-				if err != nil {
-					utils.Error("Script execution failed during restoration", "workspaceID", workspace.ID, "error", err)
-					s.updateWorkspaceStatus(workspace.ID, domain.StatusError, fmt.Sprintf("Script execution failed: %v", err))
-					return
-				}
-			}
+	// Update workspace with container ID
+	if err := s.setContainerID(workspace.ID, containerID); err != nil {
+		utils.Error("Failed to update workspace with container ID during provisioning", "workspaceID", workspace.ID, "error", err)
+		_ = s.dockerSvc.RemoveContainer(bgCtx, containerID)
+		s.updateWorkspaceStatus(workspace.ID, domain.StatusError, fmt.Sprintf("Failed to update workspace: %v", err))
+		return
+	}
+
+	// Start container
+	err = s.dockerSvc.StartContainer(bgCtx, containerID)
+	if err != nil {
+		utils.Error("Failed to start container during provisioning", "workspaceID", workspace.ID, "containerID", utils.ShortID(containerID), "error", err)
+		s.updateWorkspaceStatus(workspace.ID, domain.StatusFailed, fmt.Sprintf("Failed to start container: %v", err))
+		return
+	}
 
-			// Update status to running
-			utils.Info("Workspace restored successfully", "workspaceID", workspace.ID)
-			s.updateWorkspaceStatus(workspace.ID, domain.StatusRunning, "")
-		}(ws)
+	// Clone repositories; those already in a snapshot image are skipped
+	if err := s.cloneRepositories(bgCtx, containerID, workspace); err != nil {
+		utils.Error("Repository clone failed during provisioning", "workspaceID", workspace.ID, "error", err)
+		s.updateWorkspaceStatus(workspace.ID, domain.StatusError, fmt.Sprintf("Repository clone failed: %v", err))
+		return
 	}
 
-	utils.Info("Workspace restoration initiated", "count", len(workspaces))
+	// Execute initialization scripts
+	if len(scripts) > 0 {
+		utils.Info("Executing initialization scripts during provisioning", "workspaceID", workspace.ID, "scriptCount", len(scripts))
+		err = s.executeScripts(bgCtx, containerID, scripts)
+		if err != nil {
+			utils.Error("Script execution failed during provisioning", "workspaceID", workspace.ID, "error", err)
+			s.updateWorkspaceStatus(workspace.ID, domain.StatusError, fmt.Sprintf("Script execution failed: %v", err))
+			return
+		}
+	}
+
+	// Update status to running
+	utils.Info("Workspace provisioned successfully", "workspaceID", workspace.ID)
+	s.updateWorkspaceStatus(workspace.ID, domain.StatusRunning, "")
+}
+
+// ImportWorkspace adds a workspace from a backup or export, keeping its ID and
+// settings, and provisions a new container for it in the background
+func (s *WorkspaceService) ImportWorkspace(ctx context.Context, workspace *domain.Workspace) error {
+	utils.Info("Importing workspace", "id", workspace.ID, "name", workspace.Name)
+
+	// Clear runtime fields; the new container has the current configuration
+	workspace.ContainerID = ""
+	workspace.Status = domain.StatusCreating
+	workspace.Error = ""
+	workspace.PendingChanges = snapshotPendingChanges(workspace)
+	workspace.UpdatedAt = time.Now()
+
+	if err := s.repo.Create(workspace); err != nil {
+		utils.Error("Failed to save imported workspace", "id", workspace.ID, "error", err)
+		return fmt.Errorf("failed to save workspace: %w", err)
+	}
+
+	go s.provisionWorkspace(workspace.Clone())
 	return nil
 }
 
+// cloneSnapshotName is the snapshot holding the filesystem a clone started from
+const cloneSnapshotName = "clone"
+
+// CloneWorkspace creates a workspace with a new ID from an existing one: the
+// same image, scripts and port labels, owned by the cloning user. Port access
+// policies and members are not copied. With IncludeFilesystem the source
+// container is committed into a snapshot of the clone, which the clone starts
+// from (resetting the clone goes back to the image and scripts). Git
+// credentials are personal, so a clone owned by another user clones its
+// repositories without them.
+func (s *WorkspaceService) CloneWorkspace(ctx context.Context, id string, req CloneWorkspaceRequest) (*domain.Workspace, error) {
+	utils.Info("Cloning workspace", "sourceID", id, "includeFilesystem", req.IncludeFilesystem)
+
+	source, err := s.GetWorkspace(id)
+	if err != nil {
+		return nil, err
+	}
+	if req.IncludeFilesystem && source.ContainerID == "" {
+		return nil, ErrWorkspaceNotReady
+	}
+
+	name := req.Name
+	if name == "" {
+		name = source.Name + " (copy)"
+	}
+
+	config := source.Clone().Config
+	if req.Owner != source.Owner {
+		for i := range config.Repositories {
+			config.Repositories[i].Credential = ""
+		}
+	}
+
+	now := time.Now()
+	workspace := &domain.Workspace{
+		ID:        utils.GenerateID(),
+		Name:      name,
+		Status:    domain.StatusCreating,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Config:    config,
+		Ports:     source.Ports,
+		Owner:     req.Owner,
+	}
+
+	if req.IncludeFilesystem {
+		ref := domain.SnapshotImage(workspace.ID, cloneSnapshotName)
+		labels := map[string]string{snapshotWorkspaceLabel: workspace.ID}
+		if _, err := s.dockerSvc.CommitContainer(ctx, source.ContainerID, ref, labels); err != nil {
+			return nil, fmt.Errorf("failed to copy filesystem: %w", err)
+		}
+		workspace.Snapshot = cloneSnapshotName
+	}
+
+	if err := s.repo.Create(workspace); err != nil {
+		utils.Error("Failed to save cloned workspace", "id", workspace.ID, "error", err)
+		if req.IncludeFilesystem {
+			s.removeSnapshots(ctx, workspace.ID)
+		}
+		return nil, fmt.Errorf("failed to save workspace: %w", err)
+	}
+
+	utils.Info("Workspace cloned", "sourceID", id, "id", workspace.ID)
+	go s.provisionWorkspace(workspace.Clone())
+	return workspace, nil
+}
+
 // CleanupContainers removes all ViBox workspace containers
 func (s *WorkspaceService) CleanupContainers(ctx context.Context) error {
 	utils.Info("Cleaning up ViBox workspace containers")
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"testing"
 	"time"
 
@@ -11,6 +13,16 @@ import (
 	"github.com/1PercentSync/vibox/pkg/utils"
 )
 
+// newTestWorkspaceRepository returns an empty workspace repository in a temporary directory
+func newTestWorkspaceRepository(t *testing.T) *repository.FileRepository {
+	t.Helper()
+	repo, err := repository.NewWorkspaceRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewWorkspaceRepository failed: %v", err)
+	}
+	return repo
+}
+
 func TestNewWorkspaceService(t *testing.T) {
 	cfg := &config.Config{
 		DockerHost:   "unix:///var/run/docker.sock",
@@ -479,3 +491,164 @@ func TestScriptOrdering(t *testing.T) {
 		t.Errorf("Expected output:\n%s\nGot:\n%s", expected, output)
 	}
 }
+
+func TestCloneWorkspace_Validation(t *testing.T) {
+	repo := newTestWorkspaceRepository(t)
+	workspaceSvc := NewWorkspaceService(nil, repo, &config.Config{})
+
+	if err := repo.Create(&domain.Workspace{ID: "ws-source01", Name: "source", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := workspaceSvc.CloneWorkspace(ctx, "ws-missing0", CloneWorkspaceRequest{}); err == nil {
+		t.Error("Expected error cloning non-existent workspace")
+	}
+	// Without a container there is no filesystem to copy
+	if _, err := workspaceSvc.CloneWorkspace(ctx, "ws-source01", CloneWorkspaceRequest{IncludeFilesystem: true}); !errors.Is(err, ErrWorkspaceNotReady) {
+		t.Errorf("Expected ErrWorkspaceNotReady, got %v", err)
+	}
+	if list, _ := repo.List(); len(list) != 1 {
+		t.Errorf("Expected no clone to be stored, got %d workspaces", len(list))
+	}
+}
+
+func TestCloneWorkspace(t *testing.T) {
+	cfg := &config.Config{
+		DockerHost:   "unix:///var/run/docker.sock",
+		DefaultImage: "alpine:latest",
+	}
+
+	dockerSvc, err := NewDockerService(cfg)
+	if err != nil {
+		t.Skipf("Docker not available: %v", err)
+	}
+	defer dockerSvc.Close()
+
+	repo := newTestWorkspaceRepository(t)
+	workspaceSvc := NewWorkspaceService(dockerSvc, repo, cfg)
+
+	ctx := context.Background()
+	source, err := workspaceSvc.CreateWorkspace(ctx, CreateWorkspaceRequest{
+		Name:  "test-clone-source",
+		Image: "alpine:latest",
+		Ports: map[string]string{"8080": "web"},
+		Owner: "user-1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+	defer func() { _ = workspaceSvc.DeleteWorkspace(ctx, source.ID) }()
+
+	// Wait for background operation to complete
+	time.Sleep(3 * time.Second)
+
+	current, err := repo.Get(source.ID)
+	if err != nil || current.ContainerID == "" {
+		t.Fatalf("Workspace not ready: %+v, %v", current, err)
+	}
+	if _, err := dockerSvc.ExecCommand(ctx, current.ContainerID, []string{"sh", "-c", "echo work > /marker"}); err != nil {
+		t.Fatalf("Failed to change workspace: %v", err)
+	}
+
+	clone, err := workspaceSvc.CloneWorkspace(ctx, source.ID, CloneWorkspaceRequest{IncludeFilesystem: true, Owner: "user-2"})
+	if err != nil {
+		t.Fatalf("CloneWorkspace failed: %v", err)
+	}
+	defer func() { _ = workspaceSvc.DeleteWorkspace(ctx, clone.ID) }()
+
+	if clone.ID == source.ID || clone.Name != "test-clone-source (copy)" || clone.Owner != "user-2" {
+		t.Errorf("Unexpected clone %+v", clone)
+	}
+	if clone.Ports["8080"] != "web" || clone.Config.Image != "alpine:latest" {
+		t.Errorf("Expected clone to keep config and ports, got %+v", clone)
+	}
+
+	time.Sleep(3 * time.Second)
+
+	cloned, err := repo.Get(clone.ID)
+	if err != nil || cloned.ContainerID == "" {
+		t.Fatalf("Clone not ready: %+v, %v", cloned, err)
+	}
+	if output, err := dockerSvc.ExecCommand(ctx, cloned.ContainerID, []string{"cat", "/marker"}); err != nil || output != "work\n" {
+		t.Errorf("Expected clone to start from the source filesystem, got %q, %v", output, err)
+	}
+}
+
+func TestUpdateWorkspace(t *testing.T) {
+	repo := newTestWorkspaceRepository(t)
+	workspaceSvc := NewWorkspaceService(nil, repo, &config.Config{})
+	if err := repo.Create(&domain.Workspace{
+		ID:        "ws-update1",
+		Name:      "before",
+		CreatedAt: time.Now(),
+		Config:    domain.WorkspaceConfig{Image: "alpine:latest"},
+	}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	ctx := context.Background()
+	name, memory := "after", int64(1<<30)
+	ports := map[string]string{"8080": "web"}
+	updated, err := workspaceSvc.UpdateWorkspace(ctx, "ws-update1", UpdateWorkspaceRequest{
+		Name:        &name,
+		Ports:       &ports,
+		MemoryLimit: &memory,
+	})
+	if err != nil {
+		t.Fatalf("UpdateWorkspace failed: %v", err)
+	}
+	// Live changes leave nothing pending
+	if updated.Name != "after" || updated.Ports["8080"] != "web" || updated.Config.MemoryLimit != memory {
+		t.Errorf("Unexpected workspace %+v", updated)
+	}
+	if len(updated.PendingChanges) != 0 {
+		t.Errorf("Expected no pending changes, got %v", updated.PendingChanges)
+	}
+
+	image := "ubuntu:24.04"
+	env := map[string]string{"FOO": "bar"}
+	updated, err = workspaceSvc.UpdateWorkspace(ctx, "ws-update1", UpdateWorkspaceRequest{Image: &image, Env: &env})
+	if err != nil {
+		t.Fatalf("UpdateWorkspace failed: %v", err)
+	}
+	if want := []string{domain.PendingEnv, domain.PendingImage}; !slices.Equal(updated.PendingChanges, want) {
+		t.Errorf("Expected pending changes %v, got %v", want, updated.PendingChanges)
+	}
+
+	// Setting the same value again is not a change
+	updated, err = workspaceSvc.UpdateWorkspace(ctx, "ws-update1", UpdateWorkspaceRequest{Image: &image})
+	if err != nil || len(updated.PendingChanges) != 2 {
+		t.Errorf("Expected pending changes to be kept, got %v, %v", updated.PendingChanges, err)
+	}
+
+	// Invalid settings are rejected as a whole
+	labels := map[string]string{"vibox.workspace": "false"}
+	if _, err := workspaceSvc.UpdateWorkspace(ctx, "ws-update1", UpdateWorkspaceRequest{Name: &name, Labels: &labels}); !errors.Is(err, ErrInvalidWorkspaceConfig) {
+		t.Errorf("Expected ErrInvalidWorkspaceConfig for reserved label, got %v", err)
+	}
+	badPorts := map[string]string{"http": "web"}
+	if _, err := workspaceSvc.UpdateWorkspace(ctx, "ws-update1", UpdateWorkspaceRequest{Ports: &badPorts}); !errors.Is(err, ErrInvalidWorkspaceConfig) {
+		t.Errorf("Expected ErrInvalidWorkspaceConfig for invalid port, got %v", err)
+	}
+	tiny := int64(1024)
+	if _, err := workspaceSvc.UpdateWorkspace(ctx, "ws-update1", UpdateWorkspaceRequest{MemoryLimit: &tiny}); !errors.Is(err, ErrInvalidWorkspaceConfig) {
+		t.Errorf("Expected ErrInvalidWorkspaceConfig for tiny memory limit, got %v", err)
+	}
+	if current, _ := repo.Get("ws-update1"); current.Config.Labels != nil || current.Config.MemoryLimit != memory {
+		t.Errorf("Expected rejected updates not to be applied, got %+v", current.Config)
+	}
+}
+
+func TestApplyChanges_NothingPending(t *testing.T) {
+	repo := newTestWorkspaceRepository(t)
+	workspaceSvc := NewWorkspaceService(nil, repo, &config.Config{})
+	if err := repo.Create(&domain.Workspace{ID: "ws-apply01", Name: "apply", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	applied, err := workspaceSvc.ApplyChanges(context.Background(), "ws-apply01")
+	if err != nil || applied {
+		t.Errorf("Expected nothing to apply, got %v, %v", applied, err)
+	}
+}
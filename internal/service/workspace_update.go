@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/1PercentSync/vibox/internal/domain"
+	"github.com/1PercentSync/vibox/pkg/utils"
+)
+
+// ErrInvalidWorkspaceConfig is returned for workspace settings that cannot be applied
+var ErrInvalidWorkspaceConfig = errors.New("invalid workspace configuration")
+
+// minMemoryLimit is the smallest memory limit Docker accepts
+const minMemoryLimit = 6 * 1024 * 1024
+
+// UpdateWorkspaceRequest represents a partial update of a workspace (PATCH).
+// Omitted fields are left unchanged; maps and lists replace the current ones.
+type UpdateWorkspaceRequest struct {
+	// Applied live
+	Name        *string            `json:"name,omitempty"`
+	Ports       *map[string]string `json:"ports,omitempty"`
+	MemoryLimit *int64             `json:"memory_limit,omitempty"` // 0 restores the server default
+	CPULimit    *int64             `json:"cpu_limit,omitempty"`    // 0 restores the server default
+
+	// Applied when the container is recreated (see Workspace.PendingChanges)
+	Image        *string                 `json:"image,omitempty"`
+	Scripts      *[]domain.Script        `json:"scripts,omitempty"`
+	Env          *map[string]string      `json:"env,omitempty"`
+	Labels       *map[string]string      `json:"labels,omitempty"`
+	Repositories *[]domain.GitRepository `json:"repositories,omitempty"`
+}
+
+// UpdateWorkspace changes the settings of a workspace. The name, port labels
+// and resource limits take effect immediately; changes to the image, scripts,
+// environment, labels and repositories are recorded in PendingChanges until ApplyChanges
+// (or a reset) recreates the container.
+func (s *WorkspaceService) UpdateWorkspace(ctx context.Context, id string, req UpdateWorkspaceRequest) (*domain.Workspace, error) {
+	utils.Info("Updating workspace", "id", id)
+
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		return nil, fmt.Errorf("%w: name cannot be empty", ErrInvalidWorkspaceConfig)
+	}
+	if req.Image != nil && strings.TrimSpace(*req.Image) == "" {
+		return nil, fmt.Errorf("%w: image cannot be empty", ErrInvalidWorkspaceConfig)
+	}
+	if req.Ports != nil {
+		if err := validatePorts(*req.Ports); err != nil {
+			return nil, err
+		}
+	}
+
+	var limitsChanged bool
+	workspace, err := s.modifyWorkspace(ctx, id, func(ws *domain.Workspace) error {
+		config := ws.Config
+		var pending []string
+
+		if req.Image != nil && *req.Image != config.Image {
+			config.Image = *req.Image
+			pending = append(pending, domain.PendingImage)
+		}
+		if req.Scripts != nil && !slices.Equal(*req.Scripts, config.Scripts) {
+			config.Scripts = *req.Scripts
+			pending = append(pending, domain.PendingScripts)
+		}
+		if req.Env != nil && !maps.Equal(*req.Env, config.Env) {
+			config.Env = *req.Env
+			pending = append(pending, domain.PendingEnv)
+		}
+		if req.Labels != nil && !maps.Equal(*req.Labels, config.Labels) {
+			config.Labels = *req.Labels
+			pending = append(pending, domain.PendingLabels)
+		}
+		if req.Repositories != nil && !slices.Equal(*req.Repositories, config.Repositories) {
+			if err := s.checkCredentials(ws.Owner, *req.Repositories); err != nil {
+				return err
+			}
+			config.Repositories = *req.Repositories
+			pending = append(pending, domain.PendingRepositories)
+		}
+		limitsChanged = false
+		if req.MemoryLimit != nil && *req.MemoryLimit != config.MemoryLimit {
+			config.MemoryLimit = *req.MemoryLimit
+			limitsChanged = true
+		}
+		if req.CPULimit != nil && *req.CPULimit != config.CPULimit {
+			config.CPULimit = *req.CPULimit
+			limitsChanged = true
+		}
+
+		if err := validateWorkspaceConfig(config); err != nil {
+			return err
+		}
+
+		ws.Config = config
+		if req.Name != nil {
+			ws.Name = strings.TrimSpace(*req.Name)
+		}
+		if req.Ports != nil {
+			ws.Ports = *req.Ports
+		}
+		ws.PendingChanges = addPendingChanges(ws.PendingChanges, pending...)
+		return nil
+	})
+	if err != nil {
+		utils.Error("Failed to update workspace", "id", id, "error", err)
+		return nil, err
+	}
+
+	// Resource limits can be changed without recreating the container
+	if limitsChanged && workspace.ContainerID != "" {
+		if err := s.dockerSvc.UpdateContainerResources(ctx, workspace.ContainerID, workspace.Config.MemoryLimit, workspace.Config.CPULimit); err != nil {
+			utils.Warn("Failed to apply resource limits live, recreate the container to apply them", "id", id, "error", err)
+			updated, markErr := s.modifyWorkspace(context.Background(), id, func(ws *domain.Workspace) error {
+				ws.PendingChanges = addPendingChanges(ws.PendingChanges, domain.PendingLimits)
+				return nil
+			})
+			if markErr != nil {
+				utils.Error("Failed to record pending limits", "id", id, "error", markErr)
+			} else {
+				workspace = updated
+			}
+		}
+	}
+
+	utils.Info("Workspace updated successfully", "id", id, "pendingChanges", workspace.PendingChanges)
+	return workspace, nil
+}
+
+// ApplyChanges recreates the container of a workspace with pending changes,
+// like a reset: the container filesystem starts over from the image and
+// scripts. It reports false, doing nothing, when no changes are pending.
+func (s *WorkspaceService) ApplyChanges(ctx context.Context, id string) (bool, error) {
+	utils.Info("Applying workspace changes", "id", id)
+
+	workspace, err := s.GetWorkspace(id)
+	if err != nil {
+		return false, err
+	}
+	if version, ok := expectedVersion(ctx); ok && workspace.Version != version {
+		return false, fmt.Errorf("%w: current version is %d", ErrPreconditionFailed, workspace.Version)
+	}
+	if len(workspace.PendingChanges) == 0 {
+		utils.Info("No pending changes to apply", "id", id)
+		return false, nil
+	}
+
+	if err := s.ResetWorkspace(ctx, id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// validateWorkspaceConfig checks the settings of a workspace container
+func validateWorkspaceConfig(config domain.WorkspaceConfig) error {
+	for i, script := range config.Scripts {
+		if strings.TrimSpace(script.Name) == "" {
+			return fmt.Errorf("%w: script %d has no name", ErrInvalidWorkspaceConfig, i+1)
+		}
+		if script.Content == "" {
+			return fmt.Errorf("%w: script %q is empty", ErrInvalidWorkspaceConfig, script.Name)
+		}
+	}
+	for key, value := range config.Env {
+		if key == "" || strings.ContainsAny(key, "=\x00") || strings.ContainsRune(value, 0) {
+			return fmt.Errorf("%w: invalid environment variable %q", ErrInvalidWorkspaceConfig, key)
+		}
+	}
+	for key := range config.Labels {
+		if key == "" {
+			return fmt.Errorf("%w: label keys cannot be empty", ErrInvalidWorkspaceConfig)
+		}
+		if strings.HasPrefix(key, "vibox.") {
+			return fmt.Errorf("%w: label %q uses the reserved vibox. prefix", ErrInvalidWorkspaceConfig, key)
+		}
+	}
+	if err := validateRepositories(config.Repositories); err != nil {
+		return err
+	}
+	if config.MemoryLimit < 0 || (config.MemoryLimit > 0 && config.MemoryLimit < minMemoryLimit) {
+		return fmt.Errorf("%w: memory limit must be 0 (default) or at least %d bytes", ErrInvalidWorkspaceConfig, minMemoryLimit)
+	}
+	if config.CPULimit < 0 {
+		return fmt.Errorf("%w: CPU limit cannot be negative", ErrInvalidWorkspaceConfig)
+	}
+	return nil
+}
+
+// validatePorts checks that port label mappings are keyed by port numbers
+func validatePorts(ports map[string]string) error {
+	for port := range ports {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("%w: invalid port %q", ErrInvalidWorkspaceConfig, port)
+		}
+	}
+	return nil
+}
+
+// addPendingChanges adds changes to a list of pending changes, keeping it
+// sorted and free of duplicates
+func addPendingChanges(pending []string, changes ...string) []string {
+	for _, change := range changes {
+		if !slices.Contains(pending, change) {
+			pending = append(pending, change)
+		}
+	}
+	slices.Sort(pending)
+	return pending
+}
+
+// snapshotPendingChanges returns the pending changes a workspace keeps when
+// its container is recreated from its current configuration: none, except
+// that a container started from a snapshot does not use the image or scripts,
+// and keeps the repositories it already has
+func snapshotPendingChanges(ws *domain.Workspace) []string {
+	if ws.Snapshot == "" {
+		return nil
+	}
+	return slices.DeleteFunc(slices.Clone(ws.PendingChanges), func(change string) bool {
+		return change != domain.PendingImage && change != domain.PendingScripts && change != domain.PendingRepositories
+	})
+}
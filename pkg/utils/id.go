@@ -38,6 +38,46 @@ func GenerateSessionID() string {
 	return fmt.Sprintf("session-%s", shortID)
 }
 
+// GenerateExecID generates a unique ID for non-interactive exec runs
+func GenerateExecID() string {
+	id := uuid.New()
+	// Use first 8 characters for exec ID
+	shortID := strings.ReplaceAll(id.String(), "-", "")[:8]
+	return fmt.Sprintf("exec-%s", shortID)
+}
+
+// GenerateShareID generates a unique ID for port share links
+func GenerateShareID() string {
+	id := uuid.New()
+	// Use first 8 characters for share ID
+	shortID := strings.ReplaceAll(id.String(), "-", "")[:8]
+	return fmt.Sprintf("share-%s", shortID)
+}
+
+// GenerateUserID generates a unique ID for user accounts
+func GenerateUserID() string {
+	id := uuid.New()
+	// Use first 8 characters for user ID
+	shortID := strings.ReplaceAll(id.String(), "-", "")[:8]
+	return fmt.Sprintf("user-%s", shortID)
+}
+
+// GenerateAPIKeyID generates a unique ID for API keys
+func GenerateAPIKeyID() string {
+	id := uuid.New()
+	// Use first 8 characters for API key ID
+	shortID := strings.ReplaceAll(id.String(), "-", "")[:8]
+	return fmt.Sprintf("key-%s", shortID)
+}
+
+// GenerateGitCredentialID generates a unique ID for git credentials
+func GenerateGitCredentialID() string {
+	id := uuid.New()
+	// Use first 8 characters for git credential ID
+	shortID := strings.ReplaceAll(id.String(), "-", "")[:8]
+	return fmt.Sprintf("gitcred-%s", shortID)
+}
+
 // ShortID returns a shortened version of a Docker ID (first 12 characters)
 // This is safe even if the ID is shorter than 12 characters
 func ShortID(id string) string {
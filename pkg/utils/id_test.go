@@ -80,3 +80,18 @@ func TestGenerateSessionID(t *testing.T) {
 		t.Errorf("Expected session IDs to be unique, got same ID twice: %s", sid1)
 	}
 }
+
+func TestGenerateExecID(t *testing.T) {
+	id1 := GenerateExecID()
+	id2 := GenerateExecID()
+
+	if !strings.HasPrefix(id1, "exec-") {
+		t.Errorf("Expected exec ID to start with 'exec-', got '%s'", id1)
+	}
+	if len(id1) != 13 {
+		t.Errorf("Expected exec ID length to be 13, got %d", len(id1))
+	}
+	if id1 == id2 {
+		t.Errorf("Expected exec IDs to be unique, got same ID twice: %s", id1)
+	}
+}
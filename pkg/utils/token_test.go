@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateToken(t *testing.T) {
+	token1, err := GenerateToken("vbs_")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	token2, _ := GenerateToken("vbs_")
+
+	if !strings.HasPrefix(token1, "vbs_") {
+		t.Errorf("Expected token to start with 'vbs_', got '%s'", token1)
+	}
+	if len(token1) != len("vbs_")+43 {
+		t.Errorf("Expected token length %d, got %d", len("vbs_")+43, len(token1))
+	}
+	if token1 == token2 {
+		t.Errorf("Expected tokens to be unique, got same token twice: %s", token1)
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	hash := HashToken("secret")
+	if len(hash) != 64 {
+		t.Errorf("Expected 64 hex characters, got %d", len(hash))
+	}
+	if hash != HashToken("secret") {
+		t.Error("Expected hashing to be deterministic")
+	}
+	if hash == HashToken("other") {
+		t.Error("Expected different tokens to have different hashes")
+	}
+}